@@ -0,0 +1,240 @@
+//
+// # Subcommands of the lligne command line driver.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"lligne-cli/internal/lligne/code/compiling"
+	"lligne-cli/internal/lligne/code/diagnostics"
+	"lligne-cli/internal/lligne/code/formatting"
+	"lligne-cli/internal/lligne/code/parsing"
+	"lligne-cli/internal/lligne/code/scanning"
+	"lligne-cli/internal/lligne/code/scanning/tokenfilters"
+	"lligne-cli/internal/lligne/code/util"
+	"lligne-cli/internal/lligne/runtime/bytecode"
+	"os"
+	"strings"
+)
+
+//=====================================================================================================================
+
+// command holds the input and output streams shared by the subcommands.
+type command struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// check runs the compiler passes through type checking without evaluating the code.
+func (c *command) check(args []string) int {
+
+	flags, topLevel := c.newFlagSet("check")
+	if err := flags.Parse(args); err != nil {
+		return exitUsageError
+	}
+
+	fileName, sourceCode, exitCode := c.readSource("check", flags.Args())
+	if exitCode != exitSuccess {
+		return exitCode
+	}
+
+	checkCode := compiling.CheckExpression
+	if *topLevel {
+		checkCode = compiling.CheckTopLevel
+	}
+
+	_, diags := checkCode(sourceCode)
+
+	return c.reportDiagnostics(fileName, sourceCode, diags)
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// eval compiles and executes the code, then prints its result together with the result's type.
+func (c *command) eval(args []string) (exitCode int) {
+
+	flags, topLevel := c.newFlagSet("eval")
+	if err := flags.Parse(args); err != nil {
+		return exitUsageError
+	}
+
+	fileName, sourceCode, exitCode := c.readSource("eval", flags.Args())
+	if exitCode != exitSuccess {
+		return exitCode
+	}
+
+	compileCode := compiling.CompileExpression
+	if *topLevel {
+		compileCode = compiling.CompileTopLevel
+	}
+
+	outcome, diags := compileCode(sourceCode)
+	if exitCode = c.reportDiagnostics(fileName, sourceCode, diags); outcome == nil {
+		return exitCode
+	}
+
+	defer c.recoverRuntimeError(fileName, &exitCode)
+
+	stringPool := outcome.StringConstants.Clone()
+	typePool := outcome.TypeConstants.Clone()
+
+	interpreter := bytecode.NewInterpreter(outcome.CodeBlock, stringPool, typePool)
+	machine := bytecode.NewMachine()
+
+	interpreter.Execute(machine)
+
+	rf := &resultFormatter{
+		arrayPool:       interpreter.GetArrayPool(),
+		identifierNames: outcome.IdentifierNames,
+		optionalPool:    interpreter.GetOptionalPool(),
+		rangePool:       interpreter.GetRangePool(),
+		recordPool:      interpreter.GetRecordPool(),
+		stringPool:      stringPool,
+		typePool:        typePool,
+		unionPool:       interpreter.GetUnionPool(),
+	}
+
+	resultTypeIndex := outcome.Model.GetTypeIndex()
+	result := machine.Stack[machine.Top]
+
+	fmt.Fprintf(c.stdout, "%s: %s\n", rf.formatValue(resultTypeIndex, result), rf.formatType(resultTypeIndex))
+
+	return exitSuccess
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// fmt prints the code in canonical format or, with -w, writes it back to its file.
+func (c *command) fmt(args []string) (exitCode int) {
+
+	flags, topLevel := c.newFlagSet("fmt")
+	write := flags.Bool("w", false, "write the result to the source file instead of standard output")
+	if err := flags.Parse(args); err != nil {
+		return exitUsageError
+	}
+
+	fileName, sourceCode, exitCode := c.readSource("fmt", flags.Args())
+	if exitCode != exitSuccess {
+		return exitCode
+	}
+
+	if *write && fileName == "-" {
+		fmt.Fprintln(c.stderr, "lligne fmt: cannot use -w with standard input")
+		return exitUsageError
+	}
+
+	scanOutcome := scanning.Scan(sourceCode)
+	scanOutcome = tokenfilters.ProcessLeadingTrailingDocumentation(scanOutcome)
+
+	parse, format := parsing.ParseExpression, formatting.FormatCode
+	if *topLevel {
+		parse, format = parsing.ParseTopLevel, formatting.FormatTopLevel
+	}
+
+	parseOutcome := parse(scanOutcome)
+	if diagnostics.HasErrors(parseOutcome.Diagnostics) {
+		return c.reportDiagnostics(fileName, sourceCode, parseOutcome.Diagnostics)
+	}
+
+	formattedCode := format(parseOutcome)
+	if formattedCode != "" && !strings.HasSuffix(formattedCode, "\n") {
+		formattedCode += "\n"
+	}
+
+	if *write {
+		if err := os.WriteFile(fileName, []byte(formattedCode), 0644); err != nil {
+			fmt.Fprintf(c.stderr, "lligne fmt: %v\n", err)
+			return exitUsageError
+		}
+		return exitSuccess
+	}
+
+	fmt.Fprint(c.stdout, formattedCode)
+
+	return exitSuccess
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// newFlagSet creates the flags for a subcommand, starting with the -t flag shared by all of them.
+func (c *command) newFlagSet(commandName string) (flags *flag.FlagSet, topLevel *bool) {
+	flags = flag.NewFlagSet(commandName, flag.ContinueOnError)
+	flags.SetOutput(c.stderr)
+	topLevel = flags.Bool("t", false, "treat the code as the top level items of a file instead of one expression")
+	return flags, topLevel
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// readSource reads the source code named by the single file name argument, "-" meaning standard input.
+func (c *command) readSource(commandName string, args []string) (fileName string, sourceCode string, exitCode int) {
+
+	if len(args) != 1 {
+		fmt.Fprintf(c.stderr, "lligne %s: expected exactly one file name (or \"-\" for standard input)\n", commandName)
+		return "", "", exitUsageError
+	}
+
+	fileName = args[0]
+
+	var bytes []byte
+	var err error
+	if fileName == "-" {
+		bytes, err = io.ReadAll(c.stdin)
+	} else {
+		bytes, err = os.ReadFile(fileName)
+	}
+
+	if err != nil {
+		fmt.Fprintf(c.stderr, "lligne %s: %v\n", commandName, err)
+		return "", "", exitUsageError
+	}
+
+	return fileName, string(bytes), exitSuccess
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// recoverRuntimeError reports a failure while executing byte code as an error in the source code.
+func (c *command) recoverRuntimeError(fileName string, exitCode *int) {
+	if r := recover(); r != nil {
+		fmt.Fprintf(c.stderr, "%s: runtime error: %v\n", fileName, r)
+		*exitCode = exitSourceError
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// reportDiagnostics writes the given diagnostics to standard error and returns the resulting exit code.
+func (c *command) reportDiagnostics(fileName string, sourceCode string, diags []*diagnostics.Diagnostic) int {
+
+	if len(diags) > 0 {
+		newLineOffsets := scanning.Scan(sourceCode).NewLineOffsets
+		sourceFile := util.NewSourceFile(fileName, sourceCode, newLineOffsets)
+
+		for _, diagnostic := range diags {
+			fmt.Fprint(c.stderr, diagnostic.Render(sourceFile))
+		}
+	}
+
+	if diagnostics.HasErrors(diags) {
+		return exitSourceError
+	}
+
+	return exitSuccess
+
+}
+
+//=====================================================================================================================
@@ -1,7 +1,82 @@
+//
+// # Command line driver for the Lligne compiler and interpreter.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+//=====================================================================================================================
+
+// Exit codes of the lligne command.
+const (
+	exitSuccess     = 0
+	exitSourceError = 1
+	exitUsageError  = 2
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+const usage = `Usage: lligne <command> [arguments]
+
+Commands:
+  eval [-t] <file|->         Evaluate Lligne code and print its typed result.
+  check [-t] <file|->        Check Lligne code for errors without evaluating it.
+  fmt [-t] [-w] <file|->     Print Lligne code in canonical format (-w rewrites the file instead).
+
+The code is one expression unless -t is given, in which case it is the top level of a file: a
+sequence of "name = value" or "name: Type = value" items separated by commas, semicolons or line
+breaks. Use "-" as the file name to read from standard input.
+`
+
+//=====================================================================================================================
 
 func main() {
-	fmt.Println("Hello, world")
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 }
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// run executes the command given by args and returns the process exit code.
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsageError
+	}
+
+	cmd := &command{
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}
+
+	switch args[0] {
+
+	case "check":
+		return cmd.check(args[1:])
+	case "eval":
+		return cmd.eval(args[1:])
+	case "fmt":
+		return cmd.fmt(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitSuccess
+
+	default:
+		fmt.Fprintf(stderr, "lligne: unknown command %q\n\n", args[0])
+		fmt.Fprint(stderr, usage)
+		return exitUsageError
+
+	}
+
+}
+
+//=====================================================================================================================
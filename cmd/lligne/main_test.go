@@ -0,0 +1,247 @@
+//
+// # Tests of the lligne command line driver
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestLligneCommand(t *testing.T) {
+
+	checkErrors := func(args []string, input string, expectedErrors string) {
+		stdout := bytes.Buffer{}
+		stderr := bytes.Buffer{}
+
+		exitCode := run(args, strings.NewReader(input), &stdout, &stderr)
+
+		assert.Equal(t, exitSourceError, exitCode, "For input: "+input)
+		assert.Equal(t, expectedErrors, stderr.String(), "For input: "+input)
+	}
+
+	check := func(args []string, input string, expectedExitCode int, expectedOutput string) {
+		stdout := bytes.Buffer{}
+		stderr := bytes.Buffer{}
+
+		exitCode := run(args, strings.NewReader(input), &stdout, &stderr)
+
+		assert.Equal(t, expectedExitCode, exitCode, "For input: "+input+"\n"+stderr.String())
+		assert.Equal(t, expectedOutput, stdout.String(), "For input: "+input)
+	}
+
+	t.Run("eval", func(t *testing.T) {
+		check([]string{"eval", "-"}, "1 + 2", exitSuccess, "3: Int64\n")
+		check([]string{"eval", "-"}, "2.5 * 2.0", exitSuccess, "5.0: Float64\n")
+		check([]string{"eval", "-"}, "'a' + \"b\"", exitSuccess, "\"ab\": String\n")
+		check([]string{"eval", "-"}, "'a\\tb' + \"\\u{7}\"", exitSuccess, "\"a\\tb\\u{7}\": String\n")
+		check([]string{"eval", "-"}, "3 > 2", exitSuccess, "true: Bool\n")
+		check([]string{"eval", "-"}, "`one\n  ` two\n", exitSuccess, "\"one\\n two\": String\n")
+		check([]string{"eval", "-"}, "Int64", exitSuccess, "Int64: Type\n")
+		check([]string{"eval", "-"}, "{x = 1, y = {z = 'q'}}", exitSuccess,
+			"{x = 1, y = {z = \"q\"}}: {x: Int64, y: {z: String}}\n")
+		check([]string{"eval", "-"}, "{x = 3, xpowers = {xsquared = x * x}}", exitSuccess,
+			"{x = 3, xpowers = {xsquared = 9}}: {x: Int64, xpowers: {xsquared: Int64}}\n")
+		check([]string{"eval", "-"}, "{env: 'dev' | 'prod' = 'dev'}", exitSuccess,
+			"{env = \"dev\"}: {env: \"dev\" | \"prod\"}\n")
+		check([]string{"eval", "-"}, "String | Int64 | String", exitSuccess, "Int64 | String: Type\n")
+		check([]string{"eval", "-"}, "{x: Int64 | String = 5, y: Int64 | String = 'a', z = x == 5}", exitSuccess,
+			"{x = 5, y = \"a\", z = true}: {x: Int64 | String, y: Int64 | String, z: Bool}\n")
+		check([]string{"eval", "-"}, "{x: (Int64 | String)? = 'a', y = [x ?: 0, 1]}", exitSuccess,
+			"{x = \"a\", y = [\"a\", 1]}: {x: (Int64 | String)?, y: (Int64 | String)[]}\n")
+		check([]string{"eval", "-"}, "{x: Int64?, y: Int64? = 2}", exitSuccess,
+			"{x ?: none, y = 2}: {x: Int64?, y: Int64?}\n")
+		check([]string{"eval", "-"}, "{r: {z = 1}? = {z = 2}}.r", exitSuccess, "{z = 2}: {z: Int64}?\n")
+		check([]string{"eval", "-"}, "{r: {z = 1}?}.r.z", exitSuccess, "none: Int64?\n")
+		check([]string{"eval", "-"}, "["+strings.Repeat("1, ", 1999)+"1].length", exitSuccess, "2000: Int64\n")
+		check([]string{"eval", "-"}, "{f: (n: Int64) -> Int64 = 0 when n == 0 | 1 + f(n - 1), x = f(5000)}.x",
+			exitSuccess, "5000: Int64\n")
+		check([]string{"eval", "-"}, "{x: {a: Int64}? = {a = 1}, y = x.a ?: 0}", exitSuccess,
+			"{x = {a = 1}, y = 1}: {x: {a: Int64}?, y: Int64}\n")
+		check([]string{"eval", "-"}, "{x: {a: Int64}[] = [{a = 1}], y = x[0].a}", exitSuccess,
+			"{x = [{a = 1}], y = 1}: {x: {a: Int64}[], y: Int64}\n")
+		check([]string{"eval", "-"}, "{port: Int64 && val < 1024 = 80}", exitSuccess,
+			"{port = 80}: {port: Int64 && val < 1024}\n")
+		check([]string{"eval", "-"}, "[1, 2, 3]", exitSuccess, "[1, 2, 3]: Int64[]\n")
+		check([]string{"eval", "-"}, "[1] is Int64[]", exitSuccess, "true: Bool\n")
+		check([]string{"eval", "-"}, "{f: (n: Int64) -> Int64 = n, a = [f], b: ((n: Int64) -> Int64)? = f}", exitSuccess,
+			"{f = <function>, a = [<function>], b = <function>}: "+
+				"{f: (Int64) -> Int64, a: ((Int64) -> Int64)[], b: ((Int64) -> Int64)?}\n")
+		check([]string{"eval", "-"}, "{a: Int64[] = [], b: String?[] = ['x', none]}", exitSuccess,
+			"{a = [], b = [\"x\", none]}: {a: Int64[], b: String?[]}\n")
+		check([]string{"eval", "-"}, "{a = [[1], [2, 3]], n = a[1].length}", exitSuccess,
+			"{a = [[1], [2, 3]], n = 2}: {a: Int64[][], n: Int64}\n")
+		check([]string{"eval", "-"}, "{add: (a: Int64, b: Int64) -> Int64 = a + b, x = add(1, 2)}", exitSuccess,
+			"{add = <function>, x = 3}: {add: (Int64, Int64) -> Int64, x: Int64}\n")
+		check([]string{"eval", "-"}, "{env = 'dev', level = 'debug' when env == 'dev' | 'info'}", exitSuccess,
+			"{env = \"dev\", level = \"debug\"}: {env: String, level: String}\n")
+		check([]string{"eval", "-"}, "{x = 0, y = x != 0 and 10 / x > 1}", exitSuccess,
+			"{x = 0, y = false}: {x: Int64, y: Bool}\n")
+		check([]string{"eval", "-"}, "{r = 1..10, x = 5 in r}", exitSuccess,
+			"{r = 1..10, x = true}: {r: Int64..Int64, x: Bool}\n")
+		check([]string{"eval", "-"}, "{port: 1..65535 = 80}", exitSuccess,
+			"{port = 80}: {port: Int64 && val in 1..65535}\n")
+		check([]string{"eval", "-"}, "{n = 1234567, s = n fmt '%,d'}", exitSuccess,
+			"{n = 1234567, s = \"1,234,567\"}: {n: Int64, s: String}\n")
+		check([]string{"eval", "-"}, "{a = {x = 1, y = 2}, b = {x = 3, y = 4}, c = a + b}", exitSuccess,
+			"{a = {x = 1, y = 2}, b = {x = 3, y = 4}, c = {x = 4, y = 6}}: "+
+				"{a: {x: Int64, y: Int64}, b: {x: Int64, y: Int64}, c: {x: Int64, y: Int64}}\n")
+	})
+
+	t.Run("top level", func(t *testing.T) {
+		check([]string{"eval", "-t", "-"}, "x = 1\ny: String = 'two'\n", exitSuccess,
+			"{x = 1, y = \"two\"}: {x: Int64, y: String}\n")
+		check([]string{"eval", "-t", "-"}, "base = {host: String, port ?: 80}\ndev = base & {host = 'dev'}\n", exitSuccess,
+			"{base = {host: String, port ?: 80}, dev = {host = \"dev\", port ?: 80}}: "+
+				"{base: {host: String, port: Int64}, dev: {host: String, port: Int64}}\n")
+		check([]string{"eval", "-t", "-"}, "a: {x:1, y:2}\nb: {x:3, y:4}\nc = a + b\n", exitSuccess,
+			"{a = {x = 1, y = 2}, b = {x = 3, y = 4}, c = {x = 4, y = 6}}: "+
+				"{a: {x: Int64, y: Int64}, b: {x: Int64, y: Int64}, c: {x: Int64, y: Int64}}\n")
+		check([]string{"eval", "-t", "-"}, "a = `x\nb = 2", exitSuccess,
+			"{a = \"x\", b = 2}: {a: String, b: Int64}\n")
+		check([]string{"check", "-t", "-"}, "x = 1; y = 2", exitSuccess, "")
+		check([]string{"check", "-t", "-"}, "host: String\nport: Int64 ?: 8080\n", exitSuccess, "")
+		check([]string{"check", "-t", "-"}, "x: Int64 = 'one'", exitSourceError, "")
+		check([]string{"fmt", "-t", "-"}, "x=1,y:Int64=2", exitSuccess, "x = 1\ny: Int64 = 2\n")
+		check([]string{"eval", "-"}, "x = 1\ny = 2", exitSourceError, "")
+	})
+
+	t.Run("check", func(t *testing.T) {
+		check([]string{"check", "-"}, "1 + 2 == 3", exitSuccess, "")
+	})
+
+	t.Run("fmt", func(t *testing.T) {
+		check([]string{"fmt", "-"}, "{x=1,   y=  2}", exitSuccess, "{x = 1, y = 2}\n")
+		check([]string{"fmt", "-"}, "q   // trailing\n", exitSuccess, "q // trailing\n")
+	})
+
+	t.Run("errors in source code", func(t *testing.T) {
+		check([]string{"eval", "-"}, "1 +", exitSourceError, "")
+		check([]string{"check", "-"}, "(1", exitSourceError, "")
+		check([]string{"check", "-"}, "x + 1", exitSourceError, "")
+		check([]string{"fmt", "-"}, "{x = }", exitSourceError, "")
+	})
+
+	t.Run("diagnostic locations", func(t *testing.T) {
+		checkErrors([]string{"check", "-"}, "{\n  x = 1,\n  y = 2 + q\n}",
+			"-:3:11: error[E301]: Undefined name 'q'\n"+
+				"3 |   y = 2 + q\n"+
+				"  |           ^\n",
+		)
+		checkErrors([]string{"check", "-"}, "{\n  x = (1 + ),\n  y = [2 3]\n}",
+			"-:2:12: error[E101]: Unexpected ')'\n"+
+				"2 |   x = (1 + ),\n"+
+				"  |            ^\n"+
+				"-:3:10: error[E102]: Expected ',' or ']' but found '3'\n"+
+				"3 |   y = [2 3]\n"+
+				"  |          ^\n",
+		)
+		checkErrors([]string{"check", "-t", "-"}, "total = 1\nsub = {t = totl}\n",
+			"-:2:12: error[E301]: Undefined name 'totl'; did you mean 'total'?\n"+
+				"2 | sub = {t = totl}\n"+
+				"  |            ^^^^\n",
+		)
+		checkErrors([]string{"eval", "-t", "-"}, "host: String\nport: Int64 ?: 8080\nuser: String\n",
+			"-:1:1: error[E403]: Missing value for required field 'host'\n"+
+				"1 | host: String\n"+
+				"  | ^^^^^^^^^^^^\n"+
+				"-:3:1: error[E403]: Missing value for required field 'user'\n"+
+				"3 | user: String\n"+
+				"  | ^^^^^^^^^^^^\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{port: Int64 && val < 1024 = 8080}",
+			"-:1:30: error[E405]: Value 8080 does not satisfy the constraint 'Int64 && val < 1024'\n"+
+				"1 | {port: Int64 && val < 1024 = 8080}\n"+
+				"  |                              ^^^^\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{n: Int64 && val > 0 = 0 - 1}",
+			"-:1:24: error[E405]: Value -1 does not satisfy the constraint 'Int64 && val > 0'\n"+
+				"1 | {n: Int64 && val > 0 = 0 - 1}\n"+
+				"  |                        ^^^^^\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{base = {a = 1}, over = base & {a = 1 + 1}}",
+			"-: runtime error: Conflicting values for field 'a'\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{base = 1000, port: Int64 && val < 1024 = base + 80}",
+			"-: runtime error: Value 1080 of field 'port' does not satisfy the constraint 'Int64 && val < 1024'\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{base = 60000, port: 1..65535 = base + 8080}",
+			"-: runtime error: Value 68080 of field 'port' does not satisfy the constraint 'Int64 && val in 1..65535'\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{env = 'x', e: String && val != \"x\" = env}",
+			"-: runtime error: Value \"x\" of field 'e' does not satisfy the constraint 'String && val != \"x\"'\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{f: (n: Int64 && val > 0) -> Int64 = n, a = 0 - 3, y = f(a)}",
+			"-: runtime error: Value -3 does not satisfy the constraint 'Int64 && val > 0'\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{a = [1, 2, 3], i = 3, x = a[i]}",
+			"-: runtime error: Array index 3 out of bounds for length 3\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{f: (n: Int64) -> Int64 = f(n + 1), x = f(0)}",
+			"-: runtime error: Function calls nested more than 10000 deep\n",
+		)
+		checkErrors([]string{"eval", "-"},
+			"{f: (a: Int64, b: Int64, c: Int64, d: Int64, e: Int64, g: Int64, h: Int64, i: Int64, j: Int64, k: Int64) "+
+				"-> Int64 = f(a, b, c, d, e, g, h, i, j, k), x = f(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)}",
+			"-: runtime error: Evaluation needs more than 100000 stack slots\n",
+		)
+		checkErrors([]string{"eval", "-"}, strings.Repeat("{a = ", 10001)+"1"+strings.Repeat("}", 10001),
+			"-: runtime error: Records nested more than 10000 deep\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{n = 0, sign = -1 when n < 0 | 1 when n > 0}",
+			"-: runtime error: None of the 'when' guards is true\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{x = 0, y = 10 / x > 1}",
+			"-: runtime error: Integer division by zero\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{a = {x = 1}, b = {x = 0}, c = a / b}",
+			"-: runtime error: Integer division by zero\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{p = 'a(b', m = 'x' =~ p}",
+			"-: runtime error: Invalid pattern 'a(b': missing closing )\n",
+		)
+		checkErrors([]string{"eval", "-"}, "'x' =~ 'a(b'",
+			"-:1:8: error[E407]: Invalid pattern 'a(b': missing closing )\n"+
+				"1 | 'x' =~ 'a(b'\n"+
+				"  |        ^^^^^\n",
+		)
+		checkErrors([]string{"eval", "-"}, "{spec = '%5s', s = 1.5 fmt spec}",
+			"-: runtime error: Invalid format '%5s': verb 's' does not apply to Float64; expected one of 'f', 'e', 'g'\n",
+		)
+		checkErrors([]string{"eval", "-"}, "1.5 fmt '%5s'",
+			"-:1:9: error[E408]: Invalid format '%5s': verb 's' does not apply to Float64; expected one of 'f', 'e', 'g'\n"+
+				"1 | 1.5 fmt '%5s'\n"+
+				"  |         ^^^^^\n",
+		)
+		checkErrors([]string{"check", "-"}, "{\n  x = 1 + 'a'\n}",
+			"-:2:7: error[E402]: Cannot add Int64 and String\n"+
+				"2 |   x = 1 + 'a'\n"+
+				"  |       ^^^^^^^\n"+
+				"-:2:7: note: Left operand has type Int64\n"+
+				"2 |   x = 1 + 'a'\n"+
+				"  |       ^\n"+
+				"-:2:11: note: Right operand has type String\n"+
+				"2 |   x = 1 + 'a'\n"+
+				"  |           ^^^\n",
+		)
+	})
+
+	t.Run("usage errors", func(t *testing.T) {
+		check([]string{}, "", exitUsageError, "")
+		check([]string{"bogus"}, "", exitUsageError, "")
+		check([]string{"eval"}, "", exitUsageError, "")
+		check([]string{"eval", "no-such-file.lligne"}, "", exitUsageError, "")
+		check([]string{"fmt", "-w", "-"}, "x", exitUsageError, "")
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,183 @@
+//
+// # Formatting of evaluation results as Lligne code.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package main
+
+import (
+	"fmt"
+	"lligne-cli/internal/lligne/code/scanning"
+	"lligne-cli/internal/lligne/runtime/arrays"
+	"lligne-cli/internal/lligne/runtime/optionals"
+	"lligne-cli/internal/lligne/runtime/pools"
+	"lligne-cli/internal/lligne/runtime/ranges"
+	"lligne-cli/internal/lligne/runtime/records"
+	"lligne-cli/internal/lligne/runtime/types"
+	"lligne-cli/internal/lligne/runtime/unions"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//=====================================================================================================================
+
+// resultFormatter converts runtime values and their types back into Lligne source code.
+type resultFormatter struct {
+	arrayPool       *arrays.ArrayPool
+	identifierNames *pools.NameConstantPool
+	optionalPool    *optionals.OptionalPool
+	rangePool       *ranges.RangePool
+	recordPool      *records.RecordPool
+	stringPool      *pools.StringPool
+	typePool        *types.TypePool
+	unionPool       *unions.UnionPool
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// formatSuffixedType returns the Lligne source code for the type with given index followed by a "[]" or "?" suffix,
+// in parentheses when it is a constraint, a function, or a union, e.g. ((Int64) -> Int64)[].
+func (rf *resultFormatter) formatSuffixedType(typeIndex types.TypeIndex) string {
+	switch rf.typePool.Get(typeIndex).(type) {
+	case *types.ConstraintType, *types.FunctionType, *types.UnionType:
+		return "(" + rf.formatType(typeIndex) + ")"
+	}
+	return rf.formatType(typeIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// formatType returns the Lligne source code for the type with given index.
+func (rf *resultFormatter) formatType(typeIndex types.TypeIndex) string {
+
+	switch typ := rf.typePool.Get(typeIndex).(type) {
+
+	case *types.ArrayType:
+		return rf.formatSuffixedType(typ.ElementTypeIndex) + "[]"
+
+	case *types.OptionalType:
+		return rf.formatSuffixedType(typ.ValueTypeIndex) + "?"
+
+	case *types.RecordType:
+		sb := strings.Builder{}
+		sb.WriteString("{")
+		for i, fieldNameIndex := range typ.FieldNameIndexes {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(rf.identifierNames.Get(fieldNameIndex))
+			sb.WriteString(": ")
+			sb.WriteString(rf.formatType(typ.FieldTypeIndexes[i]))
+		}
+		sb.WriteString("}")
+		return sb.String()
+
+	default:
+		return typ.Name()
+
+	}
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// formatValue returns the Lligne source code for a value of the given type.
+func (rf *resultFormatter) formatValue(typeIndex types.TypeIndex, value uint64) string {
+
+	switch typ := rf.typePool.Get(typeIndex).(type) {
+
+	case *types.ArrayType:
+		array := rf.arrayPool.Get(value)
+		elements := make([]string, len(array.Elements))
+		for i, element := range array.Elements {
+			elements[i] = rf.formatValue(typ.ElementTypeIndex, element)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+
+	case *types.BoolType:
+		return strconv.FormatBool(value != 0)
+
+	case *types.ConstraintType:
+		return rf.formatValue(typ.ConstrainedTypeIndex, value)
+
+	case *types.Float64Type:
+		result := strconv.FormatFloat(math.Float64frombits(value), 'g', -1, 64)
+		if !strings.ContainsAny(result, ".eEIN") {
+			result += ".0"
+		}
+		return result
+
+	case *types.FunctionType:
+		// A function has no source code of its own apart from the field defining it
+		return "<function>"
+
+	case *types.Int64Type:
+		return strconv.FormatInt(int64(value), 10)
+
+	case *types.LiteralType:
+		return rf.formatValue(typ.BaseTypeIndex, value)
+
+	case *types.NoneType:
+		return "none"
+
+	case *types.OptionalType:
+		if value == optionals.NoneValue {
+			return "none"
+		}
+		return rf.formatValue(typ.ValueTypeIndex, rf.optionalPool.Get(value))
+
+	case *types.RangeType:
+		r := rf.rangePool.Get(value)
+		return rf.formatValue(typ.BoundTypeIndex, r.Low) + ".." + rf.formatValue(typ.BoundTypeIndex, r.High)
+
+	case *types.RecordType:
+		record := rf.recordPool.Get(value)
+		sb := strings.Builder{}
+		sb.WriteString("{")
+		for i, fieldNameIndex := range typ.FieldNameIndexes {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(rf.identifierNames.Get(fieldNameIndex))
+			switch typ.FieldPresence(i) {
+			case types.RecordFieldPresenceValue:
+				sb.WriteString(" = ")
+				sb.WriteString(rf.formatValue(typ.FieldTypeIndexes[i], record.FieldValues[i]))
+			case types.RecordFieldPresenceDefault:
+				sb.WriteString(" ?: ")
+				sb.WriteString(rf.formatValue(typ.FieldTypeIndexes[i], record.FieldValues[i]))
+			case types.RecordFieldPresenceRequired:
+				sb.WriteString(": ")
+				sb.WriteString(rf.formatType(typ.FieldTypeIndexes[i]))
+			}
+		}
+		sb.WriteString("}")
+		return sb.String()
+
+	case *types.StringType:
+		return scanning.EncodeStringLiteral(rf.stringPool.Get(pools.StringIndex(value)), '"')
+
+	case *types.TypeType:
+		return rf.formatType(types.TypeIndex(value))
+
+	case *types.UnionType:
+		if rf.typePool.IsTaggedUnion(typeIndex) {
+			unionValue := rf.unionPool.Get(value)
+			return rf.formatValue(unionValue.TypeIndex, unionValue.Value)
+		}
+		return rf.formatValue(typ.BaseTypeIndex, value)
+
+	case *types.UnitType:
+		return "()"
+
+	default:
+		panic(fmt.Sprintf("Missing case in formatValue: %T\n", typ))
+
+	}
+
+}
+
+//=====================================================================================================================
@@ -7,6 +7,7 @@ package nameresolution
 
 import (
 	"lligne-cli/internal/lligne/code/util"
+	"lligne-cli/internal/lligne/runtime/collations"
 	"lligne-cli/internal/lligne/runtime/pools"
 )
 
@@ -34,7 +35,7 @@ func (e *AdditionExpr) isStructuredExpression()                {}
 
 //=====================================================================================================================
 
-// ArrayLiteralExpr represents a parenthesized expression or comma-separated sequence of expressions.
+// ArrayLiteralExpr represents an array literal.
 type ArrayLiteralExpr struct {
 	SourcePosition util.SourcePos
 	Elements       []IExpression
@@ -46,6 +47,18 @@ func (e *ArrayLiteralExpr) isStructuredExpression()                {}
 
 //=====================================================================================================================
 
+// ArrayTypeExpr represents an array type using "[]" suffix.
+type ArrayTypeExpr struct {
+	SourcePosition util.SourcePos
+	ElementType    IExpression
+}
+
+func (e *ArrayTypeExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *ArrayTypeExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *ArrayTypeExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
 // BooleanLiteralExpr represents a single boolean literal.
 type BooleanLiteralExpr struct {
 	SourcePosition util.SourcePos
@@ -69,6 +82,58 @@ func (e *BuiltInTypeExpr) isStructuredExpression()                {}
 
 //=====================================================================================================================
 
+// CollatedEqualsExpr represents an equality comparison with modifiers ("==[Aa]==") operation.
+type CollatedEqualsExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+	Collation      collations.Collation
+}
+
+func (e *CollatedEqualsExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *CollatedEqualsExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *CollatedEqualsExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
+// CollatedNotEqualsExpr represents an inequality comparison with modifiers ("=![Aa]!=") operation.
+type CollatedNotEqualsExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+	Collation      collations.Collation
+}
+
+func (e *CollatedNotEqualsExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *CollatedNotEqualsExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *CollatedNotEqualsExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
+// ConstrainedValueExpr represents "val", the value being checked by the predicate of a constraint.
+type ConstrainedValueExpr struct {
+	SourcePosition util.SourcePos
+}
+
+func (e *ConstrainedValueExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *ConstrainedValueExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *ConstrainedValueExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
+// ConstraintExpr represents a type restricted by a predicate, e.g. "Int64 && val <= 100".
+type ConstraintExpr struct {
+	SourcePosition  util.SourcePos
+	ConstrainedType IExpression
+	Predicate       IExpression
+}
+
+func (e *ConstraintExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *ConstraintExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *ConstraintExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
 // DivisionExpr represents a division operation.
 type DivisionExpr struct {
 	SourcePosition util.SourcePos
@@ -120,11 +185,24 @@ func (e *Float64LiteralExpr) isStructuredExpression()                {}
 
 //=====================================================================================================================
 
-// FunctionCallExpr represents a function call (a function name followed by a parenthesized expression).
+// FormatExpr represents a value formatting ("fmt") operation.
+type FormatExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *FormatExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *FormatExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *FormatExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
+// FunctionCallExpr represents a function call (a function name followed by parenthesized arguments).
 type FunctionCallExpr struct {
 	SourcePosition    util.SourcePos
 	FunctionReference IExpression
-	Argument          IExpression
+	Arguments         []IExpression
 }
 
 func (e *FunctionCallExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
@@ -133,6 +211,46 @@ func (e *FunctionCallExpr) isStructuredExpression()                {}
 
 //=====================================================================================================================
 
+// FunctionExpr represents a function with named parameters and a body that can refer to them.
+type FunctionExpr struct {
+	SourcePosition util.SourcePos
+	Parameters     []*FunctionParameterExpr
+	ResultType     IExpression
+	Body           IExpression
+}
+
+func (e *FunctionExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *FunctionExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *FunctionExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
+// FunctionParameterExpr represents a named function parameter like 'n: Int64'.
+type FunctionParameterExpr struct {
+	SourcePosition     util.SourcePos
+	ParameterNameIndex pools.NameIndex
+	ParameterType      IExpression
+}
+
+func (e *FunctionParameterExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *FunctionParameterExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *FunctionParameterExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
+// FunctionTypeExpr represents a function type like '(n: Int64) -> Int64'.
+type FunctionTypeExpr struct {
+	SourcePosition util.SourcePos
+	Parameters     []*FunctionParameterExpr
+	ResultType     IExpression
+}
+
+func (e *FunctionTypeExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *FunctionTypeExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *FunctionTypeExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
 // GreaterThanExpr represents a greater than operation.
 type GreaterThanExpr struct {
 	SourcePosition util.SourcePos
@@ -172,6 +290,32 @@ func (e *IdentifierExpr) isStructuredExpression()                {}
 
 //=====================================================================================================================
 
+// InExpr represents a set membership "in" operation.
+type InExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *InExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *InExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *InExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
+// IndexExpr represents an array indexing ("a[i]") operation.
+type IndexExpr struct {
+	SourcePosition util.SourcePos
+	Array          IExpression
+	Index          IExpression
+}
+
+func (e *IndexExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *IndexExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *IndexExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
 // Int64LiteralExpr represents a single 64-bit integer literal.
 type Int64LiteralExpr struct {
 	SourcePosition util.SourcePos
@@ -184,6 +328,19 @@ func (e *Int64LiteralExpr) isStructuredExpression()                {}
 
 //=====================================================================================================================
 
+// IntersectExpr represents a type/value intersection "&" or "&&" operation.
+type IntersectExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *IntersectExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *IntersectExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *IntersectExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
 // IsExpr represents an "is" test.
 type IsExpr struct {
 	SourcePosition util.SourcePos
@@ -275,6 +432,19 @@ func (e *LogicalOrExpr) isStructuredExpression()                {}
 
 //=====================================================================================================================
 
+// MatchExpr represents a pattern match "=~" operation.
+type MatchExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *MatchExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *MatchExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *MatchExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
 // MultiplicationExpr represents a multiplication operation.
 type MultiplicationExpr struct {
 	SourcePosition util.SourcePos
@@ -300,6 +470,17 @@ func (e *NegationOperationExpr) isStructuredExpression()                {}
 
 //=====================================================================================================================
 
+// NoneExpr represents the absent value of an optional type ("none").
+type NoneExpr struct {
+	SourcePosition util.SourcePos
+}
+
+func (e *NoneExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *NoneExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *NoneExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
 // NotEqualsExpr represents a equals operation.
 type NotEqualsExpr struct {
 	SourcePosition util.SourcePos
@@ -313,7 +494,33 @@ func (e *NotEqualsExpr) isStructuredExpression()                {}
 
 //=====================================================================================================================
 
-// OptionalExpr represents a parenthesized expression or comma-separated sequence of expressions.
+// NotMatchExpr represents a pattern nonmatch ("!~") operation.
+type NotMatchExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *NotMatchExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *NotMatchExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *NotMatchExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
+// OptionalDefaultExpr represents the value of an optional expression or else a default value ("?:") when it has none.
+type OptionalDefaultExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *OptionalDefaultExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *OptionalDefaultExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *OptionalDefaultExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
+// OptionalExpr represents an optional type ("?") operation.
 type OptionalExpr struct {
 	SourcePosition util.SourcePos
 	Operand        IExpression
@@ -337,11 +544,25 @@ func (e *ParenthesizedExpr) isStructuredExpression()                {}
 
 //=====================================================================================================================
 
+// RangeExpr represents a range ("..") operation.
+type RangeExpr struct {
+	SourcePosition util.SourcePos
+	First          IExpression
+	Last           IExpression
+}
+
+func (e *RangeExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *RangeExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *RangeExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
 // RecordExpr represents a record.
 type RecordExpr struct {
 	SourcePosition   util.SourcePos
 	FieldNameIndexes []pools.NameIndex
 	Fields           []*RecordFieldExpr
+	EvaluationOrder  []int // Indexes of the fields with each one after the fields it refers to
 }
 
 func (e *RecordExpr) GetFieldNameIndexes() []pools.NameIndex { return e.FieldNameIndexes }
@@ -354,7 +575,9 @@ func (e *RecordExpr) isStructuredExpression()                {}
 type RecordFieldExpr struct {
 	SourcePosition util.SourcePos
 	FieldNameIndex pools.NameIndex
-	FieldValue     IExpression
+	FieldType      IExpression // nil unless the type is declared
+	FieldValue     IExpression // nil unless the value is given
+	DefaultValue   IExpression // nil unless there is a default value
 }
 
 func (e *RecordFieldExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
@@ -415,6 +638,44 @@ func (e *TrailingDocumentationExpr) isStructuredExpression() {}
 
 //=====================================================================================================================
 
+// UnionExpr represents a type union ("|") operation.
+type UnionExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *UnionExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *UnionExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *UnionExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
+// WhenExpr represents a chain of alternatives like 'a when x | b when y | c'.
+type WhenExpr struct {
+	SourcePosition util.SourcePos
+	Alternatives   []*WhenAlternativeExpr
+}
+
+func (e *WhenExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *WhenExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *WhenExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
+// WhenAlternativeExpr represents one alternative of a "when" chain, like 'a when x'.
+type WhenAlternativeExpr struct {
+	SourcePosition util.SourcePos
+	Value          IExpression
+	Guard          IExpression // nil for a last alternative without a guard
+}
+
+func (e *WhenAlternativeExpr) GetFieldNameIndexes() []pools.NameIndex { return nil }
+func (e *WhenAlternativeExpr) GetSourcePosition() util.SourcePos      { return e.SourcePosition }
+func (e *WhenAlternativeExpr) isStructuredExpression()                {}
+
+//=====================================================================================================================
+
 // WhereExpr represents a subtraction operation.
 type WhereExpr struct {
 	SourcePosition util.SourcePos
@@ -0,0 +1,140 @@
+//
+// # Dependency graph of the fields of a record under construction.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package nameresolution
+
+import (
+	"lligne-cli/internal/lligne/code/util"
+	"lligne-cli/internal/lligne/runtime/pools"
+)
+
+//=====================================================================================================================
+
+// RecordUnderConstruction tracks the fields of a record while their values are resolved, noting which sibling fields
+// each field depends upon, whether directly or from inside a nested record.
+type RecordUnderConstruction struct {
+	fieldNames        map[pools.NameIndex]NameUsage
+	currentFieldIndex int
+	dependencies      [][]fieldDependency
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// fieldDependency is one reference from the value of a field to a sibling field.
+type fieldDependency struct {
+	fieldIndex     int
+	sourcePosition util.SourcePos
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// FieldCycle is a chain of fields whose values refer to each other in a circle.
+type FieldCycle struct {
+	FieldIndexes   []int          // The fields of the cycle in order, ending with the first one again
+	SourcePosition util.SourcePos // The reference from the first field of the cycle to the second
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func NewRecordUnderConstruction(fieldNameIndexes []pools.NameIndex) *RecordUnderConstruction {
+	return &RecordUnderConstruction{
+		fieldNames:        makeNameUsageMap(fieldNameIndexes, ResolutionMechanismRecordField),
+		currentFieldIndex: 0,
+		dependencies:      make([][]fieldDependency, len(fieldNameIndexes)),
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// StartField notes that the names found from now on are used by the value of the given field.
+func (r *RecordUnderConstruction) StartField(fieldIndex int) {
+	r.currentFieldIndex = fieldIndex
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// OrderFields sorts the fields so that each one comes after the fields it depends upon, otherwise keeping them in
+// source order. Also returns the cycles that prevent such an order.
+func (r *RecordUnderConstruction) OrderFields() (evaluationOrder []int, cycles []FieldCycle) {
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	states := make([]int, len(r.dependencies))
+	evaluationOrder = make([]int, 0, len(r.dependencies))
+
+	// The fields being visited, each one depending on the next through the reference at the same index
+	var path []int
+	var pathReferences []util.SourcePos
+
+	var visit func(fieldIndex int)
+	visit = func(fieldIndex int) {
+		states[fieldIndex] = visiting
+		path = append(path, fieldIndex)
+
+		for _, dependency := range r.dependencies[fieldIndex] {
+			pathReferences = append(pathReferences, dependency.sourcePosition)
+
+			switch states[dependency.fieldIndex] {
+			case unvisited:
+				visit(dependency.fieldIndex)
+			case visiting:
+				cycles = append(cycles, makeFieldCycle(path, pathReferences, dependency.fieldIndex))
+			}
+
+			pathReferences = pathReferences[:len(pathReferences)-1]
+		}
+
+		path = path[:len(path)-1]
+		states[fieldIndex] = visited
+		evaluationOrder = append(evaluationOrder, fieldIndex)
+	}
+
+	for fieldIndex := range r.dependencies {
+		if states[fieldIndex] == unvisited {
+			visit(fieldIndex)
+		}
+	}
+
+	return evaluationOrder, cycles
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// addDependency notes that the value of the current field refers to the sibling field with given index.
+func (r *RecordUnderConstruction) addDependency(fieldIndex uint64, sourcePosition util.SourcePos) {
+	r.dependencies[r.currentFieldIndex] = append(r.dependencies[r.currentFieldIndex], fieldDependency{
+		fieldIndex:     int(fieldIndex),
+		sourcePosition: sourcePosition,
+	})
+}
+
+//=====================================================================================================================
+
+// makeFieldCycle extracts the cycle closed by a reference from the end of the path back to the given field.
+func makeFieldCycle(path []int, pathReferences []util.SourcePos, firstFieldIndex int) FieldCycle {
+
+	start := 0
+	for path[start] != firstFieldIndex {
+		start += 1
+	}
+
+	fieldIndexes := append([]int(nil), path[start:]...)
+	fieldIndexes = append(fieldIndexes, firstFieldIndex)
+
+	return FieldCycle{
+		FieldIndexes:   fieldIndexes,
+		SourcePosition: pathReferences[start],
+	}
+
+}
+
+//=====================================================================================================================
@@ -7,7 +7,10 @@
 
 package nameresolution
 
-import "lligne-cli/internal/lligne/runtime/pools"
+import (
+	"lligne-cli/internal/lligne/code/util"
+	"lligne-cli/internal/lligne/runtime/pools"
+)
 
 //=====================================================================================================================
 
@@ -19,7 +22,9 @@ import "lligne-cli/internal/lligne/runtime/pools"
 // 2. When inside the left hand side of a where expression, find the name inside the right hand side of the expression or continue.
 // 3. When inside a record, find the name as a sibling field in the record or continue.
 // 4. When inside a nested record, recursively find the name as a field of the parent record or continue.
-// 5. Find the name inside the top level.
+// 5. When inside the body of a function, find the name as a parameter of the function before looking in the record
+// defining the function.
+// 6. Find the name inside the top level, i.e. the outermost record.
 type ResolutionMechanism uint16
 
 const (
@@ -28,34 +33,49 @@ const (
 	ResolutionMechanismWhereField
 	ResolutionMechanismRecordField
 	ResolutionMechanismTopLevel
+	ResolutionMechanismFunctionParameter
 )
 
 //=====================================================================================================================
 
+// NameUsage records where a name comes from. For a field of a record under construction, RecordDepth counts the
+// records under construction between the usage of the name and that record, zero for a sibling field. For a function
+// parameter, FieldIndex is the position of the parameter.
 type NameUsage struct {
-	FieldIndex uint64
-	Mechanism  ResolutionMechanism
+	FieldIndex  uint64
+	Mechanism   ResolutionMechanism
+	RecordDepth uint64
 }
 
 //=====================================================================================================================
 
-// TODO: make these maps instead of arrays
-
+// NameResolutionContext holds the names visible at one point in the code, following the resolution rules above.
 type NameResolutionContext struct {
-	fieldReferenceNames           map[pools.NameIndex]NameUsage
-	whereNames                    map[pools.NameIndex]NameUsage
-	recordsUnderConstructionNames map[pools.NameIndex]NameUsage
-	topLevelNames                 map[pools.NameIndex]NameUsage
+	fieldReferenceNames      map[pools.NameIndex]NameUsage   // nil unless inside a field reference
+	whereNames               []map[pools.NameIndex]NameUsage // innermost last; nil if the names are not known
+	recordsUnderConstruction []*RecordUnderConstruction      // innermost last
+	function                 *functionUnderConstruction      // nil unless inside the body of a function
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// functionUnderConstruction holds the parameters of the innermost function whose body is being resolved, plus where to
+// find the record field defining the function.
+type functionUnderConstruction struct {
+	parameterNames map[pools.NameIndex]NameUsage
+	recordCount    int // The number of records under construction around the function
+	record         *RecordUnderConstruction
+	fieldIndex     int
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
 func NewNameResolutionContext() *NameResolutionContext {
 	return &NameResolutionContext{
-		fieldReferenceNames:           make(map[pools.NameIndex]NameUsage),
-		whereNames:                    make(map[pools.NameIndex]NameUsage),
-		recordsUnderConstructionNames: make(map[pools.NameIndex]NameUsage),
-		topLevelNames:                 make(map[pools.NameIndex]NameUsage),
+		fieldReferenceNames:      nil,
+		whereNames:               nil,
+		recordsUnderConstruction: nil,
+		function:                 nil,
 	}
 }
 
@@ -63,42 +83,153 @@ func NewNameResolutionContext() *NameResolutionContext {
 
 func (c *NameResolutionContext) WithFieldReferenceLhs(fieldReferenceLhs IExpression) *NameResolutionContext {
 	return &NameResolutionContext{
-		fieldReferenceNames:           makeNameUsageMap(fieldReferenceLhs.GetFieldNameIndexes(), ResolutionMechanismFieldReference),
-		whereNames:                    c.whereNames,
-		recordsUnderConstructionNames: c.recordsUnderConstructionNames,
-		topLevelNames:                 c.topLevelNames,
+		fieldReferenceNames:      makeNameUsageMap(fieldReferenceLhs.GetFieldNameIndexes(), ResolutionMechanismFieldReference),
+		whereNames:               c.whereNames,
+		recordsUnderConstruction: c.recordsUnderConstruction,
+		function:                 c.function,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// WithFunctionParameters gives the context for the body of a function defined by the current field of the innermost
+// record under construction. The parameters of any function around this one are no longer visible.
+func (c *NameResolutionContext) WithFunctionParameters(parameterNameIndexes []pools.NameIndex) *NameResolutionContext {
+	record := c.recordsUnderConstruction[len(c.recordsUnderConstruction)-1]
+
+	return &NameResolutionContext{
+		fieldReferenceNames:      nil,
+		whereNames:               nil,
+		recordsUnderConstruction: c.recordsUnderConstruction,
+		function: &functionUnderConstruction{
+			parameterNames: makeNameUsageMap(parameterNameIndexes, ResolutionMechanismFunctionParameter),
+			recordCount:    len(c.recordsUnderConstruction),
+			record:         record,
+			fieldIndex:     record.currentFieldIndex,
+		},
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (c *NameResolutionContext) WithRecordUnderConstruction(record *RecordUnderConstruction) *NameResolutionContext {
+	return &NameResolutionContext{
+		fieldReferenceNames: c.fieldReferenceNames,
+		whereNames:          c.whereNames,
+		recordsUnderConstruction: append(
+			c.recordsUnderConstruction[:len(c.recordsUnderConstruction):len(c.recordsUnderConstruction)],
+			record,
+		),
+		function: c.function,
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
 func (c *NameResolutionContext) WithWhereRhs(whereRhs IExpression) *NameResolutionContext {
+	var names map[pools.NameIndex]NameUsage
+	if _, ok := whereRhs.(*RecordExpr); ok {
+		names = makeNameUsageMap(whereRhs.GetFieldNameIndexes(), ResolutionMechanismWhereField)
+	}
+
 	return &NameResolutionContext{
-		fieldReferenceNames:           c.fieldReferenceNames,
-		whereNames:                    makeNameUsageMap(whereRhs.GetFieldNameIndexes(), ResolutionMechanismWhereField),
-		recordsUnderConstructionNames: c.recordsUnderConstructionNames,
-		topLevelNames:                 c.topLevelNames,
+		fieldReferenceNames:      c.fieldReferenceNames,
+		whereNames:               append(c.whereNames[:len(c.whereNames):len(c.whereNames)], names),
+		recordsUnderConstruction: c.recordsUnderConstruction,
+		function:                 c.function,
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// LookUpName finds the origin of a name. The mechanism of the result is ResolutionMechanismUndefined if the name is
+// not visible.
 func (c *NameResolutionContext) LookUpName(nameIndex pools.NameIndex) NameUsage {
-	// Name resolution rules:
-	// 1. When inside the right hand side of a field reference expression, find the name inside the left hand side of the expression or fail.
-	//
-	// 2. When inside the left hand side of a where expression, find the name inside the right hand side of the expression or continue.
-	// 3. When inside a record, find the name as a sibling field in the record or continue.
-	// 4. When inside a nested record, recursively find the name as a field of the parent record or continue.
-	// 5. Find the name inside the top level.
-
-	if len(c.fieldReferenceNames) > 0 {
-		return c.fieldReferenceNames[nameIndex]
+
+	// 1. Names inside a field reference come from its left hand side. That might not be a record literal, so the type
+	// checker has the final say.
+	if c.fieldReferenceNames != nil {
+		result := c.fieldReferenceNames[nameIndex]
+		result.Mechanism = ResolutionMechanismFieldReference
+		return result
+	}
+
+	// 2. Names inside the left hand side of a where expression come from its right hand side. When the right hand side
+	// is not a record literal, its names are only known to the type checker.
+	for i := len(c.whereNames) - 1; i >= 0; i-- {
+		if c.whereNames[i] == nil {
+			return NameUsage{Mechanism: ResolutionMechanismWhereField}
+		}
+
+		if result, found := c.whereNames[i][nameIndex]; found {
+			return result
+		}
 	}
 
-	result := c.whereNames[nameIndex]
+	// 3-6. Sibling fields, then fields of parent records, ending with the outermost record as the top level. The
+	// parameters of a function come between the records inside its body and the record defining it.
+	outermost := len(c.recordsUnderConstruction) - 1
+	for depth := 0; depth <= outermost; depth++ {
+		if c.function != nil && depth == outermost+1-c.function.recordCount {
+			if result, found := c.function.parameterNames[nameIndex]; found {
+				return result
+			}
+		}
+
+		if result, found := c.recordsUnderConstruction[outermost-depth].fieldNames[nameIndex]; found {
+			result.RecordDepth = uint64(depth)
+			if depth > 0 && depth == outermost {
+				result.Mechanism = ResolutionMechanismTopLevel
+			}
+			return result
+		}
+	}
+
+	return NameUsage{Mechanism: ResolutionMechanismUndefined}
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// NoteFieldDependency records that the field of a record under construction found by LookUpName is needed by the
+// field of that record currently being resolved. A function calling itself does not depend on itself.
+func (c *NameResolutionContext) NoteFieldDependency(nameUsage NameUsage, sourcePosition util.SourcePos) {
+	record := c.recordsUnderConstruction[len(c.recordsUnderConstruction)-1-int(nameUsage.RecordDepth)]
+
+	if c.function != nil && c.function.record == record && c.function.fieldIndex == int(nameUsage.FieldIndex) {
+		return
+	}
+
+	record.addDependency(nameUsage.FieldIndex, sourcePosition)
+}
 
-	// TODO: names within same or parent records if result is nil
+//---------------------------------------------------------------------------------------------------------------------
+
+// VisibleNames lists the names that could be found by LookUpName, not counting names only known to the type checker.
+func (c *NameResolutionContext) VisibleNames() []pools.NameIndex {
+	result := make([]pools.NameIndex, 0)
+
+	for nameIndex := range c.fieldReferenceNames {
+		result = append(result, nameIndex)
+	}
+
+	for _, names := range c.whereNames {
+		for nameIndex := range names {
+			result = append(result, nameIndex)
+		}
+	}
+
+	for _, record := range c.recordsUnderConstruction {
+		for nameIndex := range record.fieldNames {
+			result = append(result, nameIndex)
+		}
+	}
+
+	if c.function != nil {
+		for nameIndex := range c.function.parameterNames {
+			result = append(result, nameIndex)
+		}
+	}
 
 	return result
 }
@@ -10,7 +10,10 @@ package nameresolution
 import (
 	"fmt"
 	prior "lligne-cli/internal/lligne/code/analysis/structuring"
+	"lligne-cli/internal/lligne/code/diagnostics"
+	"lligne-cli/internal/lligne/code/util"
 	"lligne-cli/internal/lligne/runtime/pools"
+	"strings"
 )
 
 //=====================================================================================================================
@@ -21,6 +24,7 @@ type Outcome struct {
 	Model           IExpression
 	StringConstants *pools.StringConstantPool
 	IdentifierNames *pools.NameConstantPool
+	Diagnostics     []*diagnostics.Diagnostic
 }
 
 //=====================================================================================================================
@@ -38,6 +42,7 @@ func ResolveNames(priorOutcome *prior.Outcome) *Outcome {
 		Model:           model,
 		StringConstants: priorOutcome.StringConstants,
 		IdentifierNames: priorOutcome.IdentifierNames,
+		Diagnostics:     s.Diagnostics,
 	}
 }
 
@@ -48,6 +53,7 @@ type nameResolver struct {
 	NewLineOffsets  []uint32
 	StringConstants *pools.StringConstantPool
 	IdentifierNames *pools.NameConstantPool
+	Diagnostics     []*diagnostics.Diagnostic
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -58,6 +64,7 @@ func newNameResolver(priorOutcome *prior.Outcome) *nameResolver {
 		NewLineOffsets:  priorOutcome.NewLineOffsets,
 		StringConstants: priorOutcome.StringConstants,
 		IdentifierNames: priorOutcome.IdentifierNames,
+		Diagnostics:     priorOutcome.Diagnostics,
 	}
 }
 
@@ -72,10 +79,22 @@ func (s *nameResolver) resolveNames(
 
 	case *prior.AdditionExpr:
 		return s.resolveAdditionExpr(expr, context)
+	case *prior.ArrayLiteralExpr:
+		return s.resolveArrayLiteralExpr(expr, context)
+	case *prior.ArrayTypeExpr:
+		return s.resolveArrayTypeExpr(expr, context)
 	case *prior.BooleanLiteralExpr:
 		return s.resolveBooleanLiteralExpr(expr)
 	case *prior.BuiltInTypeExpr:
 		return s.resolveBuiltInTypeExpr(expr)
+	case *prior.CollatedEqualsExpr:
+		return s.resolveCollatedEqualsExpr(expr, context)
+	case *prior.CollatedNotEqualsExpr:
+		return s.resolveCollatedNotEqualsExpr(expr, context)
+	case *prior.ConstrainedValueExpr:
+		return s.resolveConstrainedValueExpr(expr)
+	case *prior.ConstraintExpr:
+		return s.resolveConstraintExpr(expr, context)
 	case *prior.DivisionExpr:
 		return s.resolveDivisionExpr(expr, context)
 	case *prior.EqualsExpr:
@@ -84,14 +103,28 @@ func (s *nameResolver) resolveNames(
 		return s.resolveFieldReferenceExpr(expr, context)
 	case *prior.Float64LiteralExpr:
 		return s.resolveFloatingPointLiteralExpr(expr)
+	case *prior.FormatExpr:
+		return s.resolveFormatExpr(expr, context)
+	case *prior.FunctionCallExpr:
+		return s.resolveFunctionCallExpr(expr, context)
+	case *prior.FunctionExpr:
+		return s.resolveFunctionExpr(expr, context)
+	case *prior.FunctionTypeExpr:
+		return s.resolveFunctionTypeExpr(expr, context)
 	case *prior.GreaterThanExpr:
 		return s.resolveGreaterThanExpr(expr, context)
 	case *prior.GreaterThanOrEqualsExpr:
 		return s.resolveGreaterThanOrEqualsExpr(expr, context)
 	case *prior.IdentifierExpr:
 		return s.resolveIdentifierExpr(expr, context)
+	case *prior.InExpr:
+		return s.resolveInExpr(expr, context)
+	case *prior.IndexExpr:
+		return s.resolveIndexExpr(expr, context)
 	case *prior.Int64LiteralExpr:
 		return s.resolveIntegerLiteralExpr(expr)
+	case *prior.IntersectExpr:
+		return s.resolveIntersectExpr(expr, context)
 	case *prior.IsExpr:
 		return s.resolveIsExpr(expr, context)
 	case *prior.LessThanExpr:
@@ -104,20 +137,36 @@ func (s *nameResolver) resolveNames(
 		return s.resolveLogicalNotOperationExpr(expr, context)
 	case *prior.LogicalOrExpr:
 		return s.resolveLogicalOrExpr(expr, context)
+	case *prior.MatchExpr:
+		return s.resolveMatchExpr(expr, context)
 	case *prior.MultiplicationExpr:
 		return s.resolveMultiplicationExpr(expr, context)
 	case *prior.NegationOperationExpr:
 		return s.resolveNegationOperationExpr(expr, context)
+	case *prior.NoneExpr:
+		return s.resolveNoneExpr(expr)
 	case *prior.NotEqualsExpr:
 		return s.resolveNotEqualsExpr(expr, context)
+	case *prior.NotMatchExpr:
+		return s.resolveNotMatchExpr(expr, context)
+	case *prior.OptionalDefaultExpr:
+		return s.resolveOptionalDefaultExpr(expr, context)
+	case *prior.OptionalExpr:
+		return s.resolveOptionalExpr(expr, context)
 	case *prior.ParenthesizedExpr:
 		return s.resolveParenthesizedExpr(expr, context)
+	case *prior.RangeExpr:
+		return s.resolveRangeExpr(expr, context)
 	case *prior.RecordExpr:
 		return s.resolveRecordExpr(expr, context)
 	case *prior.StringLiteralExpr:
 		return s.resolveStringLiteralExpr(expr)
 	case *prior.SubtractionExpr:
 		return s.resolveSubtractionExpr(expr, context)
+	case *prior.UnionExpr:
+		return s.resolveUnionExpr(expr, context)
+	case *prior.WhenExpr:
+		return s.resolveWhenExpr(expr, context)
 	case *prior.WhereExpr:
 		return s.resolveWhereExpr(expr, context)
 
@@ -145,6 +194,36 @@ func (s *nameResolver) resolveAdditionExpr(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *nameResolver) resolveArrayLiteralExpr(
+	expr *prior.ArrayLiteralExpr,
+	context *NameResolutionContext,
+) IExpression {
+	elements := make([]IExpression, 0)
+	for _, element := range expr.Elements {
+		elements = append(elements, s.resolveNames(element, context))
+	}
+
+	return &ArrayLiteralExpr{
+		SourcePosition: expr.SourcePosition,
+		Elements:       elements,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *nameResolver) resolveArrayTypeExpr(
+	expr *prior.ArrayTypeExpr,
+	context *NameResolutionContext,
+) IExpression {
+	elementType := s.resolveNames(expr.ElementType, context)
+	return &ArrayTypeExpr{
+		SourcePosition: expr.SourcePosition,
+		ElementType:    elementType,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *nameResolver) resolveBooleanLiteralExpr(expr *prior.BooleanLiteralExpr) IExpression {
 	return &BooleanLiteralExpr{
 		SourcePosition: expr.SourcePosition,
@@ -162,6 +241,63 @@ func (s *nameResolver) resolveBuiltInTypeExpr(expr *prior.BuiltInTypeExpr) IExpr
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *nameResolver) resolveCollatedEqualsExpr(
+	expr *prior.CollatedEqualsExpr,
+	context *NameResolutionContext,
+) IExpression {
+	lhs := s.resolveNames(expr.Lhs, context)
+	rhs := s.resolveNames(expr.Rhs, context)
+	return &CollatedEqualsExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+		Collation:      expr.Collation,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *nameResolver) resolveCollatedNotEqualsExpr(
+	expr *prior.CollatedNotEqualsExpr,
+	context *NameResolutionContext,
+) IExpression {
+	lhs := s.resolveNames(expr.Lhs, context)
+	rhs := s.resolveNames(expr.Rhs, context)
+	return &CollatedNotEqualsExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+		Collation:      expr.Collation,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *nameResolver) resolveConstrainedValueExpr(expr *prior.ConstrainedValueExpr) IExpression {
+	return &ConstrainedValueExpr{
+		SourcePosition: expr.SourcePosition,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// resolveConstraintExpr resolves the names of a constraint. Its predicate is checked apart from any surrounding record,
+// so the only name it can see is "val", the value being constrained.
+func (s *nameResolver) resolveConstraintExpr(
+	expr *prior.ConstraintExpr,
+	context *NameResolutionContext,
+) IExpression {
+	constrainedType := s.resolveNames(expr.ConstrainedType, context)
+	predicate := s.resolveNames(expr.Predicate, NewNameResolutionContext())
+	return &ConstraintExpr{
+		SourcePosition:  expr.SourcePosition,
+		ConstrainedType: constrainedType,
+		Predicate:       predicate,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *nameResolver) resolveDivisionExpr(
 	expr *prior.DivisionExpr,
 	context *NameResolutionContext,
@@ -216,6 +352,101 @@ func (s *nameResolver) resolveFloatingPointLiteralExpr(expr *prior.Float64Litera
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *nameResolver) resolveFormatExpr(
+	expr *prior.FormatExpr,
+	context *NameResolutionContext,
+) IExpression {
+	lhs := s.resolveNames(expr.Lhs, context)
+	rhs := s.resolveNames(expr.Rhs, context)
+	return &FormatExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *nameResolver) resolveFunctionCallExpr(
+	expr *prior.FunctionCallExpr,
+	context *NameResolutionContext,
+) IExpression {
+	functionReference := s.resolveNames(expr.FunctionReference, context)
+
+	arguments := make([]IExpression, 0)
+	for _, argument := range expr.Arguments {
+		arguments = append(arguments, s.resolveNames(argument, context))
+	}
+
+	return &FunctionCallExpr{
+		SourcePosition:    expr.SourcePosition,
+		FunctionReference: functionReference,
+		Arguments:         arguments,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// resolveFunctionExpr resolves the names of a function. The types of its parameters and result see the same names as
+// the function itself, while its body also sees the parameters.
+func (s *nameResolver) resolveFunctionExpr(
+	expr *prior.FunctionExpr,
+	context *NameResolutionContext,
+) IExpression {
+	parameters := s.resolveFunctionParameters(expr.Parameters, context)
+	resultType := s.resolveNames(expr.ResultType, context)
+
+	parameterNameIndexes := make([]pools.NameIndex, 0)
+	for _, parameter := range expr.Parameters {
+		parameterNameIndexes = append(parameterNameIndexes, parameter.ParameterNameIndex)
+	}
+
+	body := s.resolveNames(expr.Body, context.WithFunctionParameters(parameterNameIndexes))
+
+	return &FunctionExpr{
+		SourcePosition: expr.SourcePosition,
+		Parameters:     parameters,
+		ResultType:     resultType,
+		Body:           body,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *nameResolver) resolveFunctionParameters(
+	parameters []*prior.FunctionParameterExpr,
+	context *NameResolutionContext,
+) []*FunctionParameterExpr {
+	result := make([]*FunctionParameterExpr, 0)
+
+	for _, parameter := range parameters {
+		result = append(result, &FunctionParameterExpr{
+			SourcePosition:     parameter.SourcePosition,
+			ParameterNameIndex: parameter.ParameterNameIndex,
+			ParameterType:      s.resolveNames(parameter.ParameterType, context),
+		})
+	}
+
+	return result
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *nameResolver) resolveFunctionTypeExpr(
+	expr *prior.FunctionTypeExpr,
+	context *NameResolutionContext,
+) IExpression {
+	parameters := s.resolveFunctionParameters(expr.Parameters, context)
+	resultType := s.resolveNames(expr.ResultType, context)
+	return &FunctionTypeExpr{
+		SourcePosition: expr.SourcePosition,
+		Parameters:     parameters,
+		ResultType:     resultType,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *nameResolver) resolveGreaterThanExpr(
 	expr *prior.GreaterThanExpr,
 	context *NameResolutionContext,
@@ -250,10 +481,59 @@ func (s *nameResolver) resolveIdentifierExpr(
 	expr *prior.IdentifierExpr,
 	context *NameResolutionContext,
 ) IExpression {
+	nameUsage := context.LookUpName(expr.NameIndex)
+
+	switch nameUsage.Mechanism {
+
+	case ResolutionMechanismRecordField, ResolutionMechanismTopLevel:
+		context.NoteFieldDependency(nameUsage, expr.SourcePosition)
+
+	case ResolutionMechanismUndefined:
+		candidates := make([]string, 0)
+		for _, nameIndex := range context.VisibleNames() {
+			candidates = append(candidates, s.IdentifierNames.Get(nameIndex))
+		}
+
+		name := s.IdentifierNames.Get(expr.NameIndex)
+		s.Diagnostics = append(s.Diagnostics, diagnostics.NewError(diagnostics.CodeUndefinedName, expr.SourcePosition,
+			"Undefined name '%s'%s", name, util.DidYouMean(name, candidates)))
+
+	}
+
 	return &IdentifierExpr{
 		SourcePosition: expr.SourcePosition,
 		NameIndex:      expr.NameIndex,
-		NameUsage:      context.LookUpName(expr.NameIndex),
+		NameUsage:      nameUsage,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *nameResolver) resolveInExpr(
+	expr *prior.InExpr,
+	context *NameResolutionContext,
+) IExpression {
+	lhs := s.resolveNames(expr.Lhs, context)
+	rhs := s.resolveNames(expr.Rhs, context)
+	return &InExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *nameResolver) resolveIndexExpr(
+	expr *prior.IndexExpr,
+	context *NameResolutionContext,
+) IExpression {
+	array := s.resolveNames(expr.Array, context)
+	index := s.resolveNames(expr.Index, context)
+	return &IndexExpr{
+		SourcePosition: expr.SourcePosition,
+		Array:          array,
+		Index:          index,
 	}
 }
 
@@ -268,6 +548,21 @@ func (s *nameResolver) resolveIntegerLiteralExpr(expr *prior.Int64LiteralExpr) I
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *nameResolver) resolveIntersectExpr(
+	expr *prior.IntersectExpr,
+	context *NameResolutionContext,
+) IExpression {
+	lhs := s.resolveNames(expr.Lhs, context)
+	rhs := s.resolveNames(expr.Rhs, context)
+	return &IntersectExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *nameResolver) resolveIsExpr(
 	expr *prior.IsExpr,
 	context *NameResolutionContext,
@@ -356,6 +651,21 @@ func (s *nameResolver) resolveLogicalOrExpr(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *nameResolver) resolveMatchExpr(
+	expr *prior.MatchExpr,
+	context *NameResolutionContext,
+) IExpression {
+	lhs := s.resolveNames(expr.Lhs, context)
+	rhs := s.resolveNames(expr.Rhs, context)
+	return &MatchExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *nameResolver) resolveMultiplicationExpr(
 	expr *prior.MultiplicationExpr,
 	context *NameResolutionContext,
@@ -384,6 +694,14 @@ func (s *nameResolver) resolveNegationOperationExpr(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *nameResolver) resolveNoneExpr(expr *prior.NoneExpr) IExpression {
+	return &NoneExpr{
+		SourcePosition: expr.SourcePosition,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *nameResolver) resolveNotEqualsExpr(
 	expr *prior.NotEqualsExpr,
 	context *NameResolutionContext,
@@ -399,6 +717,49 @@ func (s *nameResolver) resolveNotEqualsExpr(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *nameResolver) resolveNotMatchExpr(
+	expr *prior.NotMatchExpr,
+	context *NameResolutionContext,
+) IExpression {
+	lhs := s.resolveNames(expr.Lhs, context)
+	rhs := s.resolveNames(expr.Rhs, context)
+	return &NotMatchExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *nameResolver) resolveOptionalDefaultExpr(
+	expr *prior.OptionalDefaultExpr,
+	context *NameResolutionContext,
+) IExpression {
+	lhs := s.resolveNames(expr.Lhs, context)
+	rhs := s.resolveNames(expr.Rhs, context)
+	return &OptionalDefaultExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *nameResolver) resolveOptionalExpr(
+	expr *prior.OptionalExpr,
+	context *NameResolutionContext,
+) IExpression {
+	operand := s.resolveNames(expr.Operand, context)
+	return &OptionalExpr{
+		SourcePosition: expr.SourcePosition,
+		Operand:        operand,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *nameResolver) resolveParenthesizedExpr(
 	expr *prior.ParenthesizedExpr,
 	context *NameResolutionContext,
@@ -412,6 +773,21 @@ func (s *nameResolver) resolveParenthesizedExpr(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *nameResolver) resolveRangeExpr(
+	expr *prior.RangeExpr,
+	context *NameResolutionContext,
+) IExpression {
+	first := s.resolveNames(expr.First, context)
+	last := s.resolveNames(expr.Last, context)
+	return &RangeExpr{
+		SourcePosition: expr.SourcePosition,
+		First:          first,
+		Last:           last,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *nameResolver) resolveRecordExpr(
 	expr *prior.RecordExpr,
 	context *NameResolutionContext,
@@ -421,14 +797,36 @@ func (s *nameResolver) resolveRecordExpr(
 
 	for _, field := range expr.Fields {
 		fieldNameIndexes = append(fieldNameIndexes, field.FieldNameIndex)
-		fieldExpr := s.resolveRecordFieldExpr(field, context)
+	}
+
+	// The fields can refer to each other while the record is under construction.
+	record := NewRecordUnderConstruction(fieldNameIndexes)
+	fieldContext := context.WithRecordUnderConstruction(record)
+
+	for i, field := range expr.Fields {
+		record.StartField(i)
+		fieldExpr := s.resolveRecordFieldExpr(field, fieldContext)
 		fields = append(fields, fieldExpr)
 	}
 
+	// Evaluate each field after the fields it refers to.
+	evaluationOrder, cycles := record.OrderFields()
+
+	for _, cycle := range cycles {
+		names := make([]string, 0)
+		for _, fieldIndex := range cycle.FieldIndexes {
+			names = append(names, s.IdentifierNames.Get(fieldNameIndexes[fieldIndex]))
+		}
+
+		s.Diagnostics = append(s.Diagnostics, diagnostics.NewError(diagnostics.CodeReferenceCycle, cycle.SourcePosition,
+			"Reference cycle among record fields: %s", strings.Join(names, " -> ")))
+	}
+
 	return &RecordExpr{
 		SourcePosition:   expr.SourcePosition,
 		FieldNameIndexes: fieldNameIndexes,
 		Fields:           fields,
+		EvaluationOrder:  evaluationOrder,
 	}
 }
 
@@ -438,10 +836,27 @@ func (s *nameResolver) resolveRecordFieldExpr(
 	expr *prior.RecordFieldExpr,
 	context *NameResolutionContext,
 ) *RecordFieldExpr {
+	var fieldType IExpression
+	if expr.FieldType != nil {
+		fieldType = s.resolveNames(expr.FieldType, context)
+	}
+
+	var fieldValue IExpression
+	if expr.FieldValue != nil {
+		fieldValue = s.resolveNames(expr.FieldValue, context)
+	}
+
+	var defaultValue IExpression
+	if expr.DefaultValue != nil {
+		defaultValue = s.resolveNames(expr.DefaultValue, context)
+	}
+
 	return &RecordFieldExpr{
 		SourcePosition: expr.GetSourcePosition(),
 		FieldNameIndex: expr.FieldNameIndex,
-		FieldValue:     s.resolveNames(expr.FieldValue, context),
+		FieldType:      fieldType,
+		FieldValue:     fieldValue,
+		DefaultValue:   defaultValue,
 	}
 }
 
@@ -473,6 +888,48 @@ func (s *nameResolver) resolveSubtractionExpr(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *nameResolver) resolveUnionExpr(
+	expr *prior.UnionExpr,
+	context *NameResolutionContext,
+) IExpression {
+	lhs := s.resolveNames(expr.Lhs, context)
+	rhs := s.resolveNames(expr.Rhs, context)
+	return &UnionExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *nameResolver) resolveWhenExpr(
+	expr *prior.WhenExpr,
+	context *NameResolutionContext,
+) IExpression {
+	alternatives := make([]*WhenAlternativeExpr, 0)
+
+	for _, alternative := range expr.Alternatives {
+		var guard IExpression
+		if alternative.Guard != nil {
+			guard = s.resolveNames(alternative.Guard, context)
+		}
+
+		alternatives = append(alternatives, &WhenAlternativeExpr{
+			SourcePosition: alternative.SourcePosition,
+			Value:          s.resolveNames(alternative.Value, context),
+			Guard:          guard,
+		})
+	}
+
+	return &WhenExpr{
+		SourcePosition: expr.SourcePosition,
+		Alternatives:   alternatives,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *nameResolver) resolveWhereExpr(
 	expr *prior.WhereExpr,
 	context *NameResolutionContext,
@@ -7,6 +7,7 @@ package pooling
 
 import (
 	"lligne-cli/internal/lligne/code/util"
+	"lligne-cli/internal/lligne/runtime/collations"
 	"lligne-cli/internal/lligne/runtime/pools"
 )
 
@@ -32,7 +33,7 @@ func (e *AdditionExpr) isPooledExpression()               {}
 
 //=====================================================================================================================
 
-// ArrayLiteralExpr represents a parenthesized expression or comma-separated sequence of expressions.
+// ArrayLiteralExpr represents an array literal.
 type ArrayLiteralExpr struct {
 	SourcePosition util.SourcePos
 	Elements       []IExpression
@@ -43,6 +44,17 @@ func (e *ArrayLiteralExpr) isPooledExpression()               {}
 
 //=====================================================================================================================
 
+// ArrayTypeExpr represents an array type using "[]" suffix.
+type ArrayTypeExpr struct {
+	SourcePosition util.SourcePos
+	ElementType    IExpression
+}
+
+func (e *ArrayTypeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *ArrayTypeExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
 // BooleanLiteralExpr represents a single boolean literal.
 type BooleanLiteralExpr struct {
 	SourcePosition util.SourcePos
@@ -64,6 +76,32 @@ func (e *BuiltInTypeExpr) isPooledExpression()               {}
 
 //=====================================================================================================================
 
+// CollatedEqualsExpr represents an equality comparison with modifiers ("==[Aa]==") operation.
+type CollatedEqualsExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+	Collation      collations.Collation
+}
+
+func (e *CollatedEqualsExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *CollatedEqualsExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
+// CollatedNotEqualsExpr represents an inequality comparison with modifiers ("=![Aa]!=") operation.
+type CollatedNotEqualsExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+	Collation      collations.Collation
+}
+
+func (e *CollatedNotEqualsExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *CollatedNotEqualsExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
 // DivisionExpr represents a division operation.
 type DivisionExpr struct {
 	SourcePosition util.SourcePos
@@ -111,6 +149,42 @@ func (e *Float64LiteralExpr) isPooledExpression()               {}
 
 //=====================================================================================================================
 
+// FormatExpr represents a value formatting ("fmt") operation.
+type FormatExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *FormatExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *FormatExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
+// FunctionArgumentsExpr represents a parenthesized, comma-separated sequence of expressions postfix to a function
+// reference.
+type FunctionArgumentsExpr struct {
+	SourcePosition util.SourcePos
+	Items          []IExpression
+}
+
+func (e *FunctionArgumentsExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *FunctionArgumentsExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
+// FunctionArrowExpr represents a function type with "->" operator.
+type FunctionArrowExpr struct {
+	SourcePosition util.SourcePos
+	Argument       IExpression
+	Result         IExpression
+}
+
+func (e *FunctionArrowExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *FunctionArrowExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
 // FunctionCallExpr represents a function call (a function name followed by a parenthesized expression).
 type FunctionCallExpr struct {
 	SourcePosition    util.SourcePos
@@ -158,6 +232,30 @@ func (e *IdentifierExpr) isPooledExpression()               {}
 
 //=====================================================================================================================
 
+// InExpr represents a set membership "in" operation.
+type InExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *InExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *InExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
+// IndexExpr represents an array indexing ("a[i]") operation.
+type IndexExpr struct {
+	SourcePosition util.SourcePos
+	Array          IExpression
+	Index          IExpression
+}
+
+func (e *IndexExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *IndexExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
 // Int64LiteralExpr represents a single 64-bit integer literal.
 type Int64LiteralExpr struct {
 	SourcePosition util.SourcePos
@@ -169,6 +267,18 @@ func (e *Int64LiteralExpr) isPooledExpression()               {}
 
 //=====================================================================================================================
 
+// IntersectExpr represents a type/value intersection "&" operation.
+type IntersectExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *IntersectExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *IntersectExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
 // IntersectAssignValueExpr represents a type/value intersection value assignment "=" operation.
 type IntersectAssignValueExpr struct {
 	SourcePosition util.SourcePos
@@ -181,6 +291,30 @@ func (e *IntersectAssignValueExpr) isPooledExpression()               {}
 
 //=====================================================================================================================
 
+// IntersectDefaultValueExpr represents a type/value intersection default value "?:" operation.
+type IntersectDefaultValueExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *IntersectDefaultValueExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *IntersectDefaultValueExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
+// IntersectLowPrecedenceExpr represents a low precedence type/value intersection "&&" operation.
+type IntersectLowPrecedenceExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *IntersectLowPrecedenceExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *IntersectLowPrecedenceExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
 // IsExpr represents an "is" test.
 type IsExpr struct {
 	SourcePosition util.SourcePos
@@ -265,6 +399,18 @@ func (e *LogicalOrExpr) isPooledExpression()               {}
 
 //=====================================================================================================================
 
+// MatchExpr represents a pattern match "=~" operation.
+type MatchExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *MatchExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *MatchExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
 // MultiplicationExpr represents a multiplication operation.
 type MultiplicationExpr struct {
 	SourcePosition util.SourcePos
@@ -288,6 +434,16 @@ func (e *NegationOperationExpr) isPooledExpression()               {}
 
 //=====================================================================================================================
 
+// NoneExpr represents the absent value of an optional type ("none").
+type NoneExpr struct {
+	SourcePosition util.SourcePos
+}
+
+func (e *NoneExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *NoneExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
 // NotEqualsExpr represents a equals operation.
 type NotEqualsExpr struct {
 	SourcePosition util.SourcePos
@@ -300,7 +456,19 @@ func (e *NotEqualsExpr) isPooledExpression()               {}
 
 //=====================================================================================================================
 
-// OptionalExpr represents a parenthesized expression or comma-separated sequence of expressions.
+// NotMatchExpr represents a pattern nonmatch ("!~") operation.
+type NotMatchExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *NotMatchExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *NotMatchExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
+// OptionalExpr represents an optional type ("?") operation.
 type OptionalExpr struct {
 	SourcePosition util.SourcePos
 	Operand        IExpression
@@ -322,6 +490,30 @@ func (e *ParenthesizedExpr) isPooledExpression()               {}
 
 //=====================================================================================================================
 
+// QualifyExpr represents a type qualification (":") operation.
+type QualifyExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *QualifyExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *QualifyExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
+// RangeExpr represents a range ("..") operation.
+type RangeExpr struct {
+	SourcePosition util.SourcePos
+	First          IExpression
+	Last           IExpression
+}
+
+func (e *RangeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *RangeExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
 // RecordExpr represents a record.
 type RecordExpr struct {
 	SourcePosition util.SourcePos
@@ -381,6 +573,30 @@ func (e *TrailingDocumentationExpr) isPooledExpression() {}
 
 //=====================================================================================================================
 
+// UnionExpr represents a type union ("|") operation.
+type UnionExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *UnionExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *UnionExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
+// WhenExpr represents a when ("when") operation.
+type WhenExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *WhenExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *WhenExpr) isPooledExpression()               {}
+
+//=====================================================================================================================
+
 // WhereExpr represents a where ("where") operation.
 type WhereExpr struct {
 	SourcePosition util.SourcePos
@@ -8,8 +8,11 @@
 package pooling
 
 import (
-	"fmt"
+	"lligne-cli/internal/lligne/code/diagnostics"
 	prior "lligne-cli/internal/lligne/code/parsing"
+	"lligne-cli/internal/lligne/code/scanning"
+	"lligne-cli/internal/lligne/code/util"
+	"lligne-cli/internal/lligne/runtime/collations"
 	"lligne-cli/internal/lligne/runtime/pools"
 )
 
@@ -21,6 +24,7 @@ type Outcome struct {
 	Model           IExpression
 	StringConstants *pools.StringConstantPool
 	IdentifierNames *pools.NameConstantPool
+	Diagnostics     []*diagnostics.Diagnostic
 }
 
 //=====================================================================================================================
@@ -28,7 +32,11 @@ type Outcome struct {
 func PoolConstants(priorOutcome *prior.Outcome) *Outcome {
 
 	pooler := newPooler(priorOutcome)
-	model := pooler.poolConstants(priorOutcome.Model)
+
+	var model IExpression
+	diagnostics.RunAbortable(func() {
+		model = pooler.poolConstants(priorOutcome.Model)
+	})
 
 	return &Outcome{
 		SourceCode:      priorOutcome.SourceCode,
@@ -36,6 +44,7 @@ func PoolConstants(priorOutcome *prior.Outcome) *Outcome {
 		Model:           model,
 		StringConstants: pooler.StringConstants.Freeze(),
 		IdentifierNames: pooler.IdentifierNames.Freeze(),
+		Diagnostics:     pooler.Diagnostics,
 	}
 }
 
@@ -46,6 +55,7 @@ type pooler struct {
 	NewLineOffsets  []uint32
 	StringConstants *pools.StringPool
 	IdentifierNames *pools.NamePool
+	Diagnostics     []*diagnostics.Diagnostic
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -56,6 +66,7 @@ func newPooler(priorOutcome *prior.Outcome) *pooler {
 		NewLineOffsets:  priorOutcome.NewLineOffsets,
 		StringConstants: pools.NewStringPool(),
 		IdentifierNames: pools.NewNamePool(),
+		Diagnostics:     priorOutcome.Diagnostics,
 	}
 }
 
@@ -67,10 +78,18 @@ func (p *pooler) poolConstants(expression prior.IExpression) IExpression {
 
 	case *prior.AdditionExpr:
 		return p.poolAdditionExpr(expr)
+	case *prior.ArrayLiteralExpr:
+		return p.poolArrayLiteralExpr(expr)
+	case *prior.ArrayTypeExpr:
+		return p.poolArrayTypeExpr(expr)
 	case *prior.BooleanLiteralExpr:
 		return p.poolBooleanLiteralExpr(expr)
 	case *prior.BuiltInTypeExpr:
 		return p.poolBuiltInTypeExpr(expr)
+	case *prior.CollatedEqualsExpr:
+		return p.poolCollatedEqualsExpr(expr)
+	case *prior.CollatedNotEqualsExpr:
+		return p.poolCollatedNotEqualsExpr(expr)
 	case *prior.DivisionExpr:
 		return p.poolDivisionExpr(expr)
 	case *prior.EqualsExpr:
@@ -79,16 +98,34 @@ func (p *pooler) poolConstants(expression prior.IExpression) IExpression {
 		return p.poolFieldReferenceExpr(expr)
 	case *prior.Float64LiteralExpr:
 		return p.poolFloatingPointLiteralExpr(expr)
+	case *prior.FormatExpr:
+		return p.poolFormatExpr(expr)
+	case *prior.FunctionArgumentsExpr:
+		return p.poolFunctionArgumentsExpr(expr)
+	case *prior.FunctionArrowExpr:
+		return p.poolFunctionArrowExpr(expr)
+	case *prior.FunctionCallExpr:
+		return p.poolFunctionCallExpr(expr)
 	case *prior.GreaterThanExpr:
 		return p.poolGreaterThanExpr(expr)
 	case *prior.GreaterThanOrEqualsExpr:
 		return p.poolGreaterThanOrEqualsExpr(expr)
 	case *prior.IdentifierExpr:
 		return p.poolIdentifierExpr(expr)
+	case *prior.InExpr:
+		return p.poolInExpr(expr)
+	case *prior.IndexExpr:
+		return p.poolIndexExpr(expr)
 	case *prior.Int64LiteralExpr:
 		return p.poolIntegerLiteralExpr(expr)
+	case *prior.IntersectExpr:
+		return p.poolIntersectExpr(expr)
 	case *prior.IntersectAssignValueExpr:
 		return p.poolIntersectAssignValueExpr(expr)
+	case *prior.IntersectDefaultValueExpr:
+		return p.poolIntersectDefaultValueExpr(expr)
+	case *prior.IntersectLowPrecedenceExpr:
+		return p.poolIntersectLowPrecedenceExpr(expr)
 	case *prior.IsExpr:
 		return p.poolIsExpr(expr)
 	case *prior.LessThanExpr:
@@ -101,25 +138,47 @@ func (p *pooler) poolConstants(expression prior.IExpression) IExpression {
 		return p.poolLogicalNotOperationExpr(expr)
 	case *prior.LogicalOrExpr:
 		return p.poolLogicalOrExpr(expr)
+	case *prior.MatchExpr:
+		return p.poolMatchExpr(expr)
 	case *prior.MultiplicationExpr:
 		return p.poolMultiplicationExpr(expr)
 	case *prior.NegationOperationExpr:
 		return p.poolNegationOperationExpr(expr)
+	case *prior.NoneExpr:
+		return p.poolNoneExpr(expr)
 	case *prior.NotEqualsExpr:
 		return p.poolNotEqualsExpr(expr)
+	case *prior.NotMatchExpr:
+		return p.poolNotMatchExpr(expr)
+	case *prior.OptionalExpr:
+		return p.poolOptionalExpr(expr)
 	case *prior.ParenthesizedExpr:
 		return p.poolParenthesizedExpr(expr)
+	case *prior.QualifyExpr:
+		return p.poolQualifyExpr(expr)
+	case *prior.RangeExpr:
+		return p.poolRangeExpr(expr)
 	case *prior.RecordExpr:
 		return p.poolRecordExpr(expr)
 	case *prior.StringLiteralExpr:
 		return p.poolStringLiteralExpr(expr)
 	case *prior.SubtractionExpr:
 		return p.poolSubtractionExpr(expr)
+	case *prior.UnionExpr:
+		return p.poolUnionExpr(expr)
+	case *prior.WhenExpr:
+		return p.poolWhenExpr(expr)
 	case *prior.WhereExpr:
 		return p.poolWhereExpr(expr)
 
 	default:
-		panic(fmt.Sprintf("Missing case in poolConstants: %T\n", expression))
+		p.Diagnostics = append(p.Diagnostics, diagnostics.NewError(
+			diagnostics.CodeUnsupportedExpression,
+			expression.GetSourcePosition(),
+			"Expression not yet supported",
+		))
+		diagnostics.Abort()
+		return nil
 
 	}
 
@@ -139,6 +198,30 @@ func (p *pooler) poolAdditionExpr(expr *prior.AdditionExpr) IExpression {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (p *pooler) poolArrayLiteralExpr(expr *prior.ArrayLiteralExpr) IExpression {
+	elements := make([]IExpression, 0)
+	for _, element := range expr.Elements {
+		elements = append(elements, p.poolConstants(element))
+	}
+
+	return &ArrayLiteralExpr{
+		SourcePosition: expr.SourcePosition,
+		Elements:       elements,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (p *pooler) poolArrayTypeExpr(expr *prior.ArrayTypeExpr) IExpression {
+	elementType := p.poolConstants(expr.ElementType)
+	return &ArrayTypeExpr{
+		SourcePosition: expr.SourcePosition,
+		ElementType:    elementType,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (p *pooler) poolBooleanLiteralExpr(expr *prior.BooleanLiteralExpr) IExpression {
 	return &BooleanLiteralExpr{
 		SourcePosition: expr.SourcePosition,
@@ -156,6 +239,55 @@ func (p *pooler) poolBuiltInTypeExpr(expr *prior.BuiltInTypeExpr) IExpression {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// poolCollatedEqualsExpr interprets the modifiers between the brackets of a "==[Aa]==" operator.
+func (p *pooler) poolCollatedEqualsExpr(expr *prior.CollatedEqualsExpr) IExpression {
+	lhs := p.poolConstants(expr.Lhs)
+	rhs := p.poolConstants(expr.Rhs)
+	return &CollatedEqualsExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+		Collation:      p.poolCollation(expr.OperatorPosition),
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// poolCollatedNotEqualsExpr interprets the modifiers between the brackets of a "=![Aa]!=" operator.
+func (p *pooler) poolCollatedNotEqualsExpr(expr *prior.CollatedNotEqualsExpr) IExpression {
+	lhs := p.poolConstants(expr.Lhs)
+	rhs := p.poolConstants(expr.Rhs)
+	return &CollatedNotEqualsExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+		Collation:      p.poolCollation(expr.OperatorPosition),
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// poolCollation converts the modifiers of a collated comparison operator, e.g. "Aa" in "==[Aa]==", to a collation.
+func (p *pooler) poolCollation(operatorPosition util.SourcePos) collations.Collation {
+	text := operatorPosition.GetText(p.SourceCode)
+	modifiers := text[3 : len(text)-3]
+
+	collation, err := collations.ParseCollation(modifiers)
+	if err != nil {
+		p.Diagnostics = append(p.Diagnostics, diagnostics.NewError(
+			diagnostics.CodeInvalidComparisonModifier,
+			operatorPosition,
+			"Invalid comparison modifiers '%s': %s",
+			modifiers,
+			err.Error(),
+		))
+	}
+
+	return collation
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (p *pooler) poolDivisionExpr(expr *prior.DivisionExpr) IExpression {
 	lhs := p.poolConstants(expr.Lhs)
 	rhs := p.poolConstants(expr.Rhs)
@@ -201,6 +333,66 @@ func (p *pooler) poolFloatingPointLiteralExpr(expr *prior.Float64LiteralExpr) IE
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (p *pooler) poolFormatExpr(expr *prior.FormatExpr) IExpression {
+	lhs := p.poolConstants(expr.Lhs)
+	rhs := p.poolConstants(expr.Rhs)
+	return &FormatExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (p *pooler) poolFunctionArgumentsExpr(expr *prior.FunctionArgumentsExpr) IExpression {
+	items := make([]IExpression, 0)
+	for _, item := range expr.Items {
+		items = append(items, p.poolConstants(item))
+	}
+
+	return &FunctionArgumentsExpr{
+		SourcePosition: expr.SourcePosition,
+		Items:          items,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// poolFunctionArrowExpr pools the constants of a function type, whose empty parentheses, as in '() -> Int64', are an
+// empty list of parameters.
+func (p *pooler) poolFunctionArrowExpr(expr *prior.FunctionArrowExpr) IExpression {
+	var argument IExpression
+	if unit, ok := expr.Argument.(*prior.UnitExpr); ok {
+		argument = &FunctionArgumentsExpr{
+			SourcePosition: unit.SourcePosition,
+			Items:          make([]IExpression, 0),
+		}
+	} else {
+		argument = p.poolConstants(expr.Argument)
+	}
+	result := p.poolConstants(expr.Result)
+	return &FunctionArrowExpr{
+		SourcePosition: expr.SourcePosition,
+		Argument:       argument,
+		Result:         result,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (p *pooler) poolFunctionCallExpr(expr *prior.FunctionCallExpr) IExpression {
+	functionReference := p.poolConstants(expr.FunctionReference)
+	argument := p.poolConstants(expr.Argument)
+	return &FunctionCallExpr{
+		SourcePosition:    expr.SourcePosition,
+		FunctionReference: functionReference,
+		Argument:          argument,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (p *pooler) poolGreaterThanExpr(expr *prior.GreaterThanExpr) IExpression {
 	lhs := p.poolConstants(expr.Lhs)
 	rhs := p.poolConstants(expr.Rhs)
@@ -237,6 +429,30 @@ func (p *pooler) poolIdentifierExpr(expr *prior.IdentifierExpr) IExpression {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (p *pooler) poolInExpr(expr *prior.InExpr) IExpression {
+	lhs := p.poolConstants(expr.Lhs)
+	rhs := p.poolConstants(expr.Rhs)
+	return &InExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (p *pooler) poolIndexExpr(expr *prior.IndexExpr) IExpression {
+	array := p.poolConstants(expr.Array)
+	index := p.poolConstants(expr.Index)
+	return &IndexExpr{
+		SourcePosition: expr.SourcePosition,
+		Array:          array,
+		Index:          index,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (p *pooler) poolIntegerLiteralExpr(expr *prior.Int64LiteralExpr) IExpression {
 	return &Int64LiteralExpr{
 		SourcePosition: expr.SourcePosition,
@@ -246,6 +462,18 @@ func (p *pooler) poolIntegerLiteralExpr(expr *prior.Int64LiteralExpr) IExpressio
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (p *pooler) poolIntersectExpr(expr *prior.IntersectExpr) IExpression {
+	lhs := p.poolConstants(expr.Lhs)
+	rhs := p.poolConstants(expr.Rhs)
+	return &IntersectExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (p *pooler) poolIntersectAssignValueExpr(expr *prior.IntersectAssignValueExpr) IExpression {
 	lhs := p.poolConstants(expr.Lhs)
 	rhs := p.poolConstants(expr.Rhs)
@@ -258,6 +486,30 @@ func (p *pooler) poolIntersectAssignValueExpr(expr *prior.IntersectAssignValueEx
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (p *pooler) poolIntersectDefaultValueExpr(expr *prior.IntersectDefaultValueExpr) IExpression {
+	lhs := p.poolConstants(expr.Lhs)
+	rhs := p.poolConstants(expr.Rhs)
+	return &IntersectDefaultValueExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (p *pooler) poolIntersectLowPrecedenceExpr(expr *prior.IntersectLowPrecedenceExpr) IExpression {
+	lhs := p.poolConstants(expr.Lhs)
+	rhs := p.poolConstants(expr.Rhs)
+	return &IntersectLowPrecedenceExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (p *pooler) poolIsExpr(expr *prior.IsExpr) IExpression {
 	lhs := p.poolConstants(expr.Lhs)
 	rhs := p.poolConstants(expr.Rhs)
@@ -328,6 +580,18 @@ func (p *pooler) poolLogicalOrExpr(expr *prior.LogicalOrExpr) IExpression {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (p *pooler) poolMatchExpr(expr *prior.MatchExpr) IExpression {
+	lhs := p.poolConstants(expr.Lhs)
+	rhs := p.poolConstants(expr.Rhs)
+	return &MatchExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (p *pooler) poolMultiplicationExpr(expr *prior.MultiplicationExpr) IExpression {
 	lhs := p.poolConstants(expr.Lhs)
 	rhs := p.poolConstants(expr.Rhs)
@@ -350,6 +614,14 @@ func (p *pooler) poolNegationOperationExpr(expr *prior.NegationOperationExpr) IE
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (p *pooler) poolNoneExpr(expr *prior.NoneExpr) IExpression {
+	return &NoneExpr{
+		SourcePosition: expr.SourcePosition,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (p *pooler) poolNotEqualsExpr(expr *prior.NotEqualsExpr) IExpression {
 	lhs := p.poolConstants(expr.Lhs)
 	rhs := p.poolConstants(expr.Rhs)
@@ -362,6 +634,28 @@ func (p *pooler) poolNotEqualsExpr(expr *prior.NotEqualsExpr) IExpression {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (p *pooler) poolNotMatchExpr(expr *prior.NotMatchExpr) IExpression {
+	lhs := p.poolConstants(expr.Lhs)
+	rhs := p.poolConstants(expr.Rhs)
+	return &NotMatchExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (p *pooler) poolOptionalExpr(expr *prior.OptionalExpr) IExpression {
+	operand := p.poolConstants(expr.Operand)
+	return &OptionalExpr{
+		SourcePosition: expr.SourcePosition,
+		Operand:        operand,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (p *pooler) poolParenthesizedExpr(expr *prior.ParenthesizedExpr) IExpression {
 	inner := p.poolConstants(expr.InnerExpr)
 	return &ParenthesizedExpr{
@@ -372,6 +666,30 @@ func (p *pooler) poolParenthesizedExpr(expr *prior.ParenthesizedExpr) IExpressio
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (p *pooler) poolQualifyExpr(expr *prior.QualifyExpr) IExpression {
+	lhs := p.poolConstants(expr.Lhs)
+	rhs := p.poolConstants(expr.Rhs)
+	return &QualifyExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (p *pooler) poolRangeExpr(expr *prior.RangeExpr) IExpression {
+	first := p.poolConstants(expr.First)
+	last := p.poolConstants(expr.Last)
+	return &RangeExpr{
+		SourcePosition: expr.SourcePosition,
+		First:          first,
+		Last:           last,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (p *pooler) poolRecordExpr(expr *prior.RecordExpr) IExpression {
 	items := make([]IExpression, 0)
 	for _, item := range expr.Items {
@@ -391,17 +709,29 @@ func (p *pooler) poolStringLiteralExpr(expr *prior.StringLiteralExpr) IExpressio
 	var value string
 
 	switch expr.Delimiters {
-	case prior.StringDelimitersDoubleQuotes:
-		value = text[1 : len(text)-1]
-	case prior.StringDelimitersSingleQuotes:
-		value = text[1 : len(text)-1]
+	case prior.StringDelimitersDoubleQuotes, prior.StringDelimitersSingleQuotes:
+		var escapeErrors []scanning.StringEscapeError
+		value, escapeErrors = scanning.DecodeStringLiteral(text)
+		for _, escapeError := range escapeErrors {
+			startOffset := expr.SourcePosition.GetStartOffset() + uint32(escapeError.Offset)
+			p.Diagnostics = append(p.Diagnostics, diagnostics.NewError(
+				diagnostics.CodeInvalidEscapeSequence,
+				util.NewSourcePosFromOffsets(startOffset, startOffset+uint32(escapeError.Length)),
+				escapeError.Message,
+			))
+		}
+	case prior.StringDelimitersBackTicksMultiline:
+		value = scanning.DecodeMultilineStringLiteral(text)
 	default:
-		panic("TODO: Unhandled string delimiters")
+		p.Diagnostics = append(p.Diagnostics, diagnostics.NewError(
+			diagnostics.CodeUnsupportedExpression,
+			expr.SourcePosition,
+			"Multiline strings are not yet supported",
+		))
 	}
 
 	valueIndex := p.StringConstants.Put(value)
 
-	// TODO: escape chars
 	return &StringLiteralExpr{
 		SourcePosition: expr.SourcePosition,
 		ValueIndex:     valueIndex,
@@ -422,6 +752,30 @@ func (p *pooler) poolSubtractionExpr(expr *prior.SubtractionExpr) IExpression {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (p *pooler) poolUnionExpr(expr *prior.UnionExpr) IExpression {
+	lhs := p.poolConstants(expr.Lhs)
+	rhs := p.poolConstants(expr.Rhs)
+	return &UnionExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (p *pooler) poolWhenExpr(expr *prior.WhenExpr) IExpression {
+	lhs := p.poolConstants(expr.Lhs)
+	rhs := p.poolConstants(expr.Rhs)
+	return &WhenExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (p *pooler) poolWhereExpr(expr *prior.WhereExpr) IExpression {
 	lhs := p.poolConstants(expr.Lhs)
 	rhs := p.poolConstants(expr.Rhs)
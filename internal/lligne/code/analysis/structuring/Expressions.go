@@ -7,6 +7,7 @@ package structuring
 
 import (
 	"lligne-cli/internal/lligne/code/util"
+	"lligne-cli/internal/lligne/runtime/collations"
 	"lligne-cli/internal/lligne/runtime/pools"
 )
 
@@ -32,7 +33,7 @@ func (e *AdditionExpr) isStructuredExpression()           {}
 
 //=====================================================================================================================
 
-// ArrayLiteralExpr represents a parenthesized expression or comma-separated sequence of expressions.
+// ArrayLiteralExpr represents an array literal.
 type ArrayLiteralExpr struct {
 	SourcePosition util.SourcePos
 	Elements       []IExpression
@@ -43,6 +44,17 @@ func (e *ArrayLiteralExpr) isStructuredExpression()           {}
 
 //=====================================================================================================================
 
+// ArrayTypeExpr represents an array type using "[]" suffix.
+type ArrayTypeExpr struct {
+	SourcePosition util.SourcePos
+	ElementType    IExpression
+}
+
+func (e *ArrayTypeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *ArrayTypeExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
 // BooleanLiteralExpr represents a single boolean literal.
 type BooleanLiteralExpr struct {
 	SourcePosition util.SourcePos
@@ -64,6 +76,54 @@ func (e *BuiltInTypeExpr) isStructuredExpression()           {}
 
 //=====================================================================================================================
 
+// CollatedEqualsExpr represents an equality comparison with modifiers ("==[Aa]==") operation.
+type CollatedEqualsExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+	Collation      collations.Collation
+}
+
+func (e *CollatedEqualsExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *CollatedEqualsExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
+// CollatedNotEqualsExpr represents an inequality comparison with modifiers ("=![Aa]!=") operation.
+type CollatedNotEqualsExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+	Collation      collations.Collation
+}
+
+func (e *CollatedNotEqualsExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *CollatedNotEqualsExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
+// ConstrainedValueExpr represents "val", the value being checked by the predicate of a constraint.
+type ConstrainedValueExpr struct {
+	SourcePosition util.SourcePos
+}
+
+func (e *ConstrainedValueExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *ConstrainedValueExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
+// ConstraintExpr represents a type restricted by a predicate, e.g. "Int64 && val <= 100".
+type ConstraintExpr struct {
+	SourcePosition  util.SourcePos
+	ConstrainedType IExpression
+	Predicate       IExpression
+}
+
+func (e *ConstraintExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *ConstraintExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
 // DivisionExpr represents a division operation.
 type DivisionExpr struct {
 	SourcePosition util.SourcePos
@@ -111,11 +171,23 @@ func (e *Float64LiteralExpr) isStructuredExpression()           {}
 
 //=====================================================================================================================
 
-// FunctionCallExpr represents a function call (a function name followed by a parenthesized expression).
+// FormatExpr represents a value formatting ("fmt") operation.
+type FormatExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *FormatExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *FormatExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
+// FunctionCallExpr represents a function call (a function name followed by parenthesized arguments).
 type FunctionCallExpr struct {
 	SourcePosition    util.SourcePos
 	FunctionReference IExpression
-	Argument          IExpression
+	Arguments         []IExpression
 }
 
 func (e *FunctionCallExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
@@ -123,6 +195,44 @@ func (e *FunctionCallExpr) isStructuredExpression()           {}
 
 //=====================================================================================================================
 
+// FunctionExpr represents a function, restructured from a record field like 'name: (n: Int64) -> Int64 = body'. The
+// parameters are visible to the names in the body.
+type FunctionExpr struct {
+	SourcePosition util.SourcePos
+	Parameters     []*FunctionParameterExpr
+	ResultType     IExpression
+	Body           IExpression
+}
+
+func (e *FunctionExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *FunctionExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
+// FunctionParameterExpr represents a named function parameter like 'n: Int64'.
+type FunctionParameterExpr struct {
+	SourcePosition     util.SourcePos
+	ParameterNameIndex pools.NameIndex
+	ParameterType      IExpression
+}
+
+func (e *FunctionParameterExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *FunctionParameterExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
+// FunctionTypeExpr represents a function type like '(n: Int64) -> Int64', restructured from a "->" operation.
+type FunctionTypeExpr struct {
+	SourcePosition util.SourcePos
+	Parameters     []*FunctionParameterExpr
+	ResultType     IExpression
+}
+
+func (e *FunctionTypeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *FunctionTypeExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
 // GreaterThanExpr represents a greater than operation.
 type GreaterThanExpr struct {
 	SourcePosition util.SourcePos
@@ -158,6 +268,30 @@ func (e *IdentifierExpr) isStructuredExpression()           {}
 
 //=====================================================================================================================
 
+// InExpr represents a set membership "in" operation.
+type InExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *InExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *InExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
+// IndexExpr represents an array indexing ("a[i]") operation.
+type IndexExpr struct {
+	SourcePosition util.SourcePos
+	Array          IExpression
+	Index          IExpression
+}
+
+func (e *IndexExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *IndexExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
 // Int64LiteralExpr represents a single 64-bit integer literal.
 type Int64LiteralExpr struct {
 	SourcePosition util.SourcePos
@@ -169,6 +303,18 @@ func (e *Int64LiteralExpr) isStructuredExpression()           {}
 
 //=====================================================================================================================
 
+// IntersectExpr represents a type/value intersection "&" or "&&" operation.
+type IntersectExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *IntersectExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *IntersectExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
 // IsExpr represents an "is" test.
 type IsExpr struct {
 	SourcePosition util.SourcePos
@@ -253,6 +399,18 @@ func (e *LogicalOrExpr) isStructuredExpression()           {}
 
 //=====================================================================================================================
 
+// MatchExpr represents a pattern match "=~" operation.
+type MatchExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *MatchExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *MatchExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
 // MultiplicationExpr represents a multiplication operation.
 type MultiplicationExpr struct {
 	SourcePosition util.SourcePos
@@ -276,6 +434,16 @@ func (e *NegationOperationExpr) isStructuredExpression()           {}
 
 //=====================================================================================================================
 
+// NoneExpr represents the absent value of an optional type ("none").
+type NoneExpr struct {
+	SourcePosition util.SourcePos
+}
+
+func (e *NoneExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *NoneExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
 // NotEqualsExpr represents a equals operation.
 type NotEqualsExpr struct {
 	SourcePosition util.SourcePos
@@ -288,7 +456,31 @@ func (e *NotEqualsExpr) isStructuredExpression()           {}
 
 //=====================================================================================================================
 
-// OptionalExpr represents a parenthesized expression or comma-separated sequence of expressions.
+// NotMatchExpr represents a pattern nonmatch ("!~") operation.
+type NotMatchExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *NotMatchExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *NotMatchExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
+// OptionalDefaultExpr represents the value of an optional expression or else a default value ("?:") when it has none.
+type OptionalDefaultExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *OptionalDefaultExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *OptionalDefaultExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
+// OptionalExpr represents an optional type ("?") operation.
 type OptionalExpr struct {
 	SourcePosition util.SourcePos
 	Operand        IExpression
@@ -310,6 +502,18 @@ func (e *ParenthesizedExpr) isStructuredExpression()           {}
 
 //=====================================================================================================================
 
+// RangeExpr represents a range ("..") operation.
+type RangeExpr struct {
+	SourcePosition util.SourcePos
+	First          IExpression
+	Last           IExpression
+}
+
+func (e *RangeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *RangeExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
 // RecordExpr represents a record.
 type RecordExpr struct {
 	SourcePosition util.SourcePos
@@ -325,7 +529,9 @@ func (e *RecordExpr) isStructuredExpression()           {}
 type RecordFieldExpr struct {
 	SourcePosition util.SourcePos
 	FieldNameIndex pools.NameIndex
-	FieldValue     IExpression
+	FieldType      IExpression // nil unless the type is declared
+	FieldValue     IExpression // nil unless the value is given
+	DefaultValue   IExpression // nil unless there is a default value
 }
 
 func (e *RecordFieldExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
@@ -381,6 +587,43 @@ func (e *TrailingDocumentationExpr) isStructuredExpression() {}
 
 //=====================================================================================================================
 
+// UnionExpr represents a type union ("|") operation.
+type UnionExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *UnionExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *UnionExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
+// WhenExpr represents a chain of alternatives like 'a when x | b when y | c', restructured from "when" and "|"
+// operations. Its value is the value of the first alternative whose guard is true, else that of a last alternative
+// without a guard.
+type WhenExpr struct {
+	SourcePosition util.SourcePos
+	Alternatives   []*WhenAlternativeExpr
+}
+
+func (e *WhenExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *WhenExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
+// WhenAlternativeExpr represents one alternative of a "when" chain, like 'a when x'.
+type WhenAlternativeExpr struct {
+	SourcePosition util.SourcePos
+	Value          IExpression
+	Guard          IExpression // nil for a last alternative without a guard
+}
+
+func (e *WhenAlternativeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *WhenAlternativeExpr) isStructuredExpression()           {}
+
+//=====================================================================================================================
+
 // WhereExpr represents a where ("where") operation.
 type WhereExpr struct {
 	SourcePosition util.SourcePos
@@ -10,6 +10,8 @@ package structuring
 import (
 	"fmt"
 	prior "lligne-cli/internal/lligne/code/analysis/pooling"
+	"lligne-cli/internal/lligne/code/diagnostics"
+	"lligne-cli/internal/lligne/code/util"
 	"lligne-cli/internal/lligne/runtime/pools"
 )
 
@@ -21,6 +23,7 @@ type Outcome struct {
 	Model           IExpression
 	StringConstants *pools.StringConstantPool
 	IdentifierNames *pools.NameConstantPool
+	Diagnostics     []*diagnostics.Diagnostic
 }
 
 //=====================================================================================================================
@@ -29,7 +32,10 @@ func StructureRecords(priorOutcome *prior.Outcome) *Outcome {
 
 	s := newStructurer(priorOutcome)
 
-	model := s.structureRecords(priorOutcome.Model)
+	var model IExpression
+	diagnostics.RunAbortable(func() {
+		model = s.structureRecords(priorOutcome.Model)
+	})
 
 	return &Outcome{
 		SourceCode:      priorOutcome.SourceCode,
@@ -37,6 +43,7 @@ func StructureRecords(priorOutcome *prior.Outcome) *Outcome {
 		Model:           model,
 		StringConstants: priorOutcome.StringConstants,
 		IdentifierNames: priorOutcome.IdentifierNames,
+		Diagnostics:     s.Diagnostics,
 	}
 }
 
@@ -47,6 +54,11 @@ type structurer struct {
 	NewLineOffsets  []uint32
 	StringConstants *pools.StringConstantPool
 	IdentifierNames *pools.NameConstantPool
+	Diagnostics     []*diagnostics.Diagnostic
+
+	// While structuring what might be the predicate of a constraint, "val" is the value being constrained
+	inPredicate          bool
+	constrainedValueUses int
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -57,6 +69,7 @@ func newStructurer(priorOutcome *prior.Outcome) *structurer {
 		NewLineOffsets:  priorOutcome.NewLineOffsets,
 		StringConstants: priorOutcome.StringConstants,
 		IdentifierNames: priorOutcome.IdentifierNames,
+		Diagnostics:     priorOutcome.Diagnostics,
 	}
 }
 
@@ -70,10 +83,18 @@ func (s *structurer) structureRecords(
 
 	case *prior.AdditionExpr:
 		return s.structureAdditionExpr(expr)
+	case *prior.ArrayLiteralExpr:
+		return s.structureArrayLiteralExpr(expr)
+	case *prior.ArrayTypeExpr:
+		return s.structureArrayTypeExpr(expr)
 	case *prior.BooleanLiteralExpr:
 		return s.structureBooleanLiteralExpr(expr)
 	case *prior.BuiltInTypeExpr:
 		return s.structureBuiltInTypeExpr(expr)
+	case *prior.CollatedEqualsExpr:
+		return s.structureCollatedEqualsExpr(expr)
+	case *prior.CollatedNotEqualsExpr:
+		return s.structureCollatedNotEqualsExpr(expr)
 	case *prior.DivisionExpr:
 		return s.structureDivisionExpr(expr)
 	case *prior.EqualsExpr:
@@ -82,14 +103,30 @@ func (s *structurer) structureRecords(
 		return s.structureFieldReferenceExpr(expr)
 	case *prior.Float64LiteralExpr:
 		return s.structureFloatingPointLiteralExpr(expr)
+	case *prior.FormatExpr:
+		return s.structureFormatExpr(expr)
+	case *prior.FunctionArrowExpr:
+		return s.structureFunctionArrowExpr(expr)
+	case *prior.FunctionCallExpr:
+		return s.structureFunctionCallExpr(expr)
 	case *prior.GreaterThanExpr:
 		return s.structureGreaterThanExpr(expr)
 	case *prior.GreaterThanOrEqualsExpr:
 		return s.structureGreaterThanOrEqualsExpr(expr)
 	case *prior.IdentifierExpr:
 		return s.structureIdentifierExpr(expr)
+	case *prior.InExpr:
+		return s.structureInExpr(expr)
+	case *prior.IndexExpr:
+		return s.structureIndexExpr(expr)
 	case *prior.Int64LiteralExpr:
 		return s.structureIntegerLiteralExpr(expr)
+	case *prior.IntersectDefaultValueExpr:
+		return s.structureOptionalDefaultExpr(expr)
+	case *prior.IntersectExpr:
+		return s.structureIntersectExpr(expr.SourcePosition, expr.Lhs, expr.Rhs)
+	case *prior.IntersectLowPrecedenceExpr:
+		return s.structureIntersectExpr(expr.SourcePosition, expr.Lhs, expr.Rhs)
 	case *prior.IsExpr:
 		return s.structureIsExpr(expr)
 	case *prior.LessThanExpr:
@@ -102,23 +139,46 @@ func (s *structurer) structureRecords(
 		return s.structureLogicalNotOperationExpr(expr)
 	case *prior.LogicalOrExpr:
 		return s.structureLogicalOrExpr(expr)
+	case *prior.MatchExpr:
+		return s.structureMatchExpr(expr)
 	case *prior.MultiplicationExpr:
 		return s.structureMultiplicationExpr(expr)
 	case *prior.NegationOperationExpr:
 		return s.structureNegationOperationExpr(expr)
+	case *prior.NoneExpr:
+		return s.structureNoneExpr(expr)
 	case *prior.NotEqualsExpr:
 		return s.structureNotEqualsExpr(expr)
+	case *prior.NotMatchExpr:
+		return s.structureNotMatchExpr(expr)
+	case *prior.OptionalExpr:
+		return s.structureOptionalExpr(expr)
 	case *prior.ParenthesizedExpr:
 		return s.structureParenthesizedExpr(expr)
+	case *prior.RangeExpr:
+		return s.structureRangeExpr(expr)
 	case *prior.RecordExpr:
 		return s.structureRecordExpr(expr)
 	case *prior.StringLiteralExpr:
 		return s.structureStringLiteralExpr(expr)
 	case *prior.SubtractionExpr:
 		return s.structureSubtractionExpr(expr)
+	case *prior.UnionExpr:
+		return s.structureUnionExpr(expr)
+	case *prior.WhenExpr:
+		return s.structureWhenExpr(expr)
 	case *prior.WhereExpr:
 		return s.structureWhereExpr(expr)
 
+	case *prior.FunctionArgumentsExpr, *prior.IntersectAssignValueExpr, *prior.QualifyExpr:
+		s.Diagnostics = append(s.Diagnostics, diagnostics.NewError(
+			diagnostics.CodeUnsupportedExpression,
+			expression.GetSourcePosition(),
+			"Expression not yet supported",
+		))
+		diagnostics.Abort()
+		return nil
+
 	default:
 		panic(fmt.Sprintf("Missing case in structureRecords: %T\n", expression))
 
@@ -142,6 +202,34 @@ func (s *structurer) structureAdditionExpr(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *structurer) structureArrayLiteralExpr(
+	expr *prior.ArrayLiteralExpr,
+) IExpression {
+	elements := make([]IExpression, 0)
+	for _, element := range expr.Elements {
+		elements = append(elements, s.structureRecords(element))
+	}
+
+	return &ArrayLiteralExpr{
+		SourcePosition: expr.SourcePosition,
+		Elements:       elements,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *structurer) structureArrayTypeExpr(
+	expr *prior.ArrayTypeExpr,
+) IExpression {
+	elementType := s.structureRecords(expr.ElementType)
+	return &ArrayTypeExpr{
+		SourcePosition: expr.SourcePosition,
+		ElementType:    elementType,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *structurer) structureBooleanLiteralExpr(expr *prior.BooleanLiteralExpr) IExpression {
 	return &BooleanLiteralExpr{
 		SourcePosition: expr.SourcePosition,
@@ -159,6 +247,36 @@ func (s *structurer) structureBuiltInTypeExpr(expr *prior.BuiltInTypeExpr) IExpr
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *structurer) structureCollatedEqualsExpr(
+	expr *prior.CollatedEqualsExpr,
+) IExpression {
+	lhs := s.structureRecords(expr.Lhs)
+	rhs := s.structureRecords(expr.Rhs)
+	return &CollatedEqualsExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+		Collation:      expr.Collation,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *structurer) structureCollatedNotEqualsExpr(
+	expr *prior.CollatedNotEqualsExpr,
+) IExpression {
+	lhs := s.structureRecords(expr.Lhs)
+	rhs := s.structureRecords(expr.Rhs)
+	return &CollatedNotEqualsExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+		Collation:      expr.Collation,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *structurer) structureDivisionExpr(
 	expr *prior.DivisionExpr,
 ) IExpression {
@@ -191,7 +309,13 @@ func (s *structurer) structureFieldReferenceExpr(
 	expr *prior.FieldReferenceExpr,
 ) IExpression {
 	parent := s.structureRecords(expr.Parent)
+
+	// A field named "val" is not the constrained value
+	inPredicate := s.inPredicate
+	s.inPredicate = false
 	child := s.structureRecords(expr.Child)
+	s.inPredicate = inPredicate
+
 	return &FieldReferenceExpr{
 		SourcePosition: expr.SourcePosition,
 		Parent:         parent,
@@ -210,6 +334,108 @@ func (s *structurer) structureFloatingPointLiteralExpr(expr *prior.Float64Litera
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *structurer) structureFormatExpr(
+	expr *prior.FormatExpr,
+) IExpression {
+	lhs := s.structureRecords(expr.Lhs)
+	rhs := s.structureRecords(expr.Rhs)
+	return &FormatExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *structurer) structureFunctionArrowExpr(
+	expr *prior.FunctionArrowExpr,
+) IExpression {
+	parameters := s.structureFunctionParameters(expr.Argument)
+	resultType := s.structureRecords(expr.Result)
+	return &FunctionTypeExpr{
+		SourcePosition: expr.SourcePosition,
+		Parameters:     parameters,
+		ResultType:     resultType,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// structureFunctionCallExpr restructures the arguments of a function call, which are given in the order of the
+// parameters. Arguments named like 'f(n = 2)' are reported, but their values are kept in place to check the rest.
+func (s *structurer) structureFunctionCallExpr(
+	expr *prior.FunctionCallExpr,
+) IExpression {
+	functionReference := s.structureRecords(expr.FunctionReference)
+
+	arguments := make([]IExpression, 0)
+	for _, item := range expr.Argument.(*prior.FunctionArgumentsExpr).Items {
+		if namedArgument, ok := item.(*prior.IntersectAssignValueExpr); ok {
+			s.Diagnostics = append(s.Diagnostics, diagnostics.NewError(
+				diagnostics.CodeUnsupportedExpression,
+				item.GetSourcePosition(),
+				"Named arguments are not supported; give the arguments in the order of the parameters",
+			))
+			item = namedArgument.Rhs
+		}
+
+		arguments = append(arguments, s.structureRecords(item))
+	}
+
+	return &FunctionCallExpr{
+		SourcePosition:    expr.SourcePosition,
+		FunctionReference: functionReference,
+		Arguments:         arguments,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// structureFunctionParameters restructures the parameters before the "->" of a function type, either one parameter in
+// parentheses, like '(n: Int64)', or several of them, like '(a: Int64, b: String)'.
+func (s *structurer) structureFunctionParameters(
+	expr prior.IExpression,
+) []*FunctionParameterExpr {
+
+	var items []prior.IExpression
+
+	switch argument := expr.(type) {
+	case *prior.FunctionArgumentsExpr:
+		items = argument.Items
+	case *prior.ParenthesizedExpr:
+		items = []prior.IExpression{argument.InnerExpr}
+	default:
+		items = []prior.IExpression{argument}
+	}
+
+	parameters := make([]*FunctionParameterExpr, 0)
+
+	for _, item := range items {
+		if qualifyExpr, ok := item.(*prior.QualifyExpr); ok {
+			if parameterName, ok := qualifyExpr.Lhs.(*prior.IdentifierExpr); ok {
+				parameters = append(parameters, &FunctionParameterExpr{
+					SourcePosition:     qualifyExpr.SourcePosition,
+					ParameterNameIndex: parameterName.NameIndex,
+					ParameterType:      s.structureRecords(qualifyExpr.Rhs),
+				})
+				continue
+			}
+		}
+
+		s.Diagnostics = append(s.Diagnostics, diagnostics.NewError(
+			diagnostics.CodeInvalidFunctionParameter,
+			item.GetSourcePosition(),
+			"Expected a function parameter of the form 'name: Type'",
+		))
+	}
+
+	return parameters
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *structurer) structureGreaterThanExpr(
 	expr *prior.GreaterThanExpr,
 ) IExpression {
@@ -241,6 +467,13 @@ func (s *structurer) structureGreaterThanOrEqualsExpr(
 func (s *structurer) structureIdentifierExpr(
 	expr *prior.IdentifierExpr,
 ) IExpression {
+	if s.inPredicate && s.IdentifierNames.Get(expr.NameIndex) == "val" {
+		s.constrainedValueUses += 1
+		return &ConstrainedValueExpr{
+			SourcePosition: expr.SourcePosition,
+		}
+	}
+
 	return &IdentifierExpr{
 		SourcePosition: expr.SourcePosition,
 		NameIndex:      expr.NameIndex,
@@ -249,6 +482,34 @@ func (s *structurer) structureIdentifierExpr(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *structurer) structureInExpr(
+	expr *prior.InExpr,
+) IExpression {
+	lhs := s.structureRecords(expr.Lhs)
+	rhs := s.structureRecords(expr.Rhs)
+	return &InExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *structurer) structureIndexExpr(
+	expr *prior.IndexExpr,
+) IExpression {
+	array := s.structureRecords(expr.Array)
+	index := s.structureRecords(expr.Index)
+	return &IndexExpr{
+		SourcePosition: expr.SourcePosition,
+		Array:          array,
+		Index:          index,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *structurer) structureIntegerLiteralExpr(expr *prior.Int64LiteralExpr) IExpression {
 	return &Int64LiteralExpr{
 		SourcePosition: expr.SourcePosition,
@@ -258,6 +519,60 @@ func (s *structurer) structureIntegerLiteralExpr(expr *prior.Int64LiteralExpr) I
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// structureIntersectExpr structures either kind of intersection, "&" or "&&", which differ only in precedence.
+func (s *structurer) structureIntersectExpr(
+	sourcePosition util.SourcePos,
+	lhsExpr prior.IExpression,
+	rhsExpr prior.IExpression,
+) IExpression {
+	lhs, rhs, isConstraint := s.structureIntersectOperands(lhsExpr, rhsExpr)
+
+	if isConstraint {
+		return &ConstraintExpr{
+			SourcePosition:  sourcePosition,
+			ConstrainedType: lhs,
+			Predicate:       rhs,
+		}
+	}
+
+	return &IntersectExpr{
+		SourcePosition: sourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// structureIntersectOperands structures both sides of an intersection. A type intersected with an expression that
+// mentions "val", as in 'Int64 && val <= 100', is a constraint: the right hand side is a predicate that the values of
+// the type must satisfy, with "val" standing for the value being checked. Returns true for a constraint.
+func (s *structurer) structureIntersectOperands(
+	lhsExpr prior.IExpression,
+	rhsExpr prior.IExpression,
+) (IExpression, IExpression, bool) {
+	lhs := s.structureRecords(lhsExpr)
+
+	if !isConstrainableType(lhsExpr) {
+		return lhs, s.structureRecords(rhsExpr), false
+	}
+
+	inPredicate := s.inPredicate
+	constrainedValueUses := s.constrainedValueUses
+	s.inPredicate = true
+	s.constrainedValueUses = 0
+
+	rhs := s.structureRecords(rhsExpr)
+	isConstraint := s.constrainedValueUses > 0
+
+	s.inPredicate = inPredicate
+	s.constrainedValueUses = constrainedValueUses
+
+	return lhs, rhs, isConstraint
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *structurer) structureIsExpr(
 	expr *prior.IsExpr,
 ) IExpression {
@@ -340,6 +655,20 @@ func (s *structurer) structureLogicalOrExpr(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *structurer) structureMatchExpr(
+	expr *prior.MatchExpr,
+) IExpression {
+	lhs := s.structureRecords(expr.Lhs)
+	rhs := s.structureRecords(expr.Rhs)
+	return &MatchExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *structurer) structureMultiplicationExpr(
 	expr *prior.MultiplicationExpr,
 ) IExpression {
@@ -366,6 +695,14 @@ func (s *structurer) structureNegationOperationExpr(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *structurer) structureNoneExpr(expr *prior.NoneExpr) IExpression {
+	return &NoneExpr{
+		SourcePosition: expr.SourcePosition,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *structurer) structureNotEqualsExpr(
 	expr *prior.NotEqualsExpr,
 ) IExpression {
@@ -380,6 +717,48 @@ func (s *structurer) structureNotEqualsExpr(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *structurer) structureNotMatchExpr(
+	expr *prior.NotMatchExpr,
+) IExpression {
+	lhs := s.structureRecords(expr.Lhs)
+	rhs := s.structureRecords(expr.Rhs)
+	return &NotMatchExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// structureOptionalDefaultExpr structures "?:" outside the fields of a record, where it gives the value of an
+// optional expression or else a default value.
+func (s *structurer) structureOptionalDefaultExpr(
+	expr *prior.IntersectDefaultValueExpr,
+) IExpression {
+	lhs := s.structureRecords(expr.Lhs)
+	rhs := s.structureRecords(expr.Rhs)
+	return &OptionalDefaultExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *structurer) structureOptionalExpr(
+	expr *prior.OptionalExpr,
+) IExpression {
+	operand := s.structureRecords(expr.Operand)
+	return &OptionalExpr{
+		SourcePosition: expr.SourcePosition,
+		Operand:        operand,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *structurer) structureParenthesizedExpr(
 	expr *prior.ParenthesizedExpr,
 ) IExpression {
@@ -392,12 +771,42 @@ func (s *structurer) structureParenthesizedExpr(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *structurer) structureRangeExpr(
+	expr *prior.RangeExpr,
+) IExpression {
+	first := s.structureRecords(expr.First)
+	last := s.structureRecords(expr.Last)
+	return &RangeExpr{
+		SourcePosition: expr.SourcePosition,
+		First:          first,
+		Last:           last,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *structurer) structureRecordExpr(
 	expr *prior.RecordExpr,
 ) IExpression {
 	items := make([]*RecordFieldExpr, 0)
+	fieldNameIndexes := make(map[pools.NameIndex]bool)
 	for _, item := range expr.Items {
 		fieldExpr := s.structureRecordFieldExpr(item)
+		if fieldExpr == nil {
+			continue
+		}
+
+		// A field name may only appear once per record; later occurrences are left out.
+		if fieldNameIndexes[fieldExpr.FieldNameIndex] {
+			s.Diagnostics = append(s.Diagnostics, diagnostics.NewError(
+				diagnostics.CodeDuplicateFieldName,
+				fieldExpr.SourcePosition,
+				"Duplicate field name '%s'", s.IdentifierNames.Get(fieldExpr.FieldNameIndex),
+			))
+			continue
+		}
+		fieldNameIndexes[fieldExpr.FieldNameIndex] = true
+
 		items = append(items, fieldExpr)
 	}
 
@@ -413,21 +822,158 @@ func (s *structurer) structureRecordFieldExpr(
 	expr prior.IExpression,
 ) *RecordFieldExpr {
 
-	// TODO: Qualify and other intersection expressions
+	field := &RecordFieldExpr{
+		SourcePosition: expr.GetSourcePosition(),
+	}
+
+	ok := false
 
 	switch fieldExpr := expr.(type) {
 	case *prior.IntersectAssignValueExpr:
-		fieldNameIndex := fieldExpr.Lhs.(*prior.IdentifierExpr).NameIndex
-		value := s.structureRecords(fieldExpr.Rhs)
-		return &RecordFieldExpr{
-			SourcePosition: expr.GetSourcePosition(),
-			FieldNameIndex: fieldNameIndex,
-			FieldValue:     value,
+		// name = value, name: Type = value
+		ok = s.structureRecordFieldNameAndType(fieldExpr.Lhs, field) && field.FieldValue == nil
+		field.FieldValue = s.structureRecords(fieldExpr.Rhs)
+	case *prior.IntersectDefaultValueExpr:
+		if nameAndType, value, isValue := s.structureDefaultedFieldValue(fieldExpr); isValue {
+			// name = value ?: default, name: Type = value ?: default
+			ok = s.structureRecordFieldNameAndType(nameAndType, field) && field.FieldValue == nil
+			field.FieldValue = value
+		} else {
+			// name ?: default, name: Type ?: default
+			ok = s.structureRecordFieldNameAndType(fieldExpr.Lhs, field) && field.FieldValue == nil
+			field.DefaultValue = s.structureRecords(fieldExpr.Rhs)
 		}
-	default:
-		panic(fmt.Sprintf("Missing case in structureRecordFieldExpr: %T\n", expr))
+	case *prior.QualifyExpr:
+		// name: Type, name: Type & value, name: Type && value
+		ok = s.structureRecordFieldNameAndType(fieldExpr, field)
+
+		// name: value, e.g. 'a: {x: 1, y: 2}', where a literal or a record stands for itself
+		switch field.FieldType.(type) {
+		case *BooleanLiteralExpr, *Float64LiteralExpr, *Int64LiteralExpr, *RecordExpr, *StringLiteralExpr:
+			field.FieldValue = field.FieldType
+			field.FieldType = nil
+		}
+	}
+
+	if !ok {
+		s.Diagnostics = append(s.Diagnostics, diagnostics.NewError(
+			diagnostics.CodeInvalidRecordField,
+			expr.GetSourcePosition(),
+			"Expected a record field of the form 'name = value' or 'name: Type = value'",
+		))
+		return nil
+	}
+
+	// name: (n: Type) -> Type = body
+	if functionType, isFunction := field.FieldType.(*FunctionTypeExpr); isFunction {
+		if field.FieldValue == nil || field.DefaultValue != nil {
+			s.Diagnostics = append(s.Diagnostics, diagnostics.NewError(
+				diagnostics.CodeInvalidRecordField,
+				expr.GetSourcePosition(),
+				"Expected a function field of the form 'name: (n: Type) -> Type = body'",
+			))
+			return nil
+		}
+
+		field.FieldValue = &FunctionExpr{
+			SourcePosition: functionType.SourcePosition.Thru(field.FieldValue.GetSourcePosition()),
+			Parameters:     functionType.Parameters,
+			ResultType:     functionType.ResultType,
+			Body:           field.FieldValue,
+		}
+		field.FieldType = nil
+	}
+
+	return field
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// structureDefaultedFieldValue untangles a field like 'name = value ?: default', which parses as '(name = value) ?:
+// default' since "=" and "?:" have the same precedence. Returns the part of the field before the "=" plus the value
+// with its defaults, or false when the field has no "=".
+func (s *structurer) structureDefaultedFieldValue(
+	expr prior.IExpression,
+) (prior.IExpression, IExpression, bool) {
+
+	switch fieldExpr := expr.(type) {
+	case *prior.IntersectAssignValueExpr:
+		return fieldExpr.Lhs, s.structureRecords(fieldExpr.Rhs), true
+	case *prior.IntersectDefaultValueExpr:
+		nameAndType, value, ok := s.structureDefaultedFieldValue(fieldExpr.Lhs)
+		if !ok {
+			return nil, nil, false
+		}
+		return nameAndType, &OptionalDefaultExpr{
+			SourcePosition: value.GetSourcePosition().Thru(fieldExpr.Rhs.GetSourcePosition()),
+			Lhs:            value,
+			Rhs:            s.structureRecords(fieldExpr.Rhs),
+		}, true
+	}
+
+	return nil, nil, false
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// structureRecordFieldNameAndType fills in the name of a field plus its declared type, if any, from the part of the
+// field before any "=" or "?:". A type intersected with a value, as in 'name: Type && value', also gives the value.
+func (s *structurer) structureRecordFieldNameAndType(
+	expr prior.IExpression,
+	field *RecordFieldExpr,
+) bool {
+
+	switch fieldExpr := expr.(type) {
+	case *prior.IdentifierExpr:
+		field.FieldNameIndex = fieldExpr.NameIndex
+		return true
+	case *prior.QualifyExpr:
+		fieldName, ok := fieldExpr.Lhs.(*prior.IdentifierExpr)
+		if !ok {
+			return false
+		}
+		field.FieldNameIndex = fieldName.NameIndex
+
+		switch fieldType := fieldExpr.Rhs.(type) {
+		case *prior.IntersectExpr:
+			s.structureQualifiedFieldType(fieldType.SourcePosition, fieldType.Lhs, fieldType.Rhs, field)
+		case *prior.IntersectLowPrecedenceExpr:
+			s.structureQualifiedFieldType(fieldType.SourcePosition, fieldType.Lhs, fieldType.Rhs, field)
+		default:
+			field.FieldType = s.structureRecords(fieldType)
+		}
+		return true
 	}
 
+	return false
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// structureQualifiedFieldType fills in the declared type of a field like 'name: Type && value', which also gives the
+// value, or like 'name: Type && val <= 100', whose declared type is a constraint.
+func (s *structurer) structureQualifiedFieldType(
+	sourcePosition util.SourcePos,
+	lhsExpr prior.IExpression,
+	rhsExpr prior.IExpression,
+	field *RecordFieldExpr,
+) {
+	lhs, rhs, isConstraint := s.structureIntersectOperands(lhsExpr, rhsExpr)
+
+	if isConstraint {
+		field.FieldType = &ConstraintExpr{
+			SourcePosition:  sourcePosition,
+			ConstrainedType: lhs,
+			Predicate:       rhs,
+		}
+		return
+	}
+
+	field.FieldType = lhs
+	field.FieldValue = rhs
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -457,6 +1003,76 @@ func (s *structurer) structureSubtractionExpr(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (s *structurer) structureUnionExpr(
+	expr *prior.UnionExpr,
+) IExpression {
+	// 'a when x | b' is a chain of alternatives rather than a union of types
+	if alternatives := flattenUnion(expr); containsWhen(alternatives) {
+		return s.structureWhenAlternatives(expr.SourcePosition, alternatives)
+	}
+
+	lhs := s.structureRecords(expr.Lhs)
+	rhs := s.structureRecords(expr.Rhs)
+	return &UnionExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s *structurer) structureWhenExpr(
+	expr *prior.WhenExpr,
+) IExpression {
+	return s.structureWhenAlternatives(expr.SourcePosition, []prior.IExpression{expr})
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// structureWhenAlternatives restructures the alternatives of a "when" chain. Every alternative but the last one needs a
+// guard, since those after an alternative without a guard could never be chosen.
+func (s *structurer) structureWhenAlternatives(
+	sourcePosition util.SourcePos,
+	alternatives []prior.IExpression,
+) IExpression {
+
+	result := &WhenExpr{
+		SourcePosition: sourcePosition,
+		Alternatives:   make([]*WhenAlternativeExpr, 0),
+	}
+
+	for i, alternative := range alternatives {
+		if whenExpr, ok := alternative.(*prior.WhenExpr); ok {
+			result.Alternatives = append(result.Alternatives, &WhenAlternativeExpr{
+				SourcePosition: whenExpr.SourcePosition,
+				Value:          s.structureRecords(whenExpr.Lhs),
+				Guard:          s.structureRecords(whenExpr.Rhs),
+			})
+			continue
+		}
+
+		if i < len(alternatives)-1 {
+			s.Diagnostics = append(s.Diagnostics, diagnostics.NewError(
+				diagnostics.CodeInvalidWhenAlternative,
+				alternative.GetSourcePosition(),
+				"Expected a 'when' guard; only the last alternative can go without one",
+			))
+		}
+
+		result.Alternatives = append(result.Alternatives, &WhenAlternativeExpr{
+			SourcePosition: alternative.GetSourcePosition(),
+			Value:          s.structureRecords(alternative),
+			Guard:          nil,
+		})
+	}
+
+	return result
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (s *structurer) structureWhereExpr(
 	expr *prior.WhereExpr,
 ) IExpression {
@@ -470,3 +1086,39 @@ func (s *structurer) structureWhereExpr(
 }
 
 //---------------------------------------------------------------------------------------------------------------------
+
+// containsWhen determines whether any of the alternatives of a union is guarded by "when".
+func containsWhen(alternatives []prior.IExpression) bool {
+	for _, alternative := range alternatives {
+		if _, ok := alternative.(*prior.WhenExpr); ok {
+			return true
+		}
+	}
+	return false
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// flattenUnion lists the operands of a chain of "|" operations from left to right.
+func flattenUnion(expr prior.IExpression) []prior.IExpression {
+	if unionExpr, ok := expr.(*prior.UnionExpr); ok {
+		return append(flattenUnion(unionExpr.Lhs), flattenUnion(unionExpr.Rhs)...)
+	}
+	return []prior.IExpression{expr}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// isConstrainableType determines whether an expression is syntactically a type that a constraint can restrict: a
+// built-in type or a union, possibly parenthesized.
+func isConstrainableType(expr prior.IExpression) bool {
+	switch e := expr.(type) {
+	case *prior.BuiltInTypeExpr, *prior.UnionExpr:
+		return true
+	case *prior.ParenthesizedExpr:
+		return isConstrainableType(e.InnerExpr)
+	}
+	return false
+}
+
+//---------------------------------------------------------------------------------------------------------------------
@@ -10,8 +10,14 @@ package typechecking
 import (
 	"fmt"
 	prior "lligne-cli/internal/lligne/code/analysis/nameresolution"
+	"lligne-cli/internal/lligne/code/diagnostics"
+	"lligne-cli/internal/lligne/code/scanning"
+	"lligne-cli/internal/lligne/code/util"
+	"lligne-cli/internal/lligne/runtime/formats"
 	"lligne-cli/internal/lligne/runtime/pools"
 	"lligne-cli/internal/lligne/runtime/types"
+	"math"
+	"strings"
 )
 
 //=====================================================================================================================
@@ -23,13 +29,21 @@ type Outcome struct {
 	StringConstants *pools.StringConstantPool
 	IdentifierNames *pools.NameConstantPool
 	TypeConstants   *types.TypeConstantPool
+	Diagnostics     []*diagnostics.Diagnostic
+
+	// The predicate of each constraint type, to be compiled apart from the model
+	ConstraintPredicates map[types.TypeIndex]IExpression
 }
 
 //=====================================================================================================================
 
 func CheckTypes(priorOutcome *prior.Outcome) *Outcome {
 	checker := newTypeChecker(priorOutcome)
-	model := checker.checkTypes(priorOutcome.Model, make([]types.TypeIndex, 0))
+
+	var model IExpression
+	diagnostics.RunAbortable(func() {
+		model = checker.checkTypes(priorOutcome.Model, make([]types.TypeIndex, 0))
+	})
 
 	return &Outcome{
 		SourceCode:      priorOutcome.SourceCode,
@@ -38,7 +52,75 @@ func CheckTypes(priorOutcome *prior.Outcome) *Outcome {
 		StringConstants: priorOutcome.StringConstants,
 		IdentifierNames: priorOutcome.IdentifierNames,
 		TypeConstants:   checker.TypePool.Freeze(),
+		Diagnostics:     checker.Diagnostics,
+
+		ConstraintPredicates: checker.constraintPredicates,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// CheckFieldValuesPresent ensures that the value of a whole model has no required fields still lacking a value. Type
+// checking alone accepts such fields, e.g. in a schema awaiting intersection with its values, but evaluating the model
+// needs them. Each missing field is reported at its declaration.
+func CheckFieldValuesPresent(outcome *Outcome) *Outcome {
+
+	recordType, ok := outcome.TypeConstants.Get(outcome.Model.GetTypeIndex()).(*types.RecordType)
+	if !ok {
+		return outcome
+	}
+
+	diags := outcome.Diagnostics
+
+	for i, fieldNameIndex := range recordType.FieldNameIndexes {
+		if recordType.FieldPresence(i) == types.RecordFieldPresenceRequired {
+			sourcePosition, found := findRequiredFieldDeclaration(outcome.Model, fieldNameIndex)
+			if !found {
+				sourcePosition = outcome.Model.GetSourcePosition()
+			}
+
+			diags = append(diags, diagnostics.NewError(diagnostics.CodeMissingFieldValue, sourcePosition,
+				"Missing value for required field '%s'", outcome.IdentifierNames.Get(fieldNameIndex)))
+		}
+	}
+
+	result := *outcome
+	result.Diagnostics = diags
+	return &result
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// findRequiredFieldDeclaration finds the declaration of a field without a value among the records making up the
+// value of an expression.
+func findRequiredFieldDeclaration(expression IExpression, fieldNameIndex pools.NameIndex) (util.SourcePos, bool) {
+
+	switch expr := expression.(type) {
+
+	case *ParenthesizedExpr:
+		return findRequiredFieldDeclaration(expr.InnerExpr, fieldNameIndex)
+
+	case *RecordExpr:
+		for _, field := range expr.Fields {
+			if field.FieldNameIndex == fieldNameIndex && field.FieldValue == nil && field.DefaultValue == nil {
+				return field.SourcePosition, true
+			}
+		}
+
+	case *RecordMergeExpr:
+		if sourcePosition, found := findRequiredFieldDeclaration(expr.Lhs, fieldNameIndex); found {
+			return sourcePosition, true
+		}
+		return findRequiredFieldDeclaration(expr.Rhs, fieldNameIndex)
+
+	case *WhereExpr:
+		return findRequiredFieldDeclaration(expr.Lhs, fieldNameIndex)
+
 	}
+
+	return util.SourcePos{}, false
+
 }
 
 //=====================================================================================================================
@@ -49,6 +131,35 @@ type typeChecker struct {
 	StringConstants *pools.StringConstantPool
 	IdentifierNames *pools.NameConstantPool
 	TypePool        *types.TypePool
+	Diagnostics     []*diagnostics.Diagnostic
+
+	// The types of the fields checked so far in each record under construction, innermost last
+	recordsUnderConstruction []*types.RecordType
+
+	// The expressions giving the values of the fields of each record type, nil for fields without a value
+	recordFieldValues map[types.TypeIndex][]IExpression
+
+	// The types of the values constrained by the predicates being checked, innermost last
+	constrainedValueTypeIndexes []types.TypeIndex
+
+	// The predicate of each constraint type
+	constraintPredicates map[types.TypeIndex]IExpression
+
+	// The signature of each function, known before its body is checked so that the body can call the function
+	functionSignatures map[*prior.FunctionExpr]*functionSignature
+
+	// The types of the parameters of each function whose body is being checked, innermost last
+	functionParameterTypeIndexes [][]types.TypeIndex
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// functionSignature holds the declared types of the parameters and result of a function plus the resulting function
+// type, which is the error type if any of the declared types is in error.
+type functionSignature struct {
+	parameterTypeIndexes []types.TypeIndex
+	resultTypeIndex      types.TypeIndex
+	typeIndex            types.TypeIndex
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -60,7 +171,353 @@ func newTypeChecker(priorOutcome *prior.Outcome) *typeChecker {
 		StringConstants: priorOutcome.StringConstants,
 		IdentifierNames: priorOutcome.IdentifierNames,
 		TypePool:        types.NewTypePool(),
+		Diagnostics:     priorOutcome.Diagnostics,
+
+		recordFieldValues:    make(map[types.TypeIndex][]IExpression),
+		constraintPredicates: make(map[types.TypeIndex]IExpression),
+		functionSignatures:   make(map[*prior.FunctionExpr]*functionSignature),
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// report records a type error and carries on with type checking. The offending expression should then be given the
+// error type so that the mistake is not reported again further up.
+func (t *typeChecker) report(
+	code diagnostics.Code,
+	sourcePosition util.SourcePos,
+	format string,
+	args ...any,
+) *diagnostics.Diagnostic {
+	diagnostic := diagnostics.NewError(code, sourcePosition, format, args...)
+	t.Diagnostics = append(t.Diagnostics, diagnostic)
+	return diagnostic
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// checkArithmeticOperandTypes applies the type rule of an arithmetic operator. Two records are combined field by field,
+// as described by arithmeticRecordType; any other operands follow checkOperandTypes. Returns the type of the result or
+// else the error type.
+func (t *typeChecker) checkArithmeticOperandTypes(
+	sourcePosition util.SourcePos,
+	operator string,
+	mismatchFormat string,
+	lhs IExpression,
+	rhs IExpression,
+	allowedTypeIndexes ...types.TypeIndex,
+) types.TypeIndex {
+
+	lhsTypeIndex := lhs.GetTypeIndex()
+	rhsTypeIndex := rhs.GetTypeIndex()
+
+	if !t.isRecordType(lhsTypeIndex) || !t.isRecordType(rhsTypeIndex) {
+		return t.checkOperandTypes(sourcePosition, operator, mismatchFormat, lhs, rhs, allowedTypeIndexes...)
+	}
+
+	typeIndex, diagnostic := t.arithmeticRecordType(sourcePosition, operator, mismatchFormat, lhsTypeIndex,
+		rhsTypeIndex, "")
+
+	if diagnostic != nil {
+		diagnostic.
+			WithLabel(lhs.GetSourcePosition(), "Left operand has type %s", t.typeName(lhsTypeIndex)).
+			WithLabel(rhs.GetSourcePosition(), "Right operand has type %s", t.typeName(rhsTypeIndex))
+	}
+
+	return typeIndex
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// checkArrayOperand ensures that an operand is an array, as needed to get at its elements. Returns the type of the
+// array or else the error type.
+func (t *typeChecker) checkArrayOperand(operand IExpression, format string) types.TypeIndex {
+
+	typeIndex := operand.GetTypeIndex()
+
+	if typeIndex == types.BuiltInTypeIndexError {
+		return types.BuiltInTypeIndexError
+	}
+
+	if _, ok := t.TypePool.Get(typeIndex).(*types.ArrayType); !ok {
+		t.report(diagnostics.CodeTypeMismatch, operand.GetSourcePosition(), format, t.typeName(typeIndex))
+		return types.BuiltInTypeIndexError
+	}
+
+	return typeIndex
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// checkDeclaredFieldType ensures that the declared type of a field is a built-in type, a union, an optional type, an
+// array type, a constraint, or a range, possibly in parentheses, leaving out constraints on unions whose members have
+// different base types. Returns the declared type or else the error type.
+func (t *typeChecker) checkDeclaredFieldType(fieldType IExpression) types.TypeIndex {
+
+	var typeIndex types.TypeIndex
+
+	switch expr := fieldType.(type) {
+	case *ArrayTypeExpr:
+		typeIndex = expr.ValueIndex
+	case *BuiltInTypeExpr:
+		typeIndex = expr.ValueIndex
+	case *ConstraintTypeExpr:
+		typeIndex = expr.ValueIndex
+	case *OptionalTypeExpr:
+		typeIndex = expr.ValueIndex
+	case *ParenthesizedExpr:
+		return t.checkDeclaredFieldType(expr.InnerExpr)
+	case *RangeExpr:
+		typeIndex = t.rangeConstraintTypeIndex(expr)
+	case *UnionTypeExpr:
+		typeIndex = expr.ValueIndex
+	default:
+		t.report(diagnostics.CodeUnsupportedExpression, fieldType.GetSourcePosition(),
+			"Only built-in types, unions, optional types, array types, constraints, and ranges are supported as the "+
+				"declared type of a field")
+		return types.BuiltInTypeIndexError
+	}
+
+	// The predicate of a constraint is given the untagged value of a union, so constraints on tagged unions must wait
+	valueTypeIndex, _ := t.optionalValueTypeIndex(typeIndex)
+	if constraintType, ok := t.TypePool.Get(valueTypeIndex).(*types.ConstraintType); ok &&
+		t.TypePool.IsTaggedUnion(constraintType.ConstrainedTypeIndex) {
+		t.report(diagnostics.CodeUnsupportedExpression, fieldType.GetSourcePosition(),
+			"Fields of type %s are not yet supported; the members of a constrained union must all be values of one type",
+			t.typeName(typeIndex))
+		return types.BuiltInTypeIndexError
+	}
+
+	return typeIndex
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// checkEqualityOperandTypes applies the type rule of "==" and "!=", whose operands must have compatible types as for
+// other binary operators, except that a value of a tagged union can also be compared with any value that could be a
+// value of the union. Functions cannot be compared.
+func (t *typeChecker) checkEqualityOperandTypes(
+	sourcePosition util.SourcePos,
+	operator string,
+	lhs IExpression,
+	rhs IExpression,
+) {
+
+	lhsTypeIndex := lhs.GetTypeIndex()
+	rhsTypeIndex := rhs.GetTypeIndex()
+
+	if (t.TypePool.IsTaggedUnion(lhsTypeIndex) || t.TypePool.IsTaggedUnion(rhsTypeIndex)) &&
+		(t.isAssignable(lhs, rhsTypeIndex) || t.isAssignable(rhs, lhsTypeIndex)) {
+		return
+	}
+
+	typeIndex := t.checkOperandTypes(sourcePosition, operator, "Cannot compare %s and %s", lhs, rhs)
+
+	if _, isFunction := t.TypePool.Get(typeIndex).(*types.FunctionType); isFunction {
+		t.report(diagnostics.CodeTypeMismatch, sourcePosition,
+			"Operator '%s' is not defined for type %s", operator, t.typeName(lhsTypeIndex))
+	}
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// checkFieldPresence ensures that a field referred to by name has a value to use, i.e. that it is not a required field
+// still waiting for an intersection to give it a value.
+func (t *typeChecker) checkFieldPresence(expr *prior.IdentifierExpr, recordType *types.RecordType, fieldIndex int) {
+	if recordType.FieldPresence(fieldIndex) == types.RecordFieldPresenceRequired {
+		t.report(diagnostics.CodeMissingFieldValue, expr.SourcePosition,
+			"Missing value for required field '%s'", t.IdentifierNames.Get(expr.NameIndex))
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// checkFieldValue checks the value or default value of a field, if present. An empty array, whose element type cannot
+// be inferred, takes the declared type of the field when that is an array type or an optional array type.
+func (t *typeChecker) checkFieldValue(
+	value prior.IExpression,
+	declaredTypeIndex types.TypeIndex,
+	idContexts []types.TypeIndex,
+) IExpression {
+
+	if value == nil {
+		return nil
+	}
+
+	if array, ok := value.(*prior.ArrayLiteralExpr); ok && len(array.Elements) == 0 {
+		arrayTypeIndex, _ := t.optionalValueTypeIndex(declaredTypeIndex)
+		if _, isArray := t.TypePool.Get(arrayTypeIndex).(*types.ArrayType); isArray {
+			return &ArrayLiteralExpr{
+				SourcePosition: array.SourcePosition,
+				Elements:       []IExpression{},
+				TypeIndex:      arrayTypeIndex,
+			}
+		}
+	}
+
+	return t.checkTypes(value, idContexts)
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// checkFieldValueType ensures that the value or default value of a field, if present, has the type of the field. The
+// format describes a mismatch, given the field name, the field type, and the type of the value. A literal value not
+// among the literals allowed by the field type is described by its literal type.
+func (t *typeChecker) checkFieldValueType(
+	fieldName string,
+	fieldTypeIndex types.TypeIndex,
+	value IExpression,
+	format string,
+) {
+
+	if value == nil || fieldTypeIndex == types.BuiltInTypeIndexError {
+		return
+	}
+
+	valueTypeIndex := value.GetTypeIndex()
+
+	if valueTypeIndex == types.BuiltInTypeIndexError || t.isAssignable(value, fieldTypeIndex) {
+		return
+	}
+
+	literalTypeIndex, ok := t.literalTypeIndex(value)
+	if ok && valueTypeIndex == t.TypePool.BaseTypeIndex(fieldTypeIndex) {
+		valueTypeIndex = literalTypeIndex
+	}
+
+	t.report(diagnostics.CodeTypeMismatch, value.GetSourcePosition(),
+		format, fieldName, t.typeName(fieldTypeIndex), t.typeName(valueTypeIndex))
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// checkFunctionSignature checks the declared types of the parameters and result of a function, each of which must be
+// a type that can be declared for a field.
+func (t *typeChecker) checkFunctionSignature(
+	parameters []*prior.FunctionParameterExpr,
+	resultType prior.IExpression,
+	idContexts []types.TypeIndex,
+) *functionSignature {
+
+	result := &functionSignature{
+		parameterTypeIndexes: make([]types.TypeIndex, 0),
+		typeIndex:            types.BuiltInTypeIndexError,
+	}
+
+	ok := true
+
+	for _, parameter := range parameters {
+		parameterTypeIndex := t.checkDeclaredFieldType(t.checkTypes(parameter.ParameterType, idContexts))
+		result.parameterTypeIndexes = append(result.parameterTypeIndexes, parameterTypeIndex)
+		ok = ok && parameterTypeIndex != types.BuiltInTypeIndexError
+	}
+
+	result.resultTypeIndex = t.checkDeclaredFieldType(t.checkTypes(resultType, idContexts))
+
+	if ok && result.resultTypeIndex != types.BuiltInTypeIndexError {
+		result.typeIndex = t.TypePool.PutFunction(result.parameterTypeIndexes, result.resultTypeIndex)
+	}
+
+	return result
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// checkOperandType applies the type rule of a unary operator, whose operand must have one of the given types. An
+// operand of a literal or union type counts as its base type. Returns the type of the operand or else the error type.
+func (t *typeChecker) checkOperandType(
+	sourcePosition util.SourcePos,
+	operator string,
+	operand IExpression,
+	allowedTypeIndexes ...types.TypeIndex,
+) types.TypeIndex {
+
+	typeIndex := operand.GetTypeIndex()
+
+	if typeIndex == types.BuiltInTypeIndexError {
+		return types.BuiltInTypeIndexError
+	}
+
+	if !containsTypeIndex(allowedTypeIndexes, t.TypePool.BaseTypeIndex(typeIndex)) {
+		t.report(diagnostics.CodeTypeMismatch, sourcePosition,
+			"Operator '%s' is not defined for type %s", operator, t.typeName(typeIndex))
+		return types.BuiltInTypeIndexError
+	}
+
+	return t.TypePool.BaseTypeIndex(typeIndex)
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// checkOperandTypes applies the type rule of a binary operator, whose operands must have the same type, one of the
+// given types if any are given. Operands of literal or union types count as their base types. The mismatch format
+// describes operands of different types, with the left operand's type as its first argument and the right operand's
+// as its second. Returns the type of the operands or else the error type.
+func (t *typeChecker) checkOperandTypes(
+	sourcePosition util.SourcePos,
+	operator string,
+	mismatchFormat string,
+	lhs IExpression,
+	rhs IExpression,
+	allowedTypeIndexes ...types.TypeIndex,
+) types.TypeIndex {
+
+	lhsTypeIndex := lhs.GetTypeIndex()
+	rhsTypeIndex := rhs.GetTypeIndex()
+
+	if lhsTypeIndex == types.BuiltInTypeIndexError || rhsTypeIndex == types.BuiltInTypeIndexError {
+		return types.BuiltInTypeIndexError
+	}
+
+	lhsBaseTypeIndex := t.TypePool.BaseTypeIndex(lhsTypeIndex)
+	rhsBaseTypeIndex := t.TypePool.BaseTypeIndex(rhsTypeIndex)
+
+	if !t.areTypesCompatible(lhsBaseTypeIndex, rhsBaseTypeIndex) {
+		lhsTypeName := t.typeName(lhsTypeIndex)
+		rhsTypeName := t.typeName(rhsTypeIndex)
+		t.report(diagnostics.CodeTypeMismatch, sourcePosition, mismatchFormat, lhsTypeName, rhsTypeName).
+			WithLabel(lhs.GetSourcePosition(), "Left operand has type %s", lhsTypeName).
+			WithLabel(rhs.GetSourcePosition(), "Right operand has type %s", rhsTypeName)
+		return types.BuiltInTypeIndexError
 	}
+
+	if len(allowedTypeIndexes) > 0 && !containsTypeIndex(allowedTypeIndexes, lhsBaseTypeIndex) {
+		t.report(diagnostics.CodeTypeMismatch, sourcePosition,
+			"Operator '%s' is not defined for type %s", operator, t.typeName(lhsTypeIndex))
+		return types.BuiltInTypeIndexError
+	}
+
+	return lhsBaseTypeIndex
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// checkRecordOperand ensures that an operand is a record, as needed to look up names inside it. Returns the type of
+// the record or else the error type.
+func (t *typeChecker) checkRecordOperand(operand IExpression, format string) types.TypeIndex {
+
+	typeIndex := operand.GetTypeIndex()
+
+	if typeIndex == types.BuiltInTypeIndexError {
+		return types.BuiltInTypeIndexError
+	}
+
+	if _, ok := t.TypePool.Get(typeIndex).(*types.RecordType); !ok {
+		t.report(diagnostics.CodeTypeMismatch, operand.GetSourcePosition(), format, t.typeName(typeIndex))
+		return types.BuiltInTypeIndexError
+	}
+
+	return typeIndex
+
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -74,10 +531,22 @@ func (t *typeChecker) checkTypes(
 
 	case *prior.AdditionExpr:
 		return t.typeCheckAdditionExpr(expr, idContexts)
+	case *prior.ArrayLiteralExpr:
+		return t.typeCheckArrayLiteralExpr(expr, idContexts)
+	case *prior.ArrayTypeExpr:
+		return t.typeCheckArrayTypeExpr(expr, idContexts)
 	case *prior.BooleanLiteralExpr:
 		return t.typeCheckBooleanLiteralExpr(expr)
 	case *prior.BuiltInTypeExpr:
 		return t.typeCheckBuiltInTypeExpr(expr)
+	case *prior.CollatedEqualsExpr:
+		return t.typeCheckCollatedEqualsExpr(expr, idContexts)
+	case *prior.CollatedNotEqualsExpr:
+		return t.typeCheckCollatedNotEqualsExpr(expr, idContexts)
+	case *prior.ConstrainedValueExpr:
+		return t.typeCheckConstrainedValueExpr(expr)
+	case *prior.ConstraintExpr:
+		return t.typeCheckConstraintExpr(expr, idContexts)
 	case *prior.DivisionExpr:
 		return t.typeCheckDivisionExpr(expr, idContexts)
 	case *prior.EqualsExpr:
@@ -86,14 +555,28 @@ func (t *typeChecker) checkTypes(
 		return t.typeCheckFieldReferenceExpr(expr, idContexts)
 	case *prior.Float64LiteralExpr:
 		return t.typeCheckFloat64LiteralExpr(expr)
+	case *prior.FormatExpr:
+		return t.typeCheckFormatExpr(expr, idContexts)
+	case *prior.FunctionCallExpr:
+		return t.typeCheckFunctionCallExpr(expr, idContexts)
+	case *prior.FunctionExpr:
+		return t.typeCheckFunctionExpr(expr, idContexts)
+	case *prior.FunctionTypeExpr:
+		return t.typeCheckFunctionTypeExpr(expr, idContexts)
 	case *prior.GreaterThanExpr:
 		return t.typeCheckGreaterThanExpr(expr, idContexts)
 	case *prior.GreaterThanOrEqualsExpr:
 		return t.typeCheckGreaterThanOrEqualsExpr(expr, idContexts)
 	case *prior.IdentifierExpr:
 		return t.typeCheckIdentifierExpr(expr, idContexts)
+	case *prior.InExpr:
+		return t.typeCheckInExpr(expr, idContexts)
+	case *prior.IndexExpr:
+		return t.typeCheckIndexExpr(expr, idContexts)
 	case *prior.Int64LiteralExpr:
 		return t.typeCheckInt64LiteralExpr(expr)
+	case *prior.IntersectExpr:
+		return t.typeCheckIntersectExpr(expr, idContexts)
 	case *prior.IsExpr:
 		return t.typeCheckIsExpr(expr, idContexts)
 	case *prior.LessThanExpr:
@@ -106,20 +589,36 @@ func (t *typeChecker) checkTypes(
 		return t.typeCheckLogicalNotOperationExpr(expr, idContexts)
 	case *prior.LogicalOrExpr:
 		return t.typeCheckLogicalOrExpr(expr, idContexts)
+	case *prior.MatchExpr:
+		return t.typeCheckMatchExpr(expr, idContexts)
 	case *prior.MultiplicationExpr:
 		return t.typeCheckMultiplicationExpr(expr, idContexts)
 	case *prior.NegationOperationExpr:
 		return t.typeCheckNegationOperationExpr(expr, idContexts)
+	case *prior.NoneExpr:
+		return t.typeCheckNoneExpr(expr)
 	case *prior.NotEqualsExpr:
 		return t.typeCheckNotEqualsExpr(expr, idContexts)
+	case *prior.NotMatchExpr:
+		return t.typeCheckNotMatchExpr(expr, idContexts)
+	case *prior.OptionalDefaultExpr:
+		return t.typeCheckOptionalDefaultExpr(expr, idContexts)
+	case *prior.OptionalExpr:
+		return t.typeCheckOptionalExpr(expr, idContexts)
 	case *prior.ParenthesizedExpr:
 		return t.typeCheckParenthesizedExpr(expr, idContexts)
+	case *prior.RangeExpr:
+		return t.typeCheckRangeExpr(expr, idContexts)
 	case *prior.RecordExpr:
 		return t.typeCheckRecordExpr(expr, idContexts)
 	case *prior.StringLiteralExpr:
 		return t.typeCheckStringLiteralExpr(expr)
 	case *prior.SubtractionExpr:
 		return t.typeCheckSubtractionExpr(expr, idContexts)
+	case *prior.UnionExpr:
+		return t.typeCheckUnionExpr(expr, idContexts)
+	case *prior.WhenExpr:
+		return t.typeCheckWhenExpr(expr, idContexts)
 	case *prior.WhereExpr:
 		return t.typeCheckWhereExpr(expr, idContexts)
 
@@ -132,27 +631,116 @@ func (t *typeChecker) checkTypes(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// checkTypeOperand ensures that an operand of "|", "?", or "[]" is a type known while type checking, either a built-in
+// type, a union, an optional type, an array type, or a literal standing for its literal type. The format describes any
+// other operand, given its type. Returns the index of the type or else the error type.
+func (t *typeChecker) checkTypeOperand(operand IExpression, format string) types.TypeIndex {
+
+	switch expr := operand.(type) {
+	case *ArrayTypeExpr:
+		return expr.ValueIndex
+	case *BuiltInTypeExpr:
+		return expr.ValueIndex
+	case *FunctionTypeExpr:
+		return expr.ValueIndex
+	case *OptionalTypeExpr:
+		return expr.ValueIndex
+	case *ParenthesizedExpr:
+		return t.checkTypeOperand(expr.InnerExpr, format)
+	case *UnionTypeExpr:
+		return expr.ValueIndex
+	}
+
+	if literalTypeIndex, ok := t.literalTypeIndex(operand); ok {
+		return literalTypeIndex
+	}
+
+	if operand.GetTypeIndex() != types.BuiltInTypeIndexError {
+		t.report(diagnostics.CodeTypeMismatch, operand.GetSourcePosition(), format, t.typeName(operand.GetTypeIndex()))
+	}
+
+	return types.BuiltInTypeIndexError
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (t *typeChecker) typeCheckAdditionExpr(expr *prior.AdditionExpr, idContexts []types.TypeIndex) IExpression {
 	lhs := t.checkTypes(expr.Lhs, idContexts)
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	switch lhs.GetTypeIndex() {
-	case types.BuiltInTypeIndexFloat64, types.BuiltInTypeIndexInt64:
-		// TODO: ensure they're the same
-		return &AdditionExpr{
+
+	typeIndex := t.checkArithmeticOperandTypes(expr.SourcePosition, "+", "Cannot add %s and %s", lhs, rhs,
+		types.BuiltInTypeIndexFloat64, types.BuiltInTypeIndexInt64, types.BuiltInTypeIndexString)
+
+	if typeIndex == types.BuiltInTypeIndexString {
+		return &StringConcatenationExpr{
 			SourcePosition: expr.SourcePosition,
 			Lhs:            lhs,
 			Rhs:            rhs,
-			TypeIndex:      lhs.GetTypeIndex(),
 		}
-	case types.BuiltInTypeIndexString:
-		// TODO: ensure both strings
-		return &StringConcatenationExpr{
+	}
+
+	return &AdditionExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+		TypeIndex:      typeIndex,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// typeCheckArrayLiteralExpr checks an array literal, whose elements must all have one type: the widest of the types of
+// the elements, made optional when some of the elements are none.
+func (t *typeChecker) typeCheckArrayLiteralExpr(expr *prior.ArrayLiteralExpr, idContexts []types.TypeIndex) IExpression {
+	elements := make([]IExpression, len(expr.Elements))
+	for i, element := range expr.Elements {
+		elements[i] = t.checkTypes(element, idContexts)
+	}
+
+	typeIndex := types.BuiltInTypeIndexError
+
+	const mismatchFormat = "Expected an array element of type %s but found %s"
+
+	if len(elements) == 0 {
+		t.report(diagnostics.CodeUnsupportedExpression, expr.SourcePosition,
+			"Cannot infer the element type of an empty array")
+	} else if elementTypeIndex := t.widestType(elements, mismatchFormat); elementTypeIndex != types.BuiltInTypeIndexError {
+		typeIndex = t.TypePool.PutArray(elementTypeIndex)
+	}
+
+	return &ArrayLiteralExpr{
+		SourcePosition: expr.SourcePosition,
+		Elements:       elements,
+		TypeIndex:      typeIndex,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// typeCheckArrayTypeExpr checks a "[]" type, whose element type may also be a record standing for the type of its
+// fields.
+func (t *typeChecker) typeCheckArrayTypeExpr(expr *prior.ArrayTypeExpr, idContexts []types.TypeIndex) IExpression {
+	elementType := t.checkTypes(expr.ElementType, idContexts)
+
+	var elementTypeIndex types.TypeIndex
+	if record, ok := elementType.(*RecordExpr); ok {
+		elementTypeIndex = record.TypeIndex
+	} else {
+		elementTypeIndex = t.checkTypeOperand(elementType,
+			"Expected a type or a literal value before '[]' but found a value of type %s")
+	}
+
+	if elementTypeIndex == types.BuiltInTypeIndexError {
+		return &ArrayTypeExpr{
 			SourcePosition: expr.SourcePosition,
-			Lhs:            lhs,
-			Rhs:            rhs,
+			ValueIndex:     types.BuiltInTypeIndexError,
 		}
-	default:
-		panic(fmt.Sprintf("Missing case in typeCheckAdditionExpr: %d\n", lhs.GetTypeIndex()))
+	}
+
+	return &ArrayTypeExpr{
+		SourcePosition: expr.SourcePosition,
+		ValueIndex:     t.TypePool.PutArray(elementTypeIndex),
 	}
 }
 
@@ -177,74 +765,99 @@ func (t *typeChecker) typeCheckBuiltInTypeExpr(expr *prior.BuiltInTypeExpr) IExp
 
 //---------------------------------------------------------------------------------------------------------------------
 
-func (t *typeChecker) typeCheckDivisionExpr(expr *prior.DivisionExpr, idContexts []types.TypeIndex) IExpression {
+func (t *typeChecker) typeCheckCollatedEqualsExpr(expr *prior.CollatedEqualsExpr, idContexts []types.TypeIndex) IExpression {
 	lhs := t.checkTypes(expr.Lhs, idContexts)
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	// TODO: ensure they're the same
-	return &DivisionExpr{
+	t.checkOperandTypes(expr.SourcePosition, "==["+expr.Collation.String()+"]==", "Cannot compare %s and %s", lhs, rhs,
+		types.BuiltInTypeIndexString)
+	return &CollatedEqualsExpr{
 		SourcePosition: expr.SourcePosition,
 		Lhs:            lhs,
 		Rhs:            rhs,
-		TypeIndex:      lhs.GetTypeIndex(),
+		Collation:      expr.Collation,
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
-func (t *typeChecker) typeCheckEqualsExpr(expr *prior.EqualsExpr, idContexts []types.TypeIndex) IExpression {
+func (t *typeChecker) typeCheckCollatedNotEqualsExpr(expr *prior.CollatedNotEqualsExpr, idContexts []types.TypeIndex) IExpression {
 	lhs := t.checkTypes(expr.Lhs, idContexts)
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	// TODO: ensure they're the same
-	return &EqualsExpr{
+	t.checkOperandTypes(expr.SourcePosition, "=!["+expr.Collation.String()+"]!=", "Cannot compare %s and %s", lhs, rhs,
+		types.BuiltInTypeIndexString)
+	return &CollatedNotEqualsExpr{
 		SourcePosition: expr.SourcePosition,
 		Lhs:            lhs,
 		Rhs:            rhs,
+		Collation:      expr.Collation,
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
-func (t *typeChecker) typeCheckFieldReferenceExpr(expr *prior.FieldReferenceExpr, idContexts []types.TypeIndex) IExpression {
-	parent := t.checkTypes(expr.Parent, idContexts)
-	parentTypeIndex := parent.GetTypeIndex()
-	child := t.checkTypes(expr.Child, append(idContexts, parentTypeIndex))
-	// TODO: ensure the parent is a record
-	return &FieldReferenceExpr{
+func (t *typeChecker) typeCheckConstrainedValueExpr(expr *prior.ConstrainedValueExpr) IExpression {
+	return &ConstrainedValueExpr{
 		SourcePosition: expr.SourcePosition,
-		Parent:         parent,
-		Child:          child,
+		TypeIndex:      t.constrainedValueTypeIndexes[len(t.constrainedValueTypeIndexes)-1],
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
-func (t *typeChecker) typeCheckFloat64LiteralExpr(expr *prior.Float64LiteralExpr) IExpression {
-	return &Float64LiteralExpr{
-		SourcePosition: expr.SourcePosition,
-		Value:          expr.Value,
+// typeCheckConstraintExpr checks a constraint, whose predicate must be a Bool given "val" of the constrained type. The
+// predicate is kept aside for code generation, keyed by the resulting constraint type.
+func (t *typeChecker) typeCheckConstraintExpr(expr *prior.ConstraintExpr, idContexts []types.TypeIndex) IExpression {
+	constrainedType := t.checkTypes(expr.ConstrainedType, idContexts)
+	constrainedTypeIndex := t.checkTypeOperand(constrainedType,
+		"Expected a type before a constraint but found a value of type %s")
+
+	t.constrainedValueTypeIndexes = append(t.constrainedValueTypeIndexes, constrainedTypeIndex)
+	predicate := t.checkTypes(expr.Predicate, make([]types.TypeIndex, 0))
+	t.constrainedValueTypeIndexes = t.constrainedValueTypeIndexes[:len(t.constrainedValueTypeIndexes)-1]
+
+	typeIndex := types.BuiltInTypeIndexError
+
+	switch {
+	case constrainedTypeIndex == types.BuiltInTypeIndexError || predicate.GetTypeIndex() == types.BuiltInTypeIndexError:
+		// Already reported
+	case t.TypePool.BaseTypeIndex(predicate.GetTypeIndex()) != types.BuiltInTypeIndexBool:
+		t.report(diagnostics.CodeTypeMismatch, predicate.GetSourcePosition(),
+			"Expected a Bool predicate in a constraint but found %s", t.typeName(predicate.GetTypeIndex()))
+	default:
+		typeIndex = t.TypePool.PutConstraint(constrainedTypeIndex, predicate.GetSourcePosition().GetText(t.SourceCode))
+		t.constraintPredicates[typeIndex] = predicate
+	}
+
+	return &ConstraintTypeExpr{
+		SourcePosition:  expr.SourcePosition,
+		ConstrainedType: constrainedType,
+		Predicate:       predicate,
+		ValueIndex:      typeIndex,
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
-func (t *typeChecker) typeCheckGreaterThanExpr(expr *prior.GreaterThanExpr, idContexts []types.TypeIndex) IExpression {
+func (t *typeChecker) typeCheckDivisionExpr(expr *prior.DivisionExpr, idContexts []types.TypeIndex) IExpression {
 	lhs := t.checkTypes(expr.Lhs, idContexts)
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	// TODO: ensure they're the same
-	return &GreaterThanExpr{
+	typeIndex := t.checkArithmeticOperandTypes(expr.SourcePosition, "/", "Cannot divide %s by %s", lhs, rhs,
+		types.BuiltInTypeIndexFloat64, types.BuiltInTypeIndexInt64)
+	return &DivisionExpr{
 		SourcePosition: expr.SourcePosition,
 		Lhs:            lhs,
 		Rhs:            rhs,
+		TypeIndex:      typeIndex,
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
-func (t *typeChecker) typeCheckGreaterThanOrEqualsExpr(expr *prior.GreaterThanOrEqualsExpr, idContexts []types.TypeIndex) IExpression {
+func (t *typeChecker) typeCheckEqualsExpr(expr *prior.EqualsExpr, idContexts []types.TypeIndex) IExpression {
 	lhs := t.checkTypes(expr.Lhs, idContexts)
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	// TODO: ensure they're the same
-	return &GreaterThanOrEqualsExpr{
+	t.checkEqualityOperandTypes(expr.SourcePosition, "==", lhs, rhs)
+	return &EqualsExpr{
 		SourcePosition: expr.SourcePosition,
 		Lhs:            lhs,
 		Rhs:            rhs,
@@ -253,32 +866,381 @@ func (t *typeChecker) typeCheckGreaterThanOrEqualsExpr(expr *prior.GreaterThanOr
 
 //---------------------------------------------------------------------------------------------------------------------
 
-func (t *typeChecker) typeCheckIdentifierExpr(expr *prior.IdentifierExpr, idContexts []types.TypeIndex) IExpression {
+func (t *typeChecker) typeCheckFieldReferenceExpr(expr *prior.FieldReferenceExpr, idContexts []types.TypeIndex) IExpression {
+	parent := t.checkTypes(expr.Parent, idContexts)
 
-	fieldIndex := uint64(0xFFFFFFFF)
-	typeIndex := types.TypeIndex(0xFFFFFFFF)
+	// A record that may be none gives a field that may be none too
+	recordTypeIndex, isOptional := t.optionalValueTypeIndex(parent.GetTypeIndex())
+	if isOptional && t.isRecordType(recordTypeIndex) {
+		child := t.checkTypes(expr.Child, append(idContexts, recordTypeIndex))
 
-outer:
-	for i := len(idContexts) - 1; i >= 0; i-- {
-		recordType := t.TypePool.Get(idContexts[i]).(*types.RecordType)
+		typeIndex := types.BuiltInTypeIndexError
+		if child.GetTypeIndex() != types.BuiltInTypeIndexError {
+			typeIndex = t.TypePool.PutOptional(child.GetTypeIndex())
+		}
+
+		return &OptionalFieldReferenceExpr{
+			SourcePosition: expr.SourcePosition,
+			Parent:         parent,
+			Child:          child,
+			TypeIndex:      typeIndex,
+		}
+	}
+
+	// The one field of an array is its length
+	if _, isArray := t.TypePool.Get(parent.GetTypeIndex()).(*types.ArrayType); isArray {
+		if child, ok := expr.Child.(*prior.IdentifierExpr); ok {
+			name := t.IdentifierNames.Get(child.NameIndex)
+			if name != "length" {
+				t.report(diagnostics.CodeUndefinedName, child.SourcePosition,
+					"Undefined name '%s'%s", name, util.DidYouMean(name, []string{"length"}))
+			}
+
+			return &ArrayLengthExpr{
+				SourcePosition: expr.SourcePosition,
+				Array:          parent,
+			}
+		}
+	}
+
+	parentTypeIndex := t.checkRecordOperand(parent, "Expected a record before '.' but found %s")
+	child := t.checkTypes(expr.Child, append(idContexts, parentTypeIndex))
+	return &FieldReferenceExpr{
+		SourcePosition: expr.SourcePosition,
+		Parent:         parent,
+		Child:          child,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (t *typeChecker) typeCheckFloat64LiteralExpr(expr *prior.Float64LiteralExpr) IExpression {
+	return &Float64LiteralExpr{
+		SourcePosition: expr.SourcePosition,
+		Value:          expr.Value,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// typeCheckFormatExpr checks the formatting of a Bool, Float64, Int64, or String value by a String format. A format
+// given by a string literal is parsed right away, so that one that is invalid or does not suit the type of the value
+// is reported at compile time; any other format is checked when the code runs.
+func (t *typeChecker) typeCheckFormatExpr(expr *prior.FormatExpr, idContexts []types.TypeIndex) IExpression {
+	lhs := t.checkTypes(expr.Lhs, idContexts)
+	rhs := t.checkTypes(expr.Rhs, idContexts)
+
+	operandTypeIndex := t.checkOperandType(expr.SourcePosition, "fmt", lhs,
+		types.BuiltInTypeIndexBool, types.BuiltInTypeIndexFloat64, types.BuiltInTypeIndexInt64,
+		types.BuiltInTypeIndexString)
+
+	rhsTypeIndex := rhs.GetTypeIndex()
+	isString := t.TypePool.BaseTypeIndex(rhsTypeIndex) == types.BuiltInTypeIndexString
+	if rhsTypeIndex != types.BuiltInTypeIndexError && !isString {
+		t.report(diagnostics.CodeTypeMismatch, rhs.GetSourcePosition(),
+			"Expected a String format after 'fmt' but found %s", t.typeName(rhsTypeIndex))
+	}
+
+	if literal, isLiteral := rhs.(*StringLiteralExpr); isLiteral && operandTypeIndex != types.BuiltInTypeIndexError {
+		format, err := formats.ParseFormat(t.StringConstants.Get(literal.ValueIndex))
+		if err == nil {
+			err = format.Check(formats.KindOfType(operandTypeIndex))
+		}
+		if err != nil {
+			t.report(diagnostics.CodeInvalidFormat, rhs.GetSourcePosition(), "Invalid format %s: %s",
+				rhs.GetSourcePosition().GetText(t.SourceCode), err)
+		}
+	}
+
+	return &FormatExpr{
+		SourcePosition:   expr.SourcePosition,
+		Lhs:              lhs,
+		Rhs:              rhs,
+		OperandTypeIndex: operandTypeIndex,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// typeCheckFunctionCallExpr checks a call of a function named by a field of a record under construction. Each argument
+// must be a value of the type of its parameter.
+func (t *typeChecker) typeCheckFunctionCallExpr(expr *prior.FunctionCallExpr, idContexts []types.TypeIndex) IExpression {
+	functionReference := t.checkTypes(expr.FunctionReference, idContexts)
+
+	arguments := make([]IExpression, len(expr.Arguments))
+	for i, argument := range expr.Arguments {
+		arguments[i] = t.checkTypes(argument, idContexts)
+	}
+
+	result := &FunctionCallExpr{
+		SourcePosition:    expr.SourcePosition,
+		FunctionReference: functionReference,
+		Arguments:         arguments,
+		TypeIndex:         types.BuiltInTypeIndexError,
+	}
+
+	referenceTypeIndex := functionReference.GetTypeIndex()
+	if referenceTypeIndex == types.BuiltInTypeIndexError {
+		return result
+	}
+
+	functionType, ok := t.TypePool.Get(referenceTypeIndex).(*types.FunctionType)
+	if !ok {
+		t.report(diagnostics.CodeTypeMismatch, functionReference.GetSourcePosition(),
+			"Expected a function before '(' but found %s", t.typeName(referenceTypeIndex))
+		return result
+	}
+
+	if identifier, ok := functionReference.(*IdentifierExpr); !ok ||
+		(identifier.Mechanism != prior.ResolutionMechanismRecordField &&
+			identifier.Mechanism != prior.ResolutionMechanismTopLevel) {
+		t.report(diagnostics.CodeUnsupportedExpression, functionReference.GetSourcePosition(),
+			"Only functions named by a field of a record under construction can be called")
+		return result
+	}
+
+	if len(arguments) != len(functionType.ParameterTypeIndexes) {
+		t.report(diagnostics.CodeWrongArgumentCount, expr.SourcePosition,
+			"Expected %d argument(s) to %s but found %d",
+			len(functionType.ParameterTypeIndexes), t.typeName(referenceTypeIndex), len(arguments))
+		return result
+	}
+
+	argumentsOk := true
+	for i, argument := range arguments {
+		parameterTypeIndex := functionType.ParameterTypeIndexes[i]
+
+		switch {
+		case argument.GetTypeIndex() == types.BuiltInTypeIndexError || parameterTypeIndex == types.BuiltInTypeIndexError:
+			argumentsOk = false
+		case !t.isAssignable(argument, parameterTypeIndex):
+			t.report(diagnostics.CodeTypeMismatch, argument.GetSourcePosition(),
+				"Expected an argument of type %s but found %s",
+				t.typeName(parameterTypeIndex), t.typeName(argument.GetTypeIndex()))
+			argumentsOk = false
+		}
+	}
+
+	if argumentsOk {
+		result.TypeIndex = functionType.ResultTypeIndex
+	}
+
+	return result
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// typeCheckFunctionExpr checks the body of a function given the types of its parameters. The body must be a value of
+// the declared result type.
+func (t *typeChecker) typeCheckFunctionExpr(expr *prior.FunctionExpr, idContexts []types.TypeIndex) IExpression {
+	signature := t.functionSignature(expr, idContexts)
+
+	t.functionParameterTypeIndexes = append(t.functionParameterTypeIndexes, signature.parameterTypeIndexes)
+	body := t.checkTypes(expr.Body, make([]types.TypeIndex, 0))
+	t.functionParameterTypeIndexes = t.functionParameterTypeIndexes[:len(t.functionParameterTypeIndexes)-1]
+
+	bodyTypeIndex := body.GetTypeIndex()
+
+	if bodyTypeIndex != types.BuiltInTypeIndexError && signature.resultTypeIndex != types.BuiltInTypeIndexError &&
+		!t.isAssignable(body, signature.resultTypeIndex) {
+		t.report(diagnostics.CodeTypeMismatch, body.GetSourcePosition(),
+			"Function result is declared as %s but its body has type %s",
+			t.typeName(signature.resultTypeIndex), t.typeName(bodyTypeIndex))
+	}
+
+	return &FunctionExpr{
+		SourcePosition: expr.SourcePosition,
+		Body:           body,
+		TypeIndex:      signature.typeIndex,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (t *typeChecker) typeCheckFunctionTypeExpr(expr *prior.FunctionTypeExpr, idContexts []types.TypeIndex) IExpression {
+	return &FunctionTypeExpr{
+		SourcePosition: expr.SourcePosition,
+		ValueIndex:     t.checkFunctionSignature(expr.Parameters, expr.ResultType, idContexts).typeIndex,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (t *typeChecker) typeCheckGreaterThanExpr(expr *prior.GreaterThanExpr, idContexts []types.TypeIndex) IExpression {
+	lhs := t.checkTypes(expr.Lhs, idContexts)
+	rhs := t.checkTypes(expr.Rhs, idContexts)
+	t.checkOperandTypes(expr.SourcePosition, ">", "Cannot compare %s and %s", lhs, rhs,
+		types.BuiltInTypeIndexFloat64, types.BuiltInTypeIndexInt64, types.BuiltInTypeIndexString)
+	return &GreaterThanExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (t *typeChecker) typeCheckGreaterThanOrEqualsExpr(expr *prior.GreaterThanOrEqualsExpr, idContexts []types.TypeIndex) IExpression {
+	lhs := t.checkTypes(expr.Lhs, idContexts)
+	rhs := t.checkTypes(expr.Rhs, idContexts)
+	t.checkOperandTypes(expr.SourcePosition, ">=", "Cannot compare %s and %s", lhs, rhs,
+		types.BuiltInTypeIndexFloat64, types.BuiltInTypeIndexInt64, types.BuiltInTypeIndexString)
+	return &GreaterThanOrEqualsExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (t *typeChecker) typeCheckIdentifierExpr(expr *prior.IdentifierExpr, idContexts []types.TypeIndex) IExpression {
+
+	nameUsage := expr.NameUsage
+
+	switch nameUsage.Mechanism {
+
+	case prior.ResolutionMechanismRecordField, prior.ResolutionMechanismTopLevel:
+		// Fields are checked in evaluation order, so the type of the field is already known.
+		recordType := t.recordsUnderConstruction[len(t.recordsUnderConstruction)-1-int(nameUsage.RecordDepth)]
+		t.checkFieldPresence(expr, recordType, int(nameUsage.FieldIndex))
+
+		return &IdentifierExpr{
+			SourcePosition: expr.SourcePosition,
+			NameIndex:      expr.NameIndex,
+			FieldIndex:     nameUsage.FieldIndex,
+			Mechanism:      nameUsage.Mechanism,
+			RecordDepth:    nameUsage.RecordDepth,
+			TypeIndex:      recordType.FieldTypeIndexes[nameUsage.FieldIndex],
+		}
+
+	case prior.ResolutionMechanismFunctionParameter:
+		parameterTypeIndexes := t.functionParameterTypeIndexes[len(t.functionParameterTypeIndexes)-1]
+
+		return &IdentifierExpr{
+			SourcePosition: expr.SourcePosition,
+			NameIndex:      expr.NameIndex,
+			FieldIndex:     nameUsage.FieldIndex,
+			Mechanism:      nameUsage.Mechanism,
+			TypeIndex:      parameterTypeIndexes[nameUsage.FieldIndex],
+		}
+
+	}
+
+	fieldIndex := uint64(0xFFFFFFFF)
+	typeIndex := types.TypeIndex(0xFFFFFFFF)
+	candidates := make([]string, 0)
+
+outer:
+	for i := len(idContexts) - 1; i >= 0; i-- {
+		recordType, ok := t.TypePool.Get(idContexts[i]).(*types.RecordType)
+		if !ok {
+			// The context has the error type; the problem with it has been reported already.
+			return &IdentifierExpr{
+				SourcePosition: expr.SourcePosition,
+				NameIndex:      expr.NameIndex,
+				FieldIndex:     fieldIndex,
+				Mechanism:      nameUsage.Mechanism,
+				TypeIndex:      types.BuiltInTypeIndexError,
+			}
+		}
 
 		for j, fieldNameIndex := range recordType.FieldNameIndexes {
 			if expr.NameIndex == fieldNameIndex {
+				t.checkFieldPresence(expr, recordType, j)
 				fieldIndex = uint64(j)
 				typeIndex = recordType.FieldTypeIndexes[j]
 				break outer
 			}
+			candidates = append(candidates, t.IdentifierNames.Get(fieldNameIndex))
 		}
 	}
 
 	if typeIndex == 0xFFFFFFFF {
-		panic("Identifier type not found")
+		name := t.IdentifierNames.Get(expr.NameIndex)
+		t.report(diagnostics.CodeUndefinedName, expr.SourcePosition,
+			"Undefined name '%s'%s", name, util.DidYouMean(name, candidates))
+		typeIndex = types.BuiltInTypeIndexError
 	}
 
 	return &IdentifierExpr{
 		SourcePosition: expr.SourcePosition,
 		NameIndex:      expr.NameIndex,
 		FieldIndex:     fieldIndex,
+		Mechanism:      nameUsage.Mechanism,
+		TypeIndex:      typeIndex,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// typeCheckInExpr checks a set membership test, whose right hand side is a range or an array of values of the type
+// of the left hand side.
+func (t *typeChecker) typeCheckInExpr(expr *prior.InExpr, idContexts []types.TypeIndex) IExpression {
+	lhs := t.checkTypes(expr.Lhs, idContexts)
+	rhs := t.checkTypes(expr.Rhs, idContexts)
+
+	lhsTypeIndex := lhs.GetTypeIndex()
+	rhsTypeIndex := rhs.GetTypeIndex()
+
+	if lhsTypeIndex != types.BuiltInTypeIndexError && rhsTypeIndex != types.BuiltInTypeIndexError {
+		memberTypeIndex := types.BuiltInTypeIndexError
+
+		switch rhsType := t.TypePool.Get(rhsTypeIndex).(type) {
+		case *types.ArrayType:
+			memberTypeIndex = rhsType.ElementTypeIndex
+		case *types.RangeType:
+			memberTypeIndex = rhsType.BoundTypeIndex
+		default:
+			t.report(diagnostics.CodeTypeMismatch, rhs.GetSourcePosition(),
+				"Expected a range or an array after 'in' but found %s", t.typeName(rhsTypeIndex))
+		}
+
+		lhsBaseTypeIndex := t.TypePool.BaseTypeIndex(lhsTypeIndex)
+		memberBaseTypeIndex := t.TypePool.BaseTypeIndex(memberTypeIndex)
+
+		if memberTypeIndex != types.BuiltInTypeIndexError && !t.areTypesCompatible(lhsBaseTypeIndex, memberBaseTypeIndex) {
+			lhsTypeName := t.typeName(lhsTypeIndex)
+			rhsTypeName := t.typeName(rhsTypeIndex)
+			t.report(diagnostics.CodeTypeMismatch, expr.SourcePosition,
+				"Cannot look for %s in %s", lhsTypeName, rhsTypeName).
+				WithLabel(lhs.GetSourcePosition(), "Left operand has type %s", lhsTypeName).
+				WithLabel(rhs.GetSourcePosition(), "Right operand has type %s", rhsTypeName)
+		}
+	}
+
+	return &InExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (t *typeChecker) typeCheckIndexExpr(expr *prior.IndexExpr, idContexts []types.TypeIndex) IExpression {
+	array := t.checkTypes(expr.Array, idContexts)
+	index := t.checkTypes(expr.Index, idContexts)
+
+	arrayTypeIndex := t.checkArrayOperand(array, "Expected an array before '[' but found %s")
+	indexTypeIndex := index.GetTypeIndex()
+
+	typeIndex := types.BuiltInTypeIndexError
+
+	switch {
+	case indexTypeIndex == types.BuiltInTypeIndexError:
+		// Already reported
+	case t.TypePool.BaseTypeIndex(indexTypeIndex) != types.BuiltInTypeIndexInt64:
+		t.report(diagnostics.CodeTypeMismatch, index.GetSourcePosition(),
+			"Expected an Int64 array index but found %s", t.typeName(indexTypeIndex))
+	case arrayTypeIndex != types.BuiltInTypeIndexError:
+		typeIndex = t.TypePool.Get(arrayTypeIndex).(*types.ArrayType).ElementTypeIndex
+	}
+
+	return &ArrayIndexExpr{
+		SourcePosition: expr.SourcePosition,
+		Array:          array,
+		Index:          index,
 		TypeIndex:      typeIndex,
 	}
 }
@@ -294,10 +1256,38 @@ func (t *typeChecker) typeCheckInt64LiteralExpr(expr *prior.Int64LiteralExpr) IE
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (t *typeChecker) typeCheckIntersectExpr(expr *prior.IntersectExpr, idContexts []types.TypeIndex) IExpression {
+	lhs := t.checkTypes(expr.Lhs, idContexts)
+	rhs := t.checkTypes(expr.Rhs, idContexts)
+
+	lhsTypeIndex := t.checkRecordOperand(lhs, "Expected a record to intersect but found %s")
+	rhsTypeIndex := t.checkRecordOperand(rhs, "Expected a record to intersect but found %s")
+
+	typeIndex := types.BuiltInTypeIndexError
+	if lhsTypeIndex != types.BuiltInTypeIndexError && rhsTypeIndex != types.BuiltInTypeIndexError {
+		typeIndex = t.mergeRecordTypes(expr.SourcePosition, lhsTypeIndex, rhsTypeIndex)
+	}
+
+	return &RecordMergeExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+		TypeIndex:      typeIndex,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (t *typeChecker) typeCheckIsExpr(expr *prior.IsExpr, idContexts []types.TypeIndex) IExpression {
 	lhs := t.checkTypes(expr.Lhs, idContexts)
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	// TODO: ensure the lhs and rhs are compatible
+
+	rhsTypeIndex := rhs.GetTypeIndex()
+	if rhsTypeIndex != types.BuiltInTypeIndexType && rhsTypeIndex != types.BuiltInTypeIndexError {
+		t.report(diagnostics.CodeTypeMismatch, rhs.GetSourcePosition(),
+			"Expected a type on the right hand side of 'is' but found %s", t.typeName(rhsTypeIndex))
+	}
+
 	return &IsExpr{
 		SourcePosition: expr.SourcePosition,
 		Lhs:            lhs,
@@ -310,7 +1300,8 @@ func (t *typeChecker) typeCheckIsExpr(expr *prior.IsExpr, idContexts []types.Typ
 func (t *typeChecker) typeCheckLessThanExpr(expr *prior.LessThanExpr, idContexts []types.TypeIndex) IExpression {
 	lhs := t.checkTypes(expr.Lhs, idContexts)
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	// TODO: ensure they're the same
+	t.checkOperandTypes(expr.SourcePosition, "<", "Cannot compare %s and %s", lhs, rhs,
+		types.BuiltInTypeIndexFloat64, types.BuiltInTypeIndexInt64, types.BuiltInTypeIndexString)
 	return &LessThanExpr{
 		SourcePosition: expr.SourcePosition,
 		Lhs:            lhs,
@@ -323,7 +1314,8 @@ func (t *typeChecker) typeCheckLessThanExpr(expr *prior.LessThanExpr, idContexts
 func (t *typeChecker) typeCheckLessThanOrEqualsExpr(expr *prior.LessThanOrEqualsExpr, idContexts []types.TypeIndex) IExpression {
 	lhs := t.checkTypes(expr.Lhs, idContexts)
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	// TODO: ensure they're the same
+	t.checkOperandTypes(expr.SourcePosition, "<=", "Cannot compare %s and %s", lhs, rhs,
+		types.BuiltInTypeIndexFloat64, types.BuiltInTypeIndexInt64, types.BuiltInTypeIndexString)
 	return &LessThanOrEqualsExpr{
 		SourcePosition: expr.SourcePosition,
 		Lhs:            lhs,
@@ -336,7 +1328,8 @@ func (t *typeChecker) typeCheckLessThanOrEqualsExpr(expr *prior.LessThanOrEquals
 func (t *typeChecker) typeCheckLogicalAndExpr(expr *prior.LogicalAndExpr, idContexts []types.TypeIndex) IExpression {
 	lhs := t.checkTypes(expr.Lhs, idContexts)
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	// TODO: ensure they're both boolean
+	t.checkOperandTypes(expr.SourcePosition, "and", "Cannot apply 'and' to %s and %s", lhs, rhs,
+		types.BuiltInTypeIndexBool)
 	return &LogicalAndExpr{
 		SourcePosition: expr.SourcePosition,
 		Lhs:            lhs,
@@ -349,7 +1342,7 @@ func (t *typeChecker) typeCheckLogicalAndExpr(expr *prior.LogicalAndExpr, idCont
 func (t *typeChecker) typeCheckLogicalNotOperationExpr(expr *prior.LogicalNotOperationExpr, idContexts []types.TypeIndex) IExpression {
 	operand := t.checkTypes(expr.Operand, idContexts)
 
-	// TODO: validate that operands are boolean
+	t.checkOperandType(expr.SourcePosition, "not", operand, types.BuiltInTypeIndexBool)
 
 	return &LogicalNotOperationExpr{
 		SourcePosition: expr.SourcePosition,
@@ -362,7 +1355,8 @@ func (t *typeChecker) typeCheckLogicalNotOperationExpr(expr *prior.LogicalNotOpe
 func (t *typeChecker) typeCheckLogicalOrExpr(expr *prior.LogicalOrExpr, idContexts []types.TypeIndex) IExpression {
 	lhs := t.checkTypes(expr.Lhs, idContexts)
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	// TODO: ensure they're both boolean
+	t.checkOperandTypes(expr.SourcePosition, "or", "Cannot apply 'or' to %s and %s", lhs, rhs,
+		types.BuiltInTypeIndexBool)
 	return &LogicalOrExpr{
 		SourcePosition: expr.SourcePosition,
 		Lhs:            lhs,
@@ -372,15 +1366,30 @@ func (t *typeChecker) typeCheckLogicalOrExpr(expr *prior.LogicalOrExpr, idContex
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (t *typeChecker) typeCheckMatchExpr(expr *prior.MatchExpr, idContexts []types.TypeIndex) IExpression {
+	lhs := t.checkTypes(expr.Lhs, idContexts)
+	rhs := t.checkTypes(expr.Rhs, idContexts)
+	t.checkOperandTypes(expr.SourcePosition, "=~", "Cannot match %s against a pattern of type %s", lhs, rhs,
+		types.BuiltInTypeIndexString)
+	return &MatchExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (t *typeChecker) typeCheckMultiplicationExpr(expr *prior.MultiplicationExpr, idContexts []types.TypeIndex) IExpression {
 	lhs := t.checkTypes(expr.Lhs, idContexts)
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	// TODO: ensure they're the same
+	typeIndex := t.checkArithmeticOperandTypes(expr.SourcePosition, "*", "Cannot multiply %s by %s", lhs, rhs,
+		types.BuiltInTypeIndexFloat64, types.BuiltInTypeIndexInt64)
 	return &MultiplicationExpr{
 		SourcePosition: expr.SourcePosition,
 		Lhs:            lhs,
 		Rhs:            rhs,
-		TypeIndex:      lhs.GetTypeIndex(),
+		TypeIndex:      typeIndex,
 	}
 }
 
@@ -389,10 +1398,21 @@ func (t *typeChecker) typeCheckMultiplicationExpr(expr *prior.MultiplicationExpr
 func (t *typeChecker) typeCheckNegationOperationExpr(expr *prior.NegationOperationExpr, idContexts []types.TypeIndex) IExpression {
 	operand := t.checkTypes(expr.Operand, idContexts)
 
+	typeIndex := t.checkOperandType(expr.SourcePosition, "-", operand,
+		types.BuiltInTypeIndexFloat64, types.BuiltInTypeIndexInt64)
+
 	return &NegationOperationExpr{
 		SourcePosition: expr.SourcePosition,
 		Operand:        operand,
-		TypeIndex:      operand.GetTypeIndex(),
+		TypeIndex:      typeIndex,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (t *typeChecker) typeCheckNoneExpr(expr *prior.NoneExpr) IExpression {
+	return &NoneExpr{
+		SourcePosition: expr.SourcePosition,
 	}
 }
 
@@ -401,7 +1421,7 @@ func (t *typeChecker) typeCheckNegationOperationExpr(expr *prior.NegationOperati
 func (t *typeChecker) typeCheckNotEqualsExpr(expr *prior.NotEqualsExpr, idContexts []types.TypeIndex) IExpression {
 	lhs := t.checkTypes(expr.Lhs, idContexts)
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	// TODO: ensure they're the same
+	t.checkEqualityOperandTypes(expr.SourcePosition, "!=", lhs, rhs)
 	return &NotEqualsExpr{
 		SourcePosition: expr.SourcePosition,
 		Lhs:            lhs,
@@ -411,84 +1431,343 @@ func (t *typeChecker) typeCheckNotEqualsExpr(expr *prior.NotEqualsExpr, idContex
 
 //---------------------------------------------------------------------------------------------------------------------
 
-func (t *typeChecker) typeCheckParenthesizedExpr(expr *prior.ParenthesizedExpr, idContexts []types.TypeIndex) IExpression {
-
-	inner := t.checkTypes(expr.InnerExpr, idContexts)
-
-	return &ParenthesizedExpr{
+func (t *typeChecker) typeCheckNotMatchExpr(expr *prior.NotMatchExpr, idContexts []types.TypeIndex) IExpression {
+	lhs := t.checkTypes(expr.Lhs, idContexts)
+	rhs := t.checkTypes(expr.Rhs, idContexts)
+	t.checkOperandTypes(expr.SourcePosition, "!~", "Cannot match %s against a pattern of type %s", lhs, rhs,
+		types.BuiltInTypeIndexString)
+	return &NotMatchExpr{
 		SourcePosition: expr.SourcePosition,
-		InnerExpr:      inner,
-		TypeIndex:      inner.GetTypeIndex(),
+		Lhs:            lhs,
+		Rhs:            rhs,
 	}
-
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
-func (t *typeChecker) typeCheckRecordExpr(expr *prior.RecordExpr, idContexts []types.TypeIndex) IExpression {
+// typeCheckOptionalDefaultExpr checks a "?:" expression, whose left hand side must be optional. The result has the
+// value type of the left hand side when the default value fits it, stays optional when the default value is optional
+// too, and takes the type of the default value when that is the wider one.
+func (t *typeChecker) typeCheckOptionalDefaultExpr(expr *prior.OptionalDefaultExpr, idContexts []types.TypeIndex) IExpression {
+	lhs := t.checkTypes(expr.Lhs, idContexts)
+	rhs := t.checkTypes(expr.Rhs, idContexts)
 
-	// TODO: make sure fields are in the same order as the record type
+	lhsTypeIndex := lhs.GetTypeIndex()
+	rhsTypeIndex := rhs.GetTypeIndex()
+	valueTypeIndex, isOptional := t.optionalValueTypeIndex(lhsTypeIndex)
+
+	typeIndex := types.BuiltInTypeIndexError
+
+	switch {
+	case lhsTypeIndex == types.BuiltInTypeIndexError || rhsTypeIndex == types.BuiltInTypeIndexError:
+		// Already reported
+	case lhsTypeIndex == types.BuiltInTypeIndexNone:
+		typeIndex = rhsTypeIndex
+	case !isOptional:
+		t.report(diagnostics.CodeTypeMismatch, lhs.GetSourcePosition(),
+			"Expected an optional value before '?:' but found %s", t.typeName(lhsTypeIndex))
+	case t.isAssignable(rhs, valueTypeIndex):
+		typeIndex = valueTypeIndex
+	case t.isAssignable(rhs, lhsTypeIndex):
+		typeIndex = lhsTypeIndex
+	case t.isTypeAssignable(valueTypeIndex, rhsTypeIndex) || t.isTypeAssignable(lhsTypeIndex, rhsTypeIndex):
+		typeIndex = rhsTypeIndex
+	default:
+		lhsTypeName := t.typeName(lhsTypeIndex)
+		rhsTypeName := t.typeName(rhsTypeIndex)
+		t.report(diagnostics.CodeTypeMismatch, expr.SourcePosition,
+			"Cannot default a value of type %s to a value of type %s", lhsTypeName, rhsTypeName).
+			WithLabel(lhs.GetSourcePosition(), "Left operand has type %s", lhsTypeName).
+			WithLabel(rhs.GetSourcePosition(), "Right operand has type %s", rhsTypeName)
+	}
+
+	return &OptionalDefaultExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs:            lhs,
+		Rhs:            rhs,
+		TypeIndex:      typeIndex,
+	}
+}
 
-	fields := make([]*RecordFieldExpr, 0)
-	fieldNameIndexes := make([]pools.NameIndex, 0)
-	fieldTypeIndexes := make([]types.TypeIndex, 0)
+//---------------------------------------------------------------------------------------------------------------------
 
-	for _, priorField := range expr.Fields {
-		// Type check each field
-		field := t.typeCheckRecordFieldExpr(priorField, idContexts)
-		fields = append(fields, field)
+// typeCheckOptionalExpr checks a "?" type, whose operand may also be a record standing for the type of its fields.
+func (t *typeChecker) typeCheckOptionalExpr(expr *prior.OptionalExpr, idContexts []types.TypeIndex) IExpression {
+	operand := t.checkTypes(expr.Operand, idContexts)
 
-		// Accumulate the field names and types
-		fieldTypeIndex := field.FieldValue.GetTypeIndex()
-		fieldNameIndexes = append(fieldNameIndexes, field.FieldNameIndex)
-		fieldTypeIndexes = append(fieldTypeIndexes, fieldTypeIndex)
+	var valueTypeIndex types.TypeIndex
+	if record, ok := operand.(*RecordExpr); ok {
+		valueTypeIndex = record.TypeIndex
+	} else {
+		valueTypeIndex = t.checkTypeOperand(operand,
+			"Expected a type or a literal value before '?' but found a value of type %s")
 	}
 
-	// Build the record type from its field names and types
-	recordType := &types.RecordType{
-		FieldNameIndexes: fieldNameIndexes,
-		FieldTypeIndexes: fieldTypeIndexes,
+	if valueTypeIndex == types.BuiltInTypeIndexError {
+		return &OptionalTypeExpr{
+			SourcePosition: expr.SourcePosition,
+			ValueIndex:     types.BuiltInTypeIndexError,
+		}
 	}
-	typeIndex := t.TypePool.Put(recordType)
 
-	return &RecordExpr{
+	return &OptionalTypeExpr{
 		SourcePosition: expr.SourcePosition,
-		Fields:         fields,
-		TypeIndex:      typeIndex,
+		ValueIndex:     t.TypePool.PutOptional(valueTypeIndex),
 	}
-
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
-func (t *typeChecker) typeCheckRecordFieldExpr(expr *prior.RecordFieldExpr, idContexts []types.TypeIndex) *RecordFieldExpr {
-	return &RecordFieldExpr{
+func (t *typeChecker) typeCheckParenthesizedExpr(expr *prior.ParenthesizedExpr, idContexts []types.TypeIndex) IExpression {
+
+	inner := t.checkTypes(expr.InnerExpr, idContexts)
+
+	return &ParenthesizedExpr{
 		SourcePosition: expr.SourcePosition,
-		FieldNameIndex: expr.FieldNameIndex,
-		FieldValue:     t.checkTypes(expr.FieldValue, idContexts),
+		InnerExpr:      inner,
+		TypeIndex:      inner.GetTypeIndex(),
 	}
+
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
-func (t *typeChecker) typeCheckStringLiteralExpr(expr *prior.StringLiteralExpr) IExpression {
-	return &StringLiteralExpr{
+func (t *typeChecker) typeCheckRangeExpr(expr *prior.RangeExpr, idContexts []types.TypeIndex) IExpression {
+	first := t.checkTypes(expr.First, idContexts)
+	last := t.checkTypes(expr.Last, idContexts)
+	boundTypeIndex := t.checkOperandTypes(expr.SourcePosition, "..", "Cannot make a range from %s to %s", first, last,
+		types.BuiltInTypeIndexFloat64, types.BuiltInTypeIndexInt64, types.BuiltInTypeIndexString)
+
+	typeIndex := types.BuiltInTypeIndexError
+	if boundTypeIndex != types.BuiltInTypeIndexError {
+		typeIndex = t.TypePool.PutRange(boundTypeIndex)
+	}
+
+	return &RangeExpr{
 		SourcePosition: expr.SourcePosition,
-		ValueIndex:     expr.ValueIndex,
+		First:          first,
+		Last:           last,
+		TypeIndex:      typeIndex,
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
-func (t *typeChecker) typeCheckSubtractionExpr(expr *prior.SubtractionExpr, idContexts []types.TypeIndex) IExpression {
-	lhs := t.checkTypes(expr.Lhs, idContexts)
+func (t *typeChecker) typeCheckRecordExpr(expr *prior.RecordExpr, idContexts []types.TypeIndex) IExpression {
+
+	// TODO: make sure fields are in the same order as the record type
+
+	fields := make([]*RecordFieldExpr, len(expr.Fields))
+
+	// Build the record type from its field names, types, and presences, visible to the names in its own fields
+	recordType := &types.RecordType{
+		FieldNameIndexes: make([]pools.NameIndex, len(expr.Fields)),
+		FieldTypeIndexes: make([]types.TypeIndex, len(expr.Fields)),
+		FieldPresences:   make([]types.RecordFieldPresence, len(expr.Fields)),
+	}
+	t.recordsUnderConstruction = append(t.recordsUnderConstruction, recordType)
+
+	// Functions can be called before their bodies are checked, even from their own bodies
+	for i, field := range expr.Fields {
+		if function, ok := field.FieldValue.(*prior.FunctionExpr); ok {
+			recordType.FieldNameIndexes[i] = field.FieldNameIndex
+			recordType.FieldTypeIndexes[i] = t.functionSignature(function, idContexts).typeIndex
+			recordType.FieldPresences[i] = types.RecordFieldPresenceValue
+		}
+	}
+
+	// Type check each field after the fields it refers to
+	for _, i := range expr.EvaluationOrder {
+		field := t.typeCheckRecordFieldExpr(expr.Fields[i], idContexts)
+		fields[i] = field
+
+		recordType.FieldNameIndexes[i] = field.FieldNameIndex
+		recordType.FieldTypeIndexes[i] = field.TypeIndex
+
+		switch {
+		case field.FieldValue != nil:
+			recordType.FieldPresences[i] = types.RecordFieldPresenceValue
+		case field.DefaultValue != nil:
+			recordType.FieldPresences[i] = types.RecordFieldPresenceDefault
+		default:
+			recordType.FieldPresences[i] = types.RecordFieldPresenceRequired
+		}
+	}
+
+	t.recordsUnderConstruction = t.recordsUnderConstruction[:len(t.recordsUnderConstruction)-1]
+
+	typeIndex := t.TypePool.Put(recordType)
+
+	// Remember the field values for detecting conflicts when the record is intersected with another one
+	fieldValues := make([]IExpression, len(fields))
+	for i, field := range fields {
+		fieldValues[i] = field.FieldValue
+	}
+	t.recordFieldValues[typeIndex] = fieldValues
+
+	return &RecordExpr{
+		SourcePosition:  expr.SourcePosition,
+		Fields:          fields,
+		EvaluationOrder: expr.EvaluationOrder,
+		TypeIndex:       typeIndex,
+	}
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (t *typeChecker) typeCheckRecordFieldExpr(expr *prior.RecordFieldExpr, idContexts []types.TypeIndex) *RecordFieldExpr {
+
+	fieldName := t.IdentifierNames.Get(expr.FieldNameIndex)
+
+	var fieldType IExpression
+	declaredTypeIndex := types.BuiltInTypeIndexError
+	if expr.FieldType != nil {
+		fieldType = t.checkTypes(expr.FieldType, idContexts)
+		declaredTypeIndex = t.checkDeclaredFieldType(fieldType)
+	}
+
+	value := t.checkFieldValue(expr.FieldValue, declaredTypeIndex, idContexts)
+	defaultValue := t.checkFieldValue(expr.DefaultValue, declaredTypeIndex, idContexts)
+
+	var typeIndex types.TypeIndex
+
+	switch {
+	case expr.FieldType != nil:
+		// The declared type, if any, is the type of the field; check the value and default value against it.
+		typeIndex = declaredTypeIndex
+
+		// A field of optional type is none until given a value
+		if _, isOptional := t.optionalValueTypeIndex(typeIndex); isOptional && value == nil && defaultValue == nil {
+			defaultValue = &NoneExpr{
+				SourcePosition: expr.SourcePosition,
+			}
+		}
+
+		t.checkFieldValueType(fieldName, typeIndex, value,
+			"Field '%s' is declared as %s but its value has type %s")
+		t.checkFieldValueType(fieldName, typeIndex, defaultValue,
+			"Field '%s' is declared as %s but its default value has type %s")
+
+		// Records within a value keep the fields they give values to
+		if value != nil && typeIndex != types.BuiltInTypeIndexError && t.isAssignable(value, typeIndex) {
+			typeIndex = t.fieldTypeWithValuePresences(typeIndex, value.GetTypeIndex())
+		}
+
+	case value != nil:
+		// Otherwise the value gives the type, and the default value, if any, must agree with it.
+		typeIndex = value.GetTypeIndex()
+
+		if _, isFunction := t.TypePool.Get(typeIndex).(*types.FunctionType); isFunction {
+			if _, ok := value.(*FunctionExpr); !ok {
+				t.report(diagnostics.CodeUnsupportedExpression, value.GetSourcePosition(),
+					"Field '%s' cannot be another name for a function; define it as 'name: (n: Type) -> Type = body'",
+					fieldName)
+				typeIndex = types.BuiltInTypeIndexError
+			}
+		}
+
+		t.checkFieldValueType(fieldName, typeIndex, defaultValue,
+			"Field '%s' has a value of type %s but its default value has type %s")
+
+	default:
+		typeIndex = defaultValue.GetTypeIndex()
+
+	}
+
+	return &RecordFieldExpr{
+		SourcePosition: expr.SourcePosition,
+		FieldNameIndex: expr.FieldNameIndex,
+		FieldType:      fieldType,
+		FieldValue:     value,
+		DefaultValue:   defaultValue,
+		TypeIndex:      typeIndex,
+	}
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (t *typeChecker) typeCheckStringLiteralExpr(expr *prior.StringLiteralExpr) IExpression {
+	return &StringLiteralExpr{
+		SourcePosition: expr.SourcePosition,
+		ValueIndex:     expr.ValueIndex,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (t *typeChecker) typeCheckSubtractionExpr(expr *prior.SubtractionExpr, idContexts []types.TypeIndex) IExpression {
+	lhs := t.checkTypes(expr.Lhs, idContexts)
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	// TODO: ensure they're the same
+	typeIndex := t.checkArithmeticOperandTypes(expr.SourcePosition, "-", "Cannot subtract %[2]s from %[1]s", lhs, rhs,
+		types.BuiltInTypeIndexFloat64, types.BuiltInTypeIndexInt64)
 	return &SubtractionExpr{
 		SourcePosition: expr.SourcePosition,
 		Lhs:            lhs,
 		Rhs:            rhs,
-		TypeIndex:      lhs.GetTypeIndex(),
+		TypeIndex:      typeIndex,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (t *typeChecker) typeCheckUnionExpr(expr *prior.UnionExpr, idContexts []types.TypeIndex) IExpression {
+	lhs := t.checkTypes(expr.Lhs, idContexts)
+	rhs := t.checkTypes(expr.Rhs, idContexts)
+
+	const format = "Expected a type or a literal value in a union but found a value of type %s"
+	lhsTypeIndex := t.checkTypeOperand(lhs, format)
+	rhsTypeIndex := t.checkTypeOperand(rhs, format)
+
+	if lhsTypeIndex == types.BuiltInTypeIndexError || rhsTypeIndex == types.BuiltInTypeIndexError {
+		return &UnionTypeExpr{
+			SourcePosition: expr.SourcePosition,
+			ValueIndex:     types.BuiltInTypeIndexError,
+		}
+	}
+
+	return &UnionTypeExpr{
+		SourcePosition: expr.SourcePosition,
+		ValueIndex:     t.TypePool.PutUnion([]types.TypeIndex{lhsTypeIndex, rhsTypeIndex}),
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// typeCheckWhenExpr checks a chain of "when" alternatives, whose guards must be Bools and whose values must have one
+// type between them.
+func (t *typeChecker) typeCheckWhenExpr(expr *prior.WhenExpr, idContexts []types.TypeIndex) IExpression {
+	alternatives := make([]*WhenAlternativeExpr, len(expr.Alternatives))
+	values := make([]IExpression, len(expr.Alternatives))
+
+	for i, alternative := range expr.Alternatives {
+		value := t.checkTypes(alternative.Value, idContexts)
+
+		var guard IExpression
+		if alternative.Guard != nil {
+			guard = t.checkTypes(alternative.Guard, idContexts)
+
+			guardTypeIndex := guard.GetTypeIndex()
+			if guardTypeIndex != types.BuiltInTypeIndexError &&
+				t.TypePool.BaseTypeIndex(guardTypeIndex) != types.BuiltInTypeIndexBool {
+				t.report(diagnostics.CodeTypeMismatch, guard.GetSourcePosition(),
+					"Expected a Bool guard after 'when' but found %s", t.typeName(guardTypeIndex))
+			}
+		}
+
+		alternatives[i] = &WhenAlternativeExpr{
+			SourcePosition: alternative.SourcePosition,
+			Value:          value,
+			Guard:          guard,
+		}
+		values[i] = value
+	}
+
+	return &WhenExpr{
+		SourcePosition: expr.SourcePosition,
+		Alternatives:   alternatives,
+		TypeIndex:      t.widestType(values, "Expected a 'when' alternative of type %s but found %s"),
 	}
 }
 
@@ -496,7 +1775,7 @@ func (t *typeChecker) typeCheckSubtractionExpr(expr *prior.SubtractionExpr, idCo
 
 func (t *typeChecker) typeCheckWhereExpr(expr *prior.WhereExpr, idContexts []types.TypeIndex) IExpression {
 	rhs := t.checkTypes(expr.Rhs, idContexts)
-	rhsTypeIndex := rhs.GetTypeIndex()
+	rhsTypeIndex := t.checkRecordOperand(rhs, "Expected a record on the right hand side of 'where' but found %s")
 	lhs := t.checkTypes(expr.Lhs, append(idContexts, rhsTypeIndex))
 
 	return &WhereExpr{
@@ -507,4 +1786,702 @@ func (t *typeChecker) typeCheckWhereExpr(expr *prior.WhereExpr, idContexts []typ
 	}
 }
 
+//=====================================================================================================================
+
+// areTypesCompatible determines whether values of the two given types can be compared with each other. Record types
+// are compatible when they have the same field names in the same order with compatible field types, where fields of
+// literal or union types count as their base types. Array types are compatible when their element types are, counted
+// the same way. Optional types are compatible with "none" and with optional types of compatible value types.
+func (t *typeChecker) areTypesCompatible(typeIndex1 types.TypeIndex, typeIndex2 types.TypeIndex) bool {
+
+	if typeIndex1 == typeIndex2 {
+		return true
+	}
+
+	valueTypeIndex1, isOptional1 := t.optionalValueTypeIndex(typeIndex1)
+	valueTypeIndex2, isOptional2 := t.optionalValueTypeIndex(typeIndex2)
+
+	switch {
+	case isOptional1 && isOptional2:
+		return t.areTypesCompatible(t.TypePool.BaseTypeIndex(valueTypeIndex1), t.TypePool.BaseTypeIndex(valueTypeIndex2))
+	case isOptional1 || isOptional2:
+		return typeIndex1 == types.BuiltInTypeIndexNone || typeIndex2 == types.BuiltInTypeIndexNone
+	}
+
+	arrayType1, isArray1 := t.TypePool.Get(typeIndex1).(*types.ArrayType)
+	arrayType2, isArray2 := t.TypePool.Get(typeIndex2).(*types.ArrayType)
+
+	if isArray1 && isArray2 {
+		return t.areTypesCompatible(
+			t.TypePool.BaseTypeIndex(arrayType1.ElementTypeIndex),
+			t.TypePool.BaseTypeIndex(arrayType2.ElementTypeIndex),
+		)
+	}
+
+	recordType1, ok1 := t.TypePool.Get(typeIndex1).(*types.RecordType)
+	recordType2, ok2 := t.TypePool.Get(typeIndex2).(*types.RecordType)
+
+	if !ok1 || !ok2 || len(recordType1.FieldNameIndexes) != len(recordType2.FieldNameIndexes) {
+		return false
+	}
+
+	for i, fieldNameIndex := range recordType1.FieldNameIndexes {
+		if fieldNameIndex != recordType2.FieldNameIndexes[i] ||
+			!t.areTypesCompatible(
+				t.TypePool.BaseTypeIndex(recordType1.FieldTypeIndexes[i]),
+				t.TypePool.BaseTypeIndex(recordType2.FieldTypeIndexes[i]),
+			) {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// arithmeticRecordType determines the type of the result of an arithmetic operator applied field by field to two
+// records. The records must have the same field names, though not necessarily in the same order, and each field must
+// hold Float64 or Int64 values of one type on both sides or else records that combine in turn. The result has the
+// fields of the left hand record with their base types. Reports the first field that does not fit, naming it by its
+// path from the outermost records, and returns the error type along with that diagnostic.
+func (t *typeChecker) arithmeticRecordType(
+	sourcePosition util.SourcePos,
+	operator string,
+	mismatchFormat string,
+	lhsTypeIndex types.TypeIndex,
+	rhsTypeIndex types.TypeIndex,
+	pathPrefix string,
+) (types.TypeIndex, *diagnostics.Diagnostic) {
+
+	lhsType := t.TypePool.Get(lhsTypeIndex).(*types.RecordType)
+	rhsType := t.TypePool.Get(rhsTypeIndex).(*types.RecordType)
+
+	fail := func(code diagnostics.Code, format string, args ...any) (types.TypeIndex, *diagnostics.Diagnostic) {
+		return types.BuiltInTypeIndexError, t.report(code, sourcePosition, format, args...)
+	}
+
+	for _, fieldNameIndex := range rhsType.FieldNameIndexes {
+		if lhsType.FieldIndex(fieldNameIndex) < 0 {
+			return fail(diagnostics.CodeTypeMismatch, "Field '%s' is missing from the left operand of '%s'",
+				pathPrefix+t.IdentifierNames.Get(fieldNameIndex), operator)
+		}
+	}
+
+	recordType := &types.RecordType{
+		FieldNameIndexes: append([]pools.NameIndex(nil), lhsType.FieldNameIndexes...),
+		FieldTypeIndexes: make([]types.TypeIndex, len(lhsType.FieldNameIndexes)),
+	}
+
+	for i, fieldNameIndex := range lhsType.FieldNameIndexes {
+		fieldPath := pathPrefix + t.IdentifierNames.Get(fieldNameIndex)
+
+		j := rhsType.FieldIndex(fieldNameIndex)
+		if j < 0 {
+			return fail(diagnostics.CodeTypeMismatch, "Field '%s' is missing from the right operand of '%s'",
+				fieldPath, operator)
+		}
+
+		if lhsType.FieldPresence(i) == types.RecordFieldPresenceRequired ||
+			rhsType.FieldPresence(j) == types.RecordFieldPresenceRequired {
+			return fail(diagnostics.CodeMissingFieldValue, "Missing value for required field '%s'", fieldPath)
+		}
+
+		lhsFieldTypeIndex := lhsType.FieldTypeIndexes[i]
+		rhsFieldTypeIndex := rhsType.FieldTypeIndexes[j]
+		lhsFieldBaseTypeIndex := t.TypePool.BaseTypeIndex(lhsFieldTypeIndex)
+		rhsFieldBaseTypeIndex := t.TypePool.BaseTypeIndex(rhsFieldTypeIndex)
+
+		switch {
+
+		case t.isRecordType(lhsFieldBaseTypeIndex) && t.isRecordType(rhsFieldBaseTypeIndex):
+			fieldTypeIndex, diagnostic := t.arithmeticRecordType(sourcePosition, operator, mismatchFormat,
+				lhsFieldBaseTypeIndex, rhsFieldBaseTypeIndex, fieldPath+".")
+			if diagnostic != nil {
+				return types.BuiltInTypeIndexError, diagnostic
+			}
+			recordType.FieldTypeIndexes[i] = fieldTypeIndex
+
+		case lhsFieldBaseTypeIndex != rhsFieldBaseTypeIndex:
+			return fail(diagnostics.CodeTypeMismatch, mismatchFormat+" in field '%[3]s'",
+				t.typeName(lhsFieldTypeIndex), t.typeName(rhsFieldTypeIndex), fieldPath)
+
+		case lhsFieldBaseTypeIndex != types.BuiltInTypeIndexFloat64 && lhsFieldBaseTypeIndex != types.BuiltInTypeIndexInt64:
+			return fail(diagnostics.CodeTypeMismatch, "Operator '%s' is not defined for type %s in field '%s'",
+				operator, t.typeName(lhsFieldTypeIndex), fieldPath)
+
+		default:
+			recordType.FieldTypeIndexes[i] = lhsFieldBaseTypeIndex
+
+		}
+	}
+
+	return t.TypePool.Put(recordType), nil
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// fieldTypeWithValuePresences determines the type of a field declared with a type and given a value of that type: the
+// declared type, except that the fields of records within it, whether optional or array elements, take the more
+// definite presences of the corresponding fields of the value, e.g. {a: Int64}? given {a = 1} has a value for a.
+func (t *typeChecker) fieldTypeWithValuePresences(
+	declaredTypeIndex types.TypeIndex,
+	valueTypeIndex types.TypeIndex,
+) types.TypeIndex {
+
+	if declaredTypeIndex == valueTypeIndex {
+		return declaredTypeIndex
+	}
+
+	switch declaredType := t.TypePool.Get(declaredTypeIndex).(type) {
+
+	case *types.OptionalType:
+		valueTypeIndex, _ = t.optionalValueTypeIndex(valueTypeIndex)
+		typeIndex := t.fieldTypeWithValuePresences(declaredType.ValueTypeIndex, valueTypeIndex)
+		if typeIndex != declaredType.ValueTypeIndex {
+			return t.TypePool.PutOptional(typeIndex)
+		}
+
+	case *types.ArrayType:
+		if valueType, ok := t.TypePool.Get(valueTypeIndex).(*types.ArrayType); ok {
+			typeIndex := t.fieldTypeWithValuePresences(declaredType.ElementTypeIndex, valueType.ElementTypeIndex)
+			if typeIndex != declaredType.ElementTypeIndex {
+				return t.TypePool.PutArray(typeIndex)
+			}
+		}
+
+	case *types.RecordType:
+		valueType, ok := t.TypePool.Get(valueTypeIndex).(*types.RecordType)
+		if !ok {
+			break
+		}
+
+		recordType := &types.RecordType{
+			FieldNameIndexes: declaredType.FieldNameIndexes,
+			FieldTypeIndexes: make([]types.TypeIndex, len(declaredType.FieldNameIndexes)),
+			FieldPresences:   make([]types.RecordFieldPresence, len(declaredType.FieldNameIndexes)),
+		}
+		changed := false
+
+		for i, fieldNameIndex := range declaredType.FieldNameIndexes {
+			recordType.FieldTypeIndexes[i] = declaredType.FieldTypeIndexes[i]
+			recordType.FieldPresences[i] = declaredType.FieldPresence(i)
+
+			j := valueType.FieldIndex(fieldNameIndex)
+			if j < 0 {
+				continue
+			}
+
+			fieldTypeIndex := t.fieldTypeWithValuePresences(declaredType.FieldTypeIndexes[i], valueType.FieldTypeIndexes[j])
+			if fieldTypeIndex != recordType.FieldTypeIndexes[i] {
+				recordType.FieldTypeIndexes[i] = fieldTypeIndex
+				changed = true
+			}
+
+			if presence := valueType.FieldPresence(j); presence < recordType.FieldPresences[i] {
+				recordType.FieldPresences[i] = presence
+				changed = true
+			}
+		}
+
+		if changed {
+			return t.TypePool.Put(recordType)
+		}
+
+	}
+
+	return declaredTypeIndex
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// functionSignature determines the signature of a function from the declared types of its parameters and result,
+// checking them only once even though the signature is needed both before and while checking the body.
+func (t *typeChecker) functionSignature(expr *prior.FunctionExpr, idContexts []types.TypeIndex) *functionSignature {
+
+	if signature, found := t.functionSignatures[expr]; found {
+		return signature
+	}
+
+	signature := t.checkFunctionSignature(expr.Parameters, expr.ResultType, idContexts)
+	t.functionSignatures[expr] = signature
+
+	return signature
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// isAssignable determines whether the value of an expression is a value of the given type. Beyond the type of the
+// expression, a literal value is known to be a value of its literal type.
+func (t *typeChecker) isAssignable(value IExpression, typeIndex types.TypeIndex) bool {
+
+	if t.isTypeAssignable(value.GetTypeIndex(), typeIndex) {
+		return true
+	}
+
+	// Any value of the constrained type might satisfy a constraint; its predicate is checked once the value is known
+	if constraintType, ok := t.TypePool.Get(typeIndex).(*types.ConstraintType); ok {
+		return t.isAssignable(value, constraintType.ConstrainedTypeIndex)
+	}
+
+	literalTypeIndex, ok := t.literalTypeIndex(value)
+
+	return ok && t.isTypeAssignable(literalTypeIndex, typeIndex)
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// isTypeAssignable determines whether every value of the first given type is also a value of the second one.
+func (t *typeChecker) isTypeAssignable(typeIndex types.TypeIndex, targetTypeIndex types.TypeIndex) bool {
+
+	if typeIndex == targetTypeIndex {
+		return true
+	}
+
+	targetValueTypeIndex, isTargetOptional := t.optionalValueTypeIndex(targetTypeIndex)
+
+	switch typ := t.TypePool.Get(typeIndex).(type) {
+	case *types.NoneType:
+		return isTargetOptional
+	case *types.OptionalType:
+		return isTargetOptional && t.isTypeAssignable(typ.ValueTypeIndex, targetValueTypeIndex)
+	case *types.ArrayType:
+		targetType, ok := t.TypePool.Get(targetTypeIndex).(*types.ArrayType)
+		if ok && t.isTypeAssignable(typ.ElementTypeIndex, targetType.ElementTypeIndex) {
+			return true
+		}
+	case *types.ConstraintType:
+		if t.isTypeAssignable(typ.ConstrainedTypeIndex, targetTypeIndex) {
+			return true
+		}
+	case *types.LiteralType:
+		if t.isTypeAssignable(typ.BaseTypeIndex, targetTypeIndex) {
+			return true
+		}
+	case *types.UnionType:
+		for _, memberTypeIndex := range typ.MemberTypeIndexes {
+			if !t.isTypeAssignable(memberTypeIndex, targetTypeIndex) {
+				return false
+			}
+		}
+		return true
+	case *types.RecordType:
+		targetType, ok := t.TypePool.Get(targetTypeIndex).(*types.RecordType)
+		if !ok {
+			break
+		}
+		if len(typ.FieldNameIndexes) != len(targetType.FieldNameIndexes) {
+			return false
+		}
+		for i, fieldNameIndex := range typ.FieldNameIndexes {
+			if fieldNameIndex != targetType.FieldNameIndexes[i] ||
+				!t.isTypeAssignable(typ.FieldTypeIndexes[i], targetType.FieldTypeIndexes[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if targetType, ok := t.TypePool.Get(targetTypeIndex).(*types.UnionType); ok {
+		for _, memberTypeIndex := range targetType.MemberTypeIndexes {
+			if t.isTypeAssignable(typeIndex, memberTypeIndex) {
+				return true
+			}
+		}
+	}
+
+	return isTargetOptional && t.isTypeAssignable(typeIndex, targetValueTypeIndex)
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// literalTypeIndex finds the literal type whose only value is the value of a given literal expression.
+func (t *typeChecker) literalTypeIndex(expr IExpression) (types.TypeIndex, bool) {
+
+	switch e := expr.(type) {
+	case *BooleanLiteralExpr:
+		value := uint64(0)
+		if e.Value {
+			value = 1
+		}
+		return t.TypePool.PutLiteral(types.BuiltInTypeIndexBool, value, e.SourcePosition.GetText(t.SourceCode)), true
+	case *Float64LiteralExpr:
+		return t.TypePool.PutLiteral(types.BuiltInTypeIndexFloat64, math.Float64bits(e.Value),
+			e.SourcePosition.GetText(t.SourceCode)), true
+	case *Int64LiteralExpr:
+		return t.TypePool.PutLiteral(types.BuiltInTypeIndexInt64, uint64(e.Value),
+			e.SourcePosition.GetText(t.SourceCode)), true
+	case *StringLiteralExpr:
+		return t.TypePool.PutLiteral(types.BuiltInTypeIndexString, uint64(e.ValueIndex),
+			scanning.EncodeStringLiteral(t.StringConstants.Get(e.ValueIndex), '"')), true
+	}
+
+	return types.BuiltInTypeIndexError, false
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// mergeRecordTypes determines the type of the intersection of two records: the fields of the left hand record followed
+// by the fields found only in the right hand record, each with the more definite presence of the two records. Fields
+// with record values on both sides are intersected in turn. Other fields in both records take the narrower of their
+// two types, e.g. a union of literals rather than String, kept optional if either one is, and their values must fit
+// it. Fields with values on both sides conflict if they are different constants; other values given on both sides are
+// compared when the code runs.
+func (t *typeChecker) mergeRecordTypes(
+	sourcePosition util.SourcePos,
+	lhsTypeIndex types.TypeIndex,
+	rhsTypeIndex types.TypeIndex,
+) types.TypeIndex {
+
+	lhsType := t.TypePool.Get(lhsTypeIndex).(*types.RecordType)
+	rhsType := t.TypePool.Get(rhsTypeIndex).(*types.RecordType)
+	lhsValues := t.recordFieldValues[lhsTypeIndex]
+	rhsValues := t.recordFieldValues[rhsTypeIndex]
+
+	recordType := &types.RecordType{
+		FieldNameIndexes: append([]pools.NameIndex(nil), lhsType.FieldNameIndexes...),
+		FieldTypeIndexes: append([]types.TypeIndex(nil), lhsType.FieldTypeIndexes...),
+		FieldPresences:   make([]types.RecordFieldPresence, len(lhsType.FieldNameIndexes)),
+	}
+	fieldValues := make([]IExpression, len(lhsType.FieldNameIndexes))
+
+	for i := range lhsType.FieldNameIndexes {
+		recordType.FieldPresences[i] = lhsType.FieldPresence(i)
+		fieldValues[i] = fieldValueAt(lhsValues, i)
+	}
+
+	for j, fieldNameIndex := range rhsType.FieldNameIndexes {
+		fieldTypeIndex := rhsType.FieldTypeIndexes[j]
+		presence := rhsType.FieldPresence(j)
+		value := fieldValueAt(rhsValues, j)
+
+		i := recordType.FieldIndex(fieldNameIndex)
+
+		switch {
+
+		case i < 0:
+			recordType.FieldNameIndexes = append(recordType.FieldNameIndexes, fieldNameIndex)
+			recordType.FieldTypeIndexes = append(recordType.FieldTypeIndexes, fieldTypeIndex)
+			recordType.FieldPresences = append(recordType.FieldPresences, presence)
+			fieldValues = append(fieldValues, value)
+
+		case presence == types.RecordFieldPresenceValue && recordType.FieldPresences[i] == types.RecordFieldPresenceValue &&
+			t.isRecordType(recordType.FieldTypeIndexes[i]) && t.isRecordType(fieldTypeIndex):
+			recordType.FieldTypeIndexes[i] = t.mergeRecordTypes(sourcePosition, recordType.FieldTypeIndexes[i], fieldTypeIndex)
+			fieldValues[i] = nil
+
+		default:
+			fieldName := t.IdentifierNames.Get(fieldNameIndex)
+
+			// The narrower of the two field types applies to the values on both sides
+			narrowerTypeIndex, ok := t.narrowerFieldType(recordType.FieldTypeIndexes[i], fieldTypeIndex)
+			if !ok {
+				t.report(diagnostics.CodeTypeMismatch, sourcePosition,
+					"Cannot intersect field '%s' of type %s with field '%s' of type %s",
+					fieldName, t.typeName(recordType.FieldTypeIndexes[i]), fieldName, t.typeName(fieldTypeIndex))
+				return types.BuiltInTypeIndexError
+			}
+			recordType.FieldTypeIndexes[i] = narrowerTypeIndex
+
+			t.checkFieldValueType(fieldName, recordType.FieldTypeIndexes[i], fieldValues[i],
+				"Field '%s' is declared as %s but its value has type %s")
+			t.checkFieldValueType(fieldName, recordType.FieldTypeIndexes[i], value,
+				"Field '%s' is declared as %s but its value has type %s")
+
+			bothValues := presence == types.RecordFieldPresenceValue &&
+				recordType.FieldPresences[i] == types.RecordFieldPresenceValue
+
+			if bothValues && areDifferentConstants(fieldValues[i], value) {
+				t.reportConflictingValues(sourcePosition, fieldNameIndex, fieldValues[i], value)
+			} else if !bothValues && presence <= recordType.FieldPresences[i] {
+				recordType.FieldPresences[i] = presence
+				fieldValues[i] = value
+			}
+
+		}
+	}
+
+	typeIndex := t.TypePool.Put(recordType)
+	t.recordFieldValues[typeIndex] = fieldValues
+
+	return typeIndex
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// narrowerFieldType determines the type of a field found in both of two intersected records: the narrower of its two
+// types, kept optional if either one is optional or "none". Returns false if neither type is narrower.
+func (t *typeChecker) narrowerFieldType(typeIndex1 types.TypeIndex, typeIndex2 types.TypeIndex) (types.TypeIndex, bool) {
+
+	switch {
+	case typeIndex1 == types.BuiltInTypeIndexNone:
+		return t.TypePool.PutOptional(typeIndex2), true
+	case typeIndex2 == types.BuiltInTypeIndexNone:
+		return t.TypePool.PutOptional(typeIndex1), true
+	}
+
+	valueTypeIndex1, isOptional1 := t.optionalValueTypeIndex(typeIndex1)
+	valueTypeIndex2, isOptional2 := t.optionalValueTypeIndex(typeIndex2)
+
+	var result types.TypeIndex
+	switch {
+	case t.isTypeAssignable(valueTypeIndex1, valueTypeIndex2):
+		result = valueTypeIndex1
+	case t.isTypeAssignable(valueTypeIndex2, valueTypeIndex1):
+		result = valueTypeIndex2
+	default:
+		return types.BuiltInTypeIndexError, false
+	}
+
+	if isOptional1 || isOptional2 {
+		result = t.TypePool.PutOptional(result)
+	}
+
+	return result, true
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// optionalValueTypeIndex finds the type of the present values of an optional type. Returns the given type itself and
+// false if it is not optional.
+func (t *typeChecker) optionalValueTypeIndex(typeIndex types.TypeIndex) (types.TypeIndex, bool) {
+	if optionalType, ok := t.TypePool.Get(typeIndex).(*types.OptionalType); ok {
+		return optionalType.ValueTypeIndex, true
+	}
+	return typeIndex, false
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// isRecordType determines whether the type with given index is a record type.
+func (t *typeChecker) isRecordType(typeIndex types.TypeIndex) bool {
+	_, ok := t.TypePool.Get(typeIndex).(*types.RecordType)
+	return ok
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// rangeConstraintTypeIndex turns a range used as the declared type of a field into the constraint that a value lie
+// within the range, e.g. 1..10 into Int64 && val in 1..10. The bounds must be constants.
+func (t *typeChecker) rangeConstraintTypeIndex(expr *RangeExpr) types.TypeIndex {
+
+	if expr.TypeIndex == types.BuiltInTypeIndexError {
+		return types.BuiltInTypeIndexError
+	}
+
+	for _, bound := range []IExpression{expr.First, expr.Last} {
+		if !isConstant(bound) {
+			t.report(diagnostics.CodeUnsupportedExpression, bound.GetSourcePosition(),
+				"Expected a constant bound for a range used as a type")
+			return types.BuiltInTypeIndexError
+		}
+	}
+
+	boundTypeIndex := t.TypePool.Get(expr.TypeIndex).(*types.RangeType).BoundTypeIndex
+	predicate := &InExpr{
+		SourcePosition: expr.SourcePosition,
+		Lhs: &ConstrainedValueExpr{
+			SourcePosition: expr.SourcePosition,
+			TypeIndex:      boundTypeIndex,
+		},
+		Rhs: expr,
+	}
+
+	typeIndex := t.TypePool.PutConstraint(boundTypeIndex, "val in "+expr.SourcePosition.GetText(t.SourceCode))
+	t.constraintPredicates[typeIndex] = predicate
+
+	return typeIndex
+
+}
+
 //---------------------------------------------------------------------------------------------------------------------
+
+// reportConflictingValues reports two different values given to the same field by intersected records, pointing out
+// both values where they are known.
+func (t *typeChecker) reportConflictingValues(
+	sourcePosition util.SourcePos,
+	fieldNameIndex pools.NameIndex,
+	value1 IExpression,
+	value2 IExpression,
+) {
+
+	fieldName := t.IdentifierNames.Get(fieldNameIndex)
+
+	diagnostic := t.report(diagnostics.CodeConflictingValues, sourcePosition,
+		"Conflicting values for field '%s'", fieldName)
+
+	if value1 != nil {
+		diagnostic.WithLabel(value1.GetSourcePosition(), "One value of '%s' is given here", fieldName)
+	}
+	if value2 != nil {
+		diagnostic.WithLabel(value2.GetSourcePosition(), "Another value of '%s' is given here", fieldName)
+	}
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// typeName describes a type for a diagnostic, spelling out the fields of a record type.
+func (t *typeChecker) typeName(typeIndex types.TypeIndex) string {
+
+	if arrayType, ok := t.TypePool.Get(typeIndex).(*types.ArrayType); ok {
+		elementTypeIndex, _ := t.optionalValueTypeIndex(arrayType.ElementTypeIndex)
+		switch t.TypePool.Get(elementTypeIndex).(type) {
+		case *types.ArrayType, *types.RecordType:
+			return t.typeName(arrayType.ElementTypeIndex) + "[]"
+		}
+	}
+
+	if optionalType, ok := t.TypePool.Get(typeIndex).(*types.OptionalType); ok && t.isRecordType(optionalType.ValueTypeIndex) {
+		return t.typeName(optionalType.ValueTypeIndex) + "?"
+	}
+
+	recordType, ok := t.TypePool.Get(typeIndex).(*types.RecordType)
+	if !ok {
+		return t.TypePool.Get(typeIndex).Name()
+	}
+
+	fields := make([]string, 0)
+	for i, fieldNameIndex := range recordType.FieldNameIndexes {
+		fields = append(fields, t.IdentifierNames.Get(fieldNameIndex)+": "+t.typeName(recordType.FieldTypeIndexes[i]))
+	}
+
+	return "{" + strings.Join(fields, ", ") + "}"
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// widerType determines the one type of values of the two given types, as needed for the elements of an array or the
+// alternatives of a "when" chain: the wider of the two types, made optional if either one is optional or "none".
+// Returns false if neither type is wider.
+func (t *typeChecker) widerType(typeIndex1 types.TypeIndex, typeIndex2 types.TypeIndex) (types.TypeIndex, bool) {
+
+	switch {
+	case typeIndex1 == types.BuiltInTypeIndexNone:
+		return t.TypePool.PutOptional(typeIndex2), true
+	case typeIndex2 == types.BuiltInTypeIndexNone:
+		return t.TypePool.PutOptional(typeIndex1), true
+	}
+
+	valueTypeIndex1, isOptional1 := t.optionalValueTypeIndex(typeIndex1)
+	valueTypeIndex2, isOptional2 := t.optionalValueTypeIndex(typeIndex2)
+
+	var result types.TypeIndex
+	switch {
+	case t.isTypeAssignable(valueTypeIndex2, valueTypeIndex1):
+		result = valueTypeIndex1
+	case t.isTypeAssignable(valueTypeIndex1, valueTypeIndex2):
+		result = valueTypeIndex2
+	default:
+		return types.BuiltInTypeIndexError, false
+	}
+
+	if isOptional1 || isOptional2 {
+		result = t.TypePool.PutOptional(result)
+	}
+
+	return result, true
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// widestType determines the one type of the values of several expressions, the widest of their types, reporting any
+// value whose type fits neither way with the given format. Returns the error type when the values have no such type.
+func (t *typeChecker) widestType(values []IExpression, format string) types.TypeIndex {
+
+	result := values[0].GetTypeIndex()
+
+	for _, value := range values[1:] {
+		valueTypeIndex := value.GetTypeIndex()
+
+		if result == types.BuiltInTypeIndexError || valueTypeIndex == types.BuiltInTypeIndexError {
+			result = types.BuiltInTypeIndexError
+			continue
+		}
+
+		widerTypeIndex, ok := t.widerType(result, valueTypeIndex)
+		if !ok {
+			t.report(diagnostics.CodeTypeMismatch, value.GetSourcePosition(),
+				format, t.typeName(result), t.typeName(valueTypeIndex))
+			result = types.BuiltInTypeIndexError
+			continue
+		}
+
+		result = widerTypeIndex
+	}
+
+	return result
+
+}
+
+//=====================================================================================================================
+
+// areDifferentConstants determines whether two expressions are literals of different constant values. Values that are
+// not both literals are only known when the code runs.
+func areDifferentConstants(expr1 IExpression, expr2 IExpression) bool {
+	switch e1 := expr1.(type) {
+	case *BooleanLiteralExpr:
+		e2, ok := expr2.(*BooleanLiteralExpr)
+		return ok && e1.Value != e2.Value
+	case *Float64LiteralExpr:
+		e2, ok := expr2.(*Float64LiteralExpr)
+		return ok && e1.Value != e2.Value
+	case *Int64LiteralExpr:
+		e2, ok := expr2.(*Int64LiteralExpr)
+		return ok && e1.Value != e2.Value
+	case *StringLiteralExpr:
+		e2, ok := expr2.(*StringLiteralExpr)
+		return ok && e1.ValueIndex != e2.ValueIndex
+	}
+	return false
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// containsTypeIndex determines whether a type index is one of the given type indexes.
+func containsTypeIndex(typeIndexes []types.TypeIndex, typeIndex types.TypeIndex) bool {
+	for _, index := range typeIndexes {
+		if index == typeIndex {
+			return true
+		}
+	}
+	return false
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// fieldValueAt returns the expression giving the value of the field with given index, if known.
+func fieldValueAt(fieldValues []IExpression, fieldIndex int) IExpression {
+	if fieldValues == nil {
+		return nil
+	}
+	return fieldValues[fieldIndex]
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// isConstant determines whether an expression is a literal, possibly negated or parenthesized.
+func isConstant(expr IExpression) bool {
+	switch e := expr.(type) {
+	case *Float64LiteralExpr, *Int64LiteralExpr, *StringLiteralExpr:
+		return true
+	case *NegationOperationExpr:
+		return isConstant(e.Operand)
+	case *ParenthesizedExpr:
+		return isConstant(e.InnerExpr)
+	}
+	return false
+}
+
+//=====================================================================================================================
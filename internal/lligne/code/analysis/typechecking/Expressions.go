@@ -6,7 +6,9 @@
 package typechecking
 
 import (
+	"lligne-cli/internal/lligne/code/analysis/nameresolution"
 	"lligne-cli/internal/lligne/code/util"
+	"lligne-cli/internal/lligne/runtime/collations"
 	"lligne-cli/internal/lligne/runtime/pools"
 	"lligne-cli/internal/lligne/runtime/types"
 )
@@ -36,11 +38,37 @@ func (e *AdditionExpr) isTypeExpression()                 {}
 
 //=====================================================================================================================
 
-// ArrayLiteralExpr represents a parenthesized expression or comma-separated sequence of expressions.
+// ArrayIndexExpr represents an array indexing ("a[i]") operation.
+type ArrayIndexExpr struct {
+	SourcePosition util.SourcePos
+	Array          IExpression
+	Index          IExpression
+	TypeIndex      types.TypeIndex // The element type of the array
+}
+
+func (e *ArrayIndexExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *ArrayIndexExpr) GetTypeIndex() types.TypeIndex     { return e.TypeIndex }
+func (e *ArrayIndexExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// ArrayLengthExpr represents the length of an array ("a.length").
+type ArrayLengthExpr struct {
+	SourcePosition util.SourcePos
+	Array          IExpression
+}
+
+func (e *ArrayLengthExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *ArrayLengthExpr) GetTypeIndex() types.TypeIndex     { return types.BuiltInTypeIndexInt64 }
+func (e *ArrayLengthExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// ArrayLiteralExpr represents an array literal.
 type ArrayLiteralExpr struct {
 	SourcePosition util.SourcePos
 	Elements       []IExpression
-	TypeIndex      types.TypeIndex // TODO: Should be element type
+	TypeIndex      types.TypeIndex
 }
 
 func (e *ArrayLiteralExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
@@ -49,6 +77,18 @@ func (e *ArrayLiteralExpr) isTypeExpression()                 {}
 
 //=====================================================================================================================
 
+// ArrayTypeExpr represents an array type, e.g. Int64[], that is known while type checking.
+type ArrayTypeExpr struct {
+	SourcePosition util.SourcePos
+	ValueIndex     types.TypeIndex
+}
+
+func (e *ArrayTypeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *ArrayTypeExpr) GetTypeIndex() types.TypeIndex     { return types.BuiltInTypeIndexType }
+func (e *ArrayTypeExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
 // BooleanLiteralExpr represents a single boolean literal.
 type BooleanLiteralExpr struct {
 	SourcePosition util.SourcePos
@@ -73,6 +113,60 @@ func (e *BuiltInTypeExpr) isTypeExpression()                 {}
 
 //=====================================================================================================================
 
+// CollatedEqualsExpr represents an equality comparison with modifiers ("==[Aa]==") operation.
+type CollatedEqualsExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+	Collation      collations.Collation
+}
+
+func (e *CollatedEqualsExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *CollatedEqualsExpr) GetTypeIndex() types.TypeIndex     { return types.BuiltInTypeIndexBool }
+func (e *CollatedEqualsExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// CollatedNotEqualsExpr represents an inequality comparison with modifiers ("=![Aa]!=") operation.
+type CollatedNotEqualsExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+	Collation      collations.Collation
+}
+
+func (e *CollatedNotEqualsExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *CollatedNotEqualsExpr) GetTypeIndex() types.TypeIndex     { return types.BuiltInTypeIndexBool }
+func (e *CollatedNotEqualsExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// ConstrainedValueExpr represents "val", the value being checked by the predicate of a constraint.
+type ConstrainedValueExpr struct {
+	SourcePosition util.SourcePos
+	TypeIndex      types.TypeIndex
+}
+
+func (e *ConstrainedValueExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *ConstrainedValueExpr) GetTypeIndex() types.TypeIndex     { return e.TypeIndex }
+func (e *ConstrainedValueExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// ConstraintTypeExpr represents a constraint type, e.g. "Int64 && val <= 100", whose predicate is compiled on its own.
+type ConstraintTypeExpr struct {
+	SourcePosition  util.SourcePos
+	ConstrainedType IExpression
+	Predicate       IExpression
+	ValueIndex      types.TypeIndex
+}
+
+func (e *ConstraintTypeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *ConstraintTypeExpr) GetTypeIndex() types.TypeIndex     { return types.BuiltInTypeIndexType }
+func (e *ConstraintTypeExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
 // DivisionExpr represents a division operation.
 type DivisionExpr struct {
 	SourcePosition util.SourcePos
@@ -125,11 +219,26 @@ func (e *Float64LiteralExpr) isTypeExpression()                 {}
 
 //=====================================================================================================================
 
-// FunctionCallExpr represents a function call (a function name followed by a parenthesized expression).
+// FormatExpr represents a value formatting ("fmt") operation, which makes a String from a Bool, Float64, Int64, or
+// String operand of the given type.
+type FormatExpr struct {
+	SourcePosition   util.SourcePos
+	Lhs              IExpression
+	Rhs              IExpression
+	OperandTypeIndex types.TypeIndex
+}
+
+func (e *FormatExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *FormatExpr) GetTypeIndex() types.TypeIndex     { return types.BuiltInTypeIndexString }
+func (e *FormatExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// FunctionCallExpr represents a function call (a function name followed by parenthesized arguments).
 type FunctionCallExpr struct {
 	SourcePosition    util.SourcePos
 	FunctionReference IExpression
-	Argument          IExpression
+	Arguments         []IExpression
 	TypeIndex         types.TypeIndex
 }
 
@@ -139,6 +248,31 @@ func (e *FunctionCallExpr) isTypeExpression()                 {}
 
 //=====================================================================================================================
 
+// FunctionExpr represents a function whose body computes its result from its parameters.
+type FunctionExpr struct {
+	SourcePosition util.SourcePos
+	Body           IExpression
+	TypeIndex      types.TypeIndex
+}
+
+func (e *FunctionExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *FunctionExpr) GetTypeIndex() types.TypeIndex     { return e.TypeIndex }
+func (e *FunctionExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// FunctionTypeExpr represents a function type, e.g. (n: Int64) -> Int64, that is known while type checking.
+type FunctionTypeExpr struct {
+	SourcePosition util.SourcePos
+	ValueIndex     types.TypeIndex
+}
+
+func (e *FunctionTypeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *FunctionTypeExpr) GetTypeIndex() types.TypeIndex     { return types.BuiltInTypeIndexType }
+func (e *FunctionTypeExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
 // GreaterThanExpr represents a greater than operation.
 type GreaterThanExpr struct {
 	SourcePosition util.SourcePos
@@ -169,7 +303,9 @@ func (e *GreaterThanOrEqualsExpr) isTypeExpression()                 {}
 type IdentifierExpr struct {
 	SourcePosition util.SourcePos
 	NameIndex      pools.NameIndex
-	FieldIndex     uint64
+	FieldIndex     uint64 // The position of the parameter for a function parameter
+	Mechanism      nameresolution.ResolutionMechanism
+	RecordDepth    uint64 // Number of records under construction between the name and its field
 	TypeIndex      types.TypeIndex
 }
 
@@ -179,6 +315,19 @@ func (e *IdentifierExpr) isTypeExpression()                 {}
 
 //=====================================================================================================================
 
+// InExpr represents a set membership ("in") operation, testing a value against a range or an array.
+type InExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *InExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *InExpr) GetTypeIndex() types.TypeIndex     { return types.BuiltInTypeIndexBool }
+func (e *InExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
 // Int64LiteralExpr represents a single 64-bit integer literal.
 type Int64LiteralExpr struct {
 	SourcePosition util.SourcePos
@@ -280,6 +429,19 @@ func (e *LogicalOrExpr) isTypeExpression()                 {}
 
 //=====================================================================================================================
 
+// MatchExpr represents a pattern match "=~" operation.
+type MatchExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *MatchExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *MatchExpr) GetTypeIndex() types.TypeIndex     { return types.BuiltInTypeIndexBool }
+func (e *MatchExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
 // MultiplicationExpr represents a multiplication operation.
 type MultiplicationExpr struct {
 	SourcePosition util.SourcePos
@@ -320,16 +482,67 @@ func (e *NotEqualsExpr) isTypeExpression()                 {}
 
 //=====================================================================================================================
 
-// OptionalExpr represents a parenthesized expression or comma-separated sequence of expressions.
-type OptionalExpr struct {
+// NotMatchExpr represents a pattern nonmatch ("!~") operation.
+type NotMatchExpr struct {
 	SourcePosition util.SourcePos
-	Operand        IExpression
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *NotMatchExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *NotMatchExpr) GetTypeIndex() types.TypeIndex     { return types.BuiltInTypeIndexBool }
+func (e *NotMatchExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// NoneExpr represents the absent value of an optional type ("none").
+type NoneExpr struct {
+	SourcePosition util.SourcePos
+}
+
+func (e *NoneExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *NoneExpr) GetTypeIndex() types.TypeIndex     { return types.BuiltInTypeIndexNone }
+func (e *NoneExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// OptionalDefaultExpr represents the value of an optional expression or else a default value ("?:") when it has none.
+type OptionalDefaultExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
 	TypeIndex      types.TypeIndex
 }
 
-func (e *OptionalExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
-func (e *OptionalExpr) GetTypeIndex() types.TypeIndex     { return e.TypeIndex }
-func (e *OptionalExpr) isTypeExpression()                 {}
+func (e *OptionalDefaultExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *OptionalDefaultExpr) GetTypeIndex() types.TypeIndex     { return e.TypeIndex }
+func (e *OptionalDefaultExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// OptionalFieldReferenceExpr represents a field reference (".") from a record that may be none, giving none if so.
+type OptionalFieldReferenceExpr struct {
+	SourcePosition util.SourcePos
+	Parent         IExpression
+	Child          IExpression
+	TypeIndex      types.TypeIndex
+}
+
+func (e *OptionalFieldReferenceExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *OptionalFieldReferenceExpr) GetTypeIndex() types.TypeIndex     { return e.TypeIndex }
+func (e *OptionalFieldReferenceExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// OptionalTypeExpr represents an optional type, e.g. Int64?, that is known while type checking.
+type OptionalTypeExpr struct {
+	SourcePosition util.SourcePos
+	ValueIndex     types.TypeIndex
+}
+
+func (e *OptionalTypeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *OptionalTypeExpr) GetTypeIndex() types.TypeIndex     { return types.BuiltInTypeIndexType }
+func (e *OptionalTypeExpr) isTypeExpression()                 {}
 
 //=====================================================================================================================
 
@@ -346,13 +559,28 @@ func (e *ParenthesizedExpr) isTypeExpression()                 {}
 
 //=====================================================================================================================
 
-// RecordExpr represents a record.
-type RecordExpr struct {
+// RangeExpr represents a range ("..") operation.
+type RangeExpr struct {
 	SourcePosition util.SourcePos
-	Fields         []*RecordFieldExpr
+	First          IExpression
+	Last           IExpression
 	TypeIndex      types.TypeIndex
 }
 
+func (e *RangeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *RangeExpr) GetTypeIndex() types.TypeIndex     { return e.TypeIndex }
+func (e *RangeExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// RecordExpr represents a record.
+type RecordExpr struct {
+	SourcePosition  util.SourcePos
+	Fields          []*RecordFieldExpr
+	EvaluationOrder []int // Indexes of the fields with each one after the fields it refers to
+	TypeIndex       types.TypeIndex
+}
+
 func (e *RecordExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
 func (e *RecordExpr) GetTypeIndex() types.TypeIndex     { return e.TypeIndex }
 func (e *RecordExpr) isTypeExpression()                 {}
@@ -363,15 +591,32 @@ func (e *RecordExpr) isTypeExpression()                 {}
 type RecordFieldExpr struct {
 	SourcePosition util.SourcePos
 	FieldNameIndex pools.NameIndex // TODO: this is redundant with record type information
-	FieldValue     IExpression
+	FieldType      IExpression     // nil unless the type is declared
+	FieldValue     IExpression     // nil unless the value is given
+	DefaultValue   IExpression     // nil unless there is a default value
+	TypeIndex      types.TypeIndex
 }
 
 func (e *RecordFieldExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
-func (e *RecordFieldExpr) GetTypeIndex() types.TypeIndex     { return e.FieldValue.GetTypeIndex() }
+func (e *RecordFieldExpr) GetTypeIndex() types.TypeIndex     { return e.TypeIndex }
 func (e *RecordFieldExpr) isTypeExpression()                 {}
 
 //=====================================================================================================================
 
+// RecordMergeExpr represents the intersection of two records.
+type RecordMergeExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+	TypeIndex      types.TypeIndex
+}
+
+func (e *RecordMergeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *RecordMergeExpr) GetTypeIndex() types.TypeIndex     { return e.TypeIndex }
+func (e *RecordMergeExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
 // StringConcatenationExpr represents concatenation of two strings.
 type StringConcatenationExpr struct {
 	SourcePosition util.SourcePos
@@ -423,6 +668,45 @@ func (e *TrailingDocumentationExpr) isTypeExpression()                 {}
 
 //=====================================================================================================================
 
+// UnionTypeExpr represents a union of types, e.g. "dev" | "prod", that is known while type checking.
+type UnionTypeExpr struct {
+	SourcePosition util.SourcePos
+	ValueIndex     types.TypeIndex
+}
+
+func (e *UnionTypeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *UnionTypeExpr) GetTypeIndex() types.TypeIndex     { return types.BuiltInTypeIndexType }
+func (e *UnionTypeExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// WhenExpr represents a chain of alternatives like 'a when x | b when y | c', whose type is the widest type of the
+// values of its alternatives.
+type WhenExpr struct {
+	SourcePosition util.SourcePos
+	Alternatives   []*WhenAlternativeExpr
+	TypeIndex      types.TypeIndex
+}
+
+func (e *WhenExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *WhenExpr) GetTypeIndex() types.TypeIndex     { return e.TypeIndex }
+func (e *WhenExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
+// WhenAlternativeExpr represents one alternative of a "when" chain, like 'a when x'.
+type WhenAlternativeExpr struct {
+	SourcePosition util.SourcePos
+	Value          IExpression
+	Guard          IExpression // nil for a last alternative without a guard
+}
+
+func (e *WhenAlternativeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *WhenAlternativeExpr) GetTypeIndex() types.TypeIndex     { return e.Value.GetTypeIndex() }
+func (e *WhenAlternativeExpr) isTypeExpression()                 {}
+
+//=====================================================================================================================
+
 // WhereExpr represents a subtraction operation.
 type WhereExpr struct {
 	SourcePosition util.SourcePos
@@ -7,40 +7,52 @@ package codegeneration
 
 import (
 	"fmt"
+	"lligne-cli/internal/lligne/code/analysis/nameresolution"
 	prior "lligne-cli/internal/lligne/code/analysis/typechecking"
+	"lligne-cli/internal/lligne/code/diagnostics"
+	"lligne-cli/internal/lligne/code/util"
 	"lligne-cli/internal/lligne/runtime/bytecode"
 	"lligne-cli/internal/lligne/runtime/pools"
 	"lligne-cli/internal/lligne/runtime/types"
+	"sort"
 )
 
 //=====================================================================================================================
 
 type Outcome struct {
-	SourceCode      string
-	NewLineOffsets  []uint32
-	Model           prior.IExpression
-	StringConstants *pools.StringConstantPool
-	IdentifierNames *pools.NameConstantPool
-	TypeConstants   *types.TypeConstantPool
-	CodeBlock       *bytecode.CodeBlock
+	SourceCode       string
+	NewLineOffsets   []uint32
+	Model            prior.IExpression
+	StringConstants  *pools.StringConstantPool
+	PatternConstants *pools.PatternPool
+	IdentifierNames  *pools.NameConstantPool
+	TypeConstants    *types.TypeConstantPool
+	CodeBlock        *bytecode.CodeBlock
+	Diagnostics      []*diagnostics.Diagnostic
 }
 
 //=====================================================================================================================
 
 func GenerateByteCode(priorOutcome *prior.Outcome) *Outcome {
 	generator := newGenerator(priorOutcome)
-	generator.buildCodeBlock(priorOutcome.Model)
 
-	generator.CodeBlock.Stop()
+	diagnostics.RunAbortable(func() {
+		generator.buildConstraintCodeBlocks(priorOutcome.ConstraintPredicates)
+		generator.buildCodeBlock(priorOutcome.Model)
+		generator.CodeBlock.Stop()
+		generator.CodeBlock.Functions = generator.Functions
+	})
 
 	return &Outcome{
-		SourceCode:      priorOutcome.SourceCode,
-		NewLineOffsets:  priorOutcome.NewLineOffsets,
-		Model:           priorOutcome.Model,
-		StringConstants: priorOutcome.StringConstants,
-		IdentifierNames: priorOutcome.IdentifierNames,
-		TypeConstants:   priorOutcome.TypeConstants,
-		CodeBlock:       generator.CodeBlock,
+		SourceCode:       priorOutcome.SourceCode,
+		NewLineOffsets:   priorOutcome.NewLineOffsets,
+		Model:            priorOutcome.Model,
+		StringConstants:  priorOutcome.StringConstants,
+		PatternConstants: generator.CodeBlock.Patterns,
+		IdentifierNames:  priorOutcome.IdentifierNames,
+		TypeConstants:    priorOutcome.TypeConstants,
+		CodeBlock:        generator.CodeBlock,
+		Diagnostics:      generator.Diagnostics,
 	}
 }
 
@@ -49,37 +61,96 @@ func GenerateByteCode(priorOutcome *prior.Outcome) *Outcome {
 type generator struct {
 	SourceCode      string
 	NewLineOffsets  []uint32
+	StringPool      *pools.StringConstantPool
 	StringConstants *pools.StringPool
 	IdentifierNames *pools.NamePool
 	TypeConstants   *types.TypeConstantPool
 	CodeBlock       *bytecode.CodeBlock
+	Functions       []*bytecode.CodeBlock
+	Diagnostics     []*diagnostics.Diagnostic
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
 func newGenerator(priorOutcome *prior.Outcome) *generator {
+	codeBlock := bytecode.NewCodeBlock()
+	codeBlock.FieldNames = priorOutcome.IdentifierNames
+
 	return &generator{
 		SourceCode:      priorOutcome.SourceCode,
 		NewLineOffsets:  priorOutcome.NewLineOffsets,
+		StringPool:      priorOutcome.StringConstants,
 		StringConstants: pools.NewStringPool(),
 		IdentifierNames: pools.NewNamePool(),
 		TypeConstants:   priorOutcome.TypeConstants,
-		CodeBlock:       bytecode.NewCodeBlock(),
+		CodeBlock:       codeBlock,
+		Functions:       nil,
+		Diagnostics:     priorOutcome.Diagnostics,
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// failUnsupportedConversion records that a value of one type cannot be stored as another type, because values of the
+// two types are represented differently somewhere inside, and abandons code generation.
+func (g *generator) failUnsupportedConversion(
+	sourcePosition util.SourcePos,
+	valueTypeIndex types.TypeIndex,
+	typeIndex types.TypeIndex,
+) {
+	g.Diagnostics = append(g.Diagnostics, diagnostics.NewError(
+		diagnostics.CodeUnsupportedExpression,
+		sourcePosition,
+		"Values of type %s cannot yet be stored as %s",
+		g.TypeConstants.Get(valueTypeIndex).Name(),
+		g.TypeConstants.Get(typeIndex).Name(),
+	))
+	diagnostics.Abort()
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// failUnsupportedOperator records that an operator cannot be applied to operands of a given type and abandons code
+// generation.
+func (g *generator) failUnsupportedOperator(sourcePosition util.SourcePos, operator string, typeIndex types.TypeIndex) {
+	g.Diagnostics = append(g.Diagnostics, diagnostics.NewError(
+		diagnostics.CodeUnsupportedExpression,
+		sourcePosition,
+		"Operator '%s' is not supported for type %s",
+		operator,
+		g.TypeConstants.Get(typeIndex).Name(),
+	))
+	diagnostics.Abort()
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (g *generator) buildCodeBlock(expression prior.IExpression) {
 
 	switch expr := expression.(type) {
 
 	case *prior.AdditionExpr:
 		g.buildAdditionCodeBlock(expr)
+	case *prior.ArrayIndexExpr:
+		g.buildArrayIndexCodeBlock(expr)
+	case *prior.ArrayLengthExpr:
+		g.buildArrayLengthCodeBlock(expr)
+	case *prior.ArrayLiteralExpr:
+		g.buildArrayLiteralCodeBlock(expr)
+	case *prior.ArrayTypeExpr:
+		g.buildArrayTypeCodeBlock(expr)
 	case *prior.BooleanLiteralExpr:
 		g.buildBooleanLiteralCodeBlock(expr)
 	case *prior.BuiltInTypeExpr:
 		g.buildBuiltInTypeCodeBlock(expr)
+	case *prior.CollatedEqualsExpr:
+		g.buildCollatedEqualsCodeBlock(expr)
+	case *prior.CollatedNotEqualsExpr:
+		g.buildCollatedNotEqualsCodeBlock(expr)
+	case *prior.ConstrainedValueExpr:
+		g.buildConstrainedValueCodeBlock(expr)
+	case *prior.ConstraintTypeExpr:
+		g.buildConstraintTypeCodeBlock(expr)
 	case *prior.DivisionExpr:
 		g.buildDivisionCodeBlock(expr)
 	case *prior.EqualsExpr:
@@ -88,12 +159,22 @@ func (g *generator) buildCodeBlock(expression prior.IExpression) {
 		g.buildFieldReferenceCodeBlock(expr)
 	case *prior.Float64LiteralExpr:
 		g.buildFloat64LiteralCodeBlock(expr)
+	case *prior.FormatExpr:
+		g.buildFormatCodeBlock(expr)
+	case *prior.FunctionCallExpr:
+		g.buildFunctionCallCodeBlock(expr)
+	case *prior.FunctionExpr:
+		g.buildFunctionCodeBlock(expr)
+	case *prior.FunctionTypeExpr:
+		g.buildFunctionTypeCodeBlock(expr)
 	case *prior.GreaterThanExpr:
 		g.buildGreaterThanCodeBlock(expr)
 	case *prior.GreaterThanOrEqualsExpr:
 		g.buildGreaterThanOrEqualsCodeBlock(expr)
 	case *prior.IdentifierExpr:
 		g.buildIdentifierCodeBlock(expr)
+	case *prior.InExpr:
+		g.buildInCodeBlock(expr)
 	case *prior.Int64LiteralExpr:
 		g.buildInt64LiteralCodeBlock(expr)
 	case *prior.IsExpr:
@@ -108,22 +189,42 @@ func (g *generator) buildCodeBlock(expression prior.IExpression) {
 		g.buildLogicalNotCodeBlock(expr)
 	case *prior.LogicalOrExpr:
 		g.buildLogicalOrCodeBlock(expr)
+	case *prior.MatchExpr:
+		g.buildMatchCodeBlock(expr)
 	case *prior.MultiplicationExpr:
 		g.buildMultiplicationCodeBlock(expr)
 	case *prior.NegationOperationExpr:
 		g.buildNegationCodeBlock(expr)
+	case *prior.NoneExpr:
+		g.buildNoneCodeBlock(expr)
 	case *prior.NotEqualsExpr:
 		g.buildNotEqualsCodeBlock(expr)
+	case *prior.NotMatchExpr:
+		g.buildNotMatchCodeBlock(expr)
+	case *prior.OptionalDefaultExpr:
+		g.buildOptionalDefaultCodeBlock(expr)
+	case *prior.OptionalFieldReferenceExpr:
+		g.buildOptionalFieldReferenceCodeBlock(expr)
+	case *prior.OptionalTypeExpr:
+		g.buildOptionalTypeCodeBlock(expr)
 	case *prior.ParenthesizedExpr:
 		g.buildParenthesizedCodeBlock(expr)
+	case *prior.RangeExpr:
+		g.buildRangeCodeBlock(expr)
 	case *prior.RecordExpr:
 		g.buildRecordCodeBlock(expr)
+	case *prior.RecordMergeExpr:
+		g.buildRecordMergeCodeBlock(expr)
 	case *prior.StringConcatenationExpr:
 		g.buildStringConcatenationCodeBlock(expr)
 	case *prior.StringLiteralExpr:
 		g.buildStringLiteralCodeBlock(expr)
 	case *prior.SubtractionExpr:
 		g.buildSubtractionCodeBlock(expr)
+	case *prior.UnionTypeExpr:
+		g.buildUnionTypeCodeBlock(expr)
+	case *prior.WhenExpr:
+		g.buildWhenCodeBlock(expr)
 	case *prior.WhereExpr:
 		g.buildWhereCodeBlock(expr)
 	default:
@@ -152,13 +253,52 @@ func (g *generator) buildAdditionCodeBlock(expr *prior.AdditionExpr) {
 		case types.BuiltInTypeIndexInt64:
 			g.CodeBlock.Int64Add()
 		default:
-			panic(fmt.Sprintf("Missing case in buildAdditionCodeBlock: %d\n", expr.TypeIndex))
+			if g.TypeConstants.Get(expr.TypeIndex).Category() == types.TypeCategoryRecord {
+				g.CodeBlock.RecordAdd(expr.TypeIndex)
+			} else {
+				g.failUnsupportedOperator(expr.SourcePosition, "+", expr.TypeIndex)
+			}
 		}
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (g *generator) buildArrayIndexCodeBlock(expr *prior.ArrayIndexExpr) {
+	g.buildCodeBlock(expr.Array)
+	g.buildCodeBlock(expr.Index)
+	g.CodeBlock.ArrayIndex()
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (g *generator) buildArrayLengthCodeBlock(expr *prior.ArrayLengthExpr) {
+	g.buildCodeBlock(expr.Array)
+	g.CodeBlock.ArrayLength()
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (g *generator) buildArrayLiteralCodeBlock(expr *prior.ArrayLiteralExpr) {
+	// Load the array type followed by the elements, each stored as the element type, then copy them into the array pool
+	g.CodeBlock.TypeLoad(expr.TypeIndex)
+
+	elementTypeIndex := g.TypeConstants.Get(expr.TypeIndex).(*types.ArrayType).ElementTypeIndex
+	for _, element := range expr.Elements {
+		g.buildStoredValueCodeBlock(element, elementTypeIndex)
+	}
+
+	g.CodeBlock.ArrayStore(len(expr.Elements))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (g *generator) buildArrayTypeCodeBlock(expr *prior.ArrayTypeExpr) {
+	g.CodeBlock.TypeLoad(expr.ValueIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (g *generator) buildBooleanLiteralCodeBlock(expr *prior.BooleanLiteralExpr) {
 	if expr.Value {
 		g.CodeBlock.BoolLoadTrue()
@@ -175,6 +315,95 @@ func (g *generator) buildBuiltInTypeCodeBlock(expr *prior.BuiltInTypeExpr) {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (g *generator) buildCollatedEqualsCodeBlock(expr *prior.CollatedEqualsExpr) {
+	g.buildCodeBlock(expr.Lhs)
+	g.buildCodeBlock(expr.Rhs)
+	g.CodeBlock.StringEqualsCollated(expr.Collation)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (g *generator) buildCollatedNotEqualsCodeBlock(expr *prior.CollatedNotEqualsExpr) {
+	g.buildCodeBlock(expr.Lhs)
+	g.buildCodeBlock(expr.Rhs)
+	g.CodeBlock.StringEqualsCollated(expr.Collation)
+	g.CodeBlock.BoolNot()
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// buildConstraintCheckCodeBlock checks a value just built against the constraint type it is to be stored as, if any,
+// naming the field to hold it, if any, in a runtime error. A constant value is checked right away during code
+// generation; any other value is checked when the code runs.
+func (g *generator) buildConstraintCheckCodeBlock(
+	value prior.IExpression,
+	typeIndex types.TypeIndex,
+	fieldNameIndex pools.NameIndex,
+) {
+
+	constraintType, ok := g.TypeConstants.Get(typeIndex).(*types.ConstraintType)
+	if !ok || value.GetTypeIndex() == typeIndex {
+		return
+	}
+
+	satisfied, constantValue, isConstant := g.satisfiesConstraint(value, typeIndex)
+	if !isConstant {
+		g.CodeBlock.ConstraintCheck(typeIndex, fieldNameIndex)
+		return
+	}
+
+	if !satisfied {
+		g.Diagnostics = append(g.Diagnostics, diagnostics.NewError(
+			diagnostics.CodeConstraintViolation,
+			value.GetSourcePosition(),
+			"Value %s does not satisfy the constraint '%s'",
+			constantValue,
+			constraintType.Name(),
+		))
+	}
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// buildConstraintCodeBlocks compiles the predicate of each constraint type into a code block of its own.
+func (g *generator) buildConstraintCodeBlocks(predicates map[types.TypeIndex]prior.IExpression) {
+
+	// Compile the predicates in order of their types for repeatable output
+	typeIndexes := make([]types.TypeIndex, 0, len(predicates))
+	for typeIndex := range predicates {
+		typeIndexes = append(typeIndexes, typeIndex)
+	}
+	sort.Slice(typeIndexes, func(i, j int) bool { return typeIndexes[i] < typeIndexes[j] })
+
+	codeBlock := g.CodeBlock
+	for _, typeIndex := range typeIndexes {
+		g.CodeBlock = bytecode.NewCodeBlock()
+		g.CodeBlock.Constraints = codeBlock.Constraints
+		g.CodeBlock.Patterns = codeBlock.Patterns
+		g.CodeBlock.FieldNames = codeBlock.FieldNames
+		g.buildCodeBlock(predicates[typeIndex])
+		g.CodeBlock.Stop()
+		codeBlock.Constraints[typeIndex] = g.CodeBlock
+	}
+	g.CodeBlock = codeBlock
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (g *generator) buildConstrainedValueCodeBlock(expr *prior.ConstrainedValueExpr) {
+	g.CodeBlock.ConstraintValueLoad()
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (g *generator) buildConstraintTypeCodeBlock(expr *prior.ConstraintTypeExpr) {
+	g.CodeBlock.TypeLoad(expr.ValueIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (g *generator) buildDivisionCodeBlock(expr *prior.DivisionExpr) {
 	g.buildCodeBlock(expr.Lhs)
 	g.buildCodeBlock(expr.Rhs)
@@ -184,16 +413,29 @@ func (g *generator) buildDivisionCodeBlock(expr *prior.DivisionExpr) {
 	case types.BuiltInTypeIndexInt64:
 		g.CodeBlock.Int64Divide()
 	default:
-		panic("Undefined division type")
+		if g.TypeConstants.Get(expr.TypeIndex).Category() == types.TypeCategoryRecord {
+			g.CodeBlock.RecordDivide(expr.TypeIndex)
+		} else {
+			g.failUnsupportedOperator(expr.SourcePosition, "/", expr.TypeIndex)
+		}
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
 func (g *generator) buildEqualsCodeBlock(expr *prior.EqualsExpr) {
+	if unionTypeIndex, ok := g.taggedUnionOperandType(expr.Lhs, expr.Rhs); ok {
+		g.buildStoredValueCodeBlock(expr.Lhs, unionTypeIndex)
+		g.buildStoredValueCodeBlock(expr.Rhs, unionTypeIndex)
+		g.CodeBlock.UnionEquals()
+		return
+	}
+
 	g.buildCodeBlock(expr.Lhs)
 	g.buildCodeBlock(expr.Rhs)
-	switch expr.Lhs.GetTypeIndex() {
+	switch g.TypeConstants.BaseTypeIndex(expr.Lhs.GetTypeIndex()) {
+	case types.BuiltInTypeIndexBool:
+		g.CodeBlock.BoolEquals()
 	case types.BuiltInTypeIndexFloat64:
 		g.CodeBlock.Float64Equals()
 	case types.BuiltInTypeIndexInt64:
@@ -206,10 +448,16 @@ func (g *generator) buildEqualsCodeBlock(expr *prior.EqualsExpr) {
 		typ := g.TypeConstants.Get(expr.Lhs.GetTypeIndex())
 
 		switch typ.Category() {
+		case types.TypeCategoryNone, types.TypeCategoryOptional:
+			g.CodeBlock.OptionalEquals(g.optionalOperandType(expr.Lhs, expr.Rhs))
+		case types.TypeCategoryArray:
+			g.CodeBlock.ArrayEquals()
+		case types.TypeCategoryRange:
+			g.CodeBlock.RangeEquals()
 		case types.TypeCategoryRecord:
 			g.CodeBlock.RecordEquals()
 		default:
-			panic("Undefined equality type")
+			g.failUnsupportedOperator(expr.SourcePosition, "==", expr.Lhs.GetTypeIndex())
 		}
 	}
 }
@@ -237,16 +485,74 @@ func (g *generator) buildFloat64LiteralCodeBlock(expr *prior.Float64LiteralExpr)
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (g *generator) buildFormatCodeBlock(expr *prior.FormatExpr) {
+	g.buildCodeBlock(expr.Lhs)
+	g.buildCodeBlock(expr.Rhs)
+	g.CodeBlock.StringFormat(expr.OperandTypeIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// buildFunctionCallCodeBlock calls a function named by a field of a record under construction. The function's body
+// sees the fields of that record, so the call tells how far out it is.
+func (g *generator) buildFunctionCallCodeBlock(expr *prior.FunctionCallExpr) {
+	functionReference := expr.FunctionReference.(*prior.IdentifierExpr)
+	functionType := g.TypeConstants.Get(functionReference.TypeIndex).(*types.FunctionType)
+
+	g.buildCodeBlock(functionReference)
+
+	for i, argument := range expr.Arguments {
+		g.buildStoredValueCodeBlock(argument, functionType.ParameterTypeIndexes[i])
+		g.buildConstraintCheckCodeBlock(argument, functionType.ParameterTypeIndexes[i], bytecode.NoFieldName)
+	}
+
+	g.CodeBlock.FunctionCall(functionReference.RecordDepth, uint64(len(expr.Arguments)))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// buildFunctionCodeBlock compiles the body of a function into a code block of its own, leaving the index of that code
+// block as the value of the function.
+func (g *generator) buildFunctionCodeBlock(expr *prior.FunctionExpr) {
+	functionType := g.TypeConstants.Get(expr.TypeIndex).(*types.FunctionType)
+
+	functionIndex := len(g.Functions)
+	g.Functions = append(g.Functions, nil)
+
+	codeBlock := g.CodeBlock
+	g.CodeBlock = bytecode.NewCodeBlock()
+	g.CodeBlock.Constraints = codeBlock.Constraints
+	g.CodeBlock.Patterns = codeBlock.Patterns
+	g.CodeBlock.FieldNames = codeBlock.FieldNames
+	g.buildStoredValueCodeBlock(expr.Body, functionType.ResultTypeIndex)
+	g.buildConstraintCheckCodeBlock(expr.Body, functionType.ResultTypeIndex, bytecode.NoFieldName)
+	g.CodeBlock.Return()
+	g.Functions[functionIndex] = g.CodeBlock
+	g.CodeBlock = codeBlock
+
+	g.CodeBlock.FunctionLoad(uint64(functionIndex))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (g *generator) buildFunctionTypeCodeBlock(expr *prior.FunctionTypeExpr) {
+	g.CodeBlock.TypeLoad(expr.ValueIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (g *generator) buildGreaterThanCodeBlock(expr *prior.GreaterThanExpr) {
 	g.buildCodeBlock(expr.Lhs)
 	g.buildCodeBlock(expr.Rhs)
-	switch expr.Lhs.GetTypeIndex() {
+	switch g.TypeConstants.BaseTypeIndex(expr.Lhs.GetTypeIndex()) {
 	case types.BuiltInTypeIndexFloat64:
 		g.CodeBlock.Float64GreaterThan()
 	case types.BuiltInTypeIndexInt64:
 		g.CodeBlock.Int64GreaterThan()
+	case types.BuiltInTypeIndexString:
+		g.CodeBlock.StringGreaterThan()
 	default:
-		panic("Undefined greater than type")
+		g.failUnsupportedOperator(expr.SourcePosition, ">", expr.Lhs.GetTypeIndex())
 	}
 }
 
@@ -255,20 +561,54 @@ func (g *generator) buildGreaterThanCodeBlock(expr *prior.GreaterThanExpr) {
 func (g *generator) buildGreaterThanOrEqualsCodeBlock(expr *prior.GreaterThanOrEqualsExpr) {
 	g.buildCodeBlock(expr.Lhs)
 	g.buildCodeBlock(expr.Rhs)
-	switch expr.Lhs.GetTypeIndex() {
+	switch g.TypeConstants.BaseTypeIndex(expr.Lhs.GetTypeIndex()) {
 	case types.BuiltInTypeIndexFloat64:
 		g.CodeBlock.Float64GreaterThanOrEquals()
 	case types.BuiltInTypeIndexInt64:
 		g.CodeBlock.Int64GreaterThanOrEquals()
+	case types.BuiltInTypeIndexString:
+		g.CodeBlock.StringGreaterThanOrEquals()
 	default:
-		panic("Undefined greater than or equals type")
+		g.failUnsupportedOperator(expr.SourcePosition, ">=", expr.Lhs.GetTypeIndex())
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
 func (g *generator) buildIdentifierCodeBlock(expr *prior.IdentifierExpr) {
-	g.CodeBlock.RecordFieldIndexLoad(expr.FieldIndex)
+	switch expr.Mechanism {
+	case nameresolution.ResolutionMechanismRecordField, nameresolution.ResolutionMechanismTopLevel:
+		g.CodeBlock.RecordFieldLoad(expr.RecordDepth, expr.FieldIndex)
+	case nameresolution.ResolutionMechanismFunctionParameter:
+		g.CodeBlock.FunctionParameterLoad(expr.FieldIndex)
+	default:
+		g.CodeBlock.RecordFieldIndexLoad(expr.FieldIndex)
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// buildInCodeBlock tests a value against the elements of an array or the bounds of a range.
+func (g *generator) buildInCodeBlock(expr *prior.InExpr) {
+	g.buildCodeBlock(expr.Lhs)
+	g.buildCodeBlock(expr.Rhs)
+
+	rangeType, isRange := g.TypeConstants.Get(expr.Rhs.GetTypeIndex()).(*types.RangeType)
+	if !isRange {
+		g.CodeBlock.ArrayContains()
+		return
+	}
+
+	switch rangeType.BoundTypeIndex {
+	case types.BuiltInTypeIndexFloat64:
+		g.CodeBlock.Float64InRange()
+	case types.BuiltInTypeIndexInt64:
+		g.CodeBlock.Int64InRange()
+	case types.BuiltInTypeIndexString:
+		g.CodeBlock.StringInRange()
+	default:
+		g.failUnsupportedOperator(expr.SourcePosition, "in", expr.Lhs.GetTypeIndex())
+	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -286,58 +626,10 @@ func (g *generator) buildInt64LiteralCodeBlock(expr *prior.Int64LiteralExpr) {
 
 //---------------------------------------------------------------------------------------------------------------------
 
-// TODO: This will evolve into a module unto itself
 func (g *generator) buildIsCodeBlock(expr *prior.IsExpr) {
 	g.buildCodeBlock(expr.Lhs)
 	g.buildCodeBlock(expr.Rhs)
-	switch expr.Lhs.GetTypeIndex() {
-	case types.BuiltInTypeIndexBool:
-		switch rhs := expr.Rhs.(type) {
-		case *prior.BuiltInTypeExpr:
-			if rhs.ValueIndex == types.BuiltInTypeIndexBool {
-				g.CodeBlock.BoolLoadTrue()
-			} else {
-				g.CodeBlock.BoolLoadFalse()
-			}
-		default:
-			panic(fmt.Sprintf("Missing case in buildIsCodeBlock for BoolType: %T\n", expr.Rhs))
-		}
-	case types.BuiltInTypeIndexFloat64:
-		switch rhs := expr.Rhs.(type) {
-		case *prior.BuiltInTypeExpr:
-			if rhs.ValueIndex == types.BuiltInTypeIndexFloat64 {
-				g.CodeBlock.BoolLoadTrue()
-			} else {
-				g.CodeBlock.BoolLoadFalse()
-			}
-		default:
-			panic(fmt.Sprintf("Missing case in buildIsCodeBlock for Float64Type: %T\n", expr.Rhs))
-		}
-	case types.BuiltInTypeIndexInt64:
-		switch rhs := expr.Rhs.(type) {
-		case *prior.BuiltInTypeExpr:
-			if rhs.ValueIndex == types.BuiltInTypeIndexInt64 {
-				g.CodeBlock.BoolLoadTrue()
-			} else {
-				g.CodeBlock.BoolLoadFalse()
-			}
-		default:
-			panic(fmt.Sprintf("Missing case in buildIsCodeBlock for Int64Type: %T\n", expr.Rhs))
-		}
-	case types.BuiltInTypeIndexString:
-		switch rhs := expr.Rhs.(type) {
-		case *prior.BuiltInTypeExpr:
-			if rhs.ValueIndex == types.BuiltInTypeIndexString {
-				g.CodeBlock.BoolLoadTrue()
-			} else {
-				g.CodeBlock.BoolLoadFalse()
-			}
-		default:
-			panic(fmt.Sprintf("Missing case in buildIsCodeBlock for StringType: %T\n", expr.Rhs))
-		}
-	default:
-		panic(fmt.Sprintf("Missing case in buildIsCodeBlock: %d\n", expr.Lhs.GetTypeIndex()))
-	}
+	g.CodeBlock.TypeContains(expr.Lhs.GetTypeIndex())
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -345,13 +637,15 @@ func (g *generator) buildIsCodeBlock(expr *prior.IsExpr) {
 func (g *generator) buildLessThanCodeBlock(expr *prior.LessThanExpr) {
 	g.buildCodeBlock(expr.Lhs)
 	g.buildCodeBlock(expr.Rhs)
-	switch expr.Lhs.GetTypeIndex() {
+	switch g.TypeConstants.BaseTypeIndex(expr.Lhs.GetTypeIndex()) {
 	case types.BuiltInTypeIndexFloat64:
 		g.CodeBlock.Float64LessThan()
 	case types.BuiltInTypeIndexInt64:
 		g.CodeBlock.Int64LessThan()
+	case types.BuiltInTypeIndexString:
+		g.CodeBlock.StringLessThan()
 	default:
-		panic("Undefined less than type")
+		g.failUnsupportedOperator(expr.SourcePosition, "<", expr.Lhs.GetTypeIndex())
 	}
 }
 
@@ -360,22 +654,27 @@ func (g *generator) buildLessThanCodeBlock(expr *prior.LessThanExpr) {
 func (g *generator) buildLessThanOrEqualsCodeBlock(expr *prior.LessThanOrEqualsExpr) {
 	g.buildCodeBlock(expr.Lhs)
 	g.buildCodeBlock(expr.Rhs)
-	switch expr.Lhs.GetTypeIndex() {
+	switch g.TypeConstants.BaseTypeIndex(expr.Lhs.GetTypeIndex()) {
 	case types.BuiltInTypeIndexFloat64:
 		g.CodeBlock.Float64LessThanOrEquals()
 	case types.BuiltInTypeIndexInt64:
 		g.CodeBlock.Int64LessThanOrEquals()
+	case types.BuiltInTypeIndexString:
+		g.CodeBlock.StringLessThanOrEquals()
 	default:
-		panic(fmt.Sprintf("Missing case in buildLessThanOrEqualsCodeBlock: %d\n", expr.Lhs.GetTypeIndex()))
+		g.failUnsupportedOperator(expr.SourcePosition, "<=", expr.Lhs.GetTypeIndex())
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// buildLogicalAndCodeBlock skips the right hand side when the left hand side is false, so that the right hand side can
+// rely on the left, as in 'x != 0 and 10 / x > 1'.
 func (g *generator) buildLogicalAndCodeBlock(expr *prior.LogicalAndExpr) {
 	g.buildCodeBlock(expr.Lhs)
+	jumpToEnd := g.CodeBlock.JumpIfFalseOrPop()
 	g.buildCodeBlock(expr.Rhs)
-	g.CodeBlock.BoolAnd()
+	g.CodeBlock.PatchJump(jumpToEnd)
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -387,10 +686,18 @@ func (g *generator) buildLogicalNotCodeBlock(expr *prior.LogicalNotOperationExpr
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// buildLogicalOrCodeBlock skips the right hand side when the left hand side is true.
 func (g *generator) buildLogicalOrCodeBlock(expr *prior.LogicalOrExpr) {
 	g.buildCodeBlock(expr.Lhs)
+	jumpToEnd := g.CodeBlock.JumpIfTrueOrPop()
 	g.buildCodeBlock(expr.Rhs)
-	g.CodeBlock.BoolOr()
+	g.CodeBlock.PatchJump(jumpToEnd)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (g *generator) buildMatchCodeBlock(expr *prior.MatchExpr) {
+	g.buildPatternMatchCodeBlock(expr.Lhs, expr.Rhs)
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -404,7 +711,11 @@ func (g *generator) buildMultiplicationCodeBlock(expr *prior.MultiplicationExpr)
 	case types.BuiltInTypeIndexInt64:
 		g.CodeBlock.Int64Multiply()
 	default:
-		panic(fmt.Sprintf("Missing case in buildMultiplicationCodeBlock: %d\n", expr.TypeIndex))
+		if g.TypeConstants.Get(expr.TypeIndex).Category() == types.TypeCategoryRecord {
+			g.CodeBlock.RecordMultiply(expr.TypeIndex)
+		} else {
+			g.failUnsupportedOperator(expr.SourcePosition, "*", expr.TypeIndex)
+		}
 	}
 }
 
@@ -418,16 +729,31 @@ func (g *generator) buildNegationCodeBlock(expr *prior.NegationOperationExpr) {
 	case types.BuiltInTypeIndexInt64:
 		g.CodeBlock.Int64Negate()
 	default:
-		panic(fmt.Sprintf("Missing case in buildNegationCodeBlock: %d\n", expr.TypeIndex))
+		g.failUnsupportedOperator(expr.SourcePosition, "-", expr.TypeIndex)
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (g *generator) buildNoneCodeBlock(expr *prior.NoneExpr) {
+	g.CodeBlock.OptionalLoadNone()
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (g *generator) buildNotEqualsCodeBlock(expr *prior.NotEqualsExpr) {
+	if unionTypeIndex, ok := g.taggedUnionOperandType(expr.Lhs, expr.Rhs); ok {
+		g.buildStoredValueCodeBlock(expr.Lhs, unionTypeIndex)
+		g.buildStoredValueCodeBlock(expr.Rhs, unionTypeIndex)
+		g.CodeBlock.UnionNotEquals()
+		return
+	}
+
 	g.buildCodeBlock(expr.Lhs)
 	g.buildCodeBlock(expr.Rhs)
-	switch expr.Lhs.GetTypeIndex() {
+	switch g.TypeConstants.BaseTypeIndex(expr.Lhs.GetTypeIndex()) {
+	case types.BuiltInTypeIndexBool:
+		g.CodeBlock.BoolNotEquals()
 	case types.BuiltInTypeIndexFloat64:
 		g.CodeBlock.Float64NotEquals()
 	case types.BuiltInTypeIndexInt64:
@@ -440,29 +766,132 @@ func (g *generator) buildNotEqualsCodeBlock(expr *prior.NotEqualsExpr) {
 		typ := g.TypeConstants.Get(expr.Lhs.GetTypeIndex())
 
 		switch typ.Category() {
+		case types.TypeCategoryNone, types.TypeCategoryOptional:
+			g.CodeBlock.OptionalNotEquals(g.optionalOperandType(expr.Lhs, expr.Rhs))
+		case types.TypeCategoryArray:
+			g.CodeBlock.ArrayNotEquals()
+		case types.TypeCategoryRange:
+			g.CodeBlock.RangeNotEquals()
 		case types.TypeCategoryRecord:
 			g.CodeBlock.RecordNotEquals()
 		default:
-			panic("Undefined equality type")
+			g.failUnsupportedOperator(expr.SourcePosition, "!=", expr.Lhs.GetTypeIndex())
 		}
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (g *generator) buildNotMatchCodeBlock(expr *prior.NotMatchExpr) {
+	g.buildPatternMatchCodeBlock(expr.Lhs, expr.Rhs)
+	g.CodeBlock.BoolNot()
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (g *generator) buildOptionalDefaultCodeBlock(expr *prior.OptionalDefaultExpr) {
+	g.buildCodeBlock(expr.Lhs)
+	g.buildStoredValueCodeBlock(expr.Rhs, expr.TypeIndex)
+
+	// The value on the left is used as it is, so it must already be stored like the result
+	lhsTypeIndex := expr.Lhs.GetTypeIndex()
+	lhsType, isOptional := g.TypeConstants.Get(lhsTypeIndex).(*types.OptionalType)
+
+	switch g.TypeConstants.Get(expr.TypeIndex).Category() {
+	case types.TypeCategoryNone, types.TypeCategoryOptional:
+		// The result stays optional, so the default value is stored as an optional value too
+		if !g.isStoredAlike(lhsTypeIndex, expr.TypeIndex) {
+			g.failUnsupportedConversion(expr.Lhs.GetSourcePosition(), lhsTypeIndex, expr.TypeIndex)
+		}
+		g.CodeBlock.OptionalOrDefault()
+	default:
+		if isOptional && !g.isStoredAlike(lhsType.ValueTypeIndex, expr.TypeIndex) {
+			g.failUnsupportedConversion(expr.Lhs.GetSourcePosition(), lhsTypeIndex, expr.TypeIndex)
+		}
+		g.CodeBlock.OptionalGetOrDefault()
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (g *generator) buildOptionalFieldReferenceCodeBlock(expr *prior.OptionalFieldReferenceExpr) {
+	g.buildCodeBlock(expr.Parent)
+	g.buildCodeBlock(expr.Child)
+	g.CodeBlock.OptionalFieldReference()
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (g *generator) buildOptionalTypeCodeBlock(expr *prior.OptionalTypeExpr) {
+	g.CodeBlock.TypeLoad(expr.ValueIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (g *generator) buildParenthesizedCodeBlock(expr *prior.ParenthesizedExpr) {
 	g.buildCodeBlock(expr.InnerExpr)
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// buildPatternMatchCodeBlock tests whether a pattern matches somewhere within a String. A pattern given by a string
+// literal is compiled once, right away, so that an invalid one is reported at compile time; any other pattern is
+// compiled when the code runs.
+func (g *generator) buildPatternMatchCodeBlock(value prior.IExpression, pattern prior.IExpression) {
+	g.buildCodeBlock(value)
+
+	literal, isLiteral := pattern.(*prior.StringLiteralExpr)
+	if !isLiteral {
+		g.buildCodeBlock(pattern)
+		g.CodeBlock.StringMatchesDynamic()
+		return
+	}
+
+	patternIndex, err := g.CodeBlock.Patterns.Put(g.StringPool.Get(literal.ValueIndex))
+	if err != nil {
+		g.Diagnostics = append(g.Diagnostics, diagnostics.NewError(
+			diagnostics.CodeInvalidPattern,
+			pattern.GetSourcePosition(),
+			"Invalid pattern %s: %s",
+			pattern.GetSourcePosition().GetText(g.SourceCode),
+			pools.DescribePatternError(err),
+		))
+		return
+	}
+
+	g.CodeBlock.StringMatches(patternIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (g *generator) buildRangeCodeBlock(expr *prior.RangeExpr) {
+	g.buildCodeBlock(expr.First)
+	g.buildCodeBlock(expr.Last)
+	g.CodeBlock.RangeStore()
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (g *generator) buildRecordCodeBlock(expr *prior.RecordExpr) {
-	// Load the type index on the stack
+	// Load the type index on the stack and start the record under construction with a slot for each field
 	g.CodeBlock.TypeLoad(expr.TypeIndex)
-
-	// Evaluate each field until fields are in order on the stack
-	for _, field := range expr.Fields {
-		g.buildCodeBlock(field.FieldValue)
+	g.CodeBlock.RecordBegin(len(expr.Fields))
+
+	// Evaluate each field after the fields it refers to, moving its value into its slot
+	for _, i := range expr.EvaluationOrder {
+		field := expr.Fields[i]
+		switch {
+		case field.FieldValue != nil:
+			g.buildStoredValueCodeBlock(field.FieldValue, field.TypeIndex)
+			g.buildConstraintCheckCodeBlock(field.FieldValue, field.TypeIndex, field.FieldNameIndex)
+		case field.DefaultValue != nil:
+			g.buildStoredValueCodeBlock(field.DefaultValue, field.TypeIndex)
+			g.buildConstraintCheckCodeBlock(field.DefaultValue, field.TypeIndex, field.FieldNameIndex)
+		default:
+			// A required field has no value until an intersection gives it one; fill its slot with a placeholder.
+			g.CodeBlock.Int64LoadZero()
+		}
+		g.CodeBlock.RecordFieldStore(uint64(i))
 	}
 
 	// Copy from the stack into the record pool together with record type index (leave the record pool index on the stack).
@@ -471,6 +900,14 @@ func (g *generator) buildRecordCodeBlock(expr *prior.RecordExpr) {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (g *generator) buildRecordMergeCodeBlock(expr *prior.RecordMergeExpr) {
+	g.buildCodeBlock(expr.Lhs)
+	g.buildCodeBlock(expr.Rhs)
+	g.CodeBlock.RecordMerge(expr.TypeIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (g *generator) buildStringConcatenationCodeBlock(expr *prior.StringConcatenationExpr) {
 	g.buildCodeBlock(expr.Lhs)
 	g.buildCodeBlock(expr.Rhs)
@@ -485,6 +922,42 @@ func (g *generator) buildStringLiteralCodeBlock(expr *prior.StringLiteralExpr) {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// buildStoredValueCodeBlock builds a value that is to be stored as the given type, making it a present value when
+// that type is optional but the value's own type is not, and tagging it with its base type when that type, or its
+// value type, is a tagged union but the value's own type is not.
+func (g *generator) buildStoredValueCodeBlock(value prior.IExpression, typeIndex types.TypeIndex) {
+	g.buildCodeBlock(value)
+
+	valueTypeIndex := value.GetTypeIndex()
+	targetTypeIndex := typeIndex
+	optionalType, isOptional := g.TypeConstants.Get(typeIndex).(*types.OptionalType)
+	if isOptional {
+		targetTypeIndex = optionalType.ValueTypeIndex
+	}
+
+	switch g.TypeConstants.Get(valueTypeIndex).Category() {
+	case types.TypeCategoryNone:
+		return
+	case types.TypeCategoryOptional:
+		if !g.isStoredAlike(valueTypeIndex, typeIndex) {
+			g.failUnsupportedConversion(value.GetSourcePosition(), valueTypeIndex, typeIndex)
+		}
+		return
+	}
+
+	if g.TypeConstants.IsTaggedUnion(targetTypeIndex) && !g.TypeConstants.IsTaggedUnion(valueTypeIndex) {
+		g.CodeBlock.UnionWrap(g.TypeConstants.BaseTypeIndex(valueTypeIndex))
+	} else if !g.isStoredAlike(valueTypeIndex, targetTypeIndex) {
+		g.failUnsupportedConversion(value.GetSourcePosition(), valueTypeIndex, typeIndex)
+	}
+
+	if isOptional {
+		g.CodeBlock.OptionalWrap()
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (g *generator) buildSubtractionCodeBlock(expr *prior.SubtractionExpr) {
 	g.buildCodeBlock(expr.Lhs)
 
@@ -498,13 +971,53 @@ func (g *generator) buildSubtractionCodeBlock(expr *prior.SubtractionExpr) {
 		case types.BuiltInTypeIndexInt64:
 			g.CodeBlock.Int64Subtract()
 		default:
-			panic(fmt.Sprintf("Missing case in buildSubtractionCodeBlock: %d\n", expr.TypeIndex))
+			if g.TypeConstants.Get(expr.TypeIndex).Category() == types.TypeCategoryRecord {
+				g.CodeBlock.RecordSubtract(expr.TypeIndex)
+			} else {
+				g.failUnsupportedOperator(expr.SourcePosition, "-", expr.TypeIndex)
+			}
 		}
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (g *generator) buildUnionTypeCodeBlock(expr *prior.UnionTypeExpr) {
+	g.CodeBlock.TypeLoad(expr.ValueIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// buildWhenCodeBlock tries the guards of a "when" chain in order, jumping past the value of each alternative whose
+// guard is false. After the last guard fails, an alternative without a guard gives the value or else it is a runtime
+// error.
+func (g *generator) buildWhenCodeBlock(expr *prior.WhenExpr) {
+	jumpsToEnd := make([]int, 0)
+
+	for _, alternative := range expr.Alternatives {
+		if alternative.Guard == nil {
+			g.buildStoredValueCodeBlock(alternative.Value, expr.TypeIndex)
+			break
+		}
+
+		g.buildCodeBlock(alternative.Guard)
+		jumpToNext := g.CodeBlock.JumpIfFalse()
+		g.buildStoredValueCodeBlock(alternative.Value, expr.TypeIndex)
+		jumpsToEnd = append(jumpsToEnd, g.CodeBlock.Jump())
+		g.CodeBlock.PatchJump(jumpToNext)
+	}
+
+	if expr.Alternatives[len(expr.Alternatives)-1].Guard != nil {
+		g.CodeBlock.WhenUnmatched()
+	}
+
+	for _, jump := range jumpsToEnd {
+		g.CodeBlock.PatchJump(jump)
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (g *generator) buildWhereCodeBlock(expr *prior.WhereExpr) {
 	g.buildCodeBlock(expr.Rhs)
 	g.buildCodeBlock(expr.Lhs)
@@ -512,3 +1025,140 @@ func (g *generator) buildWhereCodeBlock(expr *prior.WhereExpr) {
 }
 
 //---------------------------------------------------------------------------------------------------------------------
+
+// isStoredAlike determines whether values of the first given type are represented the same way as values of the
+// second, with tagged union values and optional values in the same places, so that they can be stored as the second
+// type unchanged.
+func (g *generator) isStoredAlike(valueTypeIndex types.TypeIndex, typeIndex types.TypeIndex) bool {
+
+	if valueTypeIndex == typeIndex || valueTypeIndex == types.BuiltInTypeIndexNone {
+		return true
+	}
+
+	if g.TypeConstants.IsTaggedUnion(valueTypeIndex) != g.TypeConstants.IsTaggedUnion(typeIndex) ||
+		(g.TypeConstants.Get(valueTypeIndex).Category() == types.TypeCategoryOptional) !=
+			(g.TypeConstants.Get(typeIndex).Category() == types.TypeCategoryOptional) {
+		return false
+	}
+
+	switch valueType := g.TypeConstants.Get(valueTypeIndex).(type) {
+	case *types.ArrayType:
+		if typ, ok := g.TypeConstants.Get(typeIndex).(*types.ArrayType); ok {
+			return g.isStoredAlike(valueType.ElementTypeIndex, typ.ElementTypeIndex)
+		}
+	case *types.OptionalType:
+		if typ, ok := g.TypeConstants.Get(typeIndex).(*types.OptionalType); ok {
+			return g.isStoredAlike(valueType.ValueTypeIndex, typ.ValueTypeIndex)
+		}
+	case *types.RecordType:
+		if typ, ok := g.TypeConstants.Get(typeIndex).(*types.RecordType); ok {
+			for i, fieldTypeIndex := range valueType.FieldTypeIndexes {
+				if i < len(typ.FieldTypeIndexes) && !g.isStoredAlike(fieldTypeIndex, typ.FieldTypeIndexes[i]) {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// satisfiesConstraint evaluates a value built only from constants and checks it against the constraint type with given
+// index, also describing the evaluated value for a diagnostic. Returns false as its last result for a value that is not
+// constant or that fails to evaluate, leaving the check, or the failure, to the running code.
+func (g *generator) satisfiesConstraint(
+	value prior.IExpression,
+	typeIndex types.TypeIndex,
+) (satisfied bool, constantValue string, ok bool) {
+
+	if !isConstant(value) {
+		return false, "", false
+	}
+
+	codeBlock := g.CodeBlock
+	g.CodeBlock = bytecode.NewCodeBlock()
+	g.CodeBlock.Constraints = codeBlock.Constraints
+	g.CodeBlock.Patterns = codeBlock.Patterns
+	g.CodeBlock.FieldNames = codeBlock.FieldNames
+	g.buildCodeBlock(value)
+	g.CodeBlock.Stop()
+	valueCodeBlock := g.CodeBlock
+	g.CodeBlock = codeBlock
+
+	defer func() {
+		if r := recover(); r != nil {
+			satisfied, constantValue, ok = false, "", false
+		}
+	}()
+
+	interpreter := bytecode.NewInterpreter(valueCodeBlock, g.StringPool.Clone(), g.TypeConstants.Clone())
+	machine := bytecode.NewMachine()
+	interpreter.Execute(machine)
+
+	result := machine.Stack[machine.Top]
+
+	return interpreter.SatisfiesConstraint(typeIndex, result), interpreter.FormatValue(typeIndex, result), true
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// optionalOperandType finds the type of two compared optional values, that of the left hand operand unless it is
+// only ever none.
+func (g *generator) optionalOperandType(lhs prior.IExpression, rhs prior.IExpression) types.TypeIndex {
+	if g.TypeConstants.Get(lhs.GetTypeIndex()).Category() == types.TypeCategoryNone {
+		return rhs.GetTypeIndex()
+	}
+	return lhs.GetTypeIndex()
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// taggedUnionOperandType finds the type of whichever of two compared operands is a tagged union, so that both of them
+// can be compared as values of that type. Returns false when neither one is.
+func (g *generator) taggedUnionOperandType(lhs prior.IExpression, rhs prior.IExpression) (types.TypeIndex, bool) {
+	switch {
+	case g.TypeConstants.IsTaggedUnion(lhs.GetTypeIndex()):
+		return lhs.GetTypeIndex(), true
+	case g.TypeConstants.IsTaggedUnion(rhs.GetTypeIndex()):
+		return rhs.GetTypeIndex(), true
+	}
+	return types.BuiltInTypeIndexError, false
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// isConstant determines whether an expression is built only from literals by arithmetic, concatenation, and logical
+// operators, so that its value is known before the code runs.
+func isConstant(expr prior.IExpression) bool {
+	switch e := expr.(type) {
+	case *prior.BooleanLiteralExpr, *prior.Float64LiteralExpr, *prior.Int64LiteralExpr, *prior.StringLiteralExpr:
+		return true
+	case *prior.AdditionExpr:
+		return isConstant(e.Lhs) && isConstant(e.Rhs)
+	case *prior.DivisionExpr:
+		return isConstant(e.Lhs) && isConstant(e.Rhs)
+	case *prior.LogicalAndExpr:
+		return isConstant(e.Lhs) && isConstant(e.Rhs)
+	case *prior.LogicalNotOperationExpr:
+		return isConstant(e.Operand)
+	case *prior.LogicalOrExpr:
+		return isConstant(e.Lhs) && isConstant(e.Rhs)
+	case *prior.MultiplicationExpr:
+		return isConstant(e.Lhs) && isConstant(e.Rhs)
+	case *prior.NegationOperationExpr:
+		return isConstant(e.Operand)
+	case *prior.ParenthesizedExpr:
+		return isConstant(e.InnerExpr)
+	case *prior.StringConcatenationExpr:
+		return isConstant(e.Lhs) && isConstant(e.Rhs)
+	case *prior.SubtractionExpr:
+		return isConstant(e.Lhs) && isConstant(e.Rhs)
+	}
+	return false
+}
+
+//---------------------------------------------------------------------------------------------------------------------
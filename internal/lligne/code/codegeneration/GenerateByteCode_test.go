@@ -76,6 +76,10 @@ func TestGenerateBoolByteCode(t *testing.T) {
 			{"not true", false},
 			{"not false", true},
 			{"true and not false", true},
+			{"false and 1 / 0 > 0", false},
+			{"true or 1 / 0 > 0", true},
+			{"true and false or true", true},
+			{"false or true and false", false},
 
 			{"2 == 1 + 1", true},
 			{"3 == 1 + 1", false},
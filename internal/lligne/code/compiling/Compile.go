@@ -0,0 +1,148 @@
+//
+// # Chaining of the Lligne compiler passes from source code to byte code.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package compiling
+
+import (
+	"lligne-cli/internal/lligne/code/analysis/nameresolution"
+	"lligne-cli/internal/lligne/code/analysis/pooling"
+	"lligne-cli/internal/lligne/code/analysis/structuring"
+	"lligne-cli/internal/lligne/code/analysis/typechecking"
+	"lligne-cli/internal/lligne/code/codegeneration"
+	"lligne-cli/internal/lligne/code/diagnostics"
+	"lligne-cli/internal/lligne/code/parsing"
+	"lligne-cli/internal/lligne/code/scanning"
+	"lligne-cli/internal/lligne/code/scanning/tokenfilters"
+	"lligne-cli/internal/lligne/code/util"
+)
+
+//=====================================================================================================================
+
+// CheckExpression runs the passes from scanning through type checking for the given source code. The pipeline stops
+// after the first pass that reports an error, in which case the returned outcome is nil. The diagnostics from all the
+// passes that ran are returned either way.
+func CheckExpression(sourceCode string) (outcome *typechecking.Outcome, diags []*diagnostics.Diagnostic) {
+	return check(sourceCode, parsing.ParseExpression)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// CheckTopLevel is like CheckExpression but treats the source code as the top level items of a file.
+func CheckTopLevel(sourceCode string) (outcome *typechecking.Outcome, diags []*diagnostics.Diagnostic) {
+	return check(sourceCode, parsing.ParseTopLevel)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// CompileExpression runs all the compiler passes for the given source code, producing executable byte code. As with
+// CheckExpression, the outcome is nil if any pass reports an error.
+func CompileExpression(sourceCode string) (outcome *codegeneration.Outcome, diags []*diagnostics.Diagnostic) {
+	return compile(sourceCode, parsing.ParseExpression)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// CompileTopLevel is like CompileExpression but treats the source code as the top level items of a file.
+func CompileTopLevel(sourceCode string) (outcome *codegeneration.Outcome, diags []*diagnostics.Diagnostic) {
+	return compile(sourceCode, parsing.ParseTopLevel)
+}
+
+//=====================================================================================================================
+
+// check runs the passes through type checking, using the given parser entry point. Each pass carries forward the
+// diagnostics of the passes before it; they are kept after every pass so that none are lost when a later pass fails,
+// even with an internal error.
+func check(
+	sourceCode string,
+	parse func(*scanning.Outcome) *parsing.Outcome,
+) (outcome *typechecking.Outcome, diags []*diagnostics.Diagnostic) {
+
+	defer recoverInternalError(&diags)
+
+	scanOutcome := scanning.Scan(sourceCode)
+	scanOutcome = tokenfilters.RemoveDocumentation(scanOutcome)
+
+	parseOutcome := parse(scanOutcome)
+	diags = parseOutcome.Diagnostics
+	if diagnostics.HasErrors(diags) {
+		return nil, diags
+	}
+
+	poolOutcome := pooling.PoolConstants(parseOutcome)
+	diags = poolOutcome.Diagnostics
+	if diagnostics.HasErrors(diags) {
+		return nil, diags
+	}
+
+	structureOutcome := structuring.StructureRecords(poolOutcome)
+	diags = structureOutcome.Diagnostics
+	if diagnostics.HasErrors(diags) {
+		return nil, diags
+	}
+
+	resolutionOutcome := nameresolution.ResolveNames(structureOutcome)
+	diags = resolutionOutcome.Diagnostics
+	if diagnostics.HasErrors(diags) {
+		return nil, diags
+	}
+
+	typeCheckOutcome := typechecking.CheckTypes(resolutionOutcome)
+	diags = typeCheckOutcome.Diagnostics
+	if diagnostics.HasErrors(diags) {
+		return nil, diags
+	}
+
+	return typeCheckOutcome, diags
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// compile runs all the passes, using the given parser entry point.
+func compile(
+	sourceCode string,
+	parse func(*scanning.Outcome) *parsing.Outcome,
+) (outcome *codegeneration.Outcome, diags []*diagnostics.Diagnostic) {
+
+	defer recoverInternalError(&diags)
+
+	typeCheckOutcome, diags := check(sourceCode, parse)
+	if typeCheckOutcome == nil {
+		return nil, diags
+	}
+
+	// Evaluation, unlike checking, needs a value for every field of the result.
+	typeCheckOutcome = typechecking.CheckFieldValuesPresent(typeCheckOutcome)
+	diags = typeCheckOutcome.Diagnostics
+	if diagnostics.HasErrors(diags) {
+		return nil, diags
+	}
+
+	codeGenOutcome := codegeneration.GenerateByteCode(typeCheckOutcome)
+	diags = codeGenOutcome.Diagnostics
+	if diagnostics.HasErrors(diags) {
+		return nil, diags
+	}
+
+	return codeGenOutcome, diags
+
+}
+
+// recoverInternalError converts a panic from a defect inside one of the compiler passes into a diagnostic so that
+// the process embedding the compiler survives it.
+func recoverInternalError(diags *[]*diagnostics.Diagnostic) {
+	if r := recover(); r != nil {
+		*diags = append(*diags, diagnostics.NewError(
+			diagnostics.CodeInternalError,
+			util.SourcePos{},
+			"Internal compiler error: %v",
+			r,
+		))
+	}
+}
+
+//=====================================================================================================================
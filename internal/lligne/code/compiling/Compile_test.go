@@ -0,0 +1,439 @@
+//
+// # Tests of the chained Lligne compiler passes
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package compiling
+
+import (
+	"github.com/stretchr/testify/assert"
+	"lligne-cli/internal/lligne/code/diagnostics"
+	"testing"
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestCompileExpression(t *testing.T) {
+
+	checkSuccess := func(sourceCode string) {
+		outcome, diags := CompileExpression(sourceCode)
+
+		assert.NotNil(t, outcome, "For source code: "+sourceCode)
+		assert.Empty(t, diags, "For source code: "+sourceCode)
+	}
+
+	checkFailure := func(sourceCode string, expectedCode diagnostics.Code, expectedMessage string) {
+		outcome, diags := CompileExpression(sourceCode)
+
+		assert.Nil(t, outcome, "For source code: "+sourceCode)
+		if assert.Len(t, diags, 1, "For source code: "+sourceCode) {
+			assert.Equal(t, expectedCode, diags[0].Code, "For source code: "+sourceCode)
+			assert.Equal(t, expectedMessage, diags[0].Message, "For source code: "+sourceCode)
+		}
+	}
+
+	t.Run("valid code", func(t *testing.T) {
+		checkSuccess("1 + 2")
+		checkSuccess("{x = 1, y = 'z'}.y == 'z'")
+		checkSuccess("{x: Int64 = 1, y: String = 'z'}.x == 1")
+		checkSuccess(`"tab\there" + '\u{1F600}'`)
+		checkSuccess("`line one\n  `line two\n == 'line one\\nline two'")
+	})
+
+	t.Run("syntax errors stop after parsing", func(t *testing.T) {
+		checkFailure("(1 + 2", diagnostics.CodeExpectedToken, "Expected ')' but found end of file")
+	})
+
+	t.Run("invalid escape sequences", func(t *testing.T) {
+		checkFailure(`"a\qb"`, diagnostics.CodeInvalidEscapeSequence, `Invalid escape sequence '\q'`)
+		checkFailure(`'\u{D800}'`, diagnostics.CodeInvalidEscapeSequence,
+			`Invalid Unicode code point in escape sequence '\u{D800}'`)
+	})
+
+	t.Run("unsupported expressions", func(t *testing.T) {
+		checkFailure("()", diagnostics.CodeUnsupportedExpression, "Expression not yet supported")
+	})
+
+	t.Run("invalid record fields", func(t *testing.T) {
+		checkFailure("{x = 1, 2}", diagnostics.CodeInvalidRecordField, "Expected a record field of the form 'name = value' or 'name: Type = value'")
+		checkFailure("{x: Int64 = 'one'}", diagnostics.CodeTypeMismatch, "Field 'x' is declared as Int64 but its value has type String")
+		checkFailure("{a = 1, a = 'x', b = a}", diagnostics.CodeDuplicateFieldName, "Duplicate field name 'a'")
+		checkFailure("{a = 1, a = 2}.a", diagnostics.CodeDuplicateFieldName, "Duplicate field name 'a'")
+		checkFailure("{a = 1, a = 2} & {a = 1}", diagnostics.CodeDuplicateFieldName, "Duplicate field name 'a'")
+	})
+
+	t.Run("qualified record fields", func(t *testing.T) {
+		checkSuccess("{x: Int64 && 5}")
+		checkSuccess("{x: Int64 & 5}")
+		checkSuccess("{x: Int64 ?: 5, y ?: 'y'}")
+		checkFailure("{x: Bool && 5}", diagnostics.CodeTypeMismatch, "Field 'x' is declared as Bool but its value has type Int64")
+		checkFailure("{x: String ?: 5}", diagnostics.CodeTypeMismatch,
+			"Field 'x' is declared as String but its default value has type Int64")
+	})
+
+	t.Run("default values", func(t *testing.T) {
+		checkSuccess("{host: String, port: Int64 ?: 8080} & {host = 'localhost'}")
+		checkSuccess("{host: String, port: Int64 ?: 8080} && {host = 'localhost', port = 80}")
+		checkSuccess("{config = {host: String, port ?: 8080}, dev = config & {host = 'dev'}}")
+		checkFailure("{x: Int64}", diagnostics.CodeMissingFieldValue, "Missing value for required field 'x'")
+		_, diags := CheckExpression("{x: Int64}")
+		assert.Empty(t, diags, "Checking alone needs no field values")
+		checkFailure("{host: String, port: Int64 ?: 8080} & {port = 80}", diagnostics.CodeMissingFieldValue,
+			"Missing value for required field 'host'")
+		checkFailure("({host: String} & {port = 80}).host", diagnostics.CodeMissingFieldValue,
+			"Missing value for required field 'host'")
+		checkFailure("{config = {host: String, url = host + ':80'}}", diagnostics.CodeMissingFieldValue,
+			"Missing value for required field 'host'")
+		checkFailure("{port: Int64 ?: 8080} & {port = '80'}", diagnostics.CodeTypeMismatch,
+			"Cannot intersect field 'port' of type Int64 with field 'port' of type String")
+		checkFailure("{port = 8080} & 80", diagnostics.CodeTypeMismatch, "Expected a record to intersect but found Int64")
+	})
+
+	t.Run("name resolution", func(t *testing.T) {
+		checkSuccess("{x = 3, xpowers = {xsquared = x * x, xcubed = x * x * x}}")
+		checkSuccess("{a = {b = 1}, c = a.b}")
+		checkFailure("{xsquared = 9, y = xsquare}", diagnostics.CodeUndefinedName,
+			"Undefined name 'xsquare'; did you mean 'xsquared'?")
+		checkFailure("{x = 1, y = {z = w}}", diagnostics.CodeUndefinedName, "Undefined name 'w'")
+		checkFailure("{a = {size = 1}, c = a.szie}", diagnostics.CodeUndefinedName, "Undefined name 'szie'; did you mean 'size'?")
+	})
+
+	t.Run("forward references and cycles", func(t *testing.T) {
+		checkSuccess("{x = y + 1, y = 1}")
+		checkSuccess("{a = {c = b}, b = 'b'}")
+		checkFailure("{x = x}", diagnostics.CodeReferenceCycle, "Reference cycle among record fields: x -> x")
+		checkFailure("{a = b + 1, b = a}", diagnostics.CodeReferenceCycle,
+			"Reference cycle among record fields: a -> b -> a")
+		checkFailure("{p = 0, a = c, b = {q = a}, c = b.q}", diagnostics.CodeReferenceCycle,
+			"Reference cycle among record fields: a -> c -> b -> a")
+		checkFailure("{a = {b = 1, c = a}}", diagnostics.CodeReferenceCycle, "Reference cycle among record fields: a -> a")
+	})
+
+	t.Run("record intersection", func(t *testing.T) {
+		checkSuccess("{a = 1} & {a = 1}")
+		checkSuccess("{m = {a = 1} & {b: Int64}}")
+		checkSuccess("{db = {host = 'h'}} & {db = {port = 5}}")
+		checkFailure("{a = 1, b = 'x'} & {b = 'y'}", diagnostics.CodeConflictingValues, "Conflicting values for field 'b'")
+		checkFailure("{db = {host = 'h'}} & {db = {host = 'i'}}", diagnostics.CodeConflictingValues,
+			"Conflicting values for field 'host'")
+		checkSuccess("{x = 1, r = {a = x} & {a = 1}}")
+		checkSuccess("{base = {a = 1}, over = base & {a = 1 + 1}}")
+		_, diags := CheckExpression("{a = 1} & {b: Int64}")
+		assert.Empty(t, diags, "For source code: {a = 1} & {b: Int64}")
+	})
+
+	t.Run("record arithmetic", func(t *testing.T) {
+		checkSuccess("{x = 1, y = 2} + {x = 3, y = 4}")
+		checkSuccess("{x = 1.5} * {x = 2.0}")
+		checkSuccess("{a = {x = 1, y = 2}, b = {y = 4, x = 3}, c = a - b}")
+		checkSuccess("{p = {q = 1}} / {p = {q = 2}}")
+		checkFailure("{x = 1, y = 2} + {x = 3}", diagnostics.CodeTypeMismatch,
+			"Field 'y' is missing from the right operand of '+'")
+		checkFailure("{x = 1} - {x = 3, z = 4}", diagnostics.CodeTypeMismatch,
+			"Field 'z' is missing from the left operand of '-'")
+		checkFailure("{x = 1, y = 2} * {x = 3, y = 4.0}", diagnostics.CodeTypeMismatch,
+			"Cannot multiply Int64 by Float64 in field 'y'")
+		checkFailure("{p = {q = 1}} - {p = {q = 'a'}}", diagnostics.CodeTypeMismatch,
+			"Cannot subtract String from Int64 in field 'p.q'")
+		checkFailure("{s = 'a'} + {s = 'b'}", diagnostics.CodeTypeMismatch,
+			"Operator '+' is not defined for type String in field 's'")
+		checkFailure("{x = 1} / 2", diagnostics.CodeTypeMismatch, "Cannot divide {x: Int64} by Int64")
+	})
+
+	t.Run("conflicting values labels", func(t *testing.T) {
+		sourceCode := "{base = {a = 1}, over = base & {a = 2}}"
+		_, diags := CompileExpression(sourceCode)
+
+		if assert.Len(t, diags, 1) && assert.Len(t, diags[0].Labels, 2) {
+			assert.Equal(t, "base & {a = 2}", diags[0].SourcePosition.GetText(sourceCode))
+			assert.Equal(t, "1", diags[0].Labels[0].SourcePosition.GetText(sourceCode))
+			assert.Equal(t, "2", diags[0].Labels[1].SourcePosition.GetText(sourceCode))
+		}
+	})
+
+	t.Run("union types", func(t *testing.T) {
+		checkSuccess("{env: 'dev' | 'prod' = 'dev'}.env == 'dev'")
+		checkSuccess("{env: 'dev' | 'prod'} & {env = 'prod'}")
+		checkSuccess("{n: 1 | 2 = 2}.n * 10 > 15")
+		checkFailure("{env: 'dev' | 'prod' = 'test'}", diagnostics.CodeTypeMismatch,
+			`Field 'env' is declared as "dev" | "prod" but its value has type "test"`)
+		checkFailure("{env: 'dev' | 'prod' = 1}", diagnostics.CodeTypeMismatch,
+			`Field 'env' is declared as "dev" | "prod" but its value has type Int64`)
+		checkFailure("{env: 'dev' | 'prod'} & {env = 'test'}", diagnostics.CodeTypeMismatch,
+			`Field 'env' is declared as "dev" | "prod" but its value has type "test"`)
+		checkFailure("{env: 'dev' | 'prod'} & {env: 1 | 2}", diagnostics.CodeTypeMismatch,
+			`Cannot intersect field 'env' of type "dev" | "prod" with field 'env' of type 1 | 2`)
+		checkFailure("{n: 1 | 2 = 1}.n + 'a'", diagnostics.CodeTypeMismatch, `Cannot add 1 | 2 and String`)
+		checkSuccess("{x: Int64 | String = 1}.x == 1")
+		checkSuccess("{x: Int64 | String = 'a'}.x != 1")
+		checkFailure("{x: Int64 | String = true}", diagnostics.CodeTypeMismatch,
+			"Field 'x' is declared as Int64 | String but its value has type Bool")
+		checkFailure("{x: Int64 | String = 1}.x == true", diagnostics.CodeTypeMismatch,
+			"Cannot compare Int64 | String and Bool")
+		checkFailure("{x: Int64 | String = 1}.x < 2", diagnostics.CodeTypeMismatch,
+			"Cannot compare Int64 | String and Int64")
+		checkFailure("{x: (Int64 | String) && val == 1 = 1}", diagnostics.CodeUnsupportedExpression,
+			"Fields of type (Int64 | String) && val == 1 are not yet supported; "+
+				"the members of a constrained union must all be values of one type")
+		checkFailure("{x: Int64? = 1, u: Int64 | String = 2, y = x ?: u}", diagnostics.CodeUnsupportedExpression,
+			"Values of type Int64? cannot yet be stored as Int64 | String")
+		checkFailure("Int64 | (1 + 1)", diagnostics.CodeTypeMismatch,
+			"Expected a type or a literal value in a union but found a value of type Int64")
+	})
+
+	t.Run("optional types", func(t *testing.T) {
+		checkSuccess("{x: Int64?}.x == none")
+		checkSuccess("({x: Int64? = 5}.x ?: 0) == 5")
+		checkSuccess("({x: Int64? = none}.x ?: 0) == 0")
+		checkSuccess("({r: {y = 1}? = {y = 2}}.r.y ?: 0) == 2")
+		checkSuccess("{env: ('dev' | 'prod')? = 'dev'}.env != none")
+		checkSuccess("{x: Int64?} & {x = 3}")
+		checkSuccess("{x: Int64?, y: Int64? = 2}.x ?: {x: Int64?, y: Int64? = 2}.y")
+		checkFailure("{x: Int64?}.x + 1", diagnostics.CodeTypeMismatch, "Cannot add Int64? and Int64")
+		checkFailure("{x: Int64?}.x == 1", diagnostics.CodeTypeMismatch, "Cannot compare Int64? and Int64")
+		checkFailure("{x: Int64? = 'a'}", diagnostics.CodeTypeMismatch,
+			"Field 'x' is declared as Int64? but its value has type String")
+		checkFailure("{x: Int64 = none}", diagnostics.CodeTypeMismatch,
+			"Field 'x' is declared as Int64 but its value has type None")
+		checkFailure("{x = 1 ?: 2}", diagnostics.CodeTypeMismatch, "Expected an optional value before '?:' but found Int64")
+		checkFailure("{x: Int64?}.x ?: 'a'", diagnostics.CodeTypeMismatch,
+			"Cannot default a value of type Int64? to a value of type String")
+		checkFailure("(1 + 1)?", diagnostics.CodeTypeMismatch,
+			"Expected a type or a literal value before '?' but found a value of type Int64")
+	})
+
+	t.Run("constraint types", func(t *testing.T) {
+		checkSuccess("{x: Int64 && val <= 100 = 50}.x + 1")
+		checkSuccess("{x: Int64 && val > 0 and val < 10} & {x = 5}")
+		checkSuccess("{env: ('dev' | 'prod') && val != 'dev' = 'prod'}")
+		checkSuccess("50 is (Int64 && val <= 100)")
+		checkFailure("{x: Int64 && val <= 100 = 150}", diagnostics.CodeConstraintViolation,
+			"Value 150 does not satisfy the constraint 'Int64 && val <= 100'")
+		checkFailure("{x: Int64 && val > 0 = 5 - 10}", diagnostics.CodeConstraintViolation,
+			"Value -5 does not satisfy the constraint 'Int64 && val > 0'")
+		checkFailure("{s: String && val != \"ab\" = 'a' + ('b')}", diagnostics.CodeConstraintViolation,
+			"Value \"ab\" does not satisfy the constraint 'String && val != \"ab\"'")
+		checkSuccess("{x: Int64 && val > 0 = 10 - 5}")
+		checkSuccess("{x: Int64 && val > 0 = 1 / 0}")
+		checkFailure("{x: Int64 && val <= 100 = 'a'}", diagnostics.CodeTypeMismatch,
+			"Field 'x' is declared as Int64 && val <= 100 but its value has type String")
+		checkFailure("{x: Int64 && val + 1 = 1}", diagnostics.CodeTypeMismatch,
+			"Expected a Bool predicate in a constraint but found Int64")
+		checkFailure("{y = 1, x: Int64 && val < y}", diagnostics.CodeUndefinedName, "Undefined name 'y'")
+	})
+
+	t.Run("arrays", func(t *testing.T) {
+		checkSuccess("[1, 2, 3][0] + 1")
+		checkSuccess("[[1], [2, 3]][1].length == 2")
+		checkSuccess("{a = [1, 2], b = a[1] + a.length}")
+		checkSuccess("[{x = 1}, {x = 2}][1].x")
+		checkSuccess("[1, none][0] ?: 0")
+		checkSuccess("{a: Int64[] = [], b: String[]? = [], c: Int64[] ?: []}")
+		checkSuccess("{f: (xs: Int64[]) -> Int64[] = xs, y = f([1, 2])}")
+		checkSuccess("[1, 2] is (1 | 2)[]")
+		checkFailure("[]", diagnostics.CodeUnsupportedExpression, "Cannot infer the element type of an empty array")
+		checkFailure("{a: Int64 = []}", diagnostics.CodeUnsupportedExpression,
+			"Cannot infer the element type of an empty array")
+		checkFailure("{a: Int64[] = ['a']}", diagnostics.CodeTypeMismatch,
+			"Field 'a' is declared as Int64[] but its value has type String[]")
+		checkFailure("{a: (1 + 2)[] = [3]}", diagnostics.CodeTypeMismatch,
+			"Expected a type or a literal value before '[]' but found a value of type Int64")
+		checkFailure("[1, 'a']", diagnostics.CodeTypeMismatch, "Expected an array element of type Int64 but found String")
+		checkFailure("[1]['a']", diagnostics.CodeTypeMismatch, "Expected an Int64 array index but found String")
+		checkFailure("1[0]", diagnostics.CodeTypeMismatch, "Expected an array before '[' but found Int64")
+		checkFailure("[1].size", diagnostics.CodeUndefinedName, "Undefined name 'size'")
+		checkFailure("[1] == ['a']", diagnostics.CodeTypeMismatch, "Cannot compare Int64[] and String[]")
+	})
+
+	t.Run("functions", func(t *testing.T) {
+		checkSuccess("{f: (n: Int64) -> Int64 = n + 1, x = f(1)}")
+		checkSuccess("{f: (a: Int64, b: String) -> String = b, x = f(1, 'b')}")
+		checkSuccess("{f: (n: Int64) -> Int64 = f(n - 1)}")
+		checkSuccess("{k = 2, f: (n: Int64) -> Int64 = {m = n * k}.m, x = f(3)}")
+		checkSuccess("(n: Int64, s: String) -> Bool")
+		checkSuccess("{f: () -> Int64 = 1, x = f()}")
+		checkSuccess("() -> Int64")
+		checkFailure("{f: (n: Int64) -> Int64 = 'a'}", diagnostics.CodeTypeMismatch,
+			"Function result is declared as Int64 but its body has type String")
+		checkFailure("{f: (n: Int64) -> Int64 = n, x = f('a')}", diagnostics.CodeTypeMismatch,
+			"Expected an argument of type Int64 but found String")
+		checkFailure("{f: (n: Int64) -> Int64 = n, x = f(1, 2)}", diagnostics.CodeWrongArgumentCount,
+			"Expected 1 argument(s) to (Int64) -> Int64 but found 2")
+		checkFailure("{f: () -> Int64 = 1, x = f(1)}", diagnostics.CodeWrongArgumentCount,
+			"Expected 0 argument(s) to () -> Int64 but found 1")
+		checkFailure("{f: (n: Int64) -> Int64 = n, x = f(n = 1)}", diagnostics.CodeUnsupportedExpression,
+			"Named arguments are not supported; give the arguments in the order of the parameters")
+		checkFailure("{x = 1, y = x(2)}", diagnostics.CodeTypeMismatch, "Expected a function before '(' but found Int64")
+		checkFailure("{f: (n: Int64) -> Int64 = m}", diagnostics.CodeUndefinedName, "Undefined name 'm'")
+		checkFailure("{f: (n: Int64) -> Int64 = n, y = n}", diagnostics.CodeUndefinedName, "Undefined name 'n'")
+		checkFailure("{f: (n: Int64) -> Int64}", diagnostics.CodeInvalidRecordField,
+			"Expected a function field of the form 'name: (n: Type) -> Type = body'")
+		checkFailure("{f: (1) -> Int64 = 1}", diagnostics.CodeInvalidFunctionParameter,
+			"Expected a function parameter of the form 'name: Type'")
+		checkFailure("{f: (n: Int64) -> Int64 = n, g = f}", diagnostics.CodeUnsupportedExpression,
+			"Field 'g' cannot be another name for a function; define it as 'name: (n: Type) -> Type = body'")
+		checkFailure("{f: (n: Int64) -> Int64 = g(n), g: (n: Int64) -> Int64 = f(n)}", diagnostics.CodeReferenceCycle,
+			"Reference cycle among record fields: f -> g -> f")
+		checkFailure("{f: (n: Int64 && val > 0) -> Int64 = n, x = f(0)}", diagnostics.CodeConstraintViolation,
+			"Value 0 does not satisfy the constraint 'Int64 && val > 0'")
+	})
+
+	t.Run("when guards", func(t *testing.T) {
+		checkSuccess("'a' when true | 'b'")
+		checkSuccess("1 when false | 2 when true")
+		checkSuccess("{n = 1, x = 'neg' when n < 0 | 'zero' when n == 0 | 'pos'}")
+		checkSuccess("{f: (n: Int64) -> Int64 = 1 when n == 0 | n * f(n - 1) when n > 0, x = f(5)}")
+		checkFailure("1 when 2 | 3", diagnostics.CodeTypeMismatch, "Expected a Bool guard after 'when' but found Int64")
+		checkFailure("1 when true | 'a'", diagnostics.CodeTypeMismatch,
+			"Expected a 'when' alternative of type Int64 but found String")
+		checkFailure("1 when true | 2 | 3 when false", diagnostics.CodeInvalidWhenAlternative,
+			"Expected a 'when' guard; only the last alternative can go without one")
+	})
+
+	t.Run("pattern matches", func(t *testing.T) {
+		checkSuccess("'abc' =~ '^a'")
+		checkSuccess("{p = '^a', m = 'abc' !~ p}")
+		checkSuccess("{s: String && val =~ '^[a-z]+$' = 'abc'}")
+		checkFailure("'x' =~ 'a(b'", diagnostics.CodeInvalidPattern, "Invalid pattern 'a(b': missing closing )")
+		checkFailure("'x' !~ '[z-a]'", diagnostics.CodeInvalidPattern,
+			"Invalid pattern '[z-a]': invalid character class range")
+		checkFailure("1 =~ '1'", diagnostics.CodeTypeMismatch, "Cannot match Int64 against a pattern of type String")
+		checkFailure("1 =~ 2", diagnostics.CodeTypeMismatch, "Operator '=~' is not defined for type Int64")
+	})
+
+	t.Run("collated comparisons", func(t *testing.T) {
+		checkSuccess("'A' ==[Aa]== 'a'")
+		checkSuccess("{env = 'Prod', ok = env =![Aa, NFC]!= 'dev'}")
+		checkFailure("'a' ==[Bb]== 'b'", diagnostics.CodeInvalidComparisonModifier,
+			"Invalid comparison modifiers 'Bb': unknown modifier 'Bb'; expected one of Aa, NFC, NFKC")
+		checkFailure("1 ==[Aa]== 2", diagnostics.CodeTypeMismatch, "Operator '==[Aa]==' is not defined for type Int64")
+		checkFailure("'a' =![Aa]!= 1", diagnostics.CodeTypeMismatch, "Cannot compare String and Int64")
+	})
+
+	t.Run("formats", func(t *testing.T) {
+		checkSuccess("42 fmt '%5d'")
+		checkSuccess("{x = 1.5, s = x fmt '%,.2f' + '!'}")
+		checkSuccess("{spec = '%x', s = 255 fmt spec}")
+		checkFailure("1.5 fmt '%d'", diagnostics.CodeInvalidFormat,
+			"Invalid format '%d': verb 'd' does not apply to Float64; expected one of 'f', 'e', 'g'")
+		checkFailure("42 fmt '%.2d'", diagnostics.CodeInvalidFormat, "Invalid format '%.2d': a precision does not apply to Int64")
+		checkFailure("'a' fmt 'a %s b %s'", diagnostics.CodeInvalidFormat,
+			"Invalid format 'a %s b %s': expected just one directive but found another")
+		checkFailure("true fmt 'yes'", diagnostics.CodeInvalidFormat, "Invalid format 'yes': expected a directive like %d")
+		checkFailure("[1] fmt '%d'", diagnostics.CodeTypeMismatch, "Operator 'fmt' is not defined for type Int64[]")
+		checkFailure("1 fmt 2", diagnostics.CodeTypeMismatch, "Expected a String format after 'fmt' but found Int64")
+	})
+
+	t.Run("ranges", func(t *testing.T) {
+		checkSuccess("5 in 1..10")
+		checkSuccess("{low = 1, high = 5, ok = 3 in low..high}")
+		checkSuccess("'b' in ['a', 'b']")
+		checkSuccess("{port: 1..65535 = 80}")
+		checkSuccess("{ratio: 0.0..1.0 = 0.5}")
+		checkFailure("{port: 1..65535 = 70000}", diagnostics.CodeConstraintViolation,
+			"Value 70000 does not satisfy the constraint 'Int64 && val in 1..65535'")
+		checkFailure("{x = 3, port: 1..x = 2}", diagnostics.CodeUnsupportedExpression,
+			"Expected a constant bound for a range used as a type")
+		checkFailure("1..'a'", diagnostics.CodeTypeMismatch, "Cannot make a range from Int64 to String")
+		checkFailure("true..false", diagnostics.CodeTypeMismatch, "Operator '..' is not defined for type Bool")
+		checkFailure("1 in 1.0..2.0", diagnostics.CodeTypeMismatch, "Cannot look for Int64 in Float64..Float64")
+		checkFailure("'a' in [1, 2]", diagnostics.CodeTypeMismatch, "Cannot look for String in Int64[]")
+		checkFailure("1 in 3", diagnostics.CodeTypeMismatch, "Expected a range or an array after 'in' but found Int64")
+	})
+
+	t.Run("type errors", func(t *testing.T) {
+		checkFailure("q + 1", diagnostics.CodeUndefinedName, "Undefined name 'q'")
+		checkFailure("true + false", diagnostics.CodeTypeMismatch, "Operator '+' is not defined for type Bool")
+		checkFailure("1 + 'a'", diagnostics.CodeTypeMismatch, "Cannot add Int64 and String")
+		checkFailure("2.0 - 1", diagnostics.CodeTypeMismatch, "Cannot subtract Int64 from Float64")
+		checkFailure("2 * 1.5", diagnostics.CodeTypeMismatch, "Cannot multiply Int64 by Float64")
+		checkFailure("'a' / 'b'", diagnostics.CodeTypeMismatch, "Operator '/' is not defined for type String")
+		checkFailure("-'a'", diagnostics.CodeTypeMismatch, "Operator '-' is not defined for type String")
+		checkFailure("1 < 'a'", diagnostics.CodeTypeMismatch, "Cannot compare Int64 and String")
+		checkFailure("{x = 1} == {x = 'a'}", diagnostics.CodeTypeMismatch,
+			"Cannot compare {x: Int64} and {x: String}")
+		checkFailure("1 and true", diagnostics.CodeTypeMismatch, "Cannot apply 'and' to Int64 and Bool")
+		checkFailure("not 1", diagnostics.CodeTypeMismatch, "Operator 'not' is not defined for type Int64")
+		checkFailure("1 is 2", diagnostics.CodeTypeMismatch,
+			"Expected a type on the right hand side of 'is' but found Int64")
+		checkFailure("(1).x", diagnostics.CodeTypeMismatch, "Expected a record before '.' but found Int64")
+		checkFailure("x where 1", diagnostics.CodeTypeMismatch,
+			"Expected a record on the right hand side of 'where' but found Int64")
+	})
+
+	t.Run("type mismatch labels", func(t *testing.T) {
+		_, diags := CompileExpression("1 + 'a'")
+
+		if assert.Len(t, diags, 1) && assert.Len(t, diags[0].Labels, 2) {
+			assert.Equal(t, "Left operand has type Int64", diags[0].Labels[0].Message)
+			assert.Equal(t, "1", diags[0].Labels[0].SourcePosition.GetText("1 + 'a'"))
+			assert.Equal(t, "Right operand has type String", diags[0].Labels[1].Message)
+			assert.Equal(t, "'a'", diags[0].Labels[1].SourcePosition.GetText("1 + 'a'"))
+		}
+	})
+
+	t.Run("type checking continues after errors", func(t *testing.T) {
+		_, diags := CompileExpression("{a = 1 + 'x', b = a * 2, c = not 3, d: String = 4}")
+
+		if assert.Len(t, diags, 3) {
+			assert.Equal(t, "Cannot add Int64 and String", diags[0].Message)
+			assert.Equal(t, "Operator 'not' is not defined for type Int64", diags[1].Message)
+			assert.Equal(t, "Field 'd' is declared as String but its value has type Int64", diags[2].Message)
+		}
+	})
+
+	t.Run("function comparisons", func(t *testing.T) {
+		checkFailure("{f: (n: Int64) -> Int64 = n, g = f == f}", diagnostics.CodeTypeMismatch,
+			"Operator '==' is not defined for type (Int64) -> Int64")
+		checkFailure("{f: (n: Int64) -> Int64 = n, g = f != f}", diagnostics.CodeTypeMismatch,
+			"Operator '!=' is not defined for type (Int64) -> Int64")
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestCompileTopLevel(t *testing.T) {
+
+	checkSuccess := func(sourceCode string) {
+		outcome, diags := CompileTopLevel(sourceCode)
+
+		assert.NotNil(t, outcome, "For source code: "+sourceCode)
+		assert.Empty(t, diags, "For source code: "+sourceCode)
+	}
+
+	checkFailure := func(sourceCode string, expectedCode diagnostics.Code, expectedMessage string) {
+		outcome, diags := CompileTopLevel(sourceCode)
+
+		assert.Nil(t, outcome, "For source code: "+sourceCode)
+		if assert.Len(t, diags, 1, "For source code: "+sourceCode) {
+			assert.Equal(t, expectedCode, diags[0].Code, "For source code: "+sourceCode)
+			assert.Equal(t, expectedMessage, diags[0].Message, "For source code: "+sourceCode)
+		}
+	}
+
+	t.Run("valid code", func(t *testing.T) {
+		checkSuccess("")
+		checkSuccess("x = 1")
+		checkSuccess("x = 1, y = 'z'; z = true")
+		checkSuccess("// The host\nhost: String = 'localhost'\nport: Int64 = 8080 // The port\n")
+		checkSuccess("width = 3\nheight = 4\nbox = {area = width * height}")
+	})
+
+	t.Run("undefined names", func(t *testing.T) {
+		checkFailure("width = 3\nbox = {area = widht * 2}", diagnostics.CodeUndefinedName,
+			"Undefined name 'widht'; did you mean 'width'?")
+	})
+
+	t.Run("invalid items", func(t *testing.T) {
+		checkFailure("x = 1\n2", diagnostics.CodeInvalidRecordField,
+			"Expected a record field of the form 'name = value' or 'name: Type = value'")
+		checkFailure("x = 1 y = 2", diagnostics.CodeExpectedToken, "Expected ',', ';', or a line break but found 'y'")
+		checkFailure("a = 1\na: Int64", diagnostics.CodeDuplicateFieldName, "Duplicate field name 'a'")
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,44 @@
+//
+// # Early termination of a compiler pass after an unrecoverable error.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package diagnostics
+
+//=====================================================================================================================
+
+// aborted is the panic value used by Abort.
+type aborted struct{}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Abort unwinds the compiler pass currently running inside RunAbortable. The pass must have recorded a diagnostic
+// explaining why it could not continue.
+func Abort() {
+	panic(aborted{})
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// RunAbortable runs the given pass, stopping quietly if it calls Abort. Returns true if the pass ran to completion.
+// Any other panic is propagated unchanged.
+func RunAbortable(pass func()) (completed bool) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(aborted); !ok {
+				panic(r)
+			}
+			completed = false
+		}
+	}()
+
+	pass()
+
+	return true
+
+}
+
+//=====================================================================================================================
@@ -0,0 +1,57 @@
+//
+// # Codes identifying the kinds of diagnostics.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package diagnostics
+
+import "fmt"
+
+//=====================================================================================================================
+
+// Code identifies the kind of problem described by a diagnostic. Codes are grouped by hundreds according to the
+// compiler pass that reports them and, once published, are never renumbered.
+type Code uint16
+
+const (
+	// Syntax errors
+	CodeUnexpectedToken           Code = 101
+	CodeExpectedToken             Code = 102
+	CodeUnrecognizedCharacter     Code = 103
+	CodeUnclosedString            Code = 104
+	CodeInvalidEscapeSequence     Code = 105
+	CodeInvalidComparisonModifier Code = 106
+
+	// Structural errors
+	CodeInvalidRecordField       Code = 201
+	CodeInvalidFunctionParameter Code = 202
+	CodeInvalidWhenAlternative   Code = 203
+	CodeDuplicateFieldName       Code = 204
+
+	// Name resolution errors
+	CodeUndefinedName  Code = 301
+	CodeReferenceCycle Code = 302
+
+	// Type errors
+	CodeUnsupportedExpression Code = 401
+	CodeTypeMismatch          Code = 402
+	CodeMissingFieldValue     Code = 403
+	CodeConflictingValues     Code = 404
+	CodeConstraintViolation   Code = 405
+	CodeWrongArgumentCount    Code = 406
+	CodeInvalidPattern        Code = 407
+	CodeInvalidFormat         Code = 408
+
+	// Internal errors
+	CodeInternalError Code = 901
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (c Code) String() string {
+	return fmt.Sprintf("E%03d", uint16(c))
+}
+
+//=====================================================================================================================
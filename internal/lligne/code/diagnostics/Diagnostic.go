@@ -0,0 +1,130 @@
+//
+// # Diagnostics reported by the Lligne compiler passes.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package diagnostics
+
+import (
+	"fmt"
+	"lligne-cli/internal/lligne/code/util"
+	"strings"
+)
+
+//=====================================================================================================================
+
+// Severity is an enumeration of how serious a diagnostic is.
+type Severity uint16
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInformation
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (s Severity) String() string {
+
+	switch s {
+
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInformation:
+		return "info"
+
+	}
+
+	panic(fmt.Sprintf("Unhandled severity: %d", s))
+
+}
+
+//=====================================================================================================================
+
+// Diagnostic is one problem found in Lligne source code by one of the compiler passes.
+type Diagnostic struct {
+	Severity       Severity
+	Code           Code
+	Message        string
+	SourcePosition util.SourcePos
+	Labels         []Label
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Label points out further source code involved in a diagnostic, e.g. each operand of a mistyped operation.
+type Label struct {
+	SourcePosition util.SourcePos
+	Message        string
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// NewError constructs an error diagnostic with a formatted message.
+func NewError(code Code, sourcePosition util.SourcePos, format string, args ...any) *Diagnostic {
+	return &Diagnostic{
+		Severity:       SeverityError,
+		Code:           code,
+		Message:        fmt.Sprintf(format, args...),
+		SourcePosition: sourcePosition,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// WithLabel adds a label with a formatted message to the diagnostic. Returns the diagnostic itself.
+func (d *Diagnostic) WithLabel(sourcePosition util.SourcePos, format string, args ...any) *Diagnostic {
+	d.Labels = append(d.Labels, Label{
+		SourcePosition: sourcePosition,
+		Message:        fmt.Sprintf(format, args...),
+	})
+	return d
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Error returns the severity, code, and message of the diagnostic, making it usable as a Go error.
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s[%s]: %s", d.Severity, d.Code, d.Message)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Render formats the diagnostic with its file location followed by an excerpt of the offending source code, e.g.
+//
+//	config.lligne:3:11: error[E301]: Undefined name 'count'
+//	3 | total = count + 1
+//	  |         ^^^^^
+//
+// Each label follows as a note in the same format.
+func (d *Diagnostic) Render(sourceFile *util.SourceFile) string {
+	sb := strings.Builder{}
+
+	sb.WriteString(sourceFile.GetLocationText(d.SourcePosition) + ": " + d.Error() + "\n")
+	sb.WriteString(sourceFile.GetExcerpt(d.SourcePosition))
+
+	for _, label := range d.Labels {
+		sb.WriteString(sourceFile.GetLocationText(label.SourcePosition) + ": note: " + label.Message + "\n")
+		sb.WriteString(sourceFile.GetExcerpt(label.SourcePosition))
+	}
+
+	return sb.String()
+}
+
+//=====================================================================================================================
+
+// HasErrors determines whether any of the given diagnostics has error severity.
+func HasErrors(diagnostics []*Diagnostic) bool {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+//=====================================================================================================================
@@ -0,0 +1,86 @@
+//
+// # Tests of Lligne diagnostics
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package diagnostics
+
+import (
+	"github.com/stretchr/testify/assert"
+	"lligne-cli/internal/lligne/code/scanning"
+	"lligne-cli/internal/lligne/code/util"
+	"testing"
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestDiagnostics(t *testing.T) {
+
+	t.Run("error text", func(t *testing.T) {
+		diagnostic := NewError(CodeUndefinedName, util.SourcePos{}, "Undefined name '%s'", "x")
+
+		assert.Equal(t, "error[E301]: Undefined name 'x'", diagnostic.Error())
+	})
+
+	t.Run("rendering", func(t *testing.T) {
+		sourceCode := "x = 1,\ntotal = count + 1"
+		scanOutcome := scanning.Scan(sourceCode)
+		sourceFile := util.NewSourceFile("config.lligne", sourceCode, scanOutcome.NewLineOffsets)
+		diagnostic := NewError(CodeUndefinedName, util.NewSourcePos(scanOutcome.Tokens[6]), "Undefined name 'count'")
+
+		assert.Equal(t,
+			"config.lligne:2:9: error[E301]: Undefined name 'count'\n"+
+				"2 | total = count + 1\n"+
+				"  |         ^^^^^\n",
+			diagnostic.Render(sourceFile),
+		)
+	})
+
+	t.Run("rendering with labels", func(t *testing.T) {
+		sourceCode := "total = 1 + 'one'"
+		scanOutcome := scanning.Scan(sourceCode)
+		sourceFile := util.NewSourceFile("config.lligne", sourceCode, scanOutcome.NewLineOffsets)
+		lhsPos := util.NewSourcePos(scanOutcome.Tokens[2])
+		rhsPos := util.NewSourcePos(scanOutcome.Tokens[4])
+		diagnostic := NewError(CodeTypeMismatch, lhsPos.Thru(rhsPos), "Cannot add Int64 and String").
+			WithLabel(lhsPos, "Left operand has type Int64").
+			WithLabel(rhsPos, "Right operand has type String")
+
+		assert.Len(t, diagnostic.Labels, 2)
+		assert.Equal(t,
+			"config.lligne:1:9: error[E402]: Cannot add Int64 and String\n"+
+				"1 | total = 1 + 'one'\n"+
+				"  |         ^^^^^^^^^\n"+
+				"config.lligne:1:9: note: Left operand has type Int64\n"+
+				"1 | total = 1 + 'one'\n"+
+				"  |         ^\n"+
+				"config.lligne:1:13: note: Right operand has type String\n"+
+				"1 | total = 1 + 'one'\n"+
+				"  |             ^^^^^\n",
+			diagnostic.Render(sourceFile),
+		)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		progress := 0
+
+		assert.True(t, RunAbortable(func() { progress = 1 }))
+		assert.False(t, RunAbortable(func() { Abort(); progress = 2 }))
+		assert.Equal(t, 1, progress)
+		assert.Panics(t, func() { RunAbortable(func() { panic("other") }) })
+	})
+
+	t.Run("has errors", func(t *testing.T) {
+		warning := &Diagnostic{Severity: SeverityWarning, Code: CodeTypeMismatch, Message: "w"}
+		err := &Diagnostic{Severity: SeverityError, Code: CodeTypeMismatch, Message: "e"}
+
+		assert.False(t, HasErrors(nil))
+		assert.False(t, HasErrors([]*Diagnostic{warning}))
+		assert.True(t, HasErrors([]*Diagnostic{warning, err}))
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
@@ -10,6 +10,7 @@ package formatting
 import (
 	"fmt"
 	prior "lligne-cli/internal/lligne/code/parsing"
+	"lligne-cli/internal/lligne/code/scanning"
 	"lligne-cli/internal/lligne/runtime/pools"
 	"strings"
 )
@@ -21,6 +22,14 @@ func FormatCode(parseOutcome *prior.Outcome) string {
 	return formatter.formatCode(parseOutcome.Model)
 }
 
+//---------------------------------------------------------------------------------------------------------------------
+
+// FormatTopLevel formats the outcome of parsing.ParseTopLevel with one item per line.
+func FormatTopLevel(parseOutcome *prior.Outcome) string {
+	formatter := newFormatter(parseOutcome)
+	return formatter.formatTopLevel(parseOutcome.Model.(*prior.RecordExpr))
+}
+
 //=====================================================================================================================
 
 type formatter struct {
@@ -28,6 +37,12 @@ type formatter struct {
 	NewLineOffsets  []uint32
 	StringConstants *pools.StringPool
 	IdentifierNames *pools.NamePool
+
+	// The document expression whose trailing documentation is to be preceded by a comma.
+	commaBeforeDocumentation *prior.DocumentExpr
+
+	// The indentation of items that start lines of their own inside the records and arrays being formatted.
+	indentation string
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -51,18 +66,30 @@ func (f *formatter) formatCode(expression prior.IExpression) string {
 		return f.formatAdditionExpr(expr)
 	case *prior.ArrayLiteralExpr:
 		return f.formatSequenceLiteralExpr(expr)
+	case *prior.ArrayTypeExpr:
+		return f.formatArrayTypeExpr(expr)
 	case *prior.BooleanLiteralExpr:
 		return f.formatBooleanLiteralExpr(expr)
 	case *prior.BuiltInTypeExpr:
 		return f.formatBuiltInTypeExpr(expr)
+	case *prior.CollatedEqualsExpr:
+		return f.formatCollatedEqualsExpr(expr)
+	case *prior.CollatedNotEqualsExpr:
+		return f.formatCollatedNotEqualsExpr(expr)
 	case *prior.DivisionExpr:
 		return f.formatDivisionExpr(expr)
+	case *prior.DocumentExpr:
+		return f.formatDocumentExpr(expr)
 	case *prior.EqualsExpr:
 		return f.formatEqualsExpr(expr)
+	case *prior.ErrorExpr:
+		return expr.SourcePosition.GetText(f.SourceCode)
 	case *prior.FieldReferenceExpr:
 		return f.formatFieldReferenceExpr(expr)
 	case *prior.Float64LiteralExpr:
 		return f.formatFloatingPointLiteralExpr(expr)
+	case *prior.FormatExpr:
+		return f.formatFormatExpr(expr)
 	case *prior.FunctionArgumentsExpr:
 		return f.formatFunctionArgumentsExpr(expr)
 	case *prior.FunctionArrowExpr:
@@ -77,6 +104,8 @@ func (f *formatter) formatCode(expression prior.IExpression) string {
 		return f.formatIdentifierExpr(expr)
 	case *prior.InExpr:
 		return f.formatInExpr(expr)
+	case *prior.IndexExpr:
+		return f.formatIndexExpr(expr)
 	case *prior.IsExpr:
 		return f.formatIsExpr(expr)
 	case *prior.Int64LiteralExpr:
@@ -89,6 +118,8 @@ func (f *formatter) formatCode(expression prior.IExpression) string {
 		return f.formatIntersectDefaultValueExpr(expr)
 	case *prior.IntersectLowPrecedenceExpr:
 		return f.formatIntersectLowPrecedenceExpr(expr)
+	case *prior.LeadingDocumentationExpr:
+		return f.formatDocumentation(expr.SourcePosition.GetText(f.SourceCode))
 	case *prior.LessThanExpr:
 		return f.formatLessThanExpr(expr)
 	case *prior.LessThanOrEqualsExpr:
@@ -105,6 +136,8 @@ func (f *formatter) formatCode(expression prior.IExpression) string {
 		return f.formatMultiplicationExpr(expr)
 	case *prior.NegationOperationExpr:
 		return f.formatNegationOperationExpr(expr)
+	case *prior.NoneExpr:
+		return "none"
 	case *prior.NotEqualsExpr:
 		return f.formatNotEqualsExpr(expr)
 	case *prior.NotMatchExpr:
@@ -123,6 +156,8 @@ func (f *formatter) formatCode(expression prior.IExpression) string {
 		return f.formatStringLiteralExpr(expr)
 	case *prior.SubtractionExpr:
 		return f.formatSubtractionExpr(expr)
+	case *prior.TrailingDocumentationExpr:
+		return f.formatDocumentation(expr.SourcePosition.GetText(f.SourceCode))
 	case *prior.UnionExpr:
 		return f.formatUnionExpr(expr)
 	case *prior.UnitExpr:
@@ -149,6 +184,12 @@ func (f *formatter) formatAdditionExpr(expr *prior.AdditionExpr) string {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (f *formatter) formatArrayTypeExpr(expr *prior.ArrayTypeExpr) string {
+	return f.formatCode(expr.ElementType) + "[]"
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (f *formatter) formatBooleanLiteralExpr(expr *prior.BooleanLiteralExpr) string {
 	if expr.Value {
 		return "true"
@@ -164,6 +205,22 @@ func (f *formatter) formatBuiltInTypeExpr(expr *prior.BuiltInTypeExpr) string {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (f *formatter) formatCollatedEqualsExpr(expr *prior.CollatedEqualsExpr) string {
+	lhs := f.formatCode(expr.Lhs)
+	rhs := f.formatCode(expr.Rhs)
+	return lhs + " " + expr.OperatorPosition.GetText(f.SourceCode) + " " + rhs
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (f *formatter) formatCollatedNotEqualsExpr(expr *prior.CollatedNotEqualsExpr) string {
+	lhs := f.formatCode(expr.Lhs)
+	rhs := f.formatCode(expr.Rhs)
+	return lhs + " " + expr.OperatorPosition.GetText(f.SourceCode) + " " + rhs
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (f *formatter) formatDivisionExpr(expr *prior.DivisionExpr) string {
 	lhs := f.formatCode(expr.Lhs)
 	rhs := f.formatCode(expr.Rhs)
@@ -172,6 +229,44 @@ func (f *formatter) formatDivisionExpr(expr *prior.DivisionExpr) string {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// formatDocumentation normalizes the lines of a leading or trailing documentation comment. Documentation runs to the
+// end of its line, so the result always ends with a line feed.
+func (f *formatter) formatDocumentation(text string) string {
+
+	sb := strings.Builder{}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) > 0 {
+			sb.WriteString(line)
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (f *formatter) formatDocumentExpr(expr *prior.DocumentExpr) string {
+	lhs := f.formatCode(expr.Lhs)
+	rhs := f.formatCode(expr.Rhs)
+
+	// Leading documentation ends with a line feed; indent its further lines and the expression after it
+	if _, ok := expr.Lhs.(*prior.LeadingDocumentationExpr); ok {
+		return strings.ReplaceAll(lhs, "\n", "\n"+f.indentation) + rhs
+	}
+
+	if expr == f.commaBeforeDocumentation {
+		return lhs + ", " + rhs
+	}
+
+	return lhs + " " + rhs
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (f *formatter) formatEqualsExpr(expr *prior.EqualsExpr) string {
 	lhs := f.formatCode(expr.Lhs)
 	rhs := f.formatCode(expr.Rhs)
@@ -194,23 +289,20 @@ func (f *formatter) formatFloatingPointLiteralExpr(expr *prior.Float64LiteralExp
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (f *formatter) formatFormatExpr(expr *prior.FormatExpr) string {
+	lhs := f.formatCode(expr.Lhs)
+	rhs := f.formatCode(expr.Rhs)
+	return lhs + " fmt " + rhs
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (f *formatter) formatFunctionArgumentsExpr(expr *prior.FunctionArgumentsExpr) string {
 
 	sb := strings.Builder{}
 
 	sb.WriteString("(")
-
-	if len(expr.Items) > 0 {
-
-		sb.WriteString(f.formatCode(expr.Items[0]))
-
-		for _, item := range expr.Items[1:] {
-			sb.WriteString(", ")
-			sb.WriteString(f.formatCode(item))
-		}
-
-	}
-
+	f.formatItems(&sb, expr.Items)
 	sb.WriteString(")")
 
 	return sb.String()
@@ -265,6 +357,14 @@ func (f *formatter) formatInExpr(expr *prior.InExpr) string {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (f *formatter) formatIndexExpr(expr *prior.IndexExpr) string {
+	array := f.formatCode(expr.Array)
+	index := f.formatCode(expr.Index)
+	return array + "[" + index + "]"
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (f *formatter) formatIsExpr(expr *prior.IsExpr) string {
 	lhs := f.formatCode(expr.Lhs)
 	rhs := f.formatCode(expr.Rhs)
@@ -311,6 +411,62 @@ func (f *formatter) formatIntersectLowPrecedenceExpr(expr *prior.IntersectLowPre
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// formatItems writes a comma-separated list of items. A comma following an item with trailing documentation is
+// written ahead of the documentation so that it does not get swallowed by the comment. The documentation may be
+// attached to the item itself or to the last operand of the item, e.g. the value of a field. An item with leading
+// documentation starts a line of its own, indented one level deeper than the enclosing items, after a blank line when
+// it follows trailing documentation.
+func (f *formatter) formatItems(sb *strings.Builder, items []prior.IExpression) {
+
+	outerIndentation := f.indentation
+	f.indentation += "    "
+
+	afterLineFeed := false
+
+	for i, item := range items {
+
+		docExpr := trailingDocumentExpr(item)
+		isCommaBeforeDocumentation := docExpr != nil && i < len(items)-1
+
+		var text string
+		if isCommaBeforeDocumentation {
+			outerDocExpr := f.commaBeforeDocumentation
+			f.commaBeforeDocumentation = docExpr
+			text = f.formatCode(item)
+			f.commaBeforeDocumentation = outerDocExpr
+		} else {
+			text = f.formatCode(item)
+		}
+
+		// A blank line after trailing documentation keeps the two comments apart
+		if strings.HasPrefix(text, "//") {
+			sb.WriteString("\n")
+			sb.WriteString(f.indentation)
+		} else if i > 0 && !afterLineFeed {
+			sb.WriteString(" ")
+		}
+
+		sb.WriteString(text)
+
+		if isCommaBeforeDocumentation {
+			afterLineFeed = true
+			continue
+		}
+
+		if i < len(items)-1 {
+			sb.WriteString(",")
+		}
+
+		afterLineFeed = false
+
+	}
+
+	f.indentation = outerIndentation
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (f *formatter) formatLessThanExpr(expr *prior.LessThanExpr) string {
 	lhs := f.formatCode(expr.Lhs)
 	rhs := f.formatCode(expr.Rhs)
@@ -430,18 +586,7 @@ func (f *formatter) formatRecordExpr(expr *prior.RecordExpr) string {
 	sb := strings.Builder{}
 
 	sb.WriteString("{")
-
-	if len(expr.Items) > 0 {
-
-		sb.WriteString(f.formatCode(expr.Items[0]))
-
-		for _, item := range expr.Items[1:] {
-			sb.WriteString(", ")
-			sb.WriteString(f.formatCode(item))
-		}
-
-	}
-
+	f.formatItems(&sb, expr.Items)
 	sb.WriteString("}")
 
 	return sb.String()
@@ -455,18 +600,7 @@ func (f *formatter) formatSequenceLiteralExpr(expr *prior.ArrayLiteralExpr) stri
 	sb := strings.Builder{}
 
 	sb.WriteString("[")
-
-	if len(expr.Elements) > 0 {
-
-		sb.WriteString(f.formatCode(expr.Elements[0]))
-
-		for _, item := range expr.Elements[1:] {
-			sb.WriteString(", ")
-			sb.WriteString(f.formatCode(item))
-		}
-
-	}
-
+	f.formatItems(&sb, expr.Elements)
 	sb.WriteString("]")
 
 	return sb.String()
@@ -476,7 +610,28 @@ func (f *formatter) formatSequenceLiteralExpr(expr *prior.ArrayLiteralExpr) stri
 //---------------------------------------------------------------------------------------------------------------------
 
 func (f *formatter) formatStringLiteralExpr(expr *prior.StringLiteralExpr) string {
-	return expr.SourcePosition.GetText(f.SourceCode)
+	text := expr.SourcePosition.GetText(f.SourceCode)
+
+	var delimiter rune
+	switch expr.Delimiters {
+	case prior.StringDelimitersDoubleQuotes:
+		delimiter = '"'
+	case prior.StringDelimitersSingleQuotes:
+		delimiter = '\''
+	case prior.StringDelimitersBackTicksMultiline:
+		// Keep the margins and the final line break but not the indentation of whatever comes next.
+		return strings.TrimRight(text, " \t")
+	default:
+		return text
+	}
+
+	// Rewrite the escape sequences canonically, leaving any invalid ones untouched.
+	value, escapeErrors := scanning.DecodeStringLiteral(text)
+	if len(escapeErrors) > 0 {
+		return text
+	}
+
+	return scanning.EncodeStringLiteral(value, delimiter)
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -489,6 +644,26 @@ func (f *formatter) formatSubtractionExpr(expr *prior.SubtractionExpr) string {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (f *formatter) formatTopLevel(expr *prior.RecordExpr) string {
+
+	sb := strings.Builder{}
+
+	for _, item := range expr.Items {
+		text := f.formatCode(item)
+		sb.WriteString(text)
+
+		// Items with trailing documentation already end with a line feed.
+		if !strings.HasSuffix(text, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (f *formatter) formatUnionExpr(expr *prior.UnionExpr) string {
 	lhs := f.formatCode(expr.Lhs)
 	rhs := f.formatCode(expr.Rhs)
@@ -518,3 +693,77 @@ func (f *formatter) formatWhereExpr(expr *prior.WhereExpr) string {
 }
 
 //---------------------------------------------------------------------------------------------------------------------
+
+// trailingDocumentExpr finds the document expression with trailing documentation that ends the given expression, if
+// any, by descending through the right hand operands of binary operations.
+func trailingDocumentExpr(expression prior.IExpression) *prior.DocumentExpr {
+
+	switch expr := expression.(type) {
+
+	case *prior.DocumentExpr:
+		if _, ok := expr.Rhs.(*prior.TrailingDocumentationExpr); ok {
+			return expr
+		}
+		return nil
+
+	case *prior.AdditionExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.DivisionExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.EqualsExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.FormatExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.FunctionArrowExpr:
+		return trailingDocumentExpr(expr.Result)
+	case *prior.GreaterThanExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.GreaterThanOrEqualsExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.InExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.IntersectExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.IntersectAssignValueExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.IntersectDefaultValueExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.IntersectLowPrecedenceExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.IsExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.LessThanExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.LessThanOrEqualsExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.LogicalAndExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.LogicalOrExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.MatchExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.MultiplicationExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.NotEqualsExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.NotMatchExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.QualifyExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.SubtractionExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.UnionExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.WhenExpr:
+		return trailingDocumentExpr(expr.Rhs)
+	case *prior.WhereExpr:
+		return trailingDocumentExpr(expr.Rhs)
+
+	default:
+		return nil
+
+	}
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
@@ -29,6 +29,43 @@ func TestLligneFormatter(t *testing.T) {
 		assert.Equal(t, sourceCode, FormatCode(parseOutcome))
 	}
 
+	checkFormatted := func(sourceCode string, expectedCode string) {
+		scanOutcome := scanning.Scan(sourceCode)
+
+		scanOutcome = tokenfilters.ProcessLeadingTrailingDocumentation(scanOutcome)
+
+		parseOutcome := parsing.ParseExpression(scanOutcome)
+
+		assert.Equal(t, expectedCode, FormatCode(parseOutcome))
+	}
+
+	// checkRoundTrip formats code and then checks that the formatted code parses and formats to itself.
+	checkRoundTrip := func(sourceCode string, expectedCode string) {
+		formattedCode := sourceCode
+		for i := 0; i < 2; i++ {
+			scanOutcome := scanning.Scan(formattedCode)
+
+			scanOutcome = tokenfilters.ProcessLeadingTrailingDocumentation(scanOutcome)
+
+			parseOutcome := parsing.ParseExpression(scanOutcome)
+
+			assert.Empty(t, parseOutcome.Diagnostics, "For source code: "+formattedCode)
+			formattedCode = FormatCode(parseOutcome)
+			assert.Equal(t, expectedCode, formattedCode)
+		}
+	}
+
+	checkTopLevel := func(sourceCode string, expectedCode string) {
+		scanOutcome := scanning.Scan(sourceCode)
+
+		scanOutcome = tokenfilters.ProcessLeadingTrailingDocumentation(scanOutcome)
+
+		parseOutcome := parsing.ParseTopLevel(scanOutcome)
+
+		assert.Empty(t, parseOutcome.Diagnostics, "For source code: "+sourceCode)
+		assert.Equal(t, expectedCode, FormatTopLevel(parseOutcome))
+	}
+
 	t.Run("identifier literals", func(t *testing.T) {
 		check("abc")
 		check("d")
@@ -48,6 +85,8 @@ func TestLligneFormatter(t *testing.T) {
 
 	t.Run("multiline string literals", func(t *testing.T) {
 		check("` line one\n ` line two\n")
+		check("{sql = `SELECT *\n         `  FROM t\n, n = 1}")
+		checkFormatted("`abc\n    `def\n    + x", "`abc\n    `def\n + x")
 	})
 
 	t.Run("string literals", func(t *testing.T) {
@@ -55,6 +94,33 @@ func TestLligneFormatter(t *testing.T) {
 		check(`'789'`)
 	})
 
+	t.Run("string escape sequences", func(t *testing.T) {
+		check(`"a\tb\nc\\d\"e'f"`)
+		check(`'a\'b"c'`)
+		checkFormatted(`"\u{41}\'"`, `"A'"`)
+		checkFormatted(`'\u{7}\"'`, `'\u{7}"'`)
+		checkFormatted(`"\q"`, `"\q"`)
+	})
+
+	t.Run("documentation", func(t *testing.T) {
+		check("// line one\n// line two\nq")
+		check("q // line one\n// line two\n")
+		check("{a, // about a\nb}")
+		check("{a = 1, b = 2 // about b\n}")
+		check("{user = \"u\", // user\npw = \"p\"}")
+		checkFormatted("{user = \"u\", // user\n pw = \"p\"}", "{user = \"u\", // user\npw = \"p\"}")
+		check("{x: Int64 = 1 + 2, // about x\ny: {a = 1, // about a\nb = 2}, // about y\nz}")
+	})
+
+	t.Run("leading documentation of items", func(t *testing.T) {
+		checkRoundTrip("[\n  /// first\n  1,\n  2\n]", "[\n    /// first\n    1, 2]")
+		checkRoundTrip("[1,\n  /// second\n  2]", "[1,\n    /// second\n    2]")
+		checkRoundTrip("{\n /// doc for x\n x = 1, y = 2}", "{\n    /// doc for x\n    x = 1, y = 2}")
+		checkRoundTrip("{ /// leading doc\nx = 1}", "{\n    /// leading doc\n    x = 1}")
+		checkRoundTrip("{a = 1, // about a\n\n// about b\n// more\nb = {\n// about c\nc = 2}}",
+			"{a = 1, // about a\n\n    // about b\n    // more\n    b = {\n        // about c\n        c = 2}}")
+	})
+
 	//t.Run("leading documentation", func(t *testing.T) {
 	//	check("// line one\n // line two\nq", "(doc (leadingdoc\n// line one\n // line two\n) (id q))")
 	//})
@@ -77,6 +143,14 @@ func TestLligneFormatter(t *testing.T) {
 		check("name: String")
 	})
 
+	t.Run("top level", func(t *testing.T) {
+		checkTopLevel("", "")
+		checkTopLevel("x = 1", "x = 1\n")
+		checkTopLevel("x=1, y:Int64=2;z='three'", "x = 1\ny: Int64 = 2\nz = 'three'\n")
+		checkTopLevel("\n  x = 1\n\n  y = {a=1,\n b=2}\n", "x = 1\ny = {a = 1, b = 2}\n")
+		checkTopLevel("// about x\nx = 1\ny = 2 // about y\n", "// about x\nx = 1\ny = 2 // about y\n")
+	})
+
 	t.Run("table of expressions", func(t *testing.T) {
 		tests := []string{
 			"x + 1",
@@ -106,6 +180,10 @@ func TestLligneFormatter(t *testing.T) {
 
 			"[]",
 			"[1, 2, 3, 4, 5]",
+			"a[1]",
+			"a.b[i + 1]",
+			"int[]",
+			"{x: int?[][] = []}",
 
 			"true and false",
 			"a and b",
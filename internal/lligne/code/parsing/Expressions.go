@@ -54,6 +54,17 @@ func (e *ArrayLiteralExpr) isExpression()                     {}
 
 //=====================================================================================================================
 
+// ArrayTypeExpr represents an array type using "[]" suffix.
+type ArrayTypeExpr struct {
+	SourcePosition util.SourcePos
+	ElementType    IExpression
+}
+
+func (e *ArrayTypeExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *ArrayTypeExpr) isExpression()                     {}
+
+//=====================================================================================================================
+
 // BooleanLiteralExpr represents a single boolean literal.
 type BooleanLiteralExpr struct {
 	SourcePosition util.SourcePos
@@ -75,6 +86,32 @@ func (e *BuiltInTypeExpr) isExpression()                     {}
 
 //=====================================================================================================================
 
+// CollatedEqualsExpr represents an equality comparison with modifiers ("==[Aa]==") operation.
+type CollatedEqualsExpr struct {
+	SourcePosition   util.SourcePos
+	Lhs              IExpression
+	Rhs              IExpression
+	OperatorPosition util.SourcePos
+}
+
+func (e *CollatedEqualsExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *CollatedEqualsExpr) isExpression()                     {}
+
+//=====================================================================================================================
+
+// CollatedNotEqualsExpr represents an inequality comparison with modifiers ("=![Aa]!=") operation.
+type CollatedNotEqualsExpr struct {
+	SourcePosition   util.SourcePos
+	Lhs              IExpression
+	Rhs              IExpression
+	OperatorPosition util.SourcePos
+}
+
+func (e *CollatedNotEqualsExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *CollatedNotEqualsExpr) isExpression()                     {}
+
+//=====================================================================================================================
+
 // DivisionExpr represents a division ("/") operation.
 type DivisionExpr struct {
 	SourcePosition util.SourcePos
@@ -111,6 +148,16 @@ func (e *EqualsExpr) isExpression()                     {}
 
 //=====================================================================================================================
 
+// ErrorExpr represents a stretch of source code that could not be parsed because of a syntax error.
+type ErrorExpr struct {
+	SourcePosition util.SourcePos
+}
+
+func (e *ErrorExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *ErrorExpr) isExpression()                     {}
+
+//=====================================================================================================================
+
 // FieldReferenceExpr represents a field reference (".") operation.
 type FieldReferenceExpr struct {
 	SourcePosition util.SourcePos
@@ -134,6 +181,18 @@ func (e *Float64LiteralExpr) isExpression()                     {}
 
 //=====================================================================================================================
 
+// FormatExpr represents a value formatting ("fmt") operation.
+type FormatExpr struct {
+	SourcePosition util.SourcePos
+	Lhs            IExpression
+	Rhs            IExpression
+}
+
+func (e *FormatExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *FormatExpr) isExpression()                     {}
+
+//=====================================================================================================================
+
 // FunctionArgumentsExpr represents a parenthesized, comma-separated sequence of expressions postfix to
 // a function reference.
 type FunctionArgumentsExpr struct {
@@ -216,6 +275,18 @@ func (e *InExpr) isExpression()                     {}
 
 //=====================================================================================================================
 
+// IndexExpr represents an array indexing ("a[i]") operation.
+type IndexExpr struct {
+	SourcePosition util.SourcePos
+	Array          IExpression
+	Index          IExpression
+}
+
+func (e *IndexExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *IndexExpr) isExpression()                     {}
+
+//=====================================================================================================================
+
 // Int64LiteralExpr represents a single integer literal.
 type Int64LiteralExpr struct {
 	SourcePosition util.SourcePos
@@ -391,6 +462,16 @@ func (e *NegationOperationExpr) isExpression()                     {}
 
 //=====================================================================================================================
 
+// NoneExpr represents the absent value of an optional type ("none").
+type NoneExpr struct {
+	SourcePosition util.SourcePos
+}
+
+func (e *NoneExpr) GetSourcePosition() util.SourcePos { return e.SourcePosition }
+func (e *NoneExpr) isExpression()                     {}
+
+//=====================================================================================================================
+
 // NotEqualsExpr represents an equality comparison ("==") operation.
 type NotEqualsExpr struct {
 	SourcePosition util.SourcePos
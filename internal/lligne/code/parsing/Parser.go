@@ -7,9 +7,11 @@ package parsing
 
 import (
 	"fmt"
+	"lligne-cli/internal/lligne/code/diagnostics"
 	"lligne-cli/internal/lligne/code/scanning"
 	"lligne-cli/internal/lligne/code/util"
 	"strconv"
+	"strings"
 )
 
 //=====================================================================================================================
@@ -18,39 +20,51 @@ type Outcome struct {
 	SourceCode     string
 	NewLineOffsets []uint32
 	Model          IExpression
+	Diagnostics    []*diagnostics.Diagnostic
 }
 
 //=====================================================================================================================
 
+// ParseExpression parses the given tokens as one expression. Every syntax error found is reported as a diagnostic, with
+// ErrorExpr nodes standing in for the unparseable code in the model.
 func ParseExpression(scanResult *scanning.Outcome) *Outcome {
 	parser := newParser(scanResult)
 
-	model := parser.parseExprBindingPower(0)
+	model := parser.parseCompleteExpression()
 
 	return &Outcome{
 		SourceCode:     scanResult.SourceCode,
 		NewLineOffsets: scanResult.NewLineOffsets,
 		Model:          model,
+		Diagnostics:    parser.diagnostics,
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
-// TODO: ParseTopLevel
-// ParseParenthesizedItems parses a non-empty sequence of code expected to be the items within a record literal, e.g.
-// the top level of a file.
-//func ParseParenthesizedItems(sourceCode string, tokens []scanning.Token) IExpression {
-//	parser := newParser(sourceCode, tokens)
-//
-//	return parser.parseParenthesizedExpression(tokens[0], scanning.TokenTypeEof)
-//}
+// ParseTopLevel parses the given tokens as the items of a file, e.g. "name: Type = value" or "name = value", separated
+// by commas, semicolons, or line breaks. The model is a RecordExpr enclosing the items. As with ParseExpression,
+// syntax errors are reported as diagnostics.
+func ParseTopLevel(scanResult *scanning.Outcome) *Outcome {
+	parser := newParser(scanResult)
+
+	model := parser.parseTopLevel()
+
+	return &Outcome{
+		SourceCode:     scanResult.SourceCode,
+		NewLineOffsets: scanResult.NewLineOffsets,
+		Model:          model,
+		Diagnostics:    parser.diagnostics,
+	}
+}
 
 //=====================================================================================================================
 
 type lligneParser struct {
-	tokens     []scanning.Token
-	index      int
-	sourceCode string
+	tokens      []scanning.Token
+	index       int
+	sourceCode  string
+	diagnostics []*diagnostics.Diagnostic
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -64,6 +78,98 @@ func newParser(scanResult *scanning.Outcome) *lligneParser {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// describeToken returns a description of a token suitable for use in a diagnostic message.
+func (p *lligneParser) describeToken(token scanning.Token) string {
+	if token.TokenType == scanning.TokenTypeEof {
+		return "end of file"
+	}
+	return "'" + util.NewSourcePos(token).GetText(p.sourceCode) + "'"
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// expect consumes the next token, which should have the given type, and returns its source position. Otherwise
+// expect reports a syntax error and skips ahead to the next synchronizing token, consuming that token if it turns out
+// to be the expected one.
+func (p *lligneParser) expect(tokenType scanning.TokenType) util.SourcePos {
+	token := p.tokens[p.index]
+
+	if token.TokenType != tokenType {
+		p.reportError(diagnostics.CodeExpectedToken, token, "Expected '%s' but found %s", tokenType, p.describeToken(token))
+
+		if isSynchronizingToken(token.TokenType) {
+			return util.NewSourcePos(token)
+		}
+
+		sourcePos := p.synchronize(util.NewSourcePos(token), false)
+
+		if p.tokens[p.index].TokenType != tokenType {
+			return sourcePos
+		}
+
+		token = p.tokens[p.index]
+	}
+
+	p.index += 1
+
+	return util.NewSourcePos(token)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// isLineBreakBefore determines whether the token at the current position starts a new line.
+func (p *lligneParser) isLineBreakBefore() bool {
+	priorToken := p.tokens[p.index-1]
+
+	// Documentation and back-ticked strings extend through the end of their lines.
+	if priorToken.TokenType == scanning.TokenTypeTrailingDocumentation ||
+		priorToken.TokenType == scanning.TokenTypeBackTickedString {
+		return true
+	}
+
+	priorEndOffset := priorToken.SourceOffset + uint32(priorToken.SourceLength)
+	return strings.Contains(p.sourceCode[priorEndOffset:p.tokens[p.index].SourceOffset], "\n")
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// parseCompleteExpression parses an expression that must extend to the end of the input.
+func (p *lligneParser) parseCompleteExpression() IExpression {
+
+	expression := p.parseExprBindingPower(0)
+
+	if p.tokens[p.index].TokenType != scanning.TokenTypeEof {
+		p.reportUnexpectedToken(p.tokens[p.index])
+	}
+
+	return expression
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// parseErrorExpression recovers from a token that cannot start an expression. The token and whatever follows it up to
+// the next synchronizing token are replaced by an ErrorExpr.
+func (p *lligneParser) parseErrorExpression(token scanning.Token) IExpression {
+
+	p.reportUnexpectedToken(token)
+
+	// Leave a synchronizing token in place for the enclosing expression to deal with.
+	if isSynchronizingToken(token.TokenType) {
+		p.index -= 1
+		return &ErrorExpr{
+			SourcePosition: util.NewSourcePos(token),
+		}
+	}
+
+	return &ErrorExpr{
+		SourcePosition: p.synchronize(util.NewSourcePos(token), false),
+	}
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (p *lligneParser) parseExprBindingPower(minBindingPower int) IExpression {
 
 	lhs := p.parseLeftHandSide()
@@ -120,23 +226,9 @@ func (p *lligneParser) parseFunctionArgumentsExpression(
 	token scanning.Token,
 ) IExpression {
 
-	var items []IExpression
-
-	for p.tokens[p.index].TokenType != scanning.TokenTypeRightParenthesis {
-		// Parse one expression.
-		items = append(items, p.parseExprBindingPower(0))
+	items := p.parseItems(scanning.TokenTypeRightParenthesis)
 
-		if p.tokens[p.index].TokenType != scanning.TokenTypeComma {
-			break
-		}
-		p.index += 1
-	}
-
-	if p.tokens[p.index].TokenType != scanning.TokenTypeRightParenthesis {
-		panic("Expected " + scanning.TokenTypeRightParenthesis.String())
-	}
-	endSourcePos := util.NewSourcePos(p.tokens[p.index])
-	p.index += 1
+	endSourcePos := p.expect(scanning.TokenTypeRightParenthesis)
 
 	return &FunctionArgumentsExpr{
 		SourcePosition: util.NewSourcePos(token).Thru(endSourcePos),
@@ -185,6 +277,22 @@ func (p *lligneParser) parseInfixOperation(
 			Rhs:            rhs,
 		}
 
+	case scanning.TokenTypeCollatedEquals:
+		return &CollatedEqualsExpr{
+			SourcePosition:   lhs.GetSourcePosition().Thru(rhs.GetSourcePosition()),
+			Lhs:              lhs,
+			Rhs:              rhs,
+			OperatorPosition: util.NewSourcePos(opToken),
+		}
+
+	case scanning.TokenTypeCollatedNotEquals:
+		return &CollatedNotEqualsExpr{
+			SourcePosition:   lhs.GetSourcePosition().Thru(rhs.GetSourcePosition()),
+			Lhs:              lhs,
+			Rhs:              rhs,
+			OperatorPosition: util.NewSourcePos(opToken),
+		}
+
 	case scanning.TokenTypeColon:
 		return &QualifyExpr{
 			SourcePosition: lhs.GetSourcePosition().Thru(rhs.GetSourcePosition()),
@@ -248,6 +356,13 @@ func (p *lligneParser) parseInfixOperation(
 			Rhs:            rhs,
 		}
 
+	case scanning.TokenTypeFmt:
+		return &FormatExpr{
+			SourcePosition: lhs.GetSourcePosition().Thru(rhs.GetSourcePosition()),
+			Lhs:            lhs,
+			Rhs:            rhs,
+		}
+
 	case scanning.TokenTypeGreaterThan:
 		return &GreaterThanExpr{
 			SourcePosition: lhs.GetSourcePosition().Thru(rhs.GetSourcePosition()),
@@ -362,6 +477,53 @@ func (p *lligneParser) parseInfixOperation(
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// parseItems parses comma-separated items up to, but not including, the given closing token. When an item is followed
+// by something else, the error is reported, the extra tokens become an ErrorExpr, and parsing carries on with the next
+// item if a comma follows.
+func (p *lligneParser) parseItems(closingTokenType scanning.TokenType) []IExpression {
+
+	var items []IExpression
+
+	for p.tokens[p.index].TokenType != closingTokenType {
+		// Parse one expression.
+		items = append(items, p.parseExprBindingPower(0))
+
+		token := p.tokens[p.index]
+
+		// Leave a missing closing token for the caller to report.
+		if token.TokenType == closingTokenType || token.TokenType == scanning.TokenTypeEof {
+			break
+		}
+
+		if token.TokenType != scanning.TokenTypeComma {
+			p.reportError(
+				diagnostics.CodeExpectedToken,
+				token,
+				"Expected ',' or '%s' but found %s",
+				closingTokenType,
+				p.describeToken(token),
+			)
+
+			if !isSynchronizingToken(token.TokenType) {
+				items = append(items, &ErrorExpr{
+					SourcePosition: p.synchronize(util.NewSourcePos(token), false),
+				})
+			}
+
+			if p.tokens[p.index].TokenType != scanning.TokenTypeComma {
+				break
+			}
+		}
+
+		p.index += 1
+	}
+
+	return items
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (p *lligneParser) parseLeftHandSide() IExpression {
 
 	token := p.tokens[p.index]
@@ -432,6 +594,11 @@ func (p *lligneParser) parseLeftHandSide() IExpression {
 	case scanning.TokenTypeLeftParenthesis:
 		return p.parseParenthesizedExpression(token)
 
+	case scanning.TokenTypeNone:
+		return &NoneExpr{
+			SourcePosition: util.NewSourcePos(token),
+		}
+
 	case scanning.TokenTypeNot:
 		return p.parseLogicalNotOperationExpression(token)
 
@@ -463,7 +630,7 @@ func (p *lligneParser) parseLeftHandSide() IExpression {
 
 	}
 
-	panic("Unfinished parsing code: '" + strconv.Itoa(int(token.TokenType)) + "'.")
+	return p.parseErrorExpression(token)
 
 }
 
@@ -517,24 +684,9 @@ func (p *lligneParser) parseParenthesizedExpression(
 
 		p.index += 1
 
-		var items []IExpression
-		items = append(items, inner)
+		items := append([]IExpression{inner}, p.parseItems(scanning.TokenTypeRightParenthesis)...)
 
-		for p.tokens[p.index].TokenType != scanning.TokenTypeRightParenthesis {
-			// Parse one expression.
-			items = append(items, p.parseExprBindingPower(0))
-
-			if p.tokens[p.index].TokenType != scanning.TokenTypeComma {
-				break
-			}
-			p.index += 1
-		}
-
-		if p.tokens[p.index].TokenType != scanning.TokenTypeRightParenthesis {
-			panic("Expected " + scanning.TokenTypeRightParenthesis.String())
-		}
-		endSourcePos := util.NewSourcePos(p.tokens[p.index])
-		p.index += 1
+		endSourcePos := p.expect(scanning.TokenTypeRightParenthesis)
 
 		return &FunctionArgumentsExpr{
 			SourcePosition: util.NewSourcePos(token).Thru(endSourcePos),
@@ -543,12 +695,7 @@ func (p *lligneParser) parseParenthesizedExpression(
 
 	}
 
-	if p.tokens[p.index].TokenType != scanning.TokenTypeRightParenthesis {
-		panic("Expected " + scanning.TokenTypeRightParenthesis.String())
-	}
-
-	endSourcePos := util.NewSourcePos(p.tokens[p.index])
-	p.index += 1
+	endSourcePos := p.expect(scanning.TokenTypeRightParenthesis)
 
 	return &ParenthesizedExpr{
 		SourcePosition: util.NewSourcePos(token).Thru(endSourcePos),
@@ -571,15 +718,34 @@ func (p *lligneParser) parsePostfixExpression(opToken scanning.Token, lhs IExpre
 			Argument:          args,
 		}
 
+	case scanning.TokenTypeLeftBracket:
+		// Empty brackets make an array type rather than index an array.
+		if p.tokens[p.index].TokenType == scanning.TokenTypeRightBracket {
+			endSourcePos := util.NewSourcePos(p.tokens[p.index])
+			p.index += 1
+			return &ArrayTypeExpr{
+				SourcePosition: lhs.GetSourcePosition().Thru(endSourcePos),
+				ElementType:    lhs,
+			}
+		}
+
+		index := p.parseExprBindingPower(0)
+		endSourcePos := p.expect(scanning.TokenTypeRightBracket)
+		return &IndexExpr{
+			SourcePosition: lhs.GetSourcePosition().Thru(endSourcePos),
+			Array:          lhs,
+			Index:          index,
+		}
+
 	case scanning.TokenTypeQuestion:
 		return &OptionalExpr{
-			SourcePosition: lhs.GetSourcePosition(),
+			SourcePosition: lhs.GetSourcePosition().Thru(util.NewSourcePos(opToken)),
 			Operand:        lhs,
 		}
 
 	}
 
-	panic("Unfinished postfix parsing code: '" + strconv.Itoa(int(opToken.TokenType)) + "'.")
+	panic("Missing case in parsePostfixExpression: '" + opToken.TokenType.String() + "'.")
 
 }
 
@@ -589,23 +755,9 @@ func (p *lligneParser) parseRecordExpression(
 	token scanning.Token,
 ) IExpression {
 
-	var items []IExpression
-
-	for p.tokens[p.index].TokenType != scanning.TokenTypeRightBrace {
-		// Parse one expression.
-		items = append(items, p.parseExprBindingPower(0))
-
-		if p.tokens[p.index].TokenType != scanning.TokenTypeComma {
-			break
-		}
-		p.index += 1
-	}
+	items := p.parseItems(scanning.TokenTypeRightBrace)
 
-	if p.tokens[p.index].TokenType != scanning.TokenTypeRightBrace {
-		panic("Expected " + scanning.TokenTypeRightBrace.String())
-	}
-	endSourcePos := util.NewSourcePos(p.tokens[p.index])
-	p.index += 1
+	endSourcePos := p.expect(scanning.TokenTypeRightBrace)
 
 	return &RecordExpr{
 		SourcePosition: util.NewSourcePos(token).Thru(endSourcePos),
@@ -630,31 +782,156 @@ func (p *lligneParser) parseSequenceLiteral(token scanning.Token) IExpression {
 		}
 	}
 
-	for p.tokens[p.index].TokenType != scanning.TokenTypeRightBracket {
-		// Parse one expression.
+	items = p.parseItems(scanning.TokenTypeRightBracket)
+
+	endSourcePos := p.expect(scanning.TokenTypeRightBracket)
+
+	return &ArrayLiteralExpr{
+		SourcePosition: startSourcePos.Thru(endSourcePos),
+		Elements:       items,
+	}
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// parseTopLevel parses the items of a file up to the end of the input.
+func (p *lligneParser) parseTopLevel() IExpression {
+
+	startSourcePos := util.NewSourcePos(p.tokens[0])
+	var items []IExpression
+
+	for p.tokens[p.index].TokenType != scanning.TokenTypeEof {
+		// Parse one item.
 		items = append(items, p.parseExprBindingPower(0))
 
-		if p.tokens[p.index].TokenType != scanning.TokenTypeComma {
-			break
+		token := p.tokens[p.index]
+
+		if !isSynchronizingToken(token.TokenType) && !p.isLineBreakBefore() {
+			p.reportError(
+				diagnostics.CodeExpectedToken,
+				token,
+				"Expected ',', ';', or a line break but found %s",
+				p.describeToken(token),
+			)
+			items = append(items, &ErrorExpr{
+				SourcePosition: p.synchronize(util.NewSourcePos(token), true),
+			})
+			token = p.tokens[p.index]
+		}
+
+		switch token.TokenType {
+
+		case scanning.TokenTypeComma, scanning.TokenTypeSemicolon:
+			p.index += 1
+
+		case scanning.TokenTypeRightBrace, scanning.TokenTypeRightBracket, scanning.TokenTypeRightParenthesis:
+			// Nothing encloses the top level, so skip over an unmatched closing bracket.
+			p.reportUnexpectedToken(token)
+			p.index += 1
+
 		}
-		p.index += 1
 	}
 
-	if p.tokens[p.index].TokenType != scanning.TokenTypeRightBracket {
-		panic("Expected " + scanning.TokenTypeRightBracket.String())
+	return &RecordExpr{
+		SourcePosition: startSourcePos.Thru(util.NewSourcePos(p.tokens[p.index])),
+		Items:          items,
 	}
-	endSourcePos := util.NewSourcePos(p.tokens[p.index])
-	p.index += 1
 
-	return &ArrayLiteralExpr{
-		SourcePosition: startSourcePos.Thru(endSourcePos),
-		Elements:       items,
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// reportError records a syntax error at the given token. Only the first error at any one token is kept, since later
+// ones are knock-on effects of the same mistake.
+func (p *lligneParser) reportError(code diagnostics.Code, token scanning.Token, format string, args ...any) {
+
+	sourcePos := util.NewSourcePos(token)
+
+	count := len(p.diagnostics)
+	if count > 0 && p.diagnostics[count-1].SourcePosition.GetStartOffset() == sourcePos.GetStartOffset() {
+		return
+	}
+
+	p.diagnostics = append(p.diagnostics, diagnostics.NewError(code, sourcePos, format, args...))
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// reportUnexpectedToken records a syntax error for a token that cannot start or continue an expression.
+func (p *lligneParser) reportUnexpectedToken(token scanning.Token) {
+
+	switch token.TokenType {
+
+	case scanning.TokenTypeUnclosedDoubleQuotedString, scanning.TokenTypeUnclosedSingleQuotedString:
+		p.reportError(diagnostics.CodeUnclosedString, token, "Unclosed string literal")
+	case scanning.TokenTypeUnrecognizedChar:
+		p.reportError(diagnostics.CodeUnrecognizedCharacter, token, "Unrecognized character %s", p.describeToken(token))
+	default:
+		p.reportError(diagnostics.CodeUnexpectedToken, token, "Unexpected %s", p.describeToken(token))
+
+	}
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// synchronize skips ahead to the next comma, semicolon, or closing bracket not nested within brackets opened along
+// the way, or else to the end of the input. Optionally an unnested line break also ends the skipping. Returns the
+// given source position extended through the skipped tokens.
+func (p *lligneParser) synchronize(sourcePos util.SourcePos, stopAtLineBreak bool) util.SourcePos {
+
+	depth := 0
+
+	for {
+		token := p.tokens[p.index]
+
+		if stopAtLineBreak && depth == 0 && p.isLineBreakBefore() {
+			return sourcePos
+		}
+
+		switch token.TokenType {
+
+		case scanning.TokenTypeEof:
+			return sourcePos
+
+		case scanning.TokenTypeComma, scanning.TokenTypeSemicolon:
+			if depth == 0 {
+				return sourcePos
+			}
+
+		case scanning.TokenTypeLeftBrace, scanning.TokenTypeLeftBracket, scanning.TokenTypeLeftParenthesis:
+			depth += 1
+
+		case scanning.TokenTypeRightBrace, scanning.TokenTypeRightBracket, scanning.TokenTypeRightParenthesis:
+			if depth == 0 {
+				return sourcePos
+			}
+			depth -= 1
+
+		}
+
+		sourcePos = sourcePos.Thru(util.NewSourcePos(token))
+		p.index += 1
 	}
 
 }
 
 //=====================================================================================================================
 
+// isSynchronizingToken determines whether a token is one where parsing can resume after a syntax error.
+func isSynchronizingToken(tokenType scanning.TokenType) bool {
+	switch tokenType {
+	case scanning.TokenTypeComma, scanning.TokenTypeEof, scanning.TokenTypeRightBrace,
+		scanning.TokenTypeRightBracket, scanning.TokenTypeRightParenthesis, scanning.TokenTypeSemicolon:
+		return true
+	}
+	return false
+}
+
+//=====================================================================================================================
+
 type infixBindingPower struct {
 	Left  int
 	Right int
@@ -723,6 +1000,8 @@ func init() {
 
 	level += 2
 
+	infixBindingPowers[scanning.TokenTypeCollatedEquals] = infixBindingPower{level, level + 1}
+	infixBindingPowers[scanning.TokenTypeCollatedNotEquals] = infixBindingPower{level, level + 1}
 	infixBindingPowers[scanning.TokenTypeEqualsEquals] = infixBindingPower{level, level + 1}
 	infixBindingPowers[scanning.TokenTypeExclamationEquals] = infixBindingPower{level, level + 1}
 	infixBindingPowers[scanning.TokenTypeGreaterThan] = infixBindingPower{level, level + 1}
@@ -732,6 +1011,7 @@ func init() {
 
 	level += 2
 
+	infixBindingPowers[scanning.TokenTypeFmt] = infixBindingPower{level, level + 1}
 	infixBindingPowers[scanning.TokenTypeIn] = infixBindingPower{level, level + 1}
 	infixBindingPowers[scanning.TokenTypeIs] = infixBindingPower{level, level + 1}
 	infixBindingPowers[scanning.TokenTypeEqualsTilde] = infixBindingPower{level, level + 1}
@@ -761,12 +1041,13 @@ func init() {
 
 	level += 2
 
+	// Indexing binds like a field reference so that "a.b[i]" indexes "a.b"
 	infixBindingPowers[scanning.TokenTypeDot] = infixBindingPower{level, level + 1}
+	postfixBindingPowers[scanning.TokenTypeLeftBracket] = postfixBindingPower{level}
 
 	level += 2
 
 	postfixBindingPowers[scanning.TokenTypeLeftParenthesis] = postfixBindingPower{level}
-	postfixBindingPowers[scanning.TokenTypeLeftBracket] = postfixBindingPower{level}
 	postfixBindingPowers[scanning.TokenTypeQuestion] = postfixBindingPower{level}
 
 }
@@ -9,8 +9,10 @@ package parsing
 
 import (
 	"github.com/stretchr/testify/assert"
+	"lligne-cli/internal/lligne/code/diagnostics"
 	"lligne-cli/internal/lligne/code/scanning"
 	"lligne-cli/internal/lligne/code/scanning/tokenfilters"
+	"strings"
 	"testing"
 )
 
@@ -26,6 +28,55 @@ func TestLligneParser(t *testing.T) {
 		expression := ParseExpression(scanResult)
 
 		assert.NotEqual(t, nil, expression)
+		assert.Empty(t, expression.Diagnostics, "For source code: "+sourceCode)
+	}
+
+	checkError := func(sourceCode string, expectedCode diagnostics.Code, expectedMessage string) {
+		scanResult := scanning.Scan(sourceCode)
+
+		scanResult = tokenfilters.ProcessLeadingTrailingDocumentation(scanResult)
+
+		outcome := ParseExpression(scanResult)
+
+		assert.NotNil(t, outcome.Model)
+		if assert.Len(t, outcome.Diagnostics, 1, "For source code: "+sourceCode) {
+			assert.Equal(t, expectedCode, outcome.Diagnostics[0].Code, "For source code: "+sourceCode)
+			assert.Equal(t, expectedMessage, outcome.Diagnostics[0].Message, "For source code: "+sourceCode)
+		}
+	}
+
+	checkErrors := func(sourceCode string, expectedModel string, expectedMessages ...string) {
+		scanResult := scanning.Scan(sourceCode)
+
+		scanResult = tokenfilters.ProcessLeadingTrailingDocumentation(scanResult)
+
+		outcome := ParseExpression(scanResult)
+
+		var messages []string
+		for _, diagnostic := range outcome.Diagnostics {
+			messages = append(messages, diagnostic.Message)
+		}
+
+		assert.Equal(t, expectedMessages, messages, "For source code: "+sourceCode)
+		assert.Equal(t, expectedModel, describeErrorExprs(outcome.Model, sourceCode), "For source code: "+sourceCode)
+	}
+
+	checkTopLevel := func(sourceCode string, expectedItemCount int, expectedMessages ...string) {
+		scanResult := scanning.Scan(sourceCode)
+
+		scanResult = tokenfilters.ProcessLeadingTrailingDocumentation(scanResult)
+
+		outcome := ParseTopLevel(scanResult)
+
+		var messages []string
+		for _, diagnostic := range outcome.Diagnostics {
+			messages = append(messages, diagnostic.Message)
+		}
+
+		assert.Equal(t, expectedMessages, messages, "For source code: "+sourceCode)
+		if record, ok := outcome.Model.(*RecordExpr); assert.True(t, ok, "For source code: "+sourceCode) {
+			assert.Len(t, record.Items, expectedItemCount, "For source code: "+sourceCode)
+		}
 	}
 
 	t.Run("identifier literals", func(t *testing.T) {
@@ -74,6 +125,94 @@ func TestLligneParser(t *testing.T) {
 		check("name: String")
 	})
 
+	t.Run("indexing", func(t *testing.T) {
+		outcome := ParseExpression(scanning.Scan("a.b[i].c"))
+
+		// Indexing applies to the whole field reference to its left: ((a.b)[i]).c
+		fieldReference, ok := outcome.Model.(*FieldReferenceExpr)
+		if assert.True(t, ok) {
+			index, ok := fieldReference.Parent.(*IndexExpr)
+			if assert.True(t, ok) {
+				_, ok = index.Array.(*FieldReferenceExpr)
+				assert.True(t, ok)
+			}
+		}
+	})
+
+	t.Run("array types", func(t *testing.T) {
+		outcome := ParseExpression(scanning.Scan("a[][i]"))
+
+		// Empty brackets make an array type, which can then be indexed like any other operand: (a[])[i]
+		index, ok := outcome.Model.(*IndexExpr)
+		if assert.True(t, ok) {
+			arrayType, ok := index.Array.(*ArrayTypeExpr)
+			if assert.True(t, ok) {
+				_, ok = arrayType.ElementType.(*IdentifierExpr)
+				assert.True(t, ok)
+			}
+		}
+	})
+
+	t.Run("syntax errors", func(t *testing.T) {
+		checkError("(x + 5", diagnostics.CodeExpectedToken, "Expected ')' but found end of file")
+		checkError("{x = 1, y = 2", diagnostics.CodeExpectedToken, "Expected '}' but found end of file")
+		checkError("[1, 2 3]", diagnostics.CodeExpectedToken, "Expected ',' or ']' but found '3'")
+		checkError("1 +", diagnostics.CodeUnexpectedToken, "Unexpected end of file")
+		checkError("1 2", diagnostics.CodeUnexpectedToken, "Unexpected '2'")
+		checkError("x + \"abc", diagnostics.CodeUnclosedString, "Unclosed string literal")
+		checkError("x + ~", diagnostics.CodeUnrecognizedCharacter, "Unrecognized character '~'")
+		checkError("a[1", diagnostics.CodeExpectedToken, "Expected ']' but found end of file")
+	})
+
+	t.Run("error recovery", func(t *testing.T) {
+		checkErrors(
+			"{a = (1 + ), b = [2 3], c = }",
+			"[) 3 }]",
+			"Unexpected ')'",
+			"Expected ',' or ']' but found '3'",
+			"Unexpected '}'",
+		)
+		checkErrors(
+			"{a = 1 b = 2, c = 3 + , d = {e = f g}}",
+			"[b = 2 , g]",
+			"Expected ',' or '}' but found 'b'",
+			"Unexpected ','",
+			"Expected ',' or '}' but found 'g'",
+		)
+		checkErrors(
+			"f(x: 1 ~, y: (2 3))",
+			"[~]",
+			"Expected ',' or ')' but found '~'",
+			"Expected ')' but found '3'",
+		)
+		checkErrors(
+			"(1 + ] + 2",
+			"[]]",
+			"Unexpected ']'",
+		)
+		checkErrors(
+			"[1, 2; 3]",
+			"[]",
+			"Expected ',' or ']' but found ';'",
+		)
+	})
+
+	t.Run("top level", func(t *testing.T) {
+		checkTopLevel("", 0)
+		checkTopLevel("x = 1", 1)
+		checkTopLevel("x = 1,", 1)
+		checkTopLevel("x = 1, y = 2; z = 3", 3)
+		checkTopLevel("x: Int64 = 1\ny: String = 'two'\n", 2)
+		checkTopLevel("x = 1\n  + 2\ny = 3", 2)
+		checkTopLevel("// about x\nx = 1 // trailing\ny = 2", 2)
+		checkTopLevel("a = `x\nb = 2", 2)
+		checkTopLevel("a = `x\n    `y\nb = 2", 2)
+		checkTopLevel("x = 1 y = 2\nz = 3", 3, "Expected ',', ';', or a line break but found 'y'")
+		checkTopLevel("x = 1 }\ny = (2", 2, "Unexpected '}'",
+			"Expected ')' but found end of file")
+		checkTopLevel("x = , y = 2", 2, "Unexpected ','")
+	})
+
 	t.Run("table of expressions", func(t *testing.T) {
 		tests := []string{
 			"x + 1",
@@ -102,6 +241,12 @@ func TestLligneParser(t *testing.T) {
 
 			"[]",
 			"[1, 2, 3, 4, 5]",
+			"a[1]",
+			"a.b[i + 1]",
+			"[1, 2][0]",
+			"int[]",
+			"int?[][]",
+			"x: int[]? = []",
 
 			"true and false",
 			"a and b",
@@ -118,8 +263,13 @@ func TestLligneParser(t *testing.T) {
 			"x =~ y",
 			"x !~ y",
 
+			"x ==[Aa]== y",
+			"x =![Aa, NFC]!= y",
+			"x ==[1, 2]",
+
 			"int?",
 			"float | int?",
+			"x ?: none",
 			"float & 7.0",
 
 			"f(x: 0)",
@@ -127,6 +277,7 @@ func TestLligneParser(t *testing.T) {
 
 			"1..9",
 			"x in 1..9",
+			"n fmt \"%5d\"",
 
 			"x is Widget",
 
@@ -144,3 +295,50 @@ func TestLligneParser(t *testing.T) {
 }
 
 //---------------------------------------------------------------------------------------------------------------------
+
+// describeErrorExprs lists the source text of every ErrorExpr node found in an expression.
+func describeErrorExprs(expression IExpression, sourceCode string) string {
+
+	var texts []string
+
+	var visit func(expression IExpression)
+	visit = func(expression IExpression) {
+		switch expr := expression.(type) {
+		case *ErrorExpr:
+			texts = append(texts, expr.SourcePosition.GetText(sourceCode))
+		case *AdditionExpr:
+			visit(expr.Lhs)
+			visit(expr.Rhs)
+		case *ArrayLiteralExpr:
+			for _, element := range expr.Elements {
+				visit(element)
+			}
+		case *FunctionArgumentsExpr:
+			for _, item := range expr.Items {
+				visit(item)
+			}
+		case *FunctionCallExpr:
+			visit(expr.FunctionReference)
+			visit(expr.Argument)
+		case *IntersectAssignValueExpr:
+			visit(expr.Lhs)
+			visit(expr.Rhs)
+		case *ParenthesizedExpr:
+			visit(expr.InnerExpr)
+		case *QualifyExpr:
+			visit(expr.Lhs)
+			visit(expr.Rhs)
+		case *RecordExpr:
+			for _, item := range expr.Items {
+				visit(item)
+			}
+		}
+	}
+
+	visit(expression)
+
+	return "[" + strings.Join(texts, " ") + "]"
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
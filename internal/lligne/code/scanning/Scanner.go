@@ -8,6 +8,7 @@
 package scanning
 
 import (
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -193,11 +194,14 @@ func (s *scanner) advance() {
 	s.runeAhead1 = s.runeAhead2
 	s.runeAhead1Width = s.runeAhead2Width
 
-	if s.currentPos+1 >= len(s.sourceCode) {
+	// The second look-ahead rune starts after however many bytes the first one occupies.
+	runeAhead2Pos := s.currentPos + s.runeAhead1Width
+
+	if runeAhead2Pos >= len(s.sourceCode) {
 		s.runeAhead2 = 0
 		s.runeAhead2Width = 0
 	} else {
-		s.runeAhead2, s.runeAhead2Width = utf8.DecodeRuneInString(s.sourceCode[s.currentPos+1:])
+		s.runeAhead2, s.runeAhead2Width = utf8.DecodeRuneInString(s.sourceCode[runeAhead2Pos:])
 	}
 
 }
@@ -272,9 +276,17 @@ func (s *scanner) oneToThreeRuneToken(
 
 //---------------------------------------------------------------------------------------------------------------------
 
-// scanAfterEquals scans one of: '=', '==', '===', '=~'.
+// scanAfterEquals scans one of: '=', '==', '===', '=~', '==[...]==', '=![...]!='.
 func (s *scanner) scanAfterEquals() Token {
 
+	if s.runeAhead1 == '=' && s.runeAhead2 == '[' && s.scanCollatedComparison("==") {
+		return s.token(TokenTypeCollatedEquals)
+	}
+
+	if s.runeAhead1 == '!' && s.runeAhead2 == '[' && s.scanCollatedComparison("!=") {
+		return s.token(TokenTypeCollatedNotEquals)
+	}
+
 	if s.runeAhead1 == '=' {
 
 		s.advance()
@@ -377,6 +389,33 @@ func (s *scanner) scanBackTickedString() Token {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// scanCollatedComparison consumes the rest of a collated comparison operator like "==[Aa]==" after its opening '='
+// if the bracketed modifiers (letters, commas, and spaces) are followed by the given closing text. Otherwise consumes
+// nothing, leaving e.g. "==[a, b]" to be scanned as an equality with an array. Returns whether the operator was found.
+func (s *scanner) scanCollatedComparison(closing string) bool {
+
+	// Skip the second character of the opening and the left bracket
+	rest := s.sourceCode[s.currentPos+2:]
+
+	length := strings.IndexFunc(rest, func(ch rune) bool {
+		return !('a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == ',' || ch == ' ')
+	})
+
+	if length <= 0 || !strings.HasPrefix(rest[length:], "]"+closing) {
+		return false
+	}
+
+	// Consume the opening, the modifiers (all ASCII), the right bracket, and the closing
+	for i := 0; i < length+5; i += 1 {
+		s.advance()
+	}
+
+	return true
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 // scanDocumentation consumes a multiline comment.
 func (s *scanner) scanDocumentation() Token {
 
@@ -402,7 +441,7 @@ func (s *scanner) scanDocumentation() Token {
 		}
 
 		// Quit after seeing something other than another back-ticked string on the subsequent line.
-		if s.runeAhead1 != '/' || s.sourceCode[s.currentPos+1] != '/' {
+		if s.runeAhead1 != '/' || s.runeAhead2 != '/' {
 			break
 		}
 
@@ -432,10 +471,12 @@ func (s *scanner) scanDoubleQuotedString() Token {
 			s.advance()
 			return s.token(TokenTypeDoubleQuotedString)
 		case '\\':
+			// Skip the escaped character; DecodeStringLiteral checks the escape sequence itself.
 			s.advance()
-			// TODO: handle escape sequences properly
-			s.advance()
-		case '\n':
+			if s.runeAhead1 != '\n' && s.runeAhead1 != 0 {
+				s.advance()
+			}
+		case '\n', 0:
 			return s.token(TokenTypeUnclosedDoubleQuotedString)
 		default:
 			s.advance()
@@ -525,10 +566,12 @@ func (s *scanner) scanSingleQuotedString() Token {
 			s.advance()
 			return s.token(TokenTypeSingleQuotedString)
 		case '\\':
+			// Skip the escaped character; DecodeStringLiteral checks the escape sequence itself.
 			s.advance()
-			// TODO: handle escape sequences properly
-			s.advance()
-		case '\n':
+			if s.runeAhead1 != '\n' && s.runeAhead1 != 0 {
+				s.advance()
+			}
+		case '\n', 0:
 			return s.token(TokenTypeUnclosedSingleQuotedString)
 		default:
 			s.advance()
@@ -563,8 +606,10 @@ var keywords = map[string]TokenType{
 	TokenTypeAnd.String():   TokenTypeAnd,
 	TokenTypeAs.String():    TokenTypeAs,
 	TokenTypeFalse.String(): TokenTypeFalse,
+	TokenTypeFmt.String():   TokenTypeFmt,
 	TokenTypeIs.String():    TokenTypeIs,
 	TokenTypeIn.String():    TokenTypeIn,
+	TokenTypeNone.String():  TokenTypeNone,
 	TokenTypeNot.String():   TokenTypeNot,
 	TokenTypeOr.String():    TokenTypeOr,
 	TokenTypeTrue.String():  TokenTypeTrue,
@@ -66,6 +66,17 @@ func TestLligneScanner(t *testing.T) {
 		assert.Equal(t, 0, len(result.NewLineOffsets))
 	})
 
+	t.Run("multi-byte characters", func(t *testing.T) {
+		result := Scan("αβ + γδ.é")
+
+		expectToken(result.Tokens[0], TokenTypeIdentifier, 0, 4)
+		expectToken(result.Tokens[1], TokenTypePlus, 5, 1)
+		expectToken(result.Tokens[2], TokenTypeIdentifier, 7, 4)
+		expectToken(result.Tokens[3], TokenTypeDot, 11, 1)
+		expectToken(result.Tokens[4], TokenTypeIdentifier, 12, 2)
+		expectToken(result.Tokens[5], TokenTypeEof, 14, 0)
+	})
+
 	t.Run("a few integers", func(t *testing.T) {
 		result := Scan(
 			"123 4\n(99000) 5",
@@ -124,6 +135,51 @@ func TestLligneScanner(t *testing.T) {
 		assert.Equal(t, 1, len(result.NewLineOffsets))
 	})
 
+	t.Run("unclosed strings at end of file", func(t *testing.T) {
+		result := Scan(`"abc`)
+
+		expectToken(result.Tokens[0], TokenTypeUnclosedDoubleQuotedString, 0, 4)
+		expectToken(result.Tokens[1], TokenTypeEof, 4, 0)
+
+		result = Scan(`'abc`)
+
+		expectToken(result.Tokens[0], TokenTypeUnclosedSingleQuotedString, 0, 4)
+		expectToken(result.Tokens[1], TokenTypeEof, 4, 0)
+	})
+
+	t.Run("escaped characters in strings", func(t *testing.T) {
+		result := Scan(`"a\"b" 'c\'d'`)
+
+		expectToken(result.Tokens[0], TokenTypeDoubleQuotedString, 0, 6)
+		expectToken(result.Tokens[1], TokenTypeSingleQuotedString, 7, 6)
+
+		result = Scan("\"abc\\\n\"")
+
+		expectToken(result.Tokens[0], TokenTypeUnclosedDoubleQuotedString, 0, 5)
+
+		result = Scan(`'abc\`)
+
+		expectToken(result.Tokens[0], TokenTypeUnclosedSingleQuotedString, 0, 5)
+		expectToken(result.Tokens[1], TokenTypeEof, 5, 0)
+	})
+
+	t.Run("collated comparisons", func(t *testing.T) {
+		result := Scan(
+			"a ==[Aa]== b =![Aa, NFC]!= c ==[1]",
+		)
+
+		expectToken(result.Tokens[0], TokenTypeIdentifier, 0, 1)
+		expectToken(result.Tokens[1], TokenTypeCollatedEquals, 2, 8)
+		expectToken(result.Tokens[2], TokenTypeIdentifier, 11, 1)
+		expectToken(result.Tokens[3], TokenTypeCollatedNotEquals, 13, 13)
+		expectToken(result.Tokens[4], TokenTypeIdentifier, 27, 1)
+		expectToken(result.Tokens[5], TokenTypeEqualsEquals, 29, 2)
+		expectToken(result.Tokens[6], TokenTypeLeftBracket, 31, 1)
+		expectToken(result.Tokens[7], TokenTypeIntegerLiteral, 32, 1)
+		expectToken(result.Tokens[8], TokenTypeRightBracket, 33, 1)
+		expectToken(result.Tokens[9], TokenTypeEof, 34, 0)
+	})
+
 	t.Run("all fixed text tokens, one at a time", func(t *testing.T) {
 		for tokenType := TokenTypeEof; tokenType < TokenType_Count; tokenType += 1 {
 			sourceCode := tokenType.String()
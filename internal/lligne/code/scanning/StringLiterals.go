@@ -0,0 +1,183 @@
+//
+// # Decoding and encoding of Lligne string literals.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package scanning
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//=====================================================================================================================
+
+// StringEscapeError describes an invalid escape sequence found while decoding a string literal.
+type StringEscapeError struct {
+	Offset  int // Byte offset of the escape sequence within the literal
+	Length  int // Byte length of the escape sequence
+	Message string
+}
+
+//=====================================================================================================================
+
+// DecodeStringLiteral converts the text of a single or double-quoted string literal, delimiters included, to the
+// string value it denotes. The recognized escape sequences are \n, \t, \\, \", \', and \u{X} with one to six hex
+// digits X. An invalid escape sequence is kept as is in the value and described by one of the returned errors.
+func DecodeStringLiteral(text string) (value string, errs []StringEscapeError) {
+
+	sb := strings.Builder{}
+
+	body := text[1 : len(text)-1]
+
+	for i := 0; i < len(body); {
+
+		if body[i] != '\\' {
+			r, width := utf8.DecodeRuneInString(body[i:])
+			sb.WriteRune(r)
+			i += width
+			continue
+		}
+
+		r, length, message := decodeEscapeSequence(body[i:])
+		if message != "" {
+			errs = append(errs, StringEscapeError{
+				Offset:  i + 1,
+				Length:  length,
+				Message: message,
+			})
+			sb.WriteString(body[i : i+length])
+		} else {
+			sb.WriteRune(r)
+		}
+		i += length
+
+	}
+
+	return sb.String(), errs
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// DecodeMultilineStringLiteral converts the text of a back-ticked multiline string literal to the string value it
+// denotes. Each line contributes the text after its back-tick, without the margin in front of the back-tick or the
+// line break at its end, and the lines are joined with line feeds. There are no escape sequences: all the text after
+// each back-tick is taken literally.
+func DecodeMultilineStringLiteral(text string) string {
+
+	var lines []string
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimLeft(line, " \t")
+
+		// Skip the whitespace that can trail the last line.
+		if !strings.HasPrefix(line, "`") {
+			continue
+		}
+
+		lines = append(lines, strings.TrimSuffix(line[1:], "\r"))
+	}
+
+	return strings.Join(lines, "\n")
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// EncodeStringLiteral converts a string value to the text of a string literal with the given delimiter, either a
+// single or a double quote. It is the inverse of DecodeStringLiteral.
+func EncodeStringLiteral(value string, delimiter rune) string {
+
+	sb := strings.Builder{}
+
+	sb.WriteRune(delimiter)
+
+	for _, r := range value {
+		switch {
+		case r == delimiter || r == '\\':
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case !unicode.IsPrint(r) && r != ' ':
+			sb.WriteString(fmt.Sprintf(`\u{%X}`, r))
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	sb.WriteRune(delimiter)
+
+	return sb.String()
+
+}
+
+//=====================================================================================================================
+
+// decodeEscapeSequence decodes the escape sequence at the start of text, which begins with a backslash. Returns the
+// decoded rune and the byte length of the sequence, or else a message describing why the sequence is invalid.
+func decodeEscapeSequence(text string) (r rune, length int, message string) {
+
+	if len(text) < 2 {
+		return 0, len(text), "Incomplete escape sequence"
+	}
+
+	switch text[1] {
+	case 'n':
+		return '\n', 2, ""
+	case 't':
+		return '\t', 2, ""
+	case '\\', '"', '\'':
+		return rune(text[1]), 2, ""
+	case 'u':
+		return decodeUnicodeEscapeSequence(text)
+	}
+
+	_, width := utf8.DecodeRuneInString(text[1:])
+	return 0, 1 + width, fmt.Sprintf("Invalid escape sequence '%s'", text[:1+width])
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// decodeUnicodeEscapeSequence decodes an escape sequence of the form \u{X} at the start of text.
+func decodeUnicodeEscapeSequence(text string) (r rune, length int, message string) {
+
+	if len(text) < 3 || text[2] != '{' {
+		return 0, 2, "Expected '{' after '\\u' in Unicode escape sequence"
+	}
+
+	end := strings.IndexByte(text, '}')
+	if end < 0 {
+		return 0, len(text), "Unclosed Unicode escape sequence"
+	}
+
+	length = end + 1
+	digits := text[3:end]
+
+	if len(digits) == 0 || len(digits) > 6 {
+		return 0, length, fmt.Sprintf("Expected one to six hex digits in Unicode escape sequence '%s'", text[:length])
+	}
+
+	codePoint, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return 0, length, fmt.Sprintf("Expected one to six hex digits in Unicode escape sequence '%s'", text[:length])
+	}
+
+	if codePoint > unicode.MaxRune || (codePoint >= 0xD800 && codePoint <= 0xDFFF) {
+		return 0, length, fmt.Sprintf("Invalid Unicode code point in escape sequence '%s'", text[:length])
+	}
+
+	return rune(codePoint), length, ""
+
+}
+
+//=====================================================================================================================
@@ -0,0 +1,99 @@
+//
+// # Tests of string literal decoding and encoding.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package scanning
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestStringLiterals(t *testing.T) {
+
+	checkDecode := func(text string, expectedValue string) {
+		value, errs := DecodeStringLiteral(text)
+
+		assert.Equal(t, expectedValue, value, "For text: "+text)
+		assert.Empty(t, errs, "For text: "+text)
+	}
+
+	checkDecodeError := func(text string, expectedOffset int, expectedLength int, expectedMessage string) {
+		_, errs := DecodeStringLiteral(text)
+
+		if assert.Len(t, errs, 1, "For text: "+text) {
+			assert.Equal(t, expectedOffset, errs[0].Offset, "For text: "+text)
+			assert.Equal(t, expectedLength, errs[0].Length, "For text: "+text)
+			assert.Equal(t, expectedMessage, errs[0].Message, "For text: "+text)
+		}
+	}
+
+	checkEncode := func(value string, delimiter rune, expectedText string) {
+		text := EncodeStringLiteral(value, delimiter)
+
+		assert.Equal(t, expectedText, text, "For value: "+value)
+
+		decoded, errs := DecodeStringLiteral(text)
+		assert.Equal(t, value, decoded, "For value: "+value)
+		assert.Empty(t, errs, "For value: "+value)
+	}
+
+	t.Run("plain strings", func(t *testing.T) {
+		checkDecode(`""`, "")
+		checkDecode(`"abc"`, "abc")
+		checkDecode(`'déjà vu'`, "déjà vu")
+	})
+
+	t.Run("escape sequences", func(t *testing.T) {
+		checkDecode(`"a\nb"`, "a\nb")
+		checkDecode(`"a\tb"`, "a\tb")
+		checkDecode(`"a\\b"`, `a\b`)
+		checkDecode(`"a\"b"`, `a"b`)
+		checkDecode(`'a\'b'`, `a'b`)
+		checkDecode(`'a\"b'`, `a"b`)
+		checkDecode(`"\u{41}\u{e9}\u{1F600}"`, "Aé😀")
+	})
+
+	t.Run("invalid escape sequences", func(t *testing.T) {
+		checkDecodeError(`"a\qb"`, 2, 2, `Invalid escape sequence '\q'`)
+		checkDecodeError(`"\é"`, 1, 3, `Invalid escape sequence '\é'`)
+		checkDecodeError(`"\u41"`, 1, 2, `Expected '{' after '\u' in Unicode escape sequence`)
+		checkDecodeError(`"\u{41"`, 1, 5, `Unclosed Unicode escape sequence`)
+		checkDecodeError(`"\u{}"`, 1, 4, `Expected one to six hex digits in Unicode escape sequence '\u{}'`)
+		checkDecodeError(`"\u{12345678}"`, 1, 12,
+			`Expected one to six hex digits in Unicode escape sequence '\u{12345678}'`)
+		checkDecodeError(`"\u{xyz}"`, 1, 7, `Expected one to six hex digits in Unicode escape sequence '\u{xyz}'`)
+		checkDecodeError(`"\u{D800}"`, 1, 8, `Invalid Unicode code point in escape sequence '\u{D800}'`)
+		checkDecodeError(`"\u{110000}"`, 1, 10, `Invalid Unicode code point in escape sequence '\u{110000}'`)
+
+		value, _ := DecodeStringLiteral(`"a\qb"`)
+		assert.Equal(t, `a\qb`, value)
+	})
+
+	t.Run("multiline strings", func(t *testing.T) {
+		assert.Equal(t, "abc", DecodeMultilineStringLiteral("`abc"))
+		assert.Equal(t, "abc", DecodeMultilineStringLiteral("`abc\n"))
+		assert.Equal(t, "abc\n def", DecodeMultilineStringLiteral("`abc\n  ` def\n  "))
+		assert.Equal(t, "SELECT *\n  FROM t\n", DecodeMultilineStringLiteral("`SELECT *\r\n\t`  FROM t\r\n\t`\r\n"))
+		assert.Equal(t, `no \n escapes`, DecodeMultilineStringLiteral("`no \\n escapes\n"))
+	})
+
+	t.Run("encoding", func(t *testing.T) {
+		checkEncode("", '"', `""`)
+		checkEncode("abc", '"', `"abc"`)
+		checkEncode("a\nb\tc", '"', `"a\nb\tc"`)
+		checkEncode(`a\b`, '\'', `'a\\b'`)
+		checkEncode(`it's "quoted"`, '"', `"it's \"quoted\""`)
+		checkEncode(`it's "quoted"`, '\'', `'it\'s "quoted"'`)
+		checkEncode("bell\a", '"', `"bell\u{7}"`)
+		checkEncode("Aé😀", '"', `"Aé😀"`)
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
@@ -58,8 +58,10 @@ const (
 	TokenTypeAnd
 	TokenTypeAs
 	TokenTypeFalse
+	TokenTypeFmt
 	TokenTypeIn
 	TokenTypeIs
+	TokenTypeNone
 	TokenTypeNot
 	TokenTypeOr
 	TokenTypeTrue
@@ -69,6 +71,8 @@ const (
 	// Others
 	TokenTypeBackTickedString
 	TokenTypeBuiltInType
+	TokenTypeCollatedEquals
+	TokenTypeCollatedNotEquals
 	TokenTypeDocumentation
 	TokenTypeDoubleQuotedString
 	TokenTypeFloatingPointLiteral
@@ -174,10 +178,14 @@ func (tt TokenType) String() string {
 		return "as"
 	case TokenTypeFalse:
 		return "false"
+	case TokenTypeFmt:
+		return "fmt"
 	case TokenTypeIn:
 		return "in"
 	case TokenTypeIs:
 		return "is"
+	case TokenTypeNone:
+		return "none"
 	case TokenTypeNot:
 		return "not"
 	case TokenTypeOr:
@@ -194,6 +202,10 @@ func (tt TokenType) String() string {
 		return "[back-ticked string]"
 	case TokenTypeBuiltInType:
 		return "[built in type]"
+	case TokenTypeCollatedEquals:
+		return "[collated equality operator]"
+	case TokenTypeCollatedNotEquals:
+		return "[collated inequality operator]"
 	case TokenTypeDocumentation:
 		return "[documentation]"
 	case TokenTypeDoubleQuotedString:
@@ -34,7 +34,9 @@ func ProcessLeadingTrailingDocumentation(scanResult *scanning.Outcome) *scanning
 			})
 			index += 1
 		} else if tokens[index+1].TokenType == scanning.TokenTypeDocumentation {
-			if tokensOnSameLine(scanResult.SourceCode, tokens[index].SourceOffset, tokens[index+1].SourceOffset) {
+			// Documentation following an opening delimiter on its line leads the first item inside the delimiters
+			if tokensOnSameLine(scanResult.SourceCode, tokens[index].SourceOffset, tokens[index+1].SourceOffset) &&
+				!isOpeningDelimiter(tokens[index].TokenType) {
 
 				if tokens[index].TokenType == scanning.TokenTypeComma || tokens[index].TokenType == scanning.TokenTypeSemicolon {
 					result = append(result, scanning.Token{
@@ -96,6 +98,15 @@ func ProcessLeadingTrailingDocumentation(scanResult *scanning.Outcome) *scanning
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// isOpeningDelimiter determines whether a token type opens a record, an array, or a parenthesized expression.
+func isOpeningDelimiter(tokenType scanning.TokenType) bool {
+	return tokenType == scanning.TokenTypeLeftBrace ||
+		tokenType == scanning.TokenTypeLeftBracket ||
+		tokenType == scanning.TokenTypeLeftParenthesis
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 // tokensOnSameLine looks for a line feed in the source code between two tokens.
 func tokensOnSameLine(sourceCode string, token1StartPos uint32, token2StartPos uint32) bool {
 	return strings.IndexByte(sourceCode[token1StartPos:token2StartPos], '\n') < 0
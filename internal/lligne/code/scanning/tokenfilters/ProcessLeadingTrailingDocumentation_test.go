@@ -73,6 +73,20 @@ stuff {
 
 	})
 
+	t.Run("documentation after an opening delimiter", func(t *testing.T) {
+		sourceCode := "{ // about x\nx}"
+
+		scanOutcome := scanning.Scan(sourceCode)
+		scanOutcome = ProcessLeadingTrailingDocumentation(scanOutcome)
+		tokens := scanOutcome.Tokens
+
+		expectToken(tokens[0], scanning.TokenTypeLeftBrace, 0, 1)
+		expectToken(tokens[1], scanning.TokenTypeLeadingDocumentation, 2, 11)
+		expectToken(tokens[2], scanning.TokenTypeSynthDocument, 2, 0)
+		expectToken(tokens[3], scanning.TokenTypeIdentifier, 13, 1)
+		expectToken(tokens[4], scanning.TokenTypeRightBrace, 14, 1)
+	})
+
 }
 
 //---------------------------------------------------------------------------------------------------------------------
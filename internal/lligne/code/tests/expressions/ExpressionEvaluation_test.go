@@ -80,6 +80,22 @@ func TestExpressionEvaluation(t *testing.T) {
 		checkSampleFile(t, sample7)
 		checkSampleFile(t, sample8)
 		checkSampleFile(t, sample9)
+		checkSampleFile(t, sample10)
+		checkSampleFile(t, sample11)
+		checkSampleFile(t, sample12)
+		checkSampleFile(t, sample13)
+		checkSampleFile(t, sample14)
+		checkSampleFile(t, sample15)
+		checkSampleFile(t, sample16)
+		checkSampleFile(t, sample17)
+		checkSampleFile(t, sample18)
+		checkSampleFile(t, sample19)
+		checkSampleFile(t, sample20)
+		checkSampleFile(t, sample21)
+		checkSampleFile(t, sample22)
+		checkSampleFile(t, sample23)
+		checkSampleFile(t, sample24)
+		checkSampleFile(t, sample25)
 
 	})
 
@@ -118,4 +134,52 @@ var sample8 string
 //go:embed types/built-in-types.lligne-tests
 var sample9 string
 
+//go:embed string/string-multiline.lligne-tests
+var sample10 string
+
+//go:embed record/record-nested-names.lligne-tests
+var sample11 string
+
+//go:embed record/record-defaults.lligne-tests
+var sample12 string
+
+//go:embed record/record-intersection.lligne-tests
+var sample13 string
+
+//go:embed types/union-types.lligne-tests
+var sample14 string
+
+//go:embed types/optional-types.lligne-tests
+var sample15 string
+
+//go:embed types/constraint-types.lligne-tests
+var sample16 string
+
+//go:embed array/array-literals.lligne-tests
+var sample17 string
+
+//go:embed function/function-calls.lligne-tests
+var sample18 string
+
+//go:embed conditional/when-guards.lligne-tests
+var sample19 string
+
+//go:embed bool/short-circuit.lligne-tests
+var sample20 string
+
+//go:embed string/string-matches.lligne-tests
+var sample21 string
+
+//go:embed range/ranges.lligne-tests
+var sample22 string
+
+//go:embed string/collated-comparisons.lligne-tests
+var sample23 string
+
+//go:embed string/string-formats.lligne-tests
+var sample24 string
+
+//go:embed record/record-arithmetic.lligne-tests
+var sample25 string
+
 //---------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,169 @@
+//
+// # Named source code files with line and column lookup.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//=====================================================================================================================
+
+// SourceLocation is a 1-based line and column within a source file. Columns count runes, not bytes.
+type SourceLocation struct {
+	Line   int
+	Column int
+}
+
+//=====================================================================================================================
+
+// SourceFile is a named file of Lligne source code together with the offsets of its line feed characters as found
+// by the scanner.
+type SourceFile struct {
+	FileName       string
+	SourceCode     string
+	NewLineOffsets []uint32
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// NewSourceFile constructs a SourceFile instance.
+func NewSourceFile(fileName string, sourceCode string, newLineOffsets []uint32) *SourceFile {
+	return &SourceFile{
+		FileName:       fileName,
+		SourceCode:     sourceCode,
+		NewLineOffsets: newLineOffsets,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// GetExcerpt renders the lines of source code spanned by the given source position with carets underlining the
+// covered text, for example:
+//
+//	12 | total = count + "items"
+//	   |         ^^^^^^^^^^^^^^^
+func (f *SourceFile) GetExcerpt(sourcePos SourcePos) string {
+
+	start := f.GetLocation(sourcePos.startOffset)
+	end := f.GetLocation(sourcePos.endOffset)
+
+	// A span ending just after a line feed does not underline the following line.
+	if end.Line > start.Line && end.Column == 1 {
+		end = f.GetLocation(sourcePos.endOffset - 1)
+		end.Column += 1
+	}
+
+	gutterWidth := len(strconv.Itoa(end.Line))
+	sb := strings.Builder{}
+
+	for line := start.Line; line <= end.Line; line += 1 {
+
+		lineText := f.getLineText(line)
+
+		firstColumn := 1
+		if line == start.Line {
+			firstColumn = start.Column
+		}
+
+		lastColumn := utf8.RuneCountInString(lineText) + 1
+		if line == end.Line {
+			lastColumn = end.Column
+		}
+
+		sb.WriteString(fmt.Sprintf("%*d | %s\n", gutterWidth, line, lineText))
+		sb.WriteString(fmt.Sprintf("%*s | ", gutterWidth, ""))
+
+		column := 1
+		for _, ch := range lineText {
+			if column >= firstColumn {
+				break
+			}
+			// Keep tabs so that the carets line up with the text above them.
+			if ch == '\t' {
+				sb.WriteRune('\t')
+			} else {
+				sb.WriteRune(' ')
+			}
+			column += 1
+		}
+		for column < firstColumn {
+			sb.WriteRune(' ')
+			column += 1
+		}
+
+		caretCount := lastColumn - firstColumn
+		if caretCount < 1 {
+			caretCount = 1
+		}
+		sb.WriteString(strings.Repeat("^", caretCount))
+		sb.WriteString("\n")
+
+	}
+
+	return sb.String()
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// GetLocation determines the line and column of the character at the given byte offset.
+func (f *SourceFile) GetLocation(offset uint32) SourceLocation {
+
+	// Count the line feeds ahead of the offset.
+	lineIndex := sort.Search(len(f.NewLineOffsets), func(i int) bool {
+		return f.NewLineOffsets[i] >= offset
+	})
+
+	lineStart := f.getLineStartOffset(lineIndex + 1)
+
+	return SourceLocation{
+		Line:   lineIndex + 1,
+		Column: utf8.RuneCountInString(f.SourceCode[lineStart:offset]) + 1,
+	}
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// GetLocationText describes the start of the given source position as "fileName:line:column".
+func (f *SourceFile) GetLocationText(sourcePos SourcePos) string {
+	location := f.GetLocation(sourcePos.startOffset)
+	return fmt.Sprintf("%s:%d:%d", f.FileName, location.Line, location.Column)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// getLineStartOffset returns the byte offset of the first character of a 1-based line.
+func (f *SourceFile) getLineStartOffset(line int) uint32 {
+	if line <= 1 {
+		return 0
+	}
+	return f.NewLineOffsets[line-2] + 1
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// getLineText returns the text of a 1-based line without its line ending.
+func (f *SourceFile) getLineText(line int) string {
+
+	startOffset := f.getLineStartOffset(line)
+
+	endOffset := uint32(len(f.SourceCode))
+	if line-1 < len(f.NewLineOffsets) {
+		endOffset = f.NewLineOffsets[line-1]
+	}
+
+	return strings.TrimSuffix(f.SourceCode[startOffset:endOffset], "\r")
+
+}
+
+//=====================================================================================================================
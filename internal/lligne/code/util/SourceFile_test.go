@@ -0,0 +1,87 @@
+//
+// # Tests of source file line and column lookup.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"lligne-cli/internal/lligne/code/scanning"
+	"testing"
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestSourceFile(t *testing.T) {
+
+	newSourceFile := func(sourceCode string) *SourceFile {
+		scanOutcome := scanning.Scan(sourceCode)
+		return NewSourceFile("sample.lligne", sourceCode, scanOutcome.NewLineOffsets)
+	}
+
+	t.Run("locations", func(t *testing.T) {
+		sourceFile := newSourceFile("abc\n  def\n\nghi")
+
+		assert.Equal(t, SourceLocation{Line: 1, Column: 1}, sourceFile.GetLocation(0))
+		assert.Equal(t, SourceLocation{Line: 1, Column: 3}, sourceFile.GetLocation(2))
+		assert.Equal(t, SourceLocation{Line: 1, Column: 4}, sourceFile.GetLocation(3))
+		assert.Equal(t, SourceLocation{Line: 2, Column: 1}, sourceFile.GetLocation(4))
+		assert.Equal(t, SourceLocation{Line: 2, Column: 3}, sourceFile.GetLocation(6))
+		assert.Equal(t, SourceLocation{Line: 3, Column: 1}, sourceFile.GetLocation(10))
+		assert.Equal(t, SourceLocation{Line: 4, Column: 2}, sourceFile.GetLocation(12))
+		assert.Equal(t, SourceLocation{Line: 4, Column: 4}, sourceFile.GetLocation(14))
+	})
+
+	t.Run("multi-byte characters count as one column", func(t *testing.T) {
+		sourceFile := newSourceFile("x\nαβ + γ")
+
+		assert.Equal(t, SourceLocation{Line: 2, Column: 4}, sourceFile.GetLocation(7))
+		assert.Equal(t, SourceLocation{Line: 2, Column: 6}, sourceFile.GetLocation(9))
+	})
+
+	t.Run("location text", func(t *testing.T) {
+		sourceCode := "{\n  x = 1,\n  y = q\n}"
+		sourceFile := newSourceFile(sourceCode)
+		tokens := scanning.Scan(sourceCode).Tokens
+
+		assert.Equal(t, "sample.lligne:3:7", sourceFile.GetLocationText(NewSourcePos(tokens[7])))
+	})
+
+	t.Run("excerpts", func(t *testing.T) {
+		sourceCode := "{\n\tx = 1,\n\ttotal = count + \"items\"\n}"
+		sourceFile := newSourceFile(sourceCode)
+		tokens := scanning.Scan(sourceCode).Tokens
+
+		assert.Equal(t,
+			"3 | \ttotal = count + \"items\"\n"+
+				"  | \t        ^^^^^\n",
+			sourceFile.GetExcerpt(NewSourcePos(tokens[7])),
+		)
+
+		assert.Equal(t,
+			"3 | \ttotal = count + \"items\"\n"+
+				"  | \t        ^^^^^^^^^^^^^^^\n",
+			sourceFile.GetExcerpt(NewSourcePos(tokens[7]).Thru(NewSourcePos(tokens[9]))),
+		)
+
+		assert.Equal(t,
+			"1 | {\n"+
+				"  | ^\n"+
+				"2 | \tx = 1,\n"+
+				"  | ^^\n",
+			sourceFile.GetExcerpt(NewSourcePos(tokens[0]).Thru(NewSourcePos(tokens[1]))),
+		)
+
+		assert.Equal(t,
+			"4 | }\n"+
+				"  | ^\n",
+			sourceFile.GetExcerpt(NewSourcePos(tokens[10])),
+		)
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
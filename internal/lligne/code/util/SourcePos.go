@@ -27,6 +27,30 @@ func NewSourcePos(token scanning.Token) SourcePos {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// NewSourcePosFromOffsets constructs a SourcePos instance for the bytes from startOffset up to endOffset.
+func NewSourcePosFromOffsets(startOffset uint32, endOffset uint32) SourcePos {
+	return SourcePos{
+		startOffset: startOffset,
+		endOffset:   endOffset,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// GetEndOffset returns the byte offset just past the end of the source position.
+func (s SourcePos) GetEndOffset() uint32 {
+	return s.endOffset
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// GetStartOffset returns the byte offset of the start of the source position.
+func (s SourcePos) GetStartOffset() uint32 {
+	return s.startOffset
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 // GetText slices the given sourceCode to produce the string demarcated by the source position.
 func (s SourcePos) GetText(sourceCode string) string {
 	return sourceCode[s.startOffset:s.endOffset]
@@ -49,30 +73,3 @@ func (s SourcePos) Thru(s2 SourcePos) SourcePos {
 }
 
 //=====================================================================================================================
-
-//func (t *LligneTokenOriginTracker) GetOrigin(sourcePos int) LligneOrigin {
-//
-//	priorNewLinePos := 0
-//	if len(t.newLinePositions) > 0 {
-//		iMin := 0
-//		iMax := len(t.newLinePositions)
-//		for iMax-iMin > 1 {
-//			iMid := (iMin + iMax) / 2
-//			if sourcePos > t.newLinePositions[iMid] {
-//				iMin = iMid
-//			} else {
-//				iMax = iMid
-//			}
-//		}
-//		priorNewLinePos = iMin
-//	}
-//
-//	return LligneOrigin{
-//		FileName: t.fileName,
-//		Line:     priorNewLinePos + 1,
-//		Column:   sourcePos - t.newLinePositions[priorNewLinePos],
-//	}
-//
-//}
-
-//=====================================================================================================================
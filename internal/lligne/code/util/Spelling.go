@@ -0,0 +1,91 @@
+//
+// # Spelling suggestions for misspelled names.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package util
+
+import "sort"
+
+//=====================================================================================================================
+
+// DidYouMean returns a suffix for an error message that suggests the candidate closest in spelling to the given
+// misspelled name, e.g. "; did you mean 'count'?". Returns an empty string when no candidate is close enough to be
+// a likely intended name: a suggestion may differ from the name by at most one edit per three characters.
+func DidYouMean(name string, candidates []string) string {
+
+	sorted := append([]string(nil), candidates...)
+	sort.Strings(sorted)
+
+	maxDistance := len([]rune(name)) / 3
+	bestCandidate := ""
+
+	for _, candidate := range sorted {
+		distance := editDistance(name, candidate)
+		if distance > 0 && distance <= maxDistance {
+			maxDistance = distance - 1
+			bestCandidate = candidate
+		}
+	}
+
+	if bestCandidate == "" {
+		return ""
+	}
+
+	return "; did you mean '" + bestCandidate + "'?"
+
+}
+
+//=====================================================================================================================
+
+// editDistance computes the number of single character insertions, deletions, substitutions, and transpositions of
+// adjacent characters needed to change one string into the other (the optimal string alignment distance).
+func editDistance(s1 string, s2 string) int {
+
+	r1 := []rune(s1)
+	r2 := []rune(s2)
+
+	// Three rows of the distance matrix: before the prior row, the prior row, and the current row
+	priorPrior := make([]int, len(r2)+1)
+	prior := make([]int, len(r2)+1)
+	current := make([]int, len(r2)+1)
+
+	for j := range prior {
+		prior[j] = j
+	}
+
+	for i := 1; i <= len(r1); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(r2); j++ {
+			substitution := prior[j-1]
+			if r1[i-1] != r2[j-1] {
+				substitution += 1
+			}
+
+			current[j] = minInt(substitution, minInt(prior[j]+1, current[j-1]+1))
+
+			if i > 1 && j > 1 && r1[i-1] == r2[j-2] && r1[i-2] == r2[j-1] {
+				current[j] = minInt(current[j], priorPrior[j-2]+1)
+			}
+		}
+
+		priorPrior, prior, current = prior, current, priorPrior
+	}
+
+	return prior[len(r2)]
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+//=====================================================================================================================
@@ -0,0 +1,50 @@
+//
+// # Tests of spelling suggestions.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestDidYouMean(t *testing.T) {
+
+	t.Run("edit distance", func(t *testing.T) {
+		assert.Equal(t, 0, editDistance("abc", "abc"))
+		assert.Equal(t, 3, editDistance("", "abc"))
+		assert.Equal(t, 1, editDistance("xsquare", "xsquared"))
+		assert.Equal(t, 1, editDistance("cuont", "count"))
+		assert.Equal(t, 1, editDistance("ab", "ba"))
+		assert.Equal(t, 3, editDistance("kitten", "sitting"))
+		assert.Equal(t, 2, editDistance("déjà", "deja"))
+	})
+
+	t.Run("suggestions", func(t *testing.T) {
+		assert.Equal(t, "; did you mean 'xsquared'?", DidYouMean("xsquare", []string{"x", "xpowers", "xsquared"}))
+		assert.Equal(t, "; did you mean 'count'?", DidYouMean("cont", []string{"amount", "count"}))
+		assert.Equal(t, "; did you mean 'total'?", DidYouMean("totl", []string{"total", "title"}))
+		assert.Equal(t, "; did you mean 'width'?", DidYouMean("widht", []string{"height", "width"}))
+	})
+
+	t.Run("closest suggestion wins", func(t *testing.T) {
+		assert.Equal(t, "; did you mean 'widths'?", DidYouMean("widthss", []string{"width", "widths"}))
+		assert.Equal(t, "; did you mean 'width'?", DidYouMean("widthz", []string{"widths", "width"}))
+		assert.Equal(t, "; did you mean 'abcd'?", DidYouMean("abcx", []string{"abcd", "abce"}))
+	})
+
+	t.Run("no suggestion", func(t *testing.T) {
+		assert.Equal(t, "", DidYouMean("q", []string{"x", "y"}))
+		assert.Equal(t, "", DidYouMean("length", []string{"height", "x"}))
+		assert.Equal(t, "", DidYouMean("name", nil))
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,61 @@
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package arrays
+
+import (
+	"lligne-cli/internal/lligne/runtime/types"
+)
+
+//=====================================================================================================================
+
+type Array struct {
+	TypeIndex types.TypeIndex
+	Elements  []ArrayElement
+}
+
+//=====================================================================================================================
+
+type ArrayElement = uint64
+
+//=====================================================================================================================
+
+// AreArraysEqual determines whether two arrays, known to have compatible types, have the same length and equal
+// elements. Elements that are arrays themselves are compared in turn; any other elements are compared by the given
+// function.
+func AreArraysEqual(
+	p *types.TypePool,
+	a *ArrayPool,
+	a1Index uint64,
+	a2Index uint64,
+	areValuesEqual func(typeIndex types.TypeIndex, value1 uint64, value2 uint64) bool,
+) bool {
+
+	a1 := a.Get(a1Index)
+	a2 := a.Get(a2Index)
+
+	if len(a1.Elements) != len(a2.Elements) {
+		return false
+	}
+
+	a1Type := p.Get(a1.TypeIndex).(*types.ArrayType)
+	elementCategory := p.Get(a1Type.ElementTypeIndex).Category()
+
+	for i, e1 := range a1.Elements {
+		e2 := a2.Elements[i]
+
+		if elementCategory == types.TypeCategoryArray {
+			if !AreArraysEqual(p, a, e1, e2, areValuesEqual) {
+				return false
+			}
+		} else if !areValuesEqual(a1Type.ElementTypeIndex, e1, e2) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//=====================================================================================================================
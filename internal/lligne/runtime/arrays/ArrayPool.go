@@ -0,0 +1,76 @@
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package arrays
+
+//=====================================================================================================================
+
+// ArrayPool holds a list of arrays stored so that they can be retrieved by index.
+type ArrayPool struct {
+	arrays []Array
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// NewArrayPool creates a new empty array pool.
+func NewArrayPool() *ArrayPool {
+	return &ArrayPool{
+		arrays: nil,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Freeze returns an immutable view of this array pool. The original mutable view should be abandoned afterward.
+func (p *ArrayPool) Freeze() *ArrayConstantPool {
+	return &ArrayConstantPool{
+		arrays: p.arrays,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Get returns the array at the given index.
+func (p *ArrayPool) Get(index uint64) Array {
+	return p.arrays[index]
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Put adds an array to the pool.
+// Returns the index of the new entry.
+func (p *ArrayPool) Put(value Array) uint64 {
+	result := uint64(len(p.arrays))
+	p.arrays = append(p.arrays, value)
+
+	return result
+}
+
+//=====================================================================================================================
+
+// ArrayConstantPool is an immutable view of an ArrayPool.
+type ArrayConstantPool struct {
+	arrays []Array
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Clone returns a mutable copy of this array pool.
+func (p *ArrayConstantPool) Clone() *ArrayPool {
+	result := NewArrayPool()
+	for _, array := range p.arrays {
+		result.Put(array)
+	}
+	return result
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Get returns the array at the given index.
+func (p *ArrayConstantPool) Get(index uint64) Array {
+	return p.arrays[index]
+}
+
+//=====================================================================================================================
@@ -0,0 +1,58 @@
+//
+// # Tests of arrays and ArrayPool.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package arrays
+
+import (
+	"github.com/stretchr/testify/assert"
+	"lligne-cli/internal/lligne/runtime/types"
+	"testing"
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestArrays(t *testing.T) {
+
+	t.Run("stored arrays", func(t *testing.T) {
+		pool := NewArrayPool()
+
+		i0 := pool.Put(Array{TypeIndex: types.BuiltInTypeIndexInt64, Elements: []ArrayElement{1, 2, 3}})
+		i1 := pool.Put(Array{TypeIndex: types.BuiltInTypeIndexInt64, Elements: []ArrayElement{1, 2, 3}})
+
+		assert.Equal(t, uint64(0), i0)
+		assert.Equal(t, uint64(1), i1)
+		assert.Equal(t, []ArrayElement{1, 2, 3}, pool.Get(i1).Elements)
+	})
+
+	t.Run("array equality", func(t *testing.T) {
+		typePool := types.NewTypePool()
+		int64Array := typePool.PutArray(types.BuiltInTypeIndexInt64)
+		int64ArrayArray := typePool.PutArray(int64Array)
+
+		pool := NewArrayPool()
+		areValuesEqual := func(typeIndex types.TypeIndex, value1 uint64, value2 uint64) bool {
+			return value1 == value2
+		}
+
+		a123 := pool.Put(Array{TypeIndex: int64Array, Elements: []ArrayElement{1, 2, 3}})
+		b123 := pool.Put(Array{TypeIndex: int64Array, Elements: []ArrayElement{1, 2, 3}})
+		a12 := pool.Put(Array{TypeIndex: int64Array, Elements: []ArrayElement{1, 2}})
+		a124 := pool.Put(Array{TypeIndex: int64Array, Elements: []ArrayElement{1, 2, 4}})
+		nested1 := pool.Put(Array{TypeIndex: int64ArrayArray, Elements: []ArrayElement{a123, a12}})
+		nested2 := pool.Put(Array{TypeIndex: int64ArrayArray, Elements: []ArrayElement{b123, a12}})
+		nested3 := pool.Put(Array{TypeIndex: int64ArrayArray, Elements: []ArrayElement{a124, a12}})
+
+		assert.True(t, AreArraysEqual(typePool, pool, a123, b123, areValuesEqual))
+		assert.False(t, AreArraysEqual(typePool, pool, a123, a12, areValuesEqual))
+		assert.False(t, AreArraysEqual(typePool, pool, a123, a124, areValuesEqual))
+		assert.True(t, AreArraysEqual(typePool, pool, nested1, nested2, areValuesEqual))
+		assert.False(t, AreArraysEqual(typePool, pool, nested1, nested3, areValuesEqual))
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
@@ -7,6 +7,7 @@ package bytecode
 
 import (
 	"fmt"
+	"lligne-cli/internal/lligne/runtime/collations"
 	"lligne-cli/internal/lligne/runtime/pools"
 	"lligne-cli/internal/lligne/runtime/types"
 	"math"
@@ -16,17 +17,32 @@ import (
 
 //=====================================================================================================================
 
-// CodeBlock consists of a sequence of op codes plus a string constant pool.
+// CodeBlock consists of a sequence of op codes plus a string constant pool. The predicates of constraint types are
+// code blocks of their own, each leaving a Bool on the stack, found by the index of the constraint type. The bodies of
+// functions are code blocks of their own too, each ending with a return, found by the value of the function. Constant
+// patterns are compiled once into a pattern pool shared by all the code blocks of a program, as are the names of
+// record fields, which describe runtime errors.
 type CodeBlock struct {
-	OpCodes []uint16
+	OpCodes     []uint16
+	Constraints map[types.TypeIndex]*CodeBlock
+	Functions   []*CodeBlock
+	Patterns    *pools.PatternPool
+	FieldNames  *pools.NameConstantPool
 }
 
+// NoFieldName stands in for the name of a field when a value checked against a constraint is not stored in a field.
+const NoFieldName pools.NameIndex = 0xFFFFFFFF
+
 //---------------------------------------------------------------------------------------------------------------------
 
 // NewCodeBlock constructs a new empty code block.
 func NewCodeBlock() *CodeBlock {
 	result := &CodeBlock{
-		OpCodes: nil,
+		OpCodes:     nil,
+		Constraints: make(map[types.TypeIndex]*CodeBlock),
+		Functions:   nil,
+		Patterns:    pools.NewPatternPool(),
+		FieldNames:  pools.NewNamePool().Freeze(),
 	}
 
 	return result
@@ -34,12 +50,63 @@ func NewCodeBlock() *CodeBlock {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// ArrayContains replaces a value and an array on top of the stack by whether the value equals an element of the array.
+func (cb *CodeBlock) ArrayContains() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeArrayContains)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// ArrayEquals replaces the two arrays on top of the stack by whether they have the same length and equal elements.
+func (cb *CodeBlock) ArrayEquals() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeArrayEquals)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// ArrayIndex replaces an array and an Int64 index on top of the stack by the element of the array at that index. An
+// index out of bounds is a runtime error.
+func (cb *CodeBlock) ArrayIndex() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeArrayIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// ArrayLength replaces the array on top of the stack by its number of elements.
+func (cb *CodeBlock) ArrayLength() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeArrayLength)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// ArrayNotEquals is the negation of ArrayEquals.
+func (cb *CodeBlock) ArrayNotEquals() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeArrayNotEquals)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// ArrayStore copies the given number of elements on top of the stack, preceded by the array type, into a new array in
+// the array pool, leaving the index of the array on the stack in their place.
+func (cb *CodeBlock) ArrayStore(elementCount int) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeArrayStore)
+	cb.append64BitOperand(uint64(elementCount))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) BoolAnd() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeBoolAnd)
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (cb *CodeBlock) BoolEquals() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeBoolEquals)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) BoolLoadFalse() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeBoolLoadFalse)
 }
@@ -58,12 +125,36 @@ func (cb *CodeBlock) BoolNot() {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+func (cb *CodeBlock) BoolNotEquals() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeBoolNotEquals)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) BoolOr() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeBoolOr)
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// ConstraintCheck runs the predicate of the constraint type with given index against the value on top of the stack,
+// leaving the value in place. A value that does not satisfy the constraint is a runtime error naming the field with
+// given name, or else NoFieldName for a value not stored in a field.
+func (cb *CodeBlock) ConstraintCheck(typeIndex types.TypeIndex, fieldNameIndex pools.NameIndex) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeConstraintCheck)
+	cb.append64BitOperand(uint64(fieldNameIndex)<<32 | uint64(typeIndex))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// ConstraintValueLoad pushes "val", the value being checked by the predicate of a constraint, onto the stack. The value
+// sits at the bottom of the stack of the machine running the predicate.
+func (cb *CodeBlock) ConstraintValueLoad() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeConstraintValueLoad)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) Float64Add() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeFloat64Add)
 }
@@ -94,6 +185,14 @@ func (cb *CodeBlock) Float64GreaterThanOrEquals() {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// Float64InRange replaces a Float64 and a range of Float64 on top of the stack by whether the value lies within the
+// range.
+func (cb *CodeBlock) Float64InRange() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeFloat64InRange)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) Float64LessThan() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeFloat64LessThan)
 }
@@ -150,6 +249,33 @@ func (cb *CodeBlock) Float64Subtract() {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// FunctionCall calls the function whose value lies beneath the given number of arguments on top of the stack, leaving
+// its result on the stack in their place. The function is a field of the record under construction recordDepth records
+// out from the innermost one, which is where the fields named in the body of the function are found.
+func (cb *CodeBlock) FunctionCall(recordDepth uint64, argumentCount uint64) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeFunctionCall)
+	cb.append64BitOperand(recordDepth<<32 | argumentCount)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// FunctionLoad pushes the value of the function with given index, i.e. the index of its body among the functions of
+// the code block being run.
+func (cb *CodeBlock) FunctionLoad(functionIndex uint64) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeFunctionLoad)
+	cb.append64BitOperand(functionIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// FunctionParameterLoad pushes the argument given for the parameter with given index of the function being run.
+func (cb *CodeBlock) FunctionParameterLoad(parameterIndex uint64) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeFunctionParameterLoad)
+	cb.append64BitOperand(parameterIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) Int64Add() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeInt64Add)
 }
@@ -186,6 +312,13 @@ func (cb *CodeBlock) Int64GreaterThanOrEquals() {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// Int64InRange replaces an Int64 and a range of Int64 on top of the stack by whether the value lies within the range.
+func (cb *CodeBlock) Int64InRange() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeInt64InRange)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) Int64Increment() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeInt64Increment)
 }
@@ -247,12 +380,157 @@ func (cb *CodeBlock) Int64Subtract() {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// Jump continues with the op code at the position given by a later PatchJump. Returns the position of the jump.
+func (cb *CodeBlock) Jump() int {
+	jump := len(cb.OpCodes)
+	cb.OpCodes = append(cb.OpCodes, OpCodeJump)
+	cb.append64BitOperand(0)
+	return jump
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// JumpIfFalse pops the Bool on top of the stack and, if it is false, continues with the op code at the position given
+// by a later PatchJump. Returns the position of the jump.
+func (cb *CodeBlock) JumpIfFalse() int {
+	jump := len(cb.OpCodes)
+	cb.OpCodes = append(cb.OpCodes, OpCodeJumpIfFalse)
+	cb.append64BitOperand(0)
+	return jump
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// JumpIfFalseOrPop leaves a false Bool on top of the stack and continues with the op code at the position given by a
+// later PatchJump. Otherwise it pops the Bool and carries on. Returns the position of the jump.
+func (cb *CodeBlock) JumpIfFalseOrPop() int {
+	jump := len(cb.OpCodes)
+	cb.OpCodes = append(cb.OpCodes, OpCodeJumpIfFalseOrPop)
+	cb.append64BitOperand(0)
+	return jump
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// JumpIfTrueOrPop leaves a true Bool on top of the stack and continues with the op code at the position given by a
+// later PatchJump. Otherwise it pops the Bool and carries on. Returns the position of the jump.
+func (cb *CodeBlock) JumpIfTrueOrPop() int {
+	jump := len(cb.OpCodes)
+	cb.OpCodes = append(cb.OpCodes, OpCodeJumpIfTrueOrPop)
+	cb.append64BitOperand(0)
+	return jump
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) NoOp() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeNoOp)
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// OptionalEquals replaces the two optional values of given type on top of the stack by whether they are both none or
+// both present values that are equal.
+func (cb *CodeBlock) OptionalEquals(typeIndex types.TypeIndex) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeOptionalEquals)
+	cb.append64BitOperand(uint64(typeIndex))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// OptionalFieldReference is like RecordFieldReference for a record that may be none. The result is none when the
+// record is, otherwise the value of the field made optional.
+func (cb *CodeBlock) OptionalFieldReference() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeOptionalFieldReference)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// OptionalGetOrDefault replaces an optional value and a default value on top of the stack by the present value of the
+// optional one or else by the default value.
+func (cb *CodeBlock) OptionalGetOrDefault() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeOptionalGetOrDefault)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// OptionalLoadNone pushes the absent value "none" onto the stack.
+func (cb *CodeBlock) OptionalLoadNone() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeOptionalLoadNone)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// OptionalNotEquals is the negation of OptionalEquals.
+func (cb *CodeBlock) OptionalNotEquals(typeIndex types.TypeIndex) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeOptionalNotEquals)
+	cb.append64BitOperand(uint64(typeIndex))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// OptionalOrDefault is like OptionalGetOrDefault for a default value that is optional too, leaving the result
+// optional.
+func (cb *CodeBlock) OptionalOrDefault() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeOptionalOrDefault)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// OptionalWrap converts the value on top of the stack into a present value of an optional type.
+func (cb *CodeBlock) OptionalWrap() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeOptionalWrap)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// RangeEquals replaces the two ranges on top of the stack by whether they have the same bounds.
+func (cb *CodeBlock) RangeEquals() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeRangeEquals)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// RangeNotEquals is the negation of RangeEquals.
+func (cb *CodeBlock) RangeNotEquals() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeRangeNotEquals)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// RangeStore replaces the low and high bounds on top of the stack by a new range in the range pool.
+func (cb *CodeBlock) RangeStore() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeRangeStore)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// RecordAdd replaces the two records on top of the stack by their field-wise sum, a new record of given type.
+func (cb *CodeBlock) RecordAdd(typeIndex types.TypeIndex) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeRecordAdd)
+	cb.append64BitOperand(uint64(typeIndex))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// RecordBegin marks the record type just loaded on top of the stack as the start of a record under construction and
+// reserves stack slots for its fields. RecordFieldStore fills the slots in whatever order the fields are evaluated,
+// RecordFieldLoad reads them back meanwhile, and RecordStore ends the record.
+func (cb *CodeBlock) RecordBegin(fieldCount int) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeRecordBegin)
+	cb.append64BitOperand(uint64(fieldCount))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// RecordDivide replaces the two records on top of the stack by their field-wise quotient, a new record of given type.
+func (cb *CodeBlock) RecordDivide(typeIndex types.TypeIndex) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeRecordDivide)
+	cb.append64BitOperand(uint64(typeIndex))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) RecordEquals() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeRecordEquals)
 }
@@ -266,12 +544,46 @@ func (cb *CodeBlock) RecordFieldIndexLoad(fieldIndex uint64) {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// RecordFieldLoad loads the value of a field of a record under construction, counting recordDepth records out from the
+// innermost one.
+func (cb *CodeBlock) RecordFieldLoad(recordDepth uint64, fieldIndex uint64) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeRecordFieldLoad)
+	cb.append64BitOperand(recordDepth<<32 | fieldIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) RecordFieldReference() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeRecordFieldReference)
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// RecordFieldStore moves the value on top of the stack into the slot of a field of the innermost record under
+// construction.
+func (cb *CodeBlock) RecordFieldStore(fieldIndex uint64) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeRecordFieldStore)
+	cb.append64BitOperand(fieldIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// RecordMerge replaces the two records on top of the stack by their intersection, a new record of given type.
+func (cb *CodeBlock) RecordMerge(typeIndex types.TypeIndex) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeRecordMerge)
+	cb.append64BitOperand(uint64(typeIndex))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// RecordMultiply replaces the two records on top of the stack by their field-wise product, a new record of given type.
+func (cb *CodeBlock) RecordMultiply(typeIndex types.TypeIndex) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeRecordMultiply)
+	cb.append64BitOperand(uint64(typeIndex))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) RecordNotEquals() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeRecordNotEquals)
 }
@@ -285,6 +597,28 @@ func (cb *CodeBlock) RecordStore(fieldCount int) {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// RecordSubtract replaces the two records on top of the stack by their field-wise difference, a new record of given
+// type.
+func (cb *CodeBlock) RecordSubtract(typeIndex types.TypeIndex) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeRecordSubtract)
+	cb.append64BitOperand(uint64(typeIndex))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// PatchJump makes the jump at the given position continue with the op code to be added next.
+func (cb *CodeBlock) PatchJump(jump int) {
+	target := uint64(len(cb.OpCodes))
+	cb.OpCodes[jump+1] = uint16(target)
+	cb.OpCodes[jump+2] = uint16(target >> 16)
+	cb.OpCodes[jump+3] = uint16(target >> 32)
+	cb.OpCodes[jump+4] = uint16(target >> 48)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Return ends the body of a function, replacing its arguments and the function itself by the result on top of the
+// stack, and carries on with the code that called it.
 func (cb *CodeBlock) Return() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeReturn)
 }
@@ -327,6 +661,64 @@ func (cb *CodeBlock) StringEquals() {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// StringEqualsCollated replaces the two Strings on top of the stack by whether they are equal under the given
+// collation, e.g. ignoring case.
+func (cb *CodeBlock) StringEqualsCollated(collation collations.Collation) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeStringEqualsCollated)
+	cb.append64BitOperand(uint64(collation))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// StringFormat replaces a value of the built-in type with given index and a format spec on top of the stack by the
+// String formatted from the value. An invalid format spec is a runtime error.
+func (cb *CodeBlock) StringFormat(typeIndex types.TypeIndex) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeStringFormat)
+	cb.append64BitOperand(uint64(typeIndex))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// StringGreaterThan replaces the two Strings on top of the stack by whether the first one comes after the second
+// one in byte order.
+func (cb *CodeBlock) StringGreaterThan() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeStringGreaterThan)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// StringGreaterThanOrEquals replaces the two Strings on top of the stack by whether the first one comes after the
+// second one in byte order or equals it.
+func (cb *CodeBlock) StringGreaterThanOrEquals() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeStringGreaterThanOrEquals)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// StringInRange replaces a String and a range of String on top of the stack by whether the value lies within the
+// range.
+func (cb *CodeBlock) StringInRange() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeStringInRange)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// StringLessThan replaces the two Strings on top of the stack by whether the first one comes before the second one
+// in byte order.
+func (cb *CodeBlock) StringLessThan() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeStringLessThan)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// StringLessThanOrEquals replaces the two Strings on top of the stack by whether the first one comes before the
+// second one in byte order or equals it.
+func (cb *CodeBlock) StringLessThanOrEquals() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeStringLessThanOrEquals)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) StringLoad(valueIndex pools.StringIndex) {
 	cb.OpCodes = append(cb.OpCodes, OpCodeStringLoad)
 	cb.append64BitOperand(uint64(valueIndex))
@@ -334,12 +726,38 @@ func (cb *CodeBlock) StringLoad(valueIndex pools.StringIndex) {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// StringMatches replaces the String on top of the stack by whether the constant pattern at the given index of the
+// pattern pool matches somewhere within it.
+func (cb *CodeBlock) StringMatches(patternIndex pools.PatternIndex) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeStringMatches)
+	cb.append64BitOperand(uint64(patternIndex))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// StringMatchesDynamic replaces a String and a pattern computed at run time on top of the stack by whether the pattern
+// matches somewhere within the String. An invalid pattern is a runtime error.
+func (cb *CodeBlock) StringMatchesDynamic() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeStringMatchesDynamic)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) StringNotEquals() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeStringNotEquals)
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// TypeContains replaces the value and the type on top of the stack by whether the value is a value of the type. The
+// operand is the type the value is known to have.
+func (cb *CodeBlock) TypeContains(valueTypeIndex types.TypeIndex) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeTypeContains)
+	cb.append64BitOperand(uint64(valueTypeIndex))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) TypeEquals() {
 	cb.OpCodes = append(cb.OpCodes, OpCodeTypeEquals)
 }
@@ -359,6 +777,37 @@ func (cb *CodeBlock) TypeNotEquals() {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// UnionEquals replaces the two tagged union values on top of the stack by whether they are values of the same type
+// that are equal.
+func (cb *CodeBlock) UnionEquals() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeUnionEquals)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// UnionNotEquals is the negation of UnionEquals.
+func (cb *CodeBlock) UnionNotEquals() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeUnionNotEquals)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// UnionWrap converts the value on top of the stack into a value of a union whose members have different base types,
+// tagging it with the given base type of the value.
+func (cb *CodeBlock) UnionWrap(baseTypeIndex types.TypeIndex) {
+	cb.OpCodes = append(cb.OpCodes, OpCodeUnionWrap)
+	cb.append64BitOperand(uint64(baseTypeIndex))
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// WhenUnmatched raises a runtime error for a "when" chain none of whose guards is true.
+func (cb *CodeBlock) WhenUnmatched() {
+	cb.OpCodes = append(cb.OpCodes, OpCodeWhenUnmatched)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func (cb *CodeBlock) append64BitOperand(bits uint64) {
 	cb.OpCodes = append(cb.OpCodes, uint16(bits))
 	cb.OpCodes = append(cb.OpCodes, uint16(bits>>16))
@@ -378,24 +827,49 @@ func (cb *CodeBlock) Disassemble(
 
 	ip := 0
 
-	for {
+	for ip < len(cb.OpCodes) {
 
 		opCode := cb.OpCodes[ip]
 		ip += 1
 
 		switch opCode {
 
+		case OpCodeArrayContains:
+			write(output, ip, "ARRAY_CONTAINS")
+		case OpCodeArrayEquals:
+			write(output, ip, "ARRAY_EQUALS")
+		case OpCodeArrayIndex:
+			write(output, ip, "ARRAY_INDEX")
+		case OpCodeArrayLength:
+			write(output, ip, "ARRAY_LENGTH")
+		case OpCodeArrayNotEquals:
+			write(output, ip, "ARRAY_NOT_EQUALS")
+		case OpCodeArrayStore:
+			writeUInt64(output, ip, "ARRAY_STORE", uint64(cb.OpCodes[ip]))
+			ip += 4
+
 		case OpCodeBoolAnd:
 			write(output, ip, "BOOL_AND")
+		case OpCodeBoolEquals:
+			write(output, ip, "BOOL_EQUALS")
 		case OpCodeBoolLoadFalse:
 			write(output, ip, "BOOL_LOAD_FALSE")
 		case OpCodeBoolLoadTrue:
 			write(output, ip, "BOOL_LOAD_TRUE")
 		case OpCodeBoolNot:
 			write(output, ip, "BOOL_NOT")
+		case OpCodeBoolNotEquals:
+			write(output, ip, "BOOL_NOT_EQUALS")
 		case OpCodeBoolOr:
 			write(output, ip, "BOOL_OR")
 
+		case OpCodeConstraintCheck:
+			operand := *(*uint64)(unsafe.Pointer(&cb.OpCodes[ip]))
+			writeType(output, ip, "CONSTRAINT_CHECK", typePool.Get(types.TypeIndex(operand&0xFFFFFFFF)))
+			ip += 4
+		case OpCodeConstraintValueLoad:
+			write(output, ip, "CONSTRAINT_VAL_LOAD")
+
 		case OpCodeFloat64Add:
 			write(output, ip, "FLOAT64_ADD")
 		case OpCodeFloat64Divide:
@@ -406,6 +880,8 @@ func (cb *CodeBlock) Disassemble(
 			write(output, ip, "FLOAT64_GREATER")
 		case OpCodeFloat64GreaterThanOrEquals:
 			write(output, ip, "FLOAT64_NOT_LESS")
+		case OpCodeFloat64InRange:
+			write(output, ip, "FLOAT64_IN_RANGE")
 		case OpCodeFloat64LessThan:
 			write(output, ip, "FLOAT64_LESS")
 		case OpCodeFloat64LessThanOrEquals:
@@ -425,6 +901,19 @@ func (cb *CodeBlock) Disassemble(
 		case OpCodeFloat64Subtract:
 			write(output, ip, "FLOAT64_SUBTRACT")
 
+		case OpCodeFunctionCall:
+			operand := *(*uint64)(unsafe.Pointer(&cb.OpCodes[ip]))
+			writeUInt64Pair(output, ip, "FUNCTION_CALL", operand>>32, operand&0xFFFFFFFF)
+			ip += 4
+		case OpCodeFunctionLoad:
+			functionIndex := *(*uint64)(unsafe.Pointer(&cb.OpCodes[ip]))
+			writeUInt64(output, ip, "FUNCTION_LOAD", functionIndex)
+			ip += 4
+		case OpCodeFunctionParameterLoad:
+			parameterIndex := *(*uint64)(unsafe.Pointer(&cb.OpCodes[ip]))
+			writeUInt64(output, ip, "FUNCTION_PARAM_LOAD", parameterIndex)
+			ip += 4
+
 		case OpCodeInt64Add:
 			write(output, ip, "INT64_ADD")
 		case OpCodeInt64Decrement:
@@ -437,6 +926,8 @@ func (cb *CodeBlock) Disassemble(
 			write(output, ip, "INT64_GREATER")
 		case OpCodeInt64GreaterThanOrEquals:
 			write(output, ip, "INT64_NOT_LESS")
+		case OpCodeInt64InRange:
+			write(output, ip, "INT64_IN_RANGE")
 		case OpCodeInt64Increment:
 			write(output, ip, "INT64_INCREMENT")
 		case OpCodeInt64LessThan:
@@ -458,20 +949,89 @@ func (cb *CodeBlock) Disassemble(
 		case OpCodeInt64Subtract:
 			write(output, ip, "INT64_SUBTRACT")
 
+		// Jump targets are shown as the line of the op code jumped to
+		case OpCodeJump:
+			target := *(*uint64)(unsafe.Pointer(&cb.OpCodes[ip]))
+			writeUInt64(output, ip, "JUMP", target+1)
+			ip += 4
+		case OpCodeJumpIfFalse:
+			target := *(*uint64)(unsafe.Pointer(&cb.OpCodes[ip]))
+			writeUInt64(output, ip, "JUMP_IF_FALSE", target+1)
+			ip += 4
+		case OpCodeJumpIfFalseOrPop:
+			target := *(*uint64)(unsafe.Pointer(&cb.OpCodes[ip]))
+			writeUInt64(output, ip, "JUMP_IF_FALSE_OR_POP", target+1)
+			ip += 4
+		case OpCodeJumpIfTrueOrPop:
+			target := *(*uint64)(unsafe.Pointer(&cb.OpCodes[ip]))
+			writeUInt64(output, ip, "JUMP_IF_TRUE_OR_POP", target+1)
+			ip += 4
+
 		case OpCodeNoOp:
 			write(output, ip, "NO_OP")
 
+		case OpCodeOptionalEquals:
+			writeType(output, ip, "OPTIONAL_EQUALS", typePool.Get(types.TypeIndex(cb.OpCodes[ip])))
+			ip += 4
+		case OpCodeOptionalFieldReference:
+			write(output, ip, "OPTIONAL_FLD_REF")
+		case OpCodeOptionalGetOrDefault:
+			write(output, ip, "OPTIONAL_GET_OR_DEFAULT")
+		case OpCodeOptionalLoadNone:
+			write(output, ip, "OPTIONAL_LOAD_NONE")
+		case OpCodeOptionalNotEquals:
+			writeType(output, ip, "OPTIONAL_NOT_EQUALS", typePool.Get(types.TypeIndex(cb.OpCodes[ip])))
+			ip += 4
+		case OpCodeOptionalOrDefault:
+			write(output, ip, "OPTIONAL_OR_DEFAULT")
+		case OpCodeOptionalWrap:
+			write(output, ip, "OPTIONAL_WRAP")
+
+		case OpCodeRangeEquals:
+			write(output, ip, "RANGE_EQUALS")
+		case OpCodeRangeNotEquals:
+			write(output, ip, "RANGE_NOT_EQUALS")
+		case OpCodeRangeStore:
+			write(output, ip, "RANGE_STORE")
+
+		case OpCodeRecordAdd:
+			writeType(output, ip, "RECORD_ADD", typePool.Get(types.TypeIndex(cb.OpCodes[ip])))
+			ip += 4
+		case OpCodeRecordBegin:
+			fieldCount := *(*uint64)(unsafe.Pointer(&cb.OpCodes[ip]))
+			writeUInt64(output, ip, "RECORD_BEGIN", fieldCount)
+			ip += 4
+		case OpCodeRecordDivide:
+			writeType(output, ip, "RECORD_DIVIDE", typePool.Get(types.TypeIndex(cb.OpCodes[ip])))
+			ip += 4
 		case OpCodeRecordEquals:
 			write(output, ip, "RECORD_EQUALS")
 		case OpCodeRecordFieldIndexLoad:
 			fieldIndex := *(*uint64)(unsafe.Pointer(&cb.OpCodes[ip]))
 			writeUInt64(output, ip, "RECORD_FLD_IDX_LOAD", fieldIndex)
 			ip += 4
+		case OpCodeRecordFieldLoad:
+			operand := *(*uint64)(unsafe.Pointer(&cb.OpCodes[ip]))
+			writeUInt64Pair(output, ip, "RECORD_FLD_LOAD", operand>>32, operand&0xFFFFFFFF)
+			ip += 4
+		case OpCodeRecordFieldStore:
+			fieldIndex := *(*uint64)(unsafe.Pointer(&cb.OpCodes[ip]))
+			writeUInt64(output, ip, "RECORD_FLD_STORE", fieldIndex)
+			ip += 4
+		case OpCodeRecordMerge:
+			writeType(output, ip, "RECORD_MERGE", typePool.Get(types.TypeIndex(cb.OpCodes[ip])))
+			ip += 4
+		case OpCodeRecordMultiply:
+			writeType(output, ip, "RECORD_MULTIPLY", typePool.Get(types.TypeIndex(cb.OpCodes[ip])))
+			ip += 4
 		case OpCodeRecordNotEquals:
 			write(output, ip, "RECORD_NOT_EQUALS")
 		case OpCodeRecordStore:
 			writeUInt64(output, ip, "RECORD_STORE", uint64(cb.OpCodes[ip]))
 			ip += 4
+		case OpCodeRecordSubtract:
+			writeType(output, ip, "RECORD_SUBTRACT", typePool.Get(types.TypeIndex(cb.OpCodes[ip])))
+			ip += 4
 
 		case OpCodeReturn:
 			write(output, ip, "RETURN")
@@ -491,11 +1051,37 @@ func (cb *CodeBlock) Disassemble(
 			write(output, ip, "STRING_CONCATENATE")
 		case OpCodeStringEquals:
 			write(output, ip, "STRING_EQUALS")
+		case OpCodeStringEqualsCollated:
+			collation := *(*collations.Collation)(unsafe.Pointer(&cb.OpCodes[ip]))
+			writeString(output, ip, "STRING_EQUALS_COLLATED", collation.String())
+			ip += 4
+		case OpCodeStringFormat:
+			writeType(output, ip, "STRING_FORMAT", typePool.Get(types.TypeIndex(cb.OpCodes[ip])))
+			ip += 4
+		case OpCodeStringGreaterThan:
+			write(output, ip, "STRING_GREATER")
+		case OpCodeStringGreaterThanOrEquals:
+			write(output, ip, "STRING_NOT_LESS")
+		case OpCodeStringInRange:
+			write(output, ip, "STRING_IN_RANGE")
+		case OpCodeStringLessThan:
+			write(output, ip, "STRING_LESS")
+		case OpCodeStringLessThanOrEquals:
+			write(output, ip, "STRING_NOT_GREATER")
 		case OpCodeStringLoad:
 			valueIndex := *(*pools.StringIndex)(unsafe.Pointer(&cb.OpCodes[ip]))
 			writeString(output, ip, "STRING_LOAD", stringPool.Get(valueIndex))
 			ip += 4
+		case OpCodeStringMatches:
+			patternIndex := *(*pools.PatternIndex)(unsafe.Pointer(&cb.OpCodes[ip]))
+			writeString(output, ip, "STRING_MATCHES", cb.Patterns.Get(patternIndex).String())
+			ip += 4
+		case OpCodeStringMatchesDynamic:
+			write(output, ip, "STRING_MATCHES_DYNAMIC")
 
+		case OpCodeTypeContains:
+			writeType(output, ip, "TYPE_CONTAINS", typePool.Get(types.TypeIndex(cb.OpCodes[ip])))
+			ip += 4
 		case OpCodeTypeEquals:
 			write(output, ip, "TYPE_EQUALS")
 		case OpCodeTypeLoad:
@@ -504,10 +1090,24 @@ func (cb *CodeBlock) Disassemble(
 		case OpCodeTypeNotEquals:
 			write(output, ip, "TYPE_NOT_EQUALS")
 
+		case OpCodeUnionEquals:
+			write(output, ip, "UNION_EQUALS")
+		case OpCodeUnionNotEquals:
+			write(output, ip, "UNION_NOT_EQUALS")
+		case OpCodeUnionWrap:
+			writeType(output, ip, "UNION_WRAP", typePool.Get(types.TypeIndex(cb.OpCodes[ip])))
+			ip += 4
+
+		case OpCodeWhenUnmatched:
+			write(output, ip, "WHEN_UNMATCHED")
+
 		}
 
 	}
 
+	// The body of a function ends with a return instead of a stop
+	return output.String() + "\n"
+
 }
 
 // ---------------------------------------------------------------------------------------------------------------------
@@ -552,4 +1152,11 @@ func writeUInt64(output *strings.Builder, line int, opCode string, operand uint6
 	output.WriteString(fmt.Sprintf("%4d  %-20s %6d", line, opCode, operand))
 }
 
+//---------------------------------------------------------------------------------------------------------------------
+
+func writeUInt64Pair(output *strings.Builder, line int, opCode string, operand1 uint64, operand2 uint64) {
+	output.WriteString("\n")
+	output.WriteString(fmt.Sprintf("%4d  %-20s %6d %6d", line, opCode, operand1, operand2))
+}
+
 //=====================================================================================================================
@@ -9,6 +9,7 @@ package bytecode
 
 import (
 	"github.com/stretchr/testify/assert"
+	"lligne-cli/internal/lligne/runtime/collations"
 	"lligne-cli/internal/lligne/runtime/pools"
 	"lligne-cli/internal/lligne/runtime/types"
 	"testing"
@@ -69,9 +70,12 @@ func TestCodeBlockDisassembly(t *testing.T) {
 		codeBlock.TypeEquals()
 		codeBlock.TypeNotEquals()
 
+		codeBlock.RecordBegin(5)
 		codeBlock.RecordStore(5)
 		codeBlock.RecordEquals()
 		codeBlock.RecordFieldIndexLoad(17)
+		codeBlock.RecordFieldLoad(1, 2)
+		codeBlock.RecordFieldStore(3)
 		codeBlock.RecordNotEquals()
 
 		codeBlock.StackPop()
@@ -130,15 +134,218 @@ func TestCodeBlockDisassembly(t *testing.T) {
   67  TYPE_LOAD            String
   72  TYPE_EQUALS
   73  TYPE_NOT_EQUALS
-  74  RECORD_STORE              5
-  79  RECORD_EQUALS
-  80  RECORD_FLD_IDX_LOAD      17
-  85  RECORD_NOT_EQUALS
-  86  STACK_POP
-  87  STACK_POP_SECOND
-  88  STACK_SWAP_TOP_TWO
-  89  RETURN
-  90  STOP
+  74  RECORD_BEGIN              5
+  79  RECORD_STORE              5
+  84  RECORD_EQUALS
+  85  RECORD_FLD_IDX_LOAD      17
+  90  RECORD_FLD_LOAD           1      2
+  95  RECORD_FLD_STORE          3
+ 100  RECORD_NOT_EQUALS
+ 101  STACK_POP
+ 102  STACK_POP_SECOND
+ 103  STACK_SWAP_TOP_TWO
+ 104  RETURN
+ 105  STOP
+`
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("function output", func(t *testing.T) {
+		typePool := types.NewTypePool().Freeze()
+
+		codeBlock := NewCodeBlock()
+
+		codeBlock.FunctionParameterLoad(0)
+		codeBlock.FunctionLoad(2)
+		codeBlock.FunctionParameterLoad(1)
+		codeBlock.FunctionCall(1, 1)
+		codeBlock.Int64Multiply()
+		codeBlock.Return()
+
+		actual := codeBlock.Disassemble(pools.NewStringPool(), typePool)
+
+		expected :=
+			`
+   1  FUNCTION_PARAM_LOAD       0
+   6  FUNCTION_LOAD             2
+  11  FUNCTION_PARAM_LOAD       1
+  16  FUNCTION_CALL             1      1
+  21  INT64_MULTIPLY
+  22  RETURN
+`
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("short-circuit output", func(t *testing.T) {
+		typePool := types.NewTypePool().Freeze()
+
+		codeBlock := NewCodeBlock()
+
+		codeBlock.BoolLoadFalse()
+		jumpPastAnd := codeBlock.JumpIfFalseOrPop()
+		codeBlock.BoolLoadTrue()
+		codeBlock.PatchJump(jumpPastAnd)
+		jumpPastOr := codeBlock.JumpIfTrueOrPop()
+		codeBlock.BoolLoadFalse()
+		codeBlock.PatchJump(jumpPastOr)
+		codeBlock.Stop()
+
+		actual := codeBlock.Disassemble(pools.NewStringPool(), typePool)
+
+		expected :=
+			`
+   1  BOOL_LOAD_FALSE
+   2  JUMP_IF_FALSE_OR_POP      8
+   7  BOOL_LOAD_TRUE
+   8  JUMP_IF_TRUE_OR_POP      14
+  13  BOOL_LOAD_FALSE
+  14  STOP
+`
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("pattern output", func(t *testing.T) {
+		typePool := types.NewTypePool().Freeze()
+		stringPool := pools.NewStringPool()
+
+		codeBlock := NewCodeBlock()
+		patternIndex, _ := codeBlock.Patterns.Put("^[a-z]+$")
+
+		codeBlock.StringLoad(stringPool.Put("abc"))
+		codeBlock.StringMatches(patternIndex)
+		codeBlock.StringLoad(stringPool.Put("abc"))
+		codeBlock.StringLoad(stringPool.Put("b"))
+		codeBlock.StringMatchesDynamic()
+		codeBlock.Stop()
+
+		actual := codeBlock.Disassemble(stringPool, typePool)
+
+		expected :=
+			`
+   1  STRING_LOAD          'abc'
+   6  STRING_MATCHES       '^[a-z]+$'
+  11  STRING_LOAD          'abc'
+  16  STRING_LOAD          'b'
+  21  STRING_MATCHES_DYNAMIC
+  22  STOP
+`
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("collated comparison output", func(t *testing.T) {
+		typePool := types.NewTypePool().Freeze()
+		stringPool := pools.NewStringPool()
+
+		codeBlock := NewCodeBlock()
+
+		codeBlock.StringLoad(stringPool.Put("PROD"))
+		codeBlock.StringLoad(stringPool.Put("prod"))
+		codeBlock.StringEqualsCollated(collations.CollationIgnoreCase | collations.CollationNFC)
+		codeBlock.Stop()
+
+		actual := codeBlock.Disassemble(stringPool, typePool)
+
+		expected :=
+			`
+   1  STRING_LOAD          'PROD'
+   6  STRING_LOAD          'prod'
+  11  STRING_EQUALS_COLLATED 'Aa,NFC'
+  16  STOP
+`
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("format output", func(t *testing.T) {
+		typePool := types.NewTypePool().Freeze()
+		stringPool := pools.NewStringPool()
+
+		codeBlock := NewCodeBlock()
+
+		codeBlock.Int64Load(255)
+		codeBlock.StringLoad(stringPool.Put("%04x"))
+		codeBlock.StringFormat(types.BuiltInTypeIndexInt64)
+		codeBlock.Stop()
+
+		actual := codeBlock.Disassemble(stringPool, typePool)
+
+		expected :=
+			`
+   1  INT64_LOAD              255
+   6  STRING_LOAD          '%04x'
+  11  STRING_FORMAT        Int64
+  16  STOP
+`
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("range output", func(t *testing.T) {
+		typePool := types.NewTypePool().Freeze()
+		stringPool := pools.NewStringPool()
+
+		codeBlock := NewCodeBlock()
+
+		codeBlock.Int64Load(5)
+		codeBlock.Int64LoadOne()
+		codeBlock.Int64Load(10)
+		codeBlock.RangeStore()
+		codeBlock.RangeEquals()
+		codeBlock.RangeNotEquals()
+		codeBlock.Int64InRange()
+		codeBlock.Float64InRange()
+		codeBlock.StringInRange()
+		codeBlock.ArrayContains()
+		codeBlock.Stop()
+
+		actual := codeBlock.Disassemble(stringPool, typePool)
+
+		expected :=
+			`
+   1  INT64_LOAD                5
+   6  INT64_LOAD_ONE
+   7  INT64_LOAD               10
+  12  RANGE_STORE
+  13  RANGE_EQUALS
+  14  RANGE_NOT_EQUALS
+  15  INT64_IN_RANGE
+  16  FLOAT64_IN_RANGE
+  17  STRING_IN_RANGE
+  18  ARRAY_CONTAINS
+  19  STOP
+`
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("jump output", func(t *testing.T) {
+		typePool := types.NewTypePool().Freeze()
+
+		codeBlock := NewCodeBlock()
+
+		codeBlock.BoolLoadTrue()
+		jumpToNext := codeBlock.JumpIfFalse()
+		codeBlock.Int64LoadOne()
+		jumpToEnd := codeBlock.Jump()
+		codeBlock.PatchJump(jumpToNext)
+		codeBlock.WhenUnmatched()
+		codeBlock.PatchJump(jumpToEnd)
+		codeBlock.Stop()
+
+		actual := codeBlock.Disassemble(pools.NewStringPool(), typePool)
+
+		expected :=
+			`
+   1  BOOL_LOAD_TRUE
+   2  JUMP_IF_FALSE            13
+   7  INT64_LOAD_ONE
+   8  JUMP                     14
+  13  WHEN_UNMATCHED
+  14  STOP
 `
 
 		assert.Equal(t, expected, actual)
@@ -7,20 +7,36 @@ package bytecode
 
 import (
 	"fmt"
+	"lligne-cli/internal/lligne/runtime/arrays"
+	"lligne-cli/internal/lligne/runtime/collations"
+	"lligne-cli/internal/lligne/runtime/formats"
+	"lligne-cli/internal/lligne/runtime/optionals"
 	"lligne-cli/internal/lligne/runtime/pools"
+	"lligne-cli/internal/lligne/runtime/ranges"
 	"lligne-cli/internal/lligne/runtime/records"
 	"lligne-cli/internal/lligne/runtime/types"
+	"lligne-cli/internal/lligne/runtime/unions"
 	"math"
+	"strconv"
 	"unsafe"
 )
 
 //=====================================================================================================================
 
 type Interpreter struct {
-	codeBlock  *CodeBlock
-	recordPool *records.RecordPool
-	stringPool *pools.StringPool
-	typePool   *types.TypePool
+	arrayPool       *arrays.ArrayPool
+	codeBlock       *CodeBlock
+	constraints     map[types.TypeIndex]*CodeBlock
+	dynamicPatterns *pools.PatternPool // Patterns compiled while running, leaving the code block's patterns unchanged
+	fieldNames      *pools.NameConstantPool
+	functions       []*CodeBlock
+	optionalPool    *optionals.OptionalPool
+	patternPool     *pools.PatternPool
+	rangePool       *ranges.RangePool
+	recordPool      *records.RecordPool
+	stringPool      *pools.StringPool
+	typePool        *types.TypePool
+	unionPool       *unions.UnionPool
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -31,15 +47,59 @@ func NewInterpreter(
 	typePool *types.TypePool,
 ) *Interpreter {
 	return &Interpreter{
-		codeBlock:  codeBlock,
-		recordPool: records.NewRecordPool(),
-		stringPool: stringPool,
-		typePool:   typePool,
+		arrayPool:       arrays.NewArrayPool(),
+		codeBlock:       codeBlock,
+		constraints:     codeBlock.Constraints,
+		dynamicPatterns: pools.NewPatternPool(),
+		fieldNames:      codeBlock.FieldNames,
+		functions:       codeBlock.Functions,
+		optionalPool:    optionals.NewOptionalPool(),
+		patternPool:     codeBlock.Patterns,
+		rangePool:       ranges.NewRangePool(),
+		recordPool:      records.NewRecordPool(),
+		stringPool:      stringPool,
+		typePool:        typePool,
+		unionPool:       unions.NewUnionPool(),
 	}
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// GetArrayPool returns the pool of arrays created while executing the code block.
+func (n *Interpreter) GetArrayPool() *arrays.ArrayPool {
+	return n.arrayPool
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// GetOptionalPool returns the pool of present optional values created while executing the code block.
+func (n *Interpreter) GetOptionalPool() *optionals.OptionalPool {
+	return n.optionalPool
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// GetRangePool returns the pool of ranges created while executing the code block.
+func (n *Interpreter) GetRangePool() *ranges.RangePool {
+	return n.rangePool
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// GetRecordPool returns the pool of records created while executing the code block.
+func (n *Interpreter) GetRecordPool() *records.RecordPool {
+	return n.recordPool
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// GetUnionPool returns the pool of tagged union values created while executing the code block.
+func (n *Interpreter) GetUnionPool() *unions.UnionPool {
+	return n.unionPool
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 // Execute runs the op code of the given code block within the given machine.
 func (n *Interpreter) Execute(machine *Machine) {
 
@@ -52,6 +112,11 @@ func (n *Interpreter) Execute(machine *Machine) {
 
 		dispatch[opCode](n, machine)
 
+		// Each op code pushes at most one value, so keep room for the next one and the marker below
+		if machine.Top >= len(machine.Stack)-2 {
+			machine.GrowStack(1)
+		}
+
 		// for debugging
 		machine.Stack[machine.Top+1] = 9999999999
 
@@ -59,6 +124,78 @@ func (n *Interpreter) Execute(machine *Machine) {
 
 }
 
+//---------------------------------------------------------------------------------------------------------------------
+
+// SatisfiesConstraint runs the predicate of the constraint type with given index against the given value.
+func (n *Interpreter) SatisfiesConstraint(typeIndex types.TypeIndex, value uint64) bool {
+
+	// The predicate runs in a machine of its own, sharing this interpreter's pools, with the value at the bottom
+	predicate := &Interpreter{
+		arrayPool:       n.arrayPool,
+		codeBlock:       n.constraints[typeIndex],
+		constraints:     n.constraints,
+		dynamicPatterns: n.dynamicPatterns,
+		fieldNames:      n.fieldNames,
+		functions:       n.functions,
+		optionalPool:    n.optionalPool,
+		patternPool:     n.patternPool,
+		rangePool:       n.rangePool,
+		recordPool:      n.recordPool,
+		stringPool:      n.stringPool,
+		typePool:        n.typePool,
+		unionPool:       n.unionPool,
+	}
+	machine := NewMachine()
+	machine.Top = 0
+	machine.Stack[0] = value
+
+	predicate.Execute(machine)
+
+	return machine.BoolGetResult()
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// checkConstraint panics with a runtime error when the given value does not satisfy the constraint type with given
+// index. The error shows the value and names the field it is for, unless the name is NoFieldName.
+func (n *Interpreter) checkConstraint(typeIndex types.TypeIndex, fieldNameIndex pools.NameIndex, value uint64) {
+
+	if n.SatisfiesConstraint(typeIndex, value) {
+		return
+	}
+
+	constraintName := n.typePool.Get(typeIndex).Name()
+
+	if fieldNameIndex == NoFieldName {
+		panic(fmt.Sprintf("Value %s does not satisfy the constraint '%s'",
+			n.FormatValue(typeIndex, value), constraintName))
+	}
+
+	panic(fmt.Sprintf("Value %s of field '%s' does not satisfy the constraint '%s'",
+		n.FormatValue(typeIndex, value), n.fieldNames.Get(fieldNameIndex), constraintName))
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// FormatValue describes a value of the given type for an error message, as it would be written in source code for a
+// Bool, Float64, Int64, or String and by its type otherwise.
+func (n *Interpreter) FormatValue(typeIndex types.TypeIndex, value uint64) string {
+	switch baseTypeIndex := n.typePool.BaseTypeIndex(typeIndex); baseTypeIndex {
+	case types.BuiltInTypeIndexBool:
+		return strconv.FormatBool(value != 0)
+	case types.BuiltInTypeIndexFloat64:
+		return strconv.FormatFloat(math.Float64frombits(value), 'g', -1, 64)
+	case types.BuiltInTypeIndexInt64:
+		return strconv.FormatInt(int64(value), 10)
+	case types.BuiltInTypeIndexString:
+		return strconv.Quote(n.stringPool.Get(pools.StringIndex(value)))
+	default:
+		return "<" + n.typePool.Get(baseTypeIndex).Name() + ">"
+	}
+}
+
 //=====================================================================================================================
 
 const true64 uint64 = 0xFFFFFFFFFFFFFFFF
@@ -72,6 +209,79 @@ var dispatch [OpCode_Count]func(*Interpreter, *Machine)
 
 func init() {
 
+	dispatch[OpCodeArrayContains] = func(n *Interpreter, m *Machine) {
+		array := n.arrayPool.Get(m.Stack[m.Top])
+		m.Top -= 1
+		value := m.Stack[m.Top]
+
+		elementTypeIndex := n.typePool.Get(array.TypeIndex).(*types.ArrayType).ElementTypeIndex
+		m.Stack[m.Top] = 0
+		for _, element := range array.Elements {
+			if n.areValuesEqual(elementTypeIndex, element, value) {
+				m.Stack[m.Top] = true64
+				break
+			}
+		}
+	}
+
+	dispatch[OpCodeArrayEquals] = func(n *Interpreter, m *Machine) {
+		arrayIndexRhs := m.Stack[m.Top]
+		m.Top -= 1
+		arrayIndexLhs := m.Stack[m.Top]
+
+		if arrays.AreArraysEqual(n.typePool, n.arrayPool, arrayIndexLhs, arrayIndexRhs, n.areValuesEqual) {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
+	dispatch[OpCodeArrayIndex] = func(n *Interpreter, m *Machine) {
+		index := int64(m.Stack[m.Top])
+		m.Top -= 1
+		array := n.arrayPool.Get(m.Stack[m.Top])
+
+		if index < 0 || index >= int64(len(array.Elements)) {
+			panic(fmt.Sprintf("Array index %d out of bounds for length %d", index, len(array.Elements)))
+		}
+
+		m.Stack[m.Top] = array.Elements[index]
+	}
+
+	dispatch[OpCodeArrayLength] = func(n *Interpreter, m *Machine) {
+		m.Stack[m.Top] = uint64(len(n.arrayPool.Get(m.Stack[m.Top]).Elements))
+	}
+
+	dispatch[OpCodeArrayNotEquals] = func(n *Interpreter, m *Machine) {
+		arrayIndexRhs := m.Stack[m.Top]
+		m.Top -= 1
+		arrayIndexLhs := m.Stack[m.Top]
+
+		if arrays.AreArraysEqual(n.typePool, n.arrayPool, arrayIndexLhs, arrayIndexRhs, n.areValuesEqual) {
+			m.Stack[m.Top] = 0
+		} else {
+			m.Stack[m.Top] = true64
+		}
+	}
+
+	dispatch[OpCodeArrayStore] = func(n *Interpreter, m *Machine) {
+		elementCount := *(*int)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP]))
+		m.IP += 4
+
+		typeIndex := types.TypeIndex(m.Stack[m.Top-elementCount])
+
+		elements := make([]uint64, elementCount)
+		copy(elements, m.Stack[m.Top-elementCount+1:m.Top+1])
+
+		array := arrays.Array{
+			TypeIndex: typeIndex,
+			Elements:  elements,
+		}
+
+		m.Top -= elementCount
+		m.Stack[m.Top] = n.arrayPool.Put(array)
+	}
+
 	dispatch[OpCodeBoolAnd] = func(n *Interpreter, m *Machine) {
 		rhs := m.Stack[m.Top] != 0
 		m.Top -= 1
@@ -83,6 +293,17 @@ func init() {
 		}
 	}
 
+	dispatch[OpCodeBoolEquals] = func(n *Interpreter, m *Machine) {
+		rhs := m.Stack[m.Top] != 0
+		m.Top -= 1
+		lhs := m.Stack[m.Top] != 0
+		if lhs == rhs {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
 	dispatch[OpCodeBoolLoadFalse] = func(n *Interpreter, m *Machine) {
 		m.Top += 1
 		m.Stack[m.Top] = 0
@@ -101,6 +322,17 @@ func init() {
 		}
 	}
 
+	dispatch[OpCodeBoolNotEquals] = func(n *Interpreter, m *Machine) {
+		rhs := m.Stack[m.Top] != 0
+		m.Top -= 1
+		lhs := m.Stack[m.Top] != 0
+		if lhs == rhs {
+			m.Stack[m.Top] = 0
+		} else {
+			m.Stack[m.Top] = true64
+		}
+	}
+
 	dispatch[OpCodeBoolOr] = func(n *Interpreter, m *Machine) {
 		rhs := m.Stack[m.Top] != 0
 		m.Top -= 1
@@ -112,6 +344,21 @@ func init() {
 		}
 	}
 
+	dispatch[OpCodeConstraintCheck] = func(n *Interpreter, m *Machine) {
+		operand := *(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP]))
+		m.IP += 4
+
+		typeIndex := types.TypeIndex(operand & 0xFFFFFFFF)
+		fieldNameIndex := pools.NameIndex(operand >> 32)
+
+		n.checkConstraint(typeIndex, fieldNameIndex, m.Stack[m.Top])
+	}
+
+	dispatch[OpCodeConstraintValueLoad] = func(n *Interpreter, m *Machine) {
+		m.Top += 1
+		m.Stack[m.Top] = m.Stack[0]
+	}
+
 	dispatch[OpCodeFloat64Add] = func(n *Interpreter, m *Machine) {
 		rhs := math.Float64frombits(m.Stack[m.Top])
 		m.Top -= 1
@@ -159,6 +406,17 @@ func init() {
 		}
 	}
 
+	dispatch[OpCodeFloat64InRange] = func(n *Interpreter, m *Machine) {
+		r := n.rangePool.Get(m.Stack[m.Top])
+		m.Top -= 1
+		value := math.Float64frombits(m.Stack[m.Top])
+		if math.Float64frombits(r.Low) <= value && value <= math.Float64frombits(r.High) {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
 	dispatch[OpCodeFloat64LessThan] = func(n *Interpreter, m *Machine) {
 		rhs := math.Float64frombits(m.Stack[m.Top])
 		m.Top -= 1
@@ -226,6 +484,47 @@ func init() {
 		m.Stack[m.Top] = math.Float64bits(lhs - rhs)
 	}
 
+	dispatch[OpCodeFunctionCall] = func(n *Interpreter, m *Machine) {
+		operand := *(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP]))
+		m.IP += 4
+
+		recordDepth := int(operand >> 32)
+		argumentCount := int(operand & 0xFFFFFFFF)
+
+		if m.FramesTop == len(m.Frames)-1 {
+			m.GrowFrames()
+		}
+
+		base := m.Top - argumentCount + 1
+		functionIndex := m.Stack[base-1]
+
+		m.FramesTop += 1
+		m.Frames[m.FramesTop] = CallFrame{
+			CodeBlock:    n.codeBlock,
+			IP:           m.IP,
+			Base:         base,
+			RecordDepth:  recordDepth,
+			RecordsFloor: m.RecordsTop,
+		}
+
+		n.codeBlock = n.functions[functionIndex]
+		m.IP = 0
+	}
+
+	dispatch[OpCodeFunctionLoad] = func(n *Interpreter, m *Machine) {
+		m.Top += 1
+		m.Stack[m.Top] = *(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP]))
+		m.IP += 4
+	}
+
+	dispatch[OpCodeFunctionParameterLoad] = func(n *Interpreter, m *Machine) {
+		parameterIndex := *(*int)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP]))
+		m.IP += 4
+
+		m.Top += 1
+		m.Stack[m.Top] = m.Stack[m.Frames[m.FramesTop].Base+parameterIndex]
+	}
+
 	dispatch[OpCodeInt64Add] = func(n *Interpreter, m *Machine) {
 		rhs := int64(m.Stack[m.Top])
 		m.Top -= 1
@@ -241,6 +540,9 @@ func init() {
 	dispatch[OpCodeInt64Divide] = func(n *Interpreter, m *Machine) {
 		rhs := int64(m.Stack[m.Top])
 		m.Top -= 1
+		if rhs == 0 {
+			panic("Integer division by zero")
+		}
 		lhs := int64(m.Stack[m.Top])
 		m.Stack[m.Top] = uint64(lhs / rhs)
 	}
@@ -278,6 +580,17 @@ func init() {
 		}
 	}
 
+	dispatch[OpCodeInt64InRange] = func(n *Interpreter, m *Machine) {
+		r := n.rangePool.Get(m.Stack[m.Top])
+		m.Top -= 1
+		value := int64(m.Stack[m.Top])
+		if int64(r.Low) <= value && value <= int64(r.High) {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
 	dispatch[OpCodeInt64Increment] = func(n *Interpreter, m *Machine) {
 		lhs := int64(m.Stack[m.Top])
 		m.Stack[m.Top] = uint64(lhs + 1)
@@ -350,16 +663,200 @@ func init() {
 		m.Stack[m.Top] = uint64(lhs - rhs)
 	}
 
+	dispatch[OpCodeJump] = func(n *Interpreter, m *Machine) {
+		m.IP = int(*(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP])))
+	}
+
+	dispatch[OpCodeJumpIfFalse] = func(n *Interpreter, m *Machine) {
+		target := int(*(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP])))
+		m.IP += 4
+
+		condition := m.Stack[m.Top]
+		m.Top -= 1
+
+		if condition == 0 {
+			m.IP = target
+		}
+	}
+
+	dispatch[OpCodeJumpIfFalseOrPop] = func(n *Interpreter, m *Machine) {
+		target := int(*(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP])))
+		m.IP += 4
+
+		if m.Stack[m.Top] == 0 {
+			m.IP = target
+		} else {
+			m.Top -= 1
+		}
+	}
+
+	dispatch[OpCodeJumpIfTrueOrPop] = func(n *Interpreter, m *Machine) {
+		target := int(*(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP])))
+		m.IP += 4
+
+		if m.Stack[m.Top] != 0 {
+			m.IP = target
+		} else {
+			m.Top -= 1
+		}
+	}
+
 	dispatch[OpCodeNoOp] = func(n *Interpreter, m *Machine) {
 		// do nothing
 	}
 
+	dispatch[OpCodeOptionalEquals] = func(n *Interpreter, m *Machine) {
+		typeIndex := types.TypeIndex(*(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP])))
+		m.IP += 4
+
+		rhs := m.Stack[m.Top]
+		m.Top -= 1
+		lhs := m.Stack[m.Top]
+		if n.areValuesEqual(typeIndex, lhs, rhs) {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
+	dispatch[OpCodeOptionalFieldReference] = func(n *Interpreter, m *Machine) {
+		fieldIndex := m.Stack[m.Top]
+		m.Top -= 1
+		recordIndex := m.Stack[m.Top]
+
+		if recordIndex == optionals.NoneValue {
+			return
+		}
+
+		record := n.recordPool.Get(n.optionalPool.Get(recordIndex))
+		value := record.FieldValues[fieldIndex]
+
+		// A field of optional type keeps its own none; any other field value becomes present
+		recordType := n.typePool.Get(record.TypeIndex).(*types.RecordType)
+		switch n.typePool.Get(recordType.FieldTypeIndexes[fieldIndex]).Category() {
+		case types.TypeCategoryNone, types.TypeCategoryOptional:
+		default:
+			value = n.optionalPool.Put(value)
+		}
+
+		m.Stack[m.Top] = value
+	}
+
+	dispatch[OpCodeOptionalGetOrDefault] = func(n *Interpreter, m *Machine) {
+		defaultValue := m.Stack[m.Top]
+		m.Top -= 1
+		if m.Stack[m.Top] == optionals.NoneValue {
+			m.Stack[m.Top] = defaultValue
+		} else {
+			m.Stack[m.Top] = n.optionalPool.Get(m.Stack[m.Top])
+		}
+	}
+
+	dispatch[OpCodeOptionalLoadNone] = func(n *Interpreter, m *Machine) {
+		m.Top += 1
+		m.Stack[m.Top] = optionals.NoneValue
+	}
+
+	dispatch[OpCodeOptionalNotEquals] = func(n *Interpreter, m *Machine) {
+		typeIndex := types.TypeIndex(*(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP])))
+		m.IP += 4
+
+		rhs := m.Stack[m.Top]
+		m.Top -= 1
+		lhs := m.Stack[m.Top]
+		if n.areValuesEqual(typeIndex, lhs, rhs) {
+			m.Stack[m.Top] = 0
+		} else {
+			m.Stack[m.Top] = true64
+		}
+	}
+
+	dispatch[OpCodeOptionalOrDefault] = func(n *Interpreter, m *Machine) {
+		defaultValue := m.Stack[m.Top]
+		m.Top -= 1
+		if m.Stack[m.Top] == optionals.NoneValue {
+			m.Stack[m.Top] = defaultValue
+		}
+	}
+
+	dispatch[OpCodeOptionalWrap] = func(n *Interpreter, m *Machine) {
+		m.Stack[m.Top] = n.optionalPool.Put(m.Stack[m.Top])
+	}
+
+	dispatch[OpCodeRangeEquals] = func(n *Interpreter, m *Machine) {
+		rangeIndexRhs := m.Stack[m.Top]
+		m.Top -= 1
+		rangeIndexLhs := m.Stack[m.Top]
+
+		if n.areRangesEqual(rangeIndexLhs, rangeIndexRhs) {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
+	dispatch[OpCodeRangeNotEquals] = func(n *Interpreter, m *Machine) {
+		rangeIndexRhs := m.Stack[m.Top]
+		m.Top -= 1
+		rangeIndexLhs := m.Stack[m.Top]
+
+		if n.areRangesEqual(rangeIndexLhs, rangeIndexRhs) {
+			m.Stack[m.Top] = 0
+		} else {
+			m.Stack[m.Top] = true64
+		}
+	}
+
+	dispatch[OpCodeRangeStore] = func(n *Interpreter, m *Machine) {
+		high := m.Stack[m.Top]
+		m.Top -= 1
+		low := m.Stack[m.Top]
+		m.Stack[m.Top] = n.rangePool.Put(ranges.Range{Low: low, High: high})
+	}
+
+	dispatch[OpCodeRecordAdd] = func(n *Interpreter, m *Machine) {
+		n.combineRecords(m,
+			func(lhs int64, rhs int64) int64 { return lhs + rhs },
+			func(lhs float64, rhs float64) float64 { return lhs + rhs },
+		)
+	}
+
+	dispatch[OpCodeRecordBegin] = func(n *Interpreter, m *Machine) {
+		fieldCount := *(*int)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP]))
+		m.IP += 4
+
+		if m.RecordsTop == len(m.Records)-1 {
+			m.GrowRecords()
+		}
+		if m.Top+fieldCount >= len(m.Stack)-2 {
+			m.GrowStack(fieldCount + 1)
+		}
+
+		m.RecordsTop += 1
+		m.Records[m.RecordsTop] = m.Top
+
+		// The slots for the field values follow the record type on the stack.
+		m.Top += fieldCount
+	}
+
+	dispatch[OpCodeRecordDivide] = func(n *Interpreter, m *Machine) {
+		n.combineRecords(m,
+			func(lhs int64, rhs int64) int64 {
+				if rhs == 0 {
+					panic("Integer division by zero")
+				}
+				return lhs / rhs
+			},
+			func(lhs float64, rhs float64) float64 { return lhs / rhs },
+		)
+	}
+
 	dispatch[OpCodeRecordEquals] = func(n *Interpreter, m *Machine) {
 		recordIndexRhs := m.Stack[m.Top]
 		m.Top -= 1
 		recordIndexLhs := m.Stack[m.Top]
 
-		if records.AreRecordsEqual(n.typePool, n.recordPool, recordIndexLhs, recordIndexRhs) {
+		if records.AreRecordsEqual(n.typePool, n.recordPool, recordIndexLhs, recordIndexRhs, n.areValuesEqual) {
 			m.Stack[m.Top] = true64
 		} else {
 			m.Stack[m.Top] = 0
@@ -372,6 +869,17 @@ func init() {
 		m.IP += 4
 	}
 
+	dispatch[OpCodeRecordFieldLoad] = func(n *Interpreter, m *Machine) {
+		operand := *(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP]))
+		m.IP += 4
+
+		recordDepth := int(operand >> 32)
+		fieldIndex := int(operand & 0xFFFFFFFF)
+
+		m.Top += 1
+		m.Stack[m.Top] = m.Stack[m.Records[m.RecordPosition(recordDepth)]+1+fieldIndex]
+	}
+
 	dispatch[OpCodeRecordFieldReference] = func(n *Interpreter, m *Machine) {
 		fieldIndex := m.Stack[m.Top]
 		m.Top -= 1
@@ -380,12 +888,49 @@ func init() {
 		m.Stack[m.Top] = n.recordPool.Get(recordIndex).FieldValues[fieldIndex]
 	}
 
+	dispatch[OpCodeRecordFieldStore] = func(n *Interpreter, m *Machine) {
+		fieldIndex := *(*int)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP]))
+		m.IP += 4
+
+		m.Stack[m.Records[m.RecordsTop]+1+fieldIndex] = m.Stack[m.Top]
+		m.Top -= 1
+	}
+
+	dispatch[OpCodeRecordMerge] = func(n *Interpreter, m *Machine) {
+		typeIndex := types.TypeIndex(*(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP])))
+		m.IP += 4
+
+		recordIndexRhs := m.Stack[m.Top]
+		m.Top -= 1
+		recordIndexLhs := m.Stack[m.Top]
+
+		m.Stack[m.Top] = records.MergeRecords(n.typePool, n.recordPool, n.optionalPool, n.unionPool, typeIndex,
+			recordIndexLhs, recordIndexRhs, n.checkSameFieldValues)
+
+		// A constraint from one side applies to the value from the other side
+		recordType := n.typePool.Get(typeIndex).(*types.RecordType)
+		record := n.recordPool.Get(m.Stack[m.Top])
+		for i, fieldTypeIndex := range recordType.FieldTypeIndexes {
+			if n.typePool.Get(fieldTypeIndex).Category() == types.TypeCategoryConstraint &&
+				recordType.FieldPresence(i) != types.RecordFieldPresenceRequired {
+				n.checkConstraint(fieldTypeIndex, recordType.FieldNameIndexes[i], record.FieldValues[i])
+			}
+		}
+	}
+
+	dispatch[OpCodeRecordMultiply] = func(n *Interpreter, m *Machine) {
+		n.combineRecords(m,
+			func(lhs int64, rhs int64) int64 { return lhs * rhs },
+			func(lhs float64, rhs float64) float64 { return lhs * rhs },
+		)
+	}
+
 	dispatch[OpCodeRecordNotEquals] = func(n *Interpreter, m *Machine) {
 		recordIndexRhs := m.Stack[m.Top]
 		m.Top -= 1
 		recordIndexLhs := m.Stack[m.Top]
 
-		if records.AreRecordsEqual(n.typePool, n.recordPool, recordIndexLhs, recordIndexRhs) {
+		if records.AreRecordsEqual(n.typePool, n.recordPool, recordIndexLhs, recordIndexRhs, n.areValuesEqual) {
 			m.Stack[m.Top] = 0
 		} else {
 			m.Stack[m.Top] = true64
@@ -410,10 +955,27 @@ func init() {
 
 		m.Top -= fieldCount
 		m.Stack[m.Top] = recordIndex
+		m.RecordsTop -= 1
+	}
+
+	dispatch[OpCodeRecordSubtract] = func(n *Interpreter, m *Machine) {
+		n.combineRecords(m,
+			func(lhs int64, rhs int64) int64 { return lhs - rhs },
+			func(lhs float64, rhs float64) float64 { return lhs - rhs },
+		)
 	}
 
 	dispatch[OpCodeReturn] = func(n *Interpreter, m *Machine) {
-		// TO DO
+		frame := &m.Frames[m.FramesTop]
+		m.FramesTop -= 1
+
+		// The result takes the place of the function beneath the arguments
+		result := m.Stack[m.Top]
+		m.Top = frame.Base - 1
+		m.Stack[m.Top] = result
+
+		n.codeBlock = frame.CodeBlock
+		m.IP = frame.IP
 	}
 
 	dispatch[OpCodeStackPop] = func(n *Interpreter, m *Machine) {
@@ -453,12 +1015,140 @@ func init() {
 		}
 	}
 
+	dispatch[OpCodeStringEqualsCollated] = func(n *Interpreter, m *Machine) {
+		collation := *(*collations.Collation)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP]))
+		m.IP += 4
+
+		rhs := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
+		m.Top -= 1
+		lhs := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
+		if collation.Equal(lhs, rhs) {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
+	dispatch[OpCodeStringFormat] = func(n *Interpreter, m *Machine) {
+		kind := formats.KindOfType(*(*types.TypeIndex)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP])))
+		m.IP += 4
+
+		spec := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
+		m.Top -= 1
+
+		format, err := formats.ParseFormat(spec)
+		if err == nil {
+			err = format.Check(kind)
+		}
+		if err != nil {
+			panic(fmt.Sprintf("Invalid format '%s': %s", spec, err))
+		}
+
+		value := m.Stack[m.Top]
+		var text string
+		switch kind {
+		case formats.KindBool:
+			text = format.FormatBool(value != 0)
+		case formats.KindFloat64:
+			text = format.FormatFloat64(math.Float64frombits(value))
+		case formats.KindInt64:
+			text = format.FormatInt64(int64(value))
+		case formats.KindString:
+			text = format.FormatString(n.stringPool.Get(pools.StringIndex(value)))
+		}
+		m.Stack[m.Top] = uint64(n.stringPool.Put(text))
+	}
+
+	dispatch[OpCodeStringGreaterThan] = func(n *Interpreter, m *Machine) {
+		rhs := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
+		m.Top -= 1
+		lhs := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
+		if lhs > rhs {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
+	dispatch[OpCodeStringGreaterThanOrEquals] = func(n *Interpreter, m *Machine) {
+		rhs := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
+		m.Top -= 1
+		lhs := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
+		if lhs >= rhs {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
+	dispatch[OpCodeStringInRange] = func(n *Interpreter, m *Machine) {
+		r := n.rangePool.Get(m.Stack[m.Top])
+		m.Top -= 1
+		value := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
+		low := n.stringPool.Get(pools.StringIndex(r.Low))
+		high := n.stringPool.Get(pools.StringIndex(r.High))
+		if low <= value && value <= high {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
+	dispatch[OpCodeStringLessThan] = func(n *Interpreter, m *Machine) {
+		rhs := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
+		m.Top -= 1
+		lhs := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
+		if lhs < rhs {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
+	dispatch[OpCodeStringLessThanOrEquals] = func(n *Interpreter, m *Machine) {
+		rhs := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
+		m.Top -= 1
+		lhs := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
+		if lhs <= rhs {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
 	dispatch[OpCodeStringLoad] = func(n *Interpreter, m *Machine) {
 		m.Top += 1
 		m.Stack[m.Top] = *(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP]))
 		m.IP += 4
 	}
 
+	dispatch[OpCodeStringMatches] = func(n *Interpreter, m *Machine) {
+		pattern := n.patternPool.Get(*(*pools.PatternIndex)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP])))
+		m.IP += 4
+
+		if pattern.MatchString(n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))) {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
+	dispatch[OpCodeStringMatchesDynamic] = func(n *Interpreter, m *Machine) {
+		source := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
+		m.Top -= 1
+
+		patternIndex, err := n.dynamicPatterns.Put(source)
+		if err != nil {
+			panic(fmt.Sprintf("Invalid pattern '%s': %s", source, pools.DescribePatternError(err)))
+		}
+
+		if n.dynamicPatterns.Get(patternIndex).MatchString(n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))) {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
 	dispatch[OpCodeStringNotEquals] = func(n *Interpreter, m *Machine) {
 		rhs := n.stringPool.Get(pools.StringIndex(m.Stack[m.Top]))
 		m.Top -= 1
@@ -470,11 +1160,26 @@ func init() {
 		}
 	}
 
+	dispatch[OpCodeTypeContains] = func(n *Interpreter, m *Machine) {
+		valueTypeIndex := types.TypeIndex(*(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP])))
+		m.IP += 4
+
+		typeIndex := types.TypeIndex(m.Stack[m.Top])
+		m.Top -= 1
+		value := m.Stack[m.Top]
+
+		if n.typeContains(typeIndex, valueTypeIndex, value) {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
 	dispatch[OpCodeTypeEquals] = func(n *Interpreter, m *Machine) {
-		rhs := m.Stack[m.Top]
+		rhs := types.TypeIndex(m.Stack[m.Top])
 		m.Top -= 1
-		lhs := m.Stack[m.Top]
-		if lhs == rhs {
+		lhs := types.TypeIndex(m.Stack[m.Top])
+		if n.typePool.AreTypesEqual(lhs, rhs) {
 			m.Stack[m.Top] = true64
 		} else {
 			m.Stack[m.Top] = 0
@@ -483,21 +1188,54 @@ func init() {
 
 	dispatch[OpCodeTypeLoad] = func(n *Interpreter, m *Machine) {
 		m.Top += 1
-		m.Stack[m.Top] = uint64(n.codeBlock.OpCodes[m.IP])
-		m.IP += 1
+		m.Stack[m.Top] = *(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP]))
+		m.IP += 4
 	}
 
 	dispatch[OpCodeTypeNotEquals] = func(n *Interpreter, m *Machine) {
+		rhs := types.TypeIndex(m.Stack[m.Top])
+		m.Top -= 1
+		lhs := types.TypeIndex(m.Stack[m.Top])
+		if n.typePool.AreTypesEqual(lhs, rhs) {
+			m.Stack[m.Top] = 0
+		} else {
+			m.Stack[m.Top] = true64
+		}
+	}
+
+	dispatch[OpCodeUnionEquals] = func(n *Interpreter, m *Machine) {
 		rhs := m.Stack[m.Top]
 		m.Top -= 1
 		lhs := m.Stack[m.Top]
-		if lhs == rhs {
+		if n.areUnionValuesEqual(lhs, rhs) {
+			m.Stack[m.Top] = true64
+		} else {
+			m.Stack[m.Top] = 0
+		}
+	}
+
+	dispatch[OpCodeUnionNotEquals] = func(n *Interpreter, m *Machine) {
+		rhs := m.Stack[m.Top]
+		m.Top -= 1
+		lhs := m.Stack[m.Top]
+		if n.areUnionValuesEqual(lhs, rhs) {
 			m.Stack[m.Top] = 0
 		} else {
 			m.Stack[m.Top] = true64
 		}
 	}
 
+	dispatch[OpCodeUnionWrap] = func(n *Interpreter, m *Machine) {
+		typeIndex := types.TypeIndex(*(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP])))
+		m.IP += 4
+
+		m.Stack[m.Top] = n.unionPool.Put(unions.UnionValue{TypeIndex: typeIndex, Value: m.Stack[m.Top]})
+	}
+
+	dispatch[OpCodeWhenUnmatched] = func(n *Interpreter, m *Machine) {
+		panic("None of the 'when' guards is true")
+	}
+
 	for i := uint16(0); i < OpCode_Count; i += 1 {
 		if dispatch[i] == nil {
 			panic(fmt.Sprintf("Missing dispatch function %d", i))
@@ -507,3 +1245,171 @@ func init() {
 }
 
 //=====================================================================================================================
+
+// areValuesEqual determines whether two values of compatible types are equal. Arrays and records are compared element
+// by element and field by field, present optional values by their values, ranges by their bounds, Float64 values as
+// numbers, and tagged union values by their tags and then their values; other values are equal when their
+// representations are.
+func (n *Interpreter) areValuesEqual(typeIndex types.TypeIndex, value1 uint64, value2 uint64) bool {
+	if n.typePool.IsTaggedUnion(typeIndex) {
+		return n.areUnionValuesEqual(value1, value2)
+	}
+
+	if n.typePool.BaseTypeIndex(typeIndex) == types.BuiltInTypeIndexFloat64 {
+		return math.Float64frombits(value1) == math.Float64frombits(value2)
+	}
+
+	switch n.typePool.Get(typeIndex).Category() {
+	case types.TypeCategoryArray:
+		return arrays.AreArraysEqual(n.typePool, n.arrayPool, value1, value2, n.areValuesEqual)
+	case types.TypeCategoryOptional:
+		if value1 == optionals.NoneValue || value2 == optionals.NoneValue {
+			return value1 == value2
+		}
+		valueTypeIndex := n.typePool.Get(typeIndex).(*types.OptionalType).ValueTypeIndex
+		return n.areValuesEqual(valueTypeIndex, n.optionalPool.Get(value1), n.optionalPool.Get(value2))
+	case types.TypeCategoryRange:
+		return n.areRangesEqual(value1, value2)
+	case types.TypeCategoryRecord:
+		return records.AreRecordsEqual(n.typePool, n.recordPool, value1, value2, n.areValuesEqual)
+	default:
+		return value1 == value2
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// areRangesEqual determines whether two ranges have the same bounds, whatever their places in the range pool.
+func (n *Interpreter) areRangesEqual(rangeIndex1 uint64, rangeIndex2 uint64) bool {
+	return n.rangePool.Get(rangeIndex1) == n.rangePool.Get(rangeIndex2)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// areUnionValuesEqual determines whether two tagged union values are values of the same base type that are equal.
+func (n *Interpreter) areUnionValuesEqual(unionIndex1 uint64, unionIndex2 uint64) bool {
+	value1 := n.unionPool.Get(unionIndex1)
+	value2 := n.unionPool.Get(unionIndex2)
+	return value1.TypeIndex == value2.TypeIndex && n.areValuesEqual(value1.TypeIndex, value1.Value, value2.Value)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// checkSameFieldValues panics with a runtime error when the values given for a field by both of two intersected records
+// differ.
+func (n *Interpreter) checkSameFieldValues(
+	fieldNameIndex pools.NameIndex,
+	typeIndex types.TypeIndex,
+	value1 uint64,
+	value2 uint64,
+) {
+	if !n.areValuesEqual(typeIndex, value1, value2) {
+		panic(fmt.Sprintf("Conflicting values for field '%s'", n.fieldNames.Get(fieldNameIndex)))
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// combineRecords replaces the two records on top of the stack by a new record, of the type given by the operand of the
+// current op code, whose fields combine the fields of the two records by the given Int64 or Float64 operation.
+func (n *Interpreter) combineRecords(
+	m *Machine,
+	int64Operation func(lhs int64, rhs int64) int64,
+	float64Operation func(lhs float64, rhs float64) float64,
+) {
+	typeIndex := types.TypeIndex(*(*uint64)(unsafe.Pointer(&n.codeBlock.OpCodes[m.IP])))
+	m.IP += 4
+
+	recordIndexRhs := m.Stack[m.Top]
+	m.Top -= 1
+	recordIndexLhs := m.Stack[m.Top]
+
+	m.Stack[m.Top] = records.CombineRecords(n.typePool, n.recordPool, typeIndex, recordIndexLhs, recordIndexRhs,
+		func(fieldTypeIndex types.TypeIndex, lhs uint64, rhs uint64) uint64 {
+			if fieldTypeIndex == types.BuiltInTypeIndexFloat64 {
+				return math.Float64bits(float64Operation(math.Float64frombits(lhs), math.Float64frombits(rhs)))
+			}
+			return uint64(int64Operation(int64(lhs), int64(rhs)))
+		})
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// typeContains determines whether a value, known to be a value of one type, is also a value of another type. Literal
+// types compare the value itself; unions contain the values of any of their members; array types contain arrays whose
+// elements they all contain. A value of an optional type is tested by its present value, and a tagged union value by
+// its value and tag, while "none" is contained only by optional types and the type of none itself.
+func (n *Interpreter) typeContains(typeIndex types.TypeIndex, valueTypeIndex types.TypeIndex, value uint64) bool {
+
+	if typeIndex == valueTypeIndex {
+		return true
+	}
+
+	switch valueType := n.typePool.Get(valueTypeIndex).(type) {
+	case *types.NoneType:
+		return n.typePool.Get(typeIndex).Category() == types.TypeCategoryOptional
+	case *types.OptionalType:
+		if value == optionals.NoneValue {
+			return n.typePool.Get(typeIndex).Category() == types.TypeCategoryOptional ||
+				typeIndex == types.BuiltInTypeIndexNone
+		}
+		return n.typeContains(typeIndex, valueType.ValueTypeIndex, n.optionalPool.Get(value))
+	case *types.UnionType:
+		if n.typePool.IsTaggedUnion(valueTypeIndex) {
+			unionValue := n.unionPool.Get(value)
+			return n.typeContains(typeIndex, unionValue.TypeIndex, unionValue.Value)
+		}
+	}
+
+	switch typ := n.typePool.Get(typeIndex).(type) {
+
+	case *types.ArrayType:
+		valueType, ok := n.typePool.Get(valueTypeIndex).(*types.ArrayType)
+		if !ok {
+			return false
+		}
+		for _, element := range n.arrayPool.Get(value).Elements {
+			if !n.typeContains(typ.ElementTypeIndex, valueType.ElementTypeIndex, element) {
+				return false
+			}
+		}
+		return true
+
+	case *types.ConstraintType:
+		return n.typeContains(typ.ConstrainedTypeIndex, valueTypeIndex, value) && n.SatisfiesConstraint(typeIndex, value)
+
+	case *types.OptionalType:
+		return n.typeContains(typ.ValueTypeIndex, valueTypeIndex, value)
+
+	case *types.LiteralType:
+		if n.typePool.BaseTypeIndex(valueTypeIndex) != typ.BaseTypeIndex {
+			return false
+		}
+
+		switch typ.BaseTypeIndex {
+		case types.BuiltInTypeIndexBool:
+			return (value != 0) == (typ.Value != 0)
+		case types.BuiltInTypeIndexFloat64:
+			return math.Float64frombits(value) == math.Float64frombits(typ.Value)
+		case types.BuiltInTypeIndexString:
+			return n.stringPool.Get(pools.StringIndex(value)) == n.stringPool.Get(pools.StringIndex(typ.Value))
+		default:
+			return value == typ.Value
+		}
+
+	case *types.UnionType:
+		for _, memberTypeIndex := range typ.MemberTypeIndexes {
+			if n.typeContains(memberTypeIndex, valueTypeIndex, value) {
+				return true
+			}
+		}
+		return false
+
+	default:
+		return n.typePool.BaseTypeIndex(valueTypeIndex) == typeIndex
+
+	}
+
+}
+
+//=====================================================================================================================
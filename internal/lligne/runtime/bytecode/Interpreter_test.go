@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"lligne-cli/internal/lligne/runtime/pools"
 	"lligne-cli/internal/lligne/runtime/types"
+	"math"
 	"testing"
 )
 
@@ -41,6 +42,199 @@ func TestInterpreter(t *testing.T) {
 		assert.Equal(t, expected, actual)
 	})
 
+	t.Run("records under construction", func(t *testing.T) {
+		codeBlock := NewCodeBlock()
+		machine := NewMachine()
+		interpreter := NewInterpreter(codeBlock, pools.NewStringPool(), types.NewTypePool())
+
+		// {a = 2, b = {d = c + 1, c = a * 3}}
+		codeBlock.TypeLoad(6)
+		codeBlock.RecordBegin(2)
+		codeBlock.Int64Load(2)
+		codeBlock.RecordFieldStore(0)
+		codeBlock.TypeLoad(7)
+		codeBlock.RecordBegin(2)
+		codeBlock.RecordFieldLoad(1, 0)
+		codeBlock.Int64Load(3)
+		codeBlock.Int64Multiply()
+		codeBlock.RecordFieldStore(1)
+		codeBlock.RecordFieldLoad(0, 1)
+		codeBlock.Int64Increment()
+		codeBlock.RecordFieldStore(0)
+		codeBlock.RecordStore(2)
+		codeBlock.RecordFieldStore(1)
+		codeBlock.RecordStore(2)
+
+		codeBlock.Stop()
+
+		interpreter.Execute(machine)
+
+		outer := interpreter.GetRecordPool().Get(machine.Stack[machine.Top])
+		assert.Equal(t, types.TypeIndex(6), outer.TypeIndex)
+		assert.Equal(t, uint64(2), outer.FieldValues[0])
+
+		inner := interpreter.GetRecordPool().Get(outer.FieldValues[1])
+		assert.Equal(t, types.TypeIndex(7), inner.TypeIndex)
+		assert.Equal(t, []uint64{7, 6}, inner.FieldValues)
+
+		assert.Equal(t, 0, machine.Top)
+		assert.Equal(t, -1, machine.RecordsTop)
+	})
+
+	t.Run("record merge", func(t *testing.T) {
+		codeBlock := NewCodeBlock()
+		machine := NewMachine()
+		typePool := types.NewTypePool()
+		interpreter := NewInterpreter(codeBlock, pools.NewStringPool(), typePool)
+		int64Type := types.BuiltInTypeIndexInt64
+
+		// {a: Int64, b: Int64 ?: 2, c: Int64 ?: 3} & {c = 30, a = 10}
+		lhsTypeIndex := typePool.Put(&types.RecordType{
+			FieldNameIndexes: []pools.NameIndex{1, 2, 3},
+			FieldTypeIndexes: []types.TypeIndex{int64Type, int64Type, int64Type},
+			FieldPresences: []types.RecordFieldPresence{
+				types.RecordFieldPresenceRequired, types.RecordFieldPresenceDefault, types.RecordFieldPresenceDefault,
+			},
+		})
+		rhsTypeIndex := typePool.Put(&types.RecordType{
+			FieldNameIndexes: []pools.NameIndex{3, 1},
+			FieldTypeIndexes: []types.TypeIndex{int64Type, int64Type},
+		})
+		mergedTypeIndex := typePool.Put(&types.RecordType{
+			FieldNameIndexes: []pools.NameIndex{1, 2, 3},
+			FieldTypeIndexes: []types.TypeIndex{int64Type, int64Type, int64Type},
+			FieldPresences: []types.RecordFieldPresence{
+				types.RecordFieldPresenceValue, types.RecordFieldPresenceDefault, types.RecordFieldPresenceValue,
+			},
+		})
+
+		codeBlock.TypeLoad(lhsTypeIndex)
+		codeBlock.RecordBegin(3)
+		codeBlock.Int64LoadZero()
+		codeBlock.RecordFieldStore(0)
+		codeBlock.Int64Load(2)
+		codeBlock.RecordFieldStore(1)
+		codeBlock.Int64Load(3)
+		codeBlock.RecordFieldStore(2)
+		codeBlock.RecordStore(3)
+		codeBlock.TypeLoad(rhsTypeIndex)
+		codeBlock.RecordBegin(2)
+		codeBlock.Int64Load(30)
+		codeBlock.RecordFieldStore(0)
+		codeBlock.Int64Load(10)
+		codeBlock.RecordFieldStore(1)
+		codeBlock.RecordStore(2)
+		codeBlock.RecordMerge(mergedTypeIndex)
+
+		codeBlock.Stop()
+
+		interpreter.Execute(machine)
+
+		merged := interpreter.GetRecordPool().Get(machine.Stack[machine.Top])
+		assert.Equal(t, mergedTypeIndex, merged.TypeIndex)
+		assert.Equal(t, []uint64{10, 2, 30}, merged.FieldValues)
+
+		assert.Equal(t, 0, machine.Top)
+	})
+
+	t.Run("record arithmetic", func(t *testing.T) {
+		codeBlock := NewCodeBlock()
+		machine := NewMachine()
+		typePool := types.NewTypePool()
+		interpreter := NewInterpreter(codeBlock, pools.NewStringPool(), typePool)
+		int64Type := types.BuiltInTypeIndexInt64
+		float64Type := types.BuiltInTypeIndexFloat64
+
+		// {x = 7, y = 0.5} - {y = 0.25, x = 2}
+		lhsTypeIndex := typePool.Put(&types.RecordType{
+			FieldNameIndexes: []pools.NameIndex{1, 2},
+			FieldTypeIndexes: []types.TypeIndex{int64Type, float64Type},
+		})
+		rhsTypeIndex := typePool.Put(&types.RecordType{
+			FieldNameIndexes: []pools.NameIndex{2, 1},
+			FieldTypeIndexes: []types.TypeIndex{float64Type, int64Type},
+		})
+
+		codeBlock.TypeLoad(lhsTypeIndex)
+		codeBlock.RecordBegin(2)
+		codeBlock.Int64Load(7)
+		codeBlock.RecordFieldStore(0)
+		codeBlock.Float64Load(0.5)
+		codeBlock.RecordFieldStore(1)
+		codeBlock.RecordStore(2)
+		codeBlock.TypeLoad(rhsTypeIndex)
+		codeBlock.RecordBegin(2)
+		codeBlock.Float64Load(0.25)
+		codeBlock.RecordFieldStore(0)
+		codeBlock.Int64Load(2)
+		codeBlock.RecordFieldStore(1)
+		codeBlock.RecordStore(2)
+		codeBlock.RecordSubtract(lhsTypeIndex)
+
+		codeBlock.Stop()
+
+		interpreter.Execute(machine)
+
+		difference := interpreter.GetRecordPool().Get(machine.Stack[machine.Top])
+		assert.Equal(t, lhsTypeIndex, difference.TypeIndex)
+		assert.Equal(t, []uint64{5, math.Float64bits(0.25)}, difference.FieldValues)
+
+		assert.Equal(t, 0, machine.Top)
+	})
+
+	t.Run("type contains", func(t *testing.T) {
+		codeBlock := NewCodeBlock()
+		machine := NewMachine()
+		stringPool := pools.NewStringPool()
+		typePool := types.NewTypePool()
+		interpreter := NewInterpreter(codeBlock, stringPool, typePool)
+
+		dev := typePool.PutLiteral(types.BuiltInTypeIndexString, uint64(stringPool.Put("dev")), "\"dev\"")
+		prod := typePool.PutLiteral(types.BuiltInTypeIndexString, uint64(stringPool.Put("prod")), "\"prod\"")
+		devOrProd := typePool.PutUnion([]types.TypeIndex{dev, prod})
+
+		// "dev" is ("dev" | "prod"); "test" is ("dev" | "prod"); "prod" is String; 1 is ("dev" | "prod")
+		codeBlock.StringLoad(stringPool.Put("dev"))
+		codeBlock.TypeLoad(devOrProd)
+		codeBlock.TypeContains(types.BuiltInTypeIndexString)
+		codeBlock.StringLoad(stringPool.Put("test"))
+		codeBlock.TypeLoad(devOrProd)
+		codeBlock.TypeContains(types.BuiltInTypeIndexString)
+		codeBlock.StringLoad(stringPool.Put("prod"))
+		codeBlock.TypeLoad(types.BuiltInTypeIndexString)
+		codeBlock.TypeContains(devOrProd)
+		codeBlock.Int64LoadOne()
+		codeBlock.TypeLoad(devOrProd)
+		codeBlock.TypeContains(types.BuiltInTypeIndexInt64)
+
+		codeBlock.Stop()
+
+		interpreter.Execute(machine)
+
+		assert.Equal(t, []uint64{true64, 0, true64, 0}, machine.Stack[0:4])
+		assert.Equal(t, 3, machine.Top)
+	})
+
+	t.Run("dynamic patterns", func(t *testing.T) {
+		codeBlock := NewCodeBlock()
+		stringPool := pools.NewStringPool()
+
+		codeBlock.StringLoad(stringPool.Put("abc"))
+		codeBlock.StringLoad(stringPool.Put("^[a-c]+$"))
+		codeBlock.StringMatchesDynamic()
+		codeBlock.Stop()
+
+		// Each run compiles the pattern in an interpreter of its own, leaving the shared code block unchanged
+		for i := 0; i < 2; i++ {
+			machine := NewMachine()
+			NewInterpreter(codeBlock, stringPool, types.NewTypePool()).Execute(machine)
+			assert.True(t, machine.BoolGetResult())
+		}
+
+		patternIndex, _ := codeBlock.Patterns.Put("^x$")
+		assert.Equal(t, pools.PatternIndex(0), patternIndex)
+	})
+
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -6,24 +6,121 @@
 package bytecode
 
 import (
+	"fmt"
 	"lligne-cli/internal/lligne/runtime/pools"
 	"math"
 )
 
 //=====================================================================================================================
 
-// Machine is a stack of operands for bytecode operations.
+// The limits of the stacks of a machine, which start small and grow as needed. Runaway recursion stops with a runtime
+// error when it reaches one of them rather than using up memory.
+const (
+	MaxStackSlots  = 100000 // Values on the operand stack
+	MaxRecordDepth = 10000  // Records under construction at once
+	MaxCallDepth   = 10000  // Function calls in progress at once
+)
+
+//=====================================================================================================================
+
+// Machine is a stack of operands for bytecode operations plus a stack of the records under construction and a stack
+// of the functions being called.
 type Machine struct {
-	Stack     [1000]uint64
-	Top       int
-	IP        int
-	IsRunning bool
+	Stack      []uint64
+	Top        int
+	Records    []int // Stack positions of the types of the records under construction
+	RecordsTop int
+	Frames     []CallFrame
+	FramesTop  int
+	IP         int
+	IsRunning  bool
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// CallFrame holds what is needed to run the body of a function and then return to the code that called it.
+//
+// The fields named in the body of a function belong to the record that defines the function, which is still under
+// construction but need not be the innermost one when the function is called. Counting records out from the innermost
+// one, the records begun by the body itself come first, then the defining record and the records around it, as seen
+// from the code that called the function.
+type CallFrame struct {
+	CodeBlock    *CodeBlock // The code block to return to
+	IP           int        // The instruction to return to
+	Base         int        // Stack position of the first argument
+	RecordDepth  int        // Depth of the record defining the function, as seen from the code that called it
+	RecordsFloor int        // RecordsTop when the function was called
 }
 
 //---------------------------------------------------------------------------------------------------------------------
 
 func NewMachine() *Machine {
-	return &Machine{Top: -1, IsRunning: true}
+	return &Machine{
+		Stack:      make([]uint64, 1000),
+		Top:        -1,
+		Records:    make([]int, 100),
+		RecordsTop: -1,
+		Frames:     make([]CallFrame, 100),
+		FramesTop:  -1,
+		IsRunning:  true,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// GrowFrames makes room for one more function call when all the call frames are in use, up to MaxCallDepth.
+func (m *Machine) GrowFrames() {
+	if len(m.Frames) >= MaxCallDepth {
+		panic(fmt.Sprintf("Function calls nested more than %d deep", MaxCallDepth))
+	}
+	m.Frames = append(m.Frames, make([]CallFrame, grownSize(len(m.Frames), len(m.Frames)+1, MaxCallDepth))...)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// GrowRecords makes room for one more record under construction when all the record slots are in use, up to
+// MaxRecordDepth.
+func (m *Machine) GrowRecords() {
+	if len(m.Records) >= MaxRecordDepth {
+		panic(fmt.Sprintf("Records nested more than %d deep", MaxRecordDepth))
+	}
+	m.Records = append(m.Records, make([]int, grownSize(len(m.Records), len(m.Records)+1, MaxRecordDepth))...)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// GrowStack makes room on the operand stack for the given number of values above the top one plus a slot past them,
+// up to MaxStackSlots.
+func (m *Machine) GrowStack(valueCount int) {
+	slotCount := m.Top + 1 + valueCount
+	if slotCount > MaxStackSlots {
+		panic(fmt.Sprintf("Evaluation needs more than %d stack slots", MaxStackSlots))
+	}
+	if slotCount+1 > len(m.Stack) {
+		m.Stack = append(m.Stack, make([]uint64, grownSize(len(m.Stack), slotCount+1, MaxStackSlots+1))...)
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// RecordPosition returns the position in Records of the record under construction recordDepth records out from the
+// innermost one, as seen from the code being run.
+func (m *Machine) RecordPosition(recordDepth int) int {
+	recordsTop := m.RecordsTop
+
+	for frameIndex := m.FramesTop; frameIndex >= 0; frameIndex-- {
+		frame := &m.Frames[frameIndex]
+		recordsBegunInFrame := recordsTop - frame.RecordsFloor
+
+		if recordDepth < recordsBegunInFrame {
+			break
+		}
+
+		recordDepth = frame.RecordDepth + recordDepth - recordsBegunInFrame
+		recordsTop = frame.RecordsFloor
+	}
+
+	return recordsTop - recordDepth
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -55,3 +152,18 @@ func (m *Machine) StringGetResult(stringPool *pools.StringPool) string {
 }
 
 //=====================================================================================================================
+
+// grownSize determines how many elements to add to a stack of given length that needs at least the given length:
+// enough to double it, but no more than the given limit.
+func grownSize(length int, neededLength int, limit int) int {
+	newLength := 2 * length
+	if newLength < neededLength {
+		newLength = neededLength
+	}
+	if newLength > limit {
+		newLength = limit
+	}
+	return newLength - length
+}
+
+//=====================================================================================================================
@@ -12,19 +12,34 @@ const (
 	OpCodeStop
 	OpCodeReturn
 
+	// Arrays
+	OpCodeArrayContains
+	OpCodeArrayEquals
+	OpCodeArrayIndex
+	OpCodeArrayLength
+	OpCodeArrayNotEquals
+	OpCodeArrayStore
+
 	// Booleans
 	OpCodeBoolAnd
+	OpCodeBoolEquals
 	OpCodeBoolLoadFalse
 	OpCodeBoolLoadTrue
 	OpCodeBoolNot
+	OpCodeBoolNotEquals
 	OpCodeBoolOr
 
+	// Constraints
+	OpCodeConstraintCheck
+	OpCodeConstraintValueLoad
+
 	// 64 Bit Floating Point
 	OpCodeFloat64Add
 	OpCodeFloat64Divide
 	OpCodeFloat64Equals
 	OpCodeFloat64GreaterThan
 	OpCodeFloat64GreaterThanOrEquals
+	OpCodeFloat64InRange
 	OpCodeFloat64LessThan
 	OpCodeFloat64LessThanOrEquals
 	OpCodeFloat64Load
@@ -35,6 +50,11 @@ const (
 	OpCodeFloat64NotEquals
 	OpCodeFloat64Subtract
 
+	// Functions
+	OpCodeFunctionCall
+	OpCodeFunctionLoad
+	OpCodeFunctionParameterLoad
+
 	// 64 Bit Integers
 	OpCodeInt64Add
 	OpCodeInt64Decrement
@@ -42,6 +62,7 @@ const (
 	OpCodeInt64Equals
 	OpCodeInt64GreaterThan
 	OpCodeInt64GreaterThanOrEquals
+	OpCodeInt64InRange
 	OpCodeInt64Increment
 	OpCodeInt64LessThan
 	OpCodeInt64LessThanOrEquals
@@ -53,23 +74,67 @@ const (
 	OpCodeInt64NotEquals
 	OpCodeInt64Subtract
 
+	// Jumps
+	OpCodeJump
+	OpCodeJumpIfFalse
+	OpCodeJumpIfFalseOrPop
+	OpCodeJumpIfTrueOrPop
+	OpCodeWhenUnmatched
+
 	// Strings
 	OpCodeStringConcatenate
 	OpCodeStringEquals
+	OpCodeStringEqualsCollated
+	OpCodeStringFormat
+	OpCodeStringGreaterThan
+	OpCodeStringGreaterThanOrEquals
+	OpCodeStringInRange
+	OpCodeStringLessThan
+	OpCodeStringLessThanOrEquals
 	OpCodeStringLoad
+	OpCodeStringMatches
+	OpCodeStringMatchesDynamic
 	OpCodeStringNotEquals
 
 	// Types
+	OpCodeTypeContains
 	OpCodeTypeEquals
 	OpCodeTypeLoad
 	OpCodeTypeNotEquals
 
+	// Optionals
+	OpCodeOptionalEquals
+	OpCodeOptionalFieldReference
+	OpCodeOptionalGetOrDefault
+	OpCodeOptionalLoadNone
+	OpCodeOptionalNotEquals
+	OpCodeOptionalOrDefault
+	OpCodeOptionalWrap
+
+	// Ranges
+	OpCodeRangeEquals
+	OpCodeRangeNotEquals
+	OpCodeRangeStore
+
 	// Records
+	OpCodeRecordAdd
+	OpCodeRecordBegin
+	OpCodeRecordDivide
 	OpCodeRecordEquals
 	OpCodeRecordFieldIndexLoad
+	OpCodeRecordFieldLoad
 	OpCodeRecordFieldReference
+	OpCodeRecordFieldStore
+	OpCodeRecordMerge
+	OpCodeRecordMultiply
 	OpCodeRecordNotEquals
 	OpCodeRecordStore
+	OpCodeRecordSubtract
+
+	// Unions
+	OpCodeUnionEquals
+	OpCodeUnionNotEquals
+	OpCodeUnionWrap
 
 	// Stack Operations
 	OpCodeStackPop
@@ -0,0 +1,100 @@
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package collations
+
+import (
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+)
+
+//=====================================================================================================================
+
+// Collation is a set of modifiers loosening the comparison of two strings, as written between the brackets of a
+// collated comparison like "==[Aa]==".
+type Collation uint64
+
+const (
+	// CollationIgnoreCase ("Aa") compares strings without regard to case.
+	CollationIgnoreCase Collation = 1 << iota
+
+	// CollationNFC ("NFC") compares strings after canonical Unicode normalization.
+	CollationNFC
+
+	// CollationNFKC ("NFKC") compares strings after compatibility Unicode normalization.
+	CollationNFKC
+)
+
+var modifierNames = []string{"Aa", "NFC", "NFKC"}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// ParseCollation converts the comma-separated modifiers of a collated comparison into a collation.
+func ParseCollation(modifiers string) (Collation, error) {
+
+	result := Collation(0)
+
+	for _, modifier := range strings.Split(modifiers, ",") {
+		modifier = strings.TrimSpace(modifier)
+
+		found := false
+		for i, name := range modifierNames {
+			if modifier == name {
+				result |= 1 << i
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return 0, fmt.Errorf("unknown modifier '%s'; expected one of %s", modifier, strings.Join(modifierNames, ", "))
+		}
+	}
+
+	return result, nil
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Equal determines whether two strings are equal under the collation.
+func (c Collation) Equal(s1 string, s2 string) bool {
+
+	switch {
+	case c&CollationNFKC != 0:
+		s1 = norm.NFKC.String(s1)
+		s2 = norm.NFKC.String(s2)
+	case c&CollationNFC != 0:
+		s1 = norm.NFC.String(s1)
+		s2 = norm.NFC.String(s2)
+	}
+
+	if c&CollationIgnoreCase != 0 {
+		return strings.EqualFold(s1, s2)
+	}
+
+	return s1 == s2
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// String returns the modifiers of the collation as written in source code, e.g. "Aa,NFC".
+func (c Collation) String() string {
+
+	names := make([]string, 0)
+
+	for i, name := range modifierNames {
+		if c&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+
+	return strings.Join(names, ",")
+
+}
+
+//=====================================================================================================================
@@ -0,0 +1,48 @@
+//
+// # Tests of Collation.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package collations
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestCollation(t *testing.T) {
+
+	t.Run("parsed modifiers", func(t *testing.T) {
+		collation, err := ParseCollation("Aa")
+		assert.Nil(t, err)
+		assert.Equal(t, CollationIgnoreCase, collation)
+
+		collation, err = ParseCollation("NFC, Aa")
+		assert.Nil(t, err)
+		assert.Equal(t, CollationIgnoreCase|CollationNFC, collation)
+		assert.Equal(t, "Aa,NFC", collation.String())
+
+		_, err = ParseCollation("Aa,Bb")
+		assert.EqualError(t, err, "unknown modifier 'Bb'; expected one of Aa, NFC, NFKC")
+	})
+
+	t.Run("equal strings", func(t *testing.T) {
+		assert.True(t, CollationIgnoreCase.Equal("A string", "a STRING"))
+		assert.False(t, CollationIgnoreCase.Equal("A string", "the string"))
+		assert.False(t, CollationIgnoreCase.Equal("café", "cafe\u0301"))
+
+		assert.True(t, CollationNFC.Equal("café", "cafe\u0301"))
+		assert.False(t, CollationNFC.Equal("café", "CAFE\u0301"))
+		assert.True(t, (CollationNFC|CollationIgnoreCase).Equal("café", "CAFE\u0301"))
+
+		assert.False(t, CollationNFC.Equal("Ｐrod", "Prod"))
+		assert.True(t, CollationNFKC.Equal("Ｐrod", "Prod"))
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
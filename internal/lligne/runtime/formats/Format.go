@@ -0,0 +1,394 @@
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"lligne-cli/internal/lligne/runtime/types"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//=====================================================================================================================
+
+// Kind identifies the type of value that a format is applied to.
+type Kind uint8
+
+const (
+	KindBool Kind = iota
+	KindFloat64
+	KindInt64
+	KindString
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func (k Kind) String() string {
+	switch k {
+	case KindBool:
+		return "Bool"
+	case KindFloat64:
+		return "Float64"
+	case KindInt64:
+		return "Int64"
+	case KindString:
+		return "String"
+	}
+	panic("Unhandled format kind")
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// KindOfType gives the kind of value formatted for a value of the built-in type with given index.
+func KindOfType(typeIndex types.TypeIndex) Kind {
+	switch typeIndex {
+	case types.BuiltInTypeIndexBool:
+		return KindBool
+	case types.BuiltInTypeIndexFloat64:
+		return KindFloat64
+	case types.BuiltInTypeIndexInt64:
+		return KindInt64
+	case types.BuiltInTypeIndexString:
+		return KindString
+	}
+	panic("Unhandled format type")
+}
+
+//=====================================================================================================================
+
+// Format is a parsed format spec as given on the right hand side of the "fmt" operator. A spec has exactly one
+// directive, optionally surrounded by literal text in which "%%" stands for "%". A directive has the form
+//
+//	%[flags][width][.precision]verb
+//
+// where the flags are any of
+//
+//	'-'  left align within the width (padding with spaces on the right)
+//	'0'  pad numbers with leading zeros after the sign
+//	'+'  always show the sign of a number
+//	' '  show a space in place of the sign of a non-negative number
+//	','  separate thousands with commas (verbs 'd' and 'f' only)
+//
+// the width is the minimum number of characters, the precision is the number of digits after the decimal point of a
+// Float64 or the maximum number of characters taken from a String, and the verb is one of
+//
+//	'd'       Int64 in decimal
+//	'x', 'X'  Int64 in lower or upper case hexadecimal
+//	'o'       Int64 in octal
+//	'b'       Int64 in binary
+//	'f'       Float64 without exponent (precision 6 by default)
+//	'e'       Float64 in scientific notation (precision 6 by default)
+//	'g'       Float64 in the shorter of the two (shortest exact digits by default)
+//	't'       Bool as true or false
+//	's'       String as is
+type Format struct {
+	prefix    string
+	suffix    string
+	leftAlign bool
+	zeroPad   bool
+	plusSign  bool
+	spaceSign bool
+	thousands bool
+	width     int
+	precision int
+	verb      rune
+}
+
+// maxWidth limits the width and precision of a directive to keep formatted strings reasonable.
+const maxWidth = 1000
+
+// verbsByKind lists the verbs that apply to each kind of value.
+var verbsByKind = map[Kind]string{
+	KindBool:    "t",
+	KindFloat64: "feg",
+	KindInt64:   "dxXob",
+	KindString:  "s",
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// ParseFormat parses a format spec. The result may still not apply to a given kind of value; see Check.
+func ParseFormat(spec string) (*Format, error) {
+
+	result := &Format{precision: -1}
+	sb := strings.Builder{}
+	foundDirective := false
+
+	for i := 0; i < len(spec); i += 1 {
+		ch := spec[i]
+
+		if ch != '%' {
+			sb.WriteByte(ch)
+			continue
+		}
+
+		if i+1 < len(spec) && spec[i+1] == '%' {
+			sb.WriteByte('%')
+			i += 1
+			continue
+		}
+
+		if foundDirective {
+			return nil, errors.New("expected just one directive but found another")
+		}
+
+		length, err := result.parseDirective(spec[i+1:])
+		if err != nil {
+			return nil, err
+		}
+
+		result.prefix = sb.String()
+		sb.Reset()
+		foundDirective = true
+		i += length
+	}
+
+	if !foundDirective {
+		return nil, errors.New("expected a directive like %d")
+	}
+
+	result.suffix = sb.String()
+
+	return result, nil
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Check determines whether the format applies to values of the given kind. Returns an error describing the mismatch
+// if not.
+func (f *Format) Check(kind Kind) error {
+
+	switch {
+	case !strings.ContainsRune(verbsByKind[kind], f.verb):
+		return fmt.Errorf("verb '%c' does not apply to %s; expected one of '%s'", f.verb, kind,
+			strings.Join(strings.Split(verbsByKind[kind], ""), "', '"))
+	case f.precision >= 0 && (kind == KindBool || kind == KindInt64):
+		return fmt.Errorf("a precision does not apply to %s", kind)
+	case f.thousands && f.verb != 'd' && f.verb != 'f':
+		return fmt.Errorf("thousands separators apply only to verbs 'd' and 'f'")
+	case (f.plusSign || f.spaceSign || f.zeroPad) && (kind == KindBool || kind == KindString):
+		return fmt.Errorf("the flags '+', ' ', and '0' do not apply to %s", kind)
+	}
+
+	return nil
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// FormatBool formats a Bool value.
+func (f *Format) FormatBool(value bool) string {
+	return f.pad("", strconv.FormatBool(value), false)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// FormatFloat64 formats a Float64 value.
+func (f *Format) FormatFloat64(value float64) string {
+
+	precision := f.precision
+	if precision < 0 && f.verb != 'g' {
+		precision = 6
+	}
+
+	// strconv writes "+Inf" for positive infinity; the sign comes from the flags instead.
+	digits := strings.TrimPrefix(strconv.FormatFloat(math.Abs(value), byte(f.verb), precision, 64), "+")
+	isNumber := !math.IsInf(value, 0) && !math.IsNaN(value)
+
+	if f.thousands && isNumber {
+		integer, fraction, hasFraction := strings.Cut(digits, ".")
+		digits = groupThousands(integer)
+		if hasFraction {
+			digits += "." + fraction
+		}
+	}
+
+	return f.pad(f.sign(math.Signbit(value) && !math.IsNaN(value)), digits, isNumber)
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// FormatInt64 formats an Int64 value.
+func (f *Format) FormatInt64(value int64) string {
+
+	magnitude := uint64(value)
+	if value < 0 {
+		magnitude = uint64(-value)
+	}
+
+	var digits string
+	switch f.verb {
+	case 'x':
+		digits = strconv.FormatUint(magnitude, 16)
+	case 'X':
+		digits = strings.ToUpper(strconv.FormatUint(magnitude, 16))
+	case 'o':
+		digits = strconv.FormatUint(magnitude, 8)
+	case 'b':
+		digits = strconv.FormatUint(magnitude, 2)
+	default:
+		digits = strconv.FormatUint(magnitude, 10)
+	}
+
+	if f.thousands {
+		digits = groupThousands(digits)
+	}
+
+	return f.pad(f.sign(value < 0), digits, true)
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// FormatString formats a String value.
+func (f *Format) FormatString(value string) string {
+
+	if f.precision >= 0 && utf8.RuneCountInString(value) > f.precision {
+		value = string([]rune(value)[:f.precision])
+	}
+
+	return f.pad("", value, false)
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// pad applies the width of the format to a sign and digits, then surrounds the result with the literal text of the
+// format. Only numbers are padded with zeros.
+func (f *Format) pad(sign string, digits string, isNumber bool) string {
+
+	body := sign + digits
+	padding := f.width - utf8.RuneCountInString(body)
+
+	if padding > 0 {
+		switch {
+		case f.leftAlign:
+			body += strings.Repeat(" ", padding)
+		case f.zeroPad && isNumber:
+			body = sign + strings.Repeat("0", padding) + digits
+		default:
+			body = strings.Repeat(" ", padding) + body
+		}
+	}
+
+	return f.prefix + body + f.suffix
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// parseDirective parses the text of a directive after its "%". Returns the length of the directive so parsed.
+func (f *Format) parseDirective(text string) (int, error) {
+
+	i := 0
+
+flags:
+	for ; i < len(text); i += 1 {
+		switch text[i] {
+		case '-':
+			f.leftAlign = true
+		case '0':
+			f.zeroPad = true
+		case '+':
+			f.plusSign = true
+		case ' ':
+			f.spaceSign = true
+		case ',':
+			f.thousands = true
+		default:
+			break flags
+		}
+	}
+
+	start := i
+	for i < len(text) && '0' <= text[i] && text[i] <= '9' {
+		i += 1
+	}
+	if i > start {
+		width, err := strconv.Atoi(text[start:i])
+		if err != nil || width > maxWidth {
+			return 0, fmt.Errorf("expected a width of at most %d", maxWidth)
+		}
+		f.width = width
+	}
+
+	if i < len(text) && text[i] == '.' {
+		i += 1
+		start = i
+		for i < len(text) && '0' <= text[i] && text[i] <= '9' {
+			i += 1
+		}
+		if i == start {
+			return 0, errors.New("expected digits for the precision after '.'")
+		}
+		precision, err := strconv.Atoi(text[start:i])
+		if err != nil || precision > maxWidth {
+			return 0, fmt.Errorf("expected a precision of at most %d", maxWidth)
+		}
+		f.precision = precision
+	}
+
+	if i == len(text) {
+		return 0, errors.New("expected a verb like 'd' to end the directive")
+	}
+
+	verb, verbWidth := utf8.DecodeRuneInString(text[i:])
+	if !strings.ContainsRune("dxXobfegts", verb) {
+		return 0, fmt.Errorf("unknown verb '%c'", verb)
+	}
+	f.verb = verb
+
+	return i + verbWidth, nil
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// sign returns the text showing the sign of a number.
+func (f *Format) sign(isNegative bool) string {
+	switch {
+	case isNegative:
+		return "-"
+	case f.plusSign:
+		return "+"
+	case f.spaceSign:
+		return " "
+	}
+	return ""
+}
+
+//=====================================================================================================================
+
+// groupThousands separates the given decimal digits into groups of three with commas.
+func groupThousands(digits string) string {
+
+	if len(digits) <= 3 {
+		return digits
+	}
+
+	sb := strings.Builder{}
+	lead := len(digits) % 3
+	if lead > 0 {
+		sb.WriteString(digits[:lead])
+	}
+
+	for i := lead; i < len(digits); i += 3 {
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(digits[i : i+3])
+	}
+
+	return sb.String()
+
+}
+
+//=====================================================================================================================
@@ -0,0 +1,86 @@
+//
+// # Tests of Format.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package formats
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestFormat(t *testing.T) {
+
+	parse := func(spec string) *Format {
+		format, err := ParseFormat(spec)
+		assert.Nil(t, err, "For spec: "+spec)
+		return format
+	}
+
+	checkError := func(spec string, kind Kind, expectedMessage string) {
+		format, err := ParseFormat(spec)
+		if err == nil {
+			err = format.Check(kind)
+		}
+		assert.EqualError(t, err, expectedMessage, "For spec: "+spec)
+	}
+
+	t.Run("Int64 values", func(t *testing.T) {
+		assert.Equal(t, "42", parse("%d").FormatInt64(42))
+		assert.Equal(t, "-42", parse("%d").FormatInt64(-42))
+		assert.Equal(t, "+42", parse("%+d").FormatInt64(42))
+		assert.Equal(t, " 42", parse("% d").FormatInt64(42))
+		assert.Equal(t, "   42", parse("%5d").FormatInt64(42))
+		assert.Equal(t, "42   |", parse("%-5d|").FormatInt64(42))
+		assert.Equal(t, "-0042", parse("%05d").FormatInt64(-42))
+		assert.Equal(t, "1,234,567", parse("%,d").FormatInt64(1234567))
+		assert.Equal(t, "-123,456", parse("%,d").FormatInt64(-123456))
+		assert.Equal(t, "ff", parse("%x").FormatInt64(255))
+		assert.Equal(t, "0x00FF", parse("0x%04X").FormatInt64(255))
+		assert.Equal(t, "17", parse("%o").FormatInt64(15))
+		assert.Equal(t, "101", parse("%b").FormatInt64(5))
+		assert.Equal(t, "-9223372036854775808", parse("%d").FormatInt64(math.MinInt64))
+	})
+
+	t.Run("Float64 values", func(t *testing.T) {
+		assert.Equal(t, "3.141593", parse("%f").FormatFloat64(math.Pi))
+		assert.Equal(t, "3.14", parse("%.2f").FormatFloat64(math.Pi))
+		assert.Equal(t, "  3.14", parse("%6.2f").FormatFloat64(math.Pi))
+		assert.Equal(t, "-03.14", parse("%06.2f").FormatFloat64(-math.Pi))
+		assert.Equal(t, "1,234,567.50", parse("%,.2f").FormatFloat64(1234567.5))
+		assert.Equal(t, "1.50e+03", parse("%.2e").FormatFloat64(1500))
+		assert.Equal(t, "0.1", parse("%g").FormatFloat64(0.1))
+		assert.Equal(t, "  +Inf", parse("%+06f").FormatFloat64(math.Inf(1)))
+	})
+
+	t.Run("Bool and String values", func(t *testing.T) {
+		assert.Equal(t, "true", parse("%t").FormatBool(true))
+		assert.Equal(t, "false |", parse("%-6t|").FormatBool(false))
+		assert.Equal(t, "[  abc]", parse("[%5s]").FormatString("abc"))
+		assert.Equal(t, "[ab   ]", parse("[%-5.2s]").FormatString("abc"))
+		assert.Equal(t, "né", parse("%.2s").FormatString("née"))
+		assert.Equal(t, "100% done", parse("%d%% done").FormatInt64(100))
+	})
+
+	t.Run("invalid formats", func(t *testing.T) {
+		checkError("abc", KindInt64, "expected a directive like %d")
+		checkError("%d %d", KindInt64, "expected just one directive but found another")
+		checkError("%5", KindInt64, "expected a verb like 'd' to end the directive")
+		checkError("%.f", KindFloat64, "expected digits for the precision after '.'")
+		checkError("%99999d", KindInt64, "expected a width of at most 1000")
+		checkError("%q", KindString, "unknown verb 'q'")
+		checkError("%f", KindInt64, "verb 'f' does not apply to Int64; expected one of 'd', 'x', 'X', 'o', 'b'")
+		checkError("%.2d", KindInt64, "a precision does not apply to Int64")
+		checkError("%,x", KindInt64, "thousands separators apply only to verbs 'd' and 'f'")
+		checkError("%05s", KindString, "the flags '+', ' ', and '0' do not apply to String")
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
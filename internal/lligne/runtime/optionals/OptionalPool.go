@@ -0,0 +1,58 @@
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package optionals
+
+//=====================================================================================================================
+
+// NoneValue represents the absent value "none" of every optional type. No pool ever grows large enough for it to be
+// the index of a present value.
+const NoneValue uint64 = 0xFFFFFFFFFFFFFFFF
+
+//=====================================================================================================================
+
+// OptionalPool holds the present values of optional types, interned so that they can be retrieved by index. A value of
+// an optional type is either NoneValue or the index of its present value in the pool, which keeps every optional value
+// within one 64-bit slot. Since equal present values share an index, two optional values are equal when their indexes
+// are.
+type OptionalPool struct {
+	values  []uint64
+	indexes map[uint64]uint64
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// NewOptionalPool creates a new empty optional value pool.
+func NewOptionalPool() *OptionalPool {
+	return &OptionalPool{
+		values:  nil,
+		indexes: make(map[uint64]uint64),
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Get returns the present value at the given index.
+func (p *OptionalPool) Get(index uint64) uint64 {
+	return p.values[index]
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Put looks for the present value already in the pool. It adds it if not there.
+// Returns the index of the new or existing entry.
+func (p *OptionalPool) Put(value uint64) uint64 {
+	result, found := p.indexes[value]
+
+	if !found {
+		result = uint64(len(p.values))
+		p.values = append(p.values, value)
+		p.indexes[value] = result
+	}
+
+	return result
+}
+
+//=====================================================================================================================
@@ -0,0 +1,36 @@
+//
+// # Tests of OptionalPool.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package optionals
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestOptionalPool(t *testing.T) {
+
+	t.Run("pooled present values", func(t *testing.T) {
+		pool := NewOptionalPool()
+
+		i0 := pool.Put(10)
+		i1 := pool.Put(0)
+		i2 := pool.Put(10)
+
+		assert.Equal(t, uint64(0), i0)
+		assert.Equal(t, uint64(1), i1)
+		assert.Equal(t, i0, i2)
+		assert.Equal(t, uint64(10), pool.Get(i0))
+		assert.Equal(t, uint64(0), pool.Get(i1))
+		assert.NotEqual(t, NoneValue, i1)
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,74 @@
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package pools
+
+import (
+	"regexp"
+	"regexp/syntax"
+)
+
+//=====================================================================================================================
+
+type PatternIndex uint64
+
+//=====================================================================================================================
+
+// PatternPool holds a list of compiled regular expressions interned by their source text so that they can be retrieved
+// by index. Each pattern is compiled once, when first added.
+type PatternPool struct {
+	patterns []*regexp.Regexp
+	indexes  map[string]PatternIndex
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// NewPatternPool creates a new empty pattern pool.
+func NewPatternPool() *PatternPool {
+	return &PatternPool{
+		patterns: nil,
+		indexes:  make(map[string]PatternIndex),
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Get returns the compiled pattern at the given index.
+func (p *PatternPool) Get(index PatternIndex) *regexp.Regexp {
+	return p.patterns[index]
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Put looks for the pattern already in the pool. It compiles and adds it if not there.
+// Returns the index of the new or existing entry or else the error from compiling an invalid pattern.
+func (p *PatternPool) Put(pattern string) (PatternIndex, error) {
+	result, found := p.indexes[pattern]
+
+	if !found {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return 0, err
+		}
+
+		result = PatternIndex(len(p.patterns))
+		p.patterns = append(p.patterns, compiled)
+		p.indexes[pattern] = result
+	}
+
+	return result, nil
+}
+
+//=====================================================================================================================
+
+// DescribePatternError gives the reason why a pattern could not be compiled, without repeating the pattern itself.
+func DescribePatternError(err error) string {
+	if syntaxErr, ok := err.(*syntax.Error); ok {
+		return string(syntaxErr.Code)
+	}
+	return err.Error()
+}
+
+//=====================================================================================================================
@@ -0,0 +1,51 @@
+//
+// # Tests of PatternPool.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package pools
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestPatternPool(t *testing.T) {
+
+	t.Run("pooled patterns", func(t *testing.T) {
+		pool := NewPatternPool()
+
+		i0, err0 := pool.Put("^a+$")
+		i1, err1 := pool.Put("[0-9]")
+		i2, err2 := pool.Put("^a+$")
+
+		assert.Nil(t, err0)
+		assert.Nil(t, err1)
+		assert.Nil(t, err2)
+		assert.Equal(t, PatternIndex(0), i0)
+		assert.Equal(t, PatternIndex(1), i1)
+		assert.Equal(t, PatternIndex(0), i2)
+		assert.True(t, pool.Get(0).MatchString("aaa"))
+		assert.False(t, pool.Get(0).MatchString("aab"))
+		assert.True(t, pool.Get(1).MatchString("x7y"))
+		assert.Equal(t, "[0-9]", pool.Get(1).String())
+	})
+
+	t.Run("invalid patterns", func(t *testing.T) {
+		pool := NewPatternPool()
+
+		_, err := pool.Put("a(b")
+
+		assert.EqualError(t, err, "error parsing regexp: missing closing ): `a(b`")
+
+		i0, _ := pool.Put("b")
+		assert.Equal(t, PatternIndex(0), i0)
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
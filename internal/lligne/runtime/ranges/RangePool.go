@@ -0,0 +1,50 @@
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package ranges
+
+//=====================================================================================================================
+
+// Range holds the two bounds of a range, both included in it, in the representation of their type.
+type Range struct {
+	Low  uint64
+	High uint64
+}
+
+//=====================================================================================================================
+
+// RangePool holds a list of ranges stored so that they can be retrieved by index.
+type RangePool struct {
+	ranges []Range
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// NewRangePool creates a new empty range pool.
+func NewRangePool() *RangePool {
+	return &RangePool{
+		ranges: nil,
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Get returns the range at the given index.
+func (p *RangePool) Get(index uint64) Range {
+	return p.ranges[index]
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Put adds a range to the pool.
+// Returns the index of the new entry.
+func (p *RangePool) Put(value Range) uint64 {
+	result := uint64(len(p.ranges))
+	p.ranges = append(p.ranges, value)
+
+	return result
+}
+
+//=====================================================================================================================
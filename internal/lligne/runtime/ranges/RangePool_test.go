@@ -0,0 +1,33 @@
+//
+// # Tests of RangePool.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package ranges
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestRangePool(t *testing.T) {
+
+	t.Run("pooled ranges", func(t *testing.T) {
+		pool := NewRangePool()
+
+		i0 := pool.Put(Range{Low: 1, High: 10})
+		i1 := pool.Put(Range{Low: 5, High: 5})
+
+		assert.Equal(t, uint64(0), i0)
+		assert.Equal(t, uint64(1), i1)
+		assert.Equal(t, Range{Low: 1, High: 10}, pool.Get(i0))
+		assert.Equal(t, Range{Low: 5, High: 5}, pool.Get(i1))
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
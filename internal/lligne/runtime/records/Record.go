@@ -5,7 +5,12 @@
 
 package records
 
-import "lligne-cli/internal/lligne/runtime/types"
+import (
+	"lligne-cli/internal/lligne/runtime/optionals"
+	"lligne-cli/internal/lligne/runtime/pools"
+	"lligne-cli/internal/lligne/runtime/types"
+	"lligne-cli/internal/lligne/runtime/unions"
+)
 
 //=====================================================================================================================
 
@@ -20,7 +25,15 @@ type RecordFieldValue = uint64
 
 //=====================================================================================================================
 
-func AreRecordsEqual(p *types.TypePool, r *RecordPool, r1Index uint64, r2Index uint64) bool {
+// AreRecordsEqual determines whether two records have equivalent types and equal field values. Field values that are
+// records are compared in turn; any other field values are compared by the given function.
+func AreRecordsEqual(
+	p *types.TypePool,
+	r *RecordPool,
+	r1Index uint64,
+	r2Index uint64,
+	areValuesEqual func(typeIndex types.TypeIndex, value1 uint64, value2 uint64) bool,
+) bool {
 
 	r1 := r.Get(r1Index)
 	r2 := r.Get(r2Index)
@@ -32,14 +45,14 @@ func AreRecordsEqual(p *types.TypePool, r *RecordPool, r1Index uint64, r2Index u
 	for i, f1 := range r1.FieldValues {
 		r1Type := p.Get(r1.TypeIndex).(*types.RecordType)
 
-		f1Type := p.Get(r1Type.FieldTypeIndexes[i])
+		f1TypeIndex := r1Type.FieldTypeIndexes[i]
 		f2 := r2.FieldValues[i]
 
-		if f1Type.Category() == types.TypeCategoryRecord {
-			if !AreRecordsEqual(p, r, f1, f2) {
+		if p.Get(f1TypeIndex).Category() == types.TypeCategoryRecord {
+			if !AreRecordsEqual(p, r, f1, f2, areValuesEqual) {
 				return false
 			}
-		} else if f1 != f2 {
+		} else if !areValuesEqual(f1TypeIndex, f1, f2) {
 			return false
 		}
 	}
@@ -49,6 +62,183 @@ func AreRecordsEqual(p *types.TypePool, r *RecordPool, r1Index uint64, r2Index u
 
 //=====================================================================================================================
 
+// CombineRecords creates the record with given type that results from applying an arithmetic operator field by field
+// to two records with the same field names. Each field of the new record, in the order of the given type, combines the
+// values of the fields with its name in the two records by the given function, except that field values that are
+// records are combined in turn. Returns the index of the new record.
+func CombineRecords(
+	p *types.TypePool,
+	r *RecordPool,
+	typeIndex types.TypeIndex,
+	r1Index uint64,
+	r2Index uint64,
+	combineValues func(typeIndex types.TypeIndex, value1 uint64, value2 uint64) uint64,
+) uint64 {
+
+	r1 := r.Get(r1Index)
+	r2 := r.Get(r2Index)
+
+	recordType := p.Get(typeIndex).(*types.RecordType)
+	r1Type := p.Get(r1.TypeIndex).(*types.RecordType)
+	r2Type := p.Get(r2.TypeIndex).(*types.RecordType)
+
+	fieldValues := make([]RecordFieldValue, len(recordType.FieldNameIndexes))
+
+	for i, fieldNameIndex := range recordType.FieldNameIndexes {
+		f1 := r1.FieldValues[r1Type.FieldIndex(fieldNameIndex)]
+		f2 := r2.FieldValues[r2Type.FieldIndex(fieldNameIndex)]
+
+		fieldTypeIndex := recordType.FieldTypeIndexes[i]
+
+		if p.Get(fieldTypeIndex).Category() == types.TypeCategoryRecord {
+			fieldValues[i] = CombineRecords(p, r, fieldTypeIndex, f1, f2, combineValues)
+		} else {
+			fieldValues[i] = combineValues(fieldTypeIndex, f1, f2)
+		}
+	}
+
+	return r.Put(Record{
+		TypeIndex:   typeIndex,
+		FieldValues: fieldValues,
+	})
+
+}
+
+//=====================================================================================================================
+
+// MergeRecords creates the record with given type that results from intersecting two records. Each field of the new
+// record takes its value from the record with the more definite presence of the field, the right hand record winning
+// a tie so that its default values override those of the left hand record. Record values given on both sides are
+// intersected in turn, while other values given on both sides are passed to the given function, which fails unless
+// they are equal. Values taken into fields of an optional type from fields of its value type are put in the optional
+// pool, and values taken into fields of a tagged union type from fields of another type are put in the union pool or
+// the other way around. Returns the index of the new record.
+func MergeRecords(
+	p *types.TypePool,
+	r *RecordPool,
+	o *optionals.OptionalPool,
+	u *unions.UnionPool,
+	typeIndex types.TypeIndex,
+	r1Index uint64,
+	r2Index uint64,
+	checkSameValues func(fieldNameIndex pools.NameIndex, typeIndex types.TypeIndex, value1 uint64, value2 uint64),
+) uint64 {
+
+	r1 := r.Get(r1Index)
+	r2 := r.Get(r2Index)
+
+	recordType := p.Get(typeIndex).(*types.RecordType)
+	r1Type := p.Get(r1.TypeIndex).(*types.RecordType)
+	r2Type := p.Get(r2.TypeIndex).(*types.RecordType)
+
+	fieldValues := make([]RecordFieldValue, len(recordType.FieldNameIndexes))
+
+	for i, fieldNameIndex := range recordType.FieldNameIndexes {
+		f1 := r1Type.FieldIndex(fieldNameIndex)
+		f2 := r2Type.FieldIndex(fieldNameIndex)
+
+		var fieldTypeIndex types.TypeIndex
+
+		switch {
+		case f2 < 0 || (f1 >= 0 && r1Type.FieldPresence(f1) < r2Type.FieldPresence(f2)):
+			fieldValues[i] = r1.FieldValues[f1]
+			fieldTypeIndex = r1Type.FieldTypeIndexes[f1]
+		case f1 >= 0 && r1Type.FieldPresence(f1) == types.RecordFieldPresenceValue &&
+			r2Type.FieldPresence(f2) == types.RecordFieldPresenceValue &&
+			p.Get(recordType.FieldTypeIndexes[i]).Category() == types.TypeCategoryRecord:
+			fieldValues[i] = MergeRecords(p, r, o, u, recordType.FieldTypeIndexes[i], r1.FieldValues[f1],
+				r2.FieldValues[f2], checkSameValues)
+			fieldTypeIndex = recordType.FieldTypeIndexes[i]
+		case f1 >= 0 && r1Type.FieldPresence(f1) == types.RecordFieldPresenceValue &&
+			r2Type.FieldPresence(f2) == types.RecordFieldPresenceValue:
+			value1 := toFieldValue(p, o, u, recordType.FieldTypeIndexes[i], r1Type.FieldTypeIndexes[f1], r1.FieldValues[f1])
+			value2 := toFieldValue(p, o, u, recordType.FieldTypeIndexes[i], r2Type.FieldTypeIndexes[f2], r2.FieldValues[f2])
+			checkSameValues(fieldNameIndex, recordType.FieldTypeIndexes[i], value1, value2)
+			fieldValues[i] = r2.FieldValues[f2]
+			fieldTypeIndex = r2Type.FieldTypeIndexes[f2]
+		default:
+			fieldValues[i] = r2.FieldValues[f2]
+			fieldTypeIndex = r2Type.FieldTypeIndexes[f2]
+		}
+
+		fieldValues[i] = toFieldValue(p, o, u, recordType.FieldTypeIndexes[i], fieldTypeIndex, fieldValues[i])
+	}
+
+	return r.Put(Record{
+		TypeIndex:   typeIndex,
+		FieldValues: fieldValues,
+	})
+
+}
+
+//=====================================================================================================================
+
+// isOptional determines whether the type with given index is an optional type.
+func isOptional(p *types.TypePool, typeIndex types.TypeIndex) bool {
+	return p.Get(typeIndex).Category() == types.TypeCategoryOptional
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// toFieldValue converts a value taken from a field of one type into a value of a field of another type, putting it in
+// the optional pool when the new field has an optional type and the old field has its value type. A present value is
+// tagged or untagged as needed by the value type of the new field.
+func toFieldValue(
+	p *types.TypePool,
+	o *optionals.OptionalPool,
+	u *unions.UnionPool,
+	fieldTypeIndex types.TypeIndex,
+	valueTypeIndex types.TypeIndex,
+	value uint64,
+) uint64 {
+
+	fieldValueTypeIndex := fieldTypeIndex
+	if optionalType, ok := p.Get(fieldTypeIndex).(*types.OptionalType); ok {
+		fieldValueTypeIndex = optionalType.ValueTypeIndex
+	}
+
+	switch valueType := p.Get(valueTypeIndex).(type) {
+	case *types.NoneType:
+		return value
+	case *types.OptionalType:
+		if value == optionals.NoneValue ||
+			p.IsTaggedUnion(fieldValueTypeIndex) == p.IsTaggedUnion(valueType.ValueTypeIndex) {
+			return value
+		}
+		return o.Put(toUnionValue(p, u, fieldValueTypeIndex, valueType.ValueTypeIndex, o.Get(value)))
+	}
+
+	value = toUnionValue(p, u, fieldValueTypeIndex, valueTypeIndex, value)
+
+	if isOptional(p, fieldTypeIndex) {
+		return o.Put(value)
+	}
+	return value
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// toUnionValue converts a value of one type into a value of another type, tagging it when only the new type is a
+// tagged union and untagging it when only the old type is.
+func toUnionValue(
+	p *types.TypePool,
+	u *unions.UnionPool,
+	typeIndex types.TypeIndex,
+	valueTypeIndex types.TypeIndex,
+	value uint64,
+) uint64 {
+	switch {
+	case p.IsTaggedUnion(typeIndex) && !p.IsTaggedUnion(valueTypeIndex):
+		return u.Put(unions.UnionValue{TypeIndex: p.BaseTypeIndex(valueTypeIndex), Value: value})
+	case !p.IsTaggedUnion(typeIndex) && p.IsTaggedUnion(valueTypeIndex):
+		return u.Get(value).Value
+	}
+	return value
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 func areRecordTypesEquivalent(
 	p *types.TypePool,
 	r *RecordPool,
@@ -78,7 +268,7 @@ func areRecordTypesEquivalent(
 			if !areRecordTypesEquivalent(p, r, field1TypeIndex, field2TypeIndex) {
 				return false
 			}
-		} else if field1TypeIndex != field2TypeIndex {
+		} else if p.BaseTypeIndex(field1TypeIndex) != p.BaseTypeIndex(field2TypeIndex) {
 			return false
 		}
 	}
@@ -5,6 +5,13 @@
 
 package types
 
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
 //=====================================================================================================================
 
 type TypeIndex uint64
@@ -13,9 +20,29 @@ type TypeIndex uint64
 
 // TypePool holds a list of types interned so that they can be retrieved by index.
 type TypePool struct {
-	types         []IType
-	indexes       map[IType]TypeIndex
-	indexesByName map[string]TypeIndex
+	types             []IType
+	indexes           map[IType]TypeIndex
+	indexesByName     map[string]TypeIndex
+	arrayIndexes      map[TypeIndex]TypeIndex
+	constraintIndexes map[constraintKey]TypeIndex
+	functionIndexes   map[string]TypeIndex
+	literalIndexes    map[literalKey]TypeIndex
+	optionalIndexes   map[TypeIndex]TypeIndex
+	rangeIndexes      map[TypeIndex]TypeIndex
+	unionIndexes      map[string]TypeIndex
+}
+
+// constraintKey identifies a constraint type by its constrained type and predicate, so that equal constraint types
+// are pooled once.
+type constraintKey struct {
+	constrainedTypeIndex TypeIndex
+	predicate            string
+}
+
+// literalKey identifies a literal type by its value, so that equal literal types are pooled once.
+type literalKey struct {
+	baseTypeIndex TypeIndex
+	value         uint64
 }
 
 //---------------------------------------------------------------------------------------------------------------------
@@ -23,9 +50,16 @@ type TypePool struct {
 // NewTypePool creates a new empty type pool.
 func NewTypePool() *TypePool {
 	result := &TypePool{
-		types:         nil,
-		indexes:       make(map[IType]TypeIndex),
-		indexesByName: make(map[string]TypeIndex),
+		types:             nil,
+		indexes:           make(map[IType]TypeIndex),
+		indexesByName:     make(map[string]TypeIndex),
+		arrayIndexes:      make(map[TypeIndex]TypeIndex),
+		constraintIndexes: make(map[constraintKey]TypeIndex),
+		functionIndexes:   make(map[string]TypeIndex),
+		literalIndexes:    make(map[literalKey]TypeIndex),
+		optionalIndexes:   make(map[TypeIndex]TypeIndex),
+		rangeIndexes:      make(map[TypeIndex]TypeIndex),
+		unionIndexes:      make(map[string]TypeIndex),
 	}
 
 	// NOTE: Keep these in sync with BuiltInTypeIndex just below
@@ -35,6 +69,8 @@ func NewTypePool() *TypePool {
 	result.Put(Int64TypeInstance)
 	result.Put(StringTypeInstance)
 	result.Put(TypeTypeInstance)
+	result.Put(ErrorTypeInstance)
+	result.Put(NoneTypeInstance)
 
 	return result
 }
@@ -50,10 +86,28 @@ const (
 	BuiltInTypeIndexInt64
 	BuiltInTypeIndexString
 	BuiltInTypeIndexType
+	BuiltInTypeIndexError
+	BuiltInTypeIndexNone
 )
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// AreTypesEqual determines whether the types at two given indexes are the same type, even when pooled separately, as
+// are the types of records with the same fields and of the types built from them.
+func (p *TypePool) AreTypesEqual(index1 TypeIndex, index2 TypeIndex) bool {
+	return areTypesEqual(p.types, index1, index2)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// BaseTypeIndex returns the index of the type whose representation is shared by the values of the type at the given
+// index: the base type of a literal type or of a union of such, otherwise the type itself.
+func (p *TypePool) BaseTypeIndex(index TypeIndex) TypeIndex {
+	return baseTypeIndex(p.types[index], index)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 // Freeze returns an immutable view of this type pool. The original mutable view should be abandoned afterward.
 func (p *TypePool) Freeze() *TypeConstantPool {
 	return &TypeConstantPool{
@@ -84,6 +138,14 @@ func (p *TypePool) GetIndexByName(name string) TypeIndex {
 
 //---------------------------------------------------------------------------------------------------------------------
 
+// IsTaggedUnion determines whether the values of the type at the given index are tagged with the base types of their
+// members, as are the values of a union whose members are represented in different ways.
+func (p *TypePool) IsTaggedUnion(index TypeIndex) bool {
+	return isTaggedUnion(p.types, index)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
 // Put looks for the type already in the pool. It adds it if not there.
 // Returns the index of the new or existing entry.
 func (p *TypePool) Put(value IType) TypeIndex {
@@ -94,11 +156,269 @@ func (p *TypePool) Put(value IType) TypeIndex {
 		p.types = append(p.types, value)
 		p.indexes[value] = result
 		p.indexesByName[value.Name()] = result
+
+		switch typ := value.(type) {
+		case *ArrayType:
+			p.arrayIndexes[typ.ElementTypeIndex] = result
+		case *ConstraintType:
+			p.constraintIndexes[constraintKey{typ.ConstrainedTypeIndex, typ.Predicate}] = result
+		case *FunctionType:
+			p.functionIndexes[functionKey(typ.ParameterTypeIndexes, typ.ResultTypeIndex)] = result
+		case *LiteralType:
+			p.literalIndexes[literalKey{typ.BaseTypeIndex, typ.Value}] = result
+		case *OptionalType:
+			p.optionalIndexes[typ.ValueTypeIndex] = result
+		case *RangeType:
+			p.rangeIndexes[typ.BoundTypeIndex] = result
+		case *UnionType:
+			p.unionIndexes[unionKey(typ.MemberTypeIndexes)] = result
+		}
+	}
+
+	return result
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// PutArray looks for the array type with elements of the type with given index. It adds it if not there. Returns the
+// index of the new or existing entry.
+func (p *TypePool) PutArray(elementTypeIndex TypeIndex) TypeIndex {
+	result, found := p.arrayIndexes[elementTypeIndex]
+
+	if !found {
+		name := p.types[elementTypeIndex].Name()
+		switch p.types[elementTypeIndex].(type) {
+		case *ConstraintType, *FunctionType, *UnionType:
+			name = "(" + name + ")"
+		}
+
+		result = p.Put(&ArrayType{
+			ElementTypeIndex: elementTypeIndex,
+			name:             name + "[]",
+		})
+	}
+
+	return result
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// PutConstraint looks for the constraint type restricting the type with given index to the values satisfying the
+// given predicate source code. It adds it if not there. Returns the index of the new or existing entry.
+func (p *TypePool) PutConstraint(constrainedTypeIndex TypeIndex, predicate string) TypeIndex {
+	result, found := p.constraintIndexes[constraintKey{constrainedTypeIndex, predicate}]
+
+	if !found {
+		name := p.types[constrainedTypeIndex].Name()
+		if _, isUnion := p.types[constrainedTypeIndex].(*UnionType); isUnion {
+			name = "(" + name + ")"
+		}
+
+		result = p.Put(&ConstraintType{
+			ConstrainedTypeIndex: constrainedTypeIndex,
+			BaseTypeIndex:        baseTypeIndex(p.types[constrainedTypeIndex], constrainedTypeIndex),
+			Predicate:            predicate,
+			name:                 name + " && " + predicate,
+		})
 	}
 
 	return result
 }
 
+//---------------------------------------------------------------------------------------------------------------------
+
+// PutFunction looks for the function type with parameters and result of the types with given indexes. It adds it if
+// not there. Returns the index of the new or existing entry.
+func (p *TypePool) PutFunction(parameterTypeIndexes []TypeIndex, resultTypeIndex TypeIndex) TypeIndex {
+	result, found := p.functionIndexes[functionKey(parameterTypeIndexes, resultTypeIndex)]
+
+	if !found {
+		names := make([]string, len(parameterTypeIndexes))
+		for i, parameterTypeIndex := range parameterTypeIndexes {
+			names[i] = p.types[parameterTypeIndex].Name()
+		}
+
+		resultName := p.types[resultTypeIndex].Name()
+		switch p.types[resultTypeIndex].(type) {
+		case *ConstraintType, *FunctionType, *UnionType:
+			resultName = "(" + resultName + ")"
+		}
+
+		result = p.Put(&FunctionType{
+			ParameterTypeIndexes: parameterTypeIndexes,
+			ResultTypeIndex:      resultTypeIndex,
+			name:                 "(" + strings.Join(names, ", ") + ") -> " + resultName,
+		})
+	}
+
+	return result
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// PutLiteral looks for the literal type with given value of the given base type. It adds it if not there.
+// Returns the index of the new or existing entry.
+func (p *TypePool) PutLiteral(baseTypeIndex TypeIndex, value uint64, text string) TypeIndex {
+	result, found := p.literalIndexes[literalKey{baseTypeIndex, value}]
+
+	if !found {
+		result = p.Put(&LiteralType{
+			BaseTypeIndex: baseTypeIndex,
+			Value:         value,
+			Text:          text,
+		})
+	}
+
+	return result
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// PutOptional looks for the optional type with values of the type with given index. It adds it if not there. Returns
+// the index of the new or existing entry, or the given index itself for a type that is optional already.
+func (p *TypePool) PutOptional(valueTypeIndex TypeIndex) TypeIndex {
+
+	switch p.types[valueTypeIndex].(type) {
+	case *NoneType, *OptionalType:
+		return valueTypeIndex
+	}
+
+	result, found := p.optionalIndexes[valueTypeIndex]
+
+	if !found {
+		name := p.types[valueTypeIndex].Name()
+		switch p.types[valueTypeIndex].(type) {
+		case *ConstraintType, *FunctionType, *UnionType:
+			name = "(" + name + ")"
+		}
+
+		result = p.Put(&OptionalType{
+			ValueTypeIndex: valueTypeIndex,
+			name:           name + "?",
+		})
+	}
+
+	return result
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// PutRange looks for the range type with bounds of the type with given index. It adds it if not there. Returns the
+// index of the new or existing entry.
+func (p *TypePool) PutRange(boundTypeIndex TypeIndex) TypeIndex {
+	result, found := p.rangeIndexes[boundTypeIndex]
+
+	if !found {
+		name := p.types[boundTypeIndex].Name()
+
+		result = p.Put(&RangeType{
+			BoundTypeIndex: boundTypeIndex,
+			name:           name + ".." + name,
+		})
+	}
+
+	return result
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// PutUnion looks for the union of the types with given indexes. It adds it if not there. The members of the union are
+// canonicalized first: nested unions are flattened, duplicates are removed, literal types are dropped in favor of
+// their base type when that is a member too, and the rest are put in canonical order. Returns the index of the new
+// or existing entry, which is the index of the only member when just one remains.
+func (p *TypePool) PutUnion(memberTypeIndexes []TypeIndex) TypeIndex {
+
+	var members []TypeIndex
+	isMember := make(map[TypeIndex]bool)
+	for _, memberTypeIndex := range memberTypeIndexes {
+		flattened := []TypeIndex{memberTypeIndex}
+		if union, ok := p.types[memberTypeIndex].(*UnionType); ok {
+			flattened = union.MemberTypeIndexes
+		}
+		for _, member := range flattened {
+			if !isMember[member] {
+				isMember[member] = true
+				members = append(members, member)
+			}
+		}
+	}
+
+	canonicalMembers := members[:0]
+	for _, member := range members {
+		if literal, ok := p.types[member].(*LiteralType); !ok || !isMember[literal.BaseTypeIndex] {
+			canonicalMembers = append(canonicalMembers, member)
+		}
+	}
+	members = canonicalMembers
+
+	sort.Slice(members, func(i, j int) bool {
+		return p.isOrderedBefore(members[i], members[j])
+	})
+
+	if len(members) == 1 {
+		return members[0]
+	}
+
+	result, found := p.unionIndexes[unionKey(members)]
+
+	if !found {
+		names := make([]string, len(members))
+		for i, member := range members {
+			names[i] = p.types[member].Name()
+		}
+
+		// Members represented in different ways leave the union as its own base type
+		unionBaseTypeIndex := baseTypeIndex(p.types[members[0]], members[0])
+		for _, member := range members[1:] {
+			if baseTypeIndex(p.types[member], member) != unionBaseTypeIndex {
+				unionBaseTypeIndex = TypeIndex(len(p.types))
+				break
+			}
+		}
+
+		result = p.Put(&UnionType{
+			MemberTypeIndexes: members,
+			BaseTypeIndex:     unionBaseTypeIndex,
+			name:              strings.Join(names, " | "),
+		})
+	}
+
+	return result
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// isOrderedBefore defines the canonical order of the members of a union: grouped by base type, with literal types
+// after any other types and in order of their values.
+func (p *TypePool) isOrderedBefore(index1 TypeIndex, index2 TypeIndex) bool {
+
+	base1 := baseTypeIndex(p.types[index1], index1)
+	base2 := baseTypeIndex(p.types[index2], index2)
+	if base1 != base2 {
+		return base1 < base2
+	}
+
+	literal1, ok1 := p.types[index1].(*LiteralType)
+	literal2, ok2 := p.types[index2].(*LiteralType)
+	if !ok1 || !ok2 {
+		return !ok1 && ok2
+	}
+
+	switch base1 {
+	case BuiltInTypeIndexFloat64:
+		return math.Float64frombits(literal1.Value) < math.Float64frombits(literal2.Value)
+	case BuiltInTypeIndexInt64:
+		return int64(literal1.Value) < int64(literal2.Value)
+	case BuiltInTypeIndexString:
+		return literal1.Text < literal2.Text
+	default:
+		return literal1.Value < literal2.Value
+	}
+
+}
+
 //=====================================================================================================================
 
 // TypeConstantPool is an immutable view of a TypePool.
@@ -124,4 +444,124 @@ func (p *TypeConstantPool) Get(index TypeIndex) IType {
 	return p.ITypes[index]
 }
 
+//---------------------------------------------------------------------------------------------------------------------
+
+// BaseTypeIndex returns the index of the type whose representation is shared by the values of the type at the given
+// index: the base type of a literal type or of a union of such, otherwise the type itself.
+func (p *TypeConstantPool) BaseTypeIndex(index TypeIndex) TypeIndex {
+	return baseTypeIndex(p.ITypes[index], index)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// IsTaggedUnion determines whether the values of the type at the given index are tagged with the base types of their
+// members, as are the values of a union whose members are represented in different ways.
+func (p *TypeConstantPool) IsTaggedUnion(index TypeIndex) bool {
+	return isTaggedUnion(p.ITypes, index)
+}
+
+//=====================================================================================================================
+
+func baseTypeIndex(typ IType, index TypeIndex) TypeIndex {
+	switch t := typ.(type) {
+	case *ConstraintType:
+		return t.BaseTypeIndex
+	case *LiteralType:
+		return t.BaseTypeIndex
+	case *UnionType:
+		return t.BaseTypeIndex
+	default:
+		return index
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// areTypesEqual determines whether the types at two given indexes, among the given types, are the same type. Record
+// types are not pooled by their fields, so records with the same field names, presences, and types, and the types
+// built from such records, are compared by their structure; other types are pooled once.
+func areTypesEqual(iTypes []IType, index1 TypeIndex, index2 TypeIndex) bool {
+	if index1 == index2 {
+		return true
+	}
+
+	switch type1 := iTypes[index1].(type) {
+	case *ArrayType:
+		type2, ok := iTypes[index2].(*ArrayType)
+		return ok && areTypesEqual(iTypes, type1.ElementTypeIndex, type2.ElementTypeIndex)
+	case *ConstraintType:
+		type2, ok := iTypes[index2].(*ConstraintType)
+		return ok && type1.Predicate == type2.Predicate &&
+			areTypesEqual(iTypes, type1.ConstrainedTypeIndex, type2.ConstrainedTypeIndex)
+	case *FunctionType:
+		type2, ok := iTypes[index2].(*FunctionType)
+		return ok && areTypeListsEqual(iTypes, type1.ParameterTypeIndexes, type2.ParameterTypeIndexes) &&
+			areTypesEqual(iTypes, type1.ResultTypeIndex, type2.ResultTypeIndex)
+	case *OptionalType:
+		type2, ok := iTypes[index2].(*OptionalType)
+		return ok && areTypesEqual(iTypes, type1.ValueTypeIndex, type2.ValueTypeIndex)
+	case *RangeType:
+		type2, ok := iTypes[index2].(*RangeType)
+		return ok && areTypesEqual(iTypes, type1.BoundTypeIndex, type2.BoundTypeIndex)
+	case *RecordType:
+		type2, ok := iTypes[index2].(*RecordType)
+		if !ok || len(type1.FieldNameIndexes) != len(type2.FieldNameIndexes) {
+			return false
+		}
+		for i, fieldNameIndex := range type1.FieldNameIndexes {
+			j := type2.FieldIndex(fieldNameIndex)
+			if j < 0 || type1.FieldPresence(i) != type2.FieldPresence(j) ||
+				!areTypesEqual(iTypes, type1.FieldTypeIndexes[i], type2.FieldTypeIndexes[j]) {
+				return false
+			}
+		}
+		return true
+	case *UnionType:
+		type2, ok := iTypes[index2].(*UnionType)
+		return ok && areTypeListsEqual(iTypes, type1.MemberTypeIndexes, type2.MemberTypeIndexes)
+	default:
+		return false
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// areTypeListsEqual determines whether two lists of type indexes, among the given types, have the same types in the
+// same order.
+func areTypeListsEqual(iTypes []IType, indexes1 []TypeIndex, indexes2 []TypeIndex) bool {
+	if len(indexes1) != len(indexes2) {
+		return false
+	}
+	for i := range indexes1 {
+		if !areTypesEqual(iTypes, indexes1[i], indexes2[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// isTaggedUnion determines whether the type at the given index, among the given types, is its own base type by being a
+// union of members with different base types, or is a constraint on such a union.
+func isTaggedUnion(iTypes []IType, index TypeIndex) bool {
+	_, ok := iTypes[baseTypeIndex(iTypes[index], index)].(*UnionType)
+	return ok
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// functionKey identifies a function type by its parameter and result types, so that equal function types are pooled
+// once.
+func functionKey(parameterTypeIndexes []TypeIndex, resultTypeIndex TypeIndex) string {
+	return fmt.Sprint(parameterTypeIndexes, resultTypeIndex)
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// unionKey identifies a union by its canonical members, so that equal unions are pooled once.
+func unionKey(memberTypeIndexes []TypeIndex) string {
+	return fmt.Sprint(memberTypeIndexes)
+}
+
 //=====================================================================================================================
@@ -9,6 +9,7 @@ package types
 
 import (
 	"github.com/stretchr/testify/assert"
+	"lligne-cli/internal/lligne/runtime/pools"
 	"testing"
 )
 
@@ -25,6 +26,168 @@ func TestStringPool(t *testing.T) {
 		assert.Equal(t, Int64TypeInstance, pool.Get(3))
 		assert.Equal(t, StringTypeInstance, pool.Get(4))
 		assert.Equal(t, TypeTypeInstance, pool.Get(5))
+		assert.Equal(t, ErrorTypeInstance, pool.Get(6))
+		assert.Equal(t, NoneTypeInstance, pool.Get(7))
+	})
+
+	t.Run("pooled optional types", func(t *testing.T) {
+		pool := NewTypePool()
+
+		optionalInt64 := pool.PutOptional(BuiltInTypeIndexInt64)
+		dev := pool.PutLiteral(BuiltInTypeIndexString, 1, "\"dev\"")
+		prod := pool.PutLiteral(BuiltInTypeIndexString, 2, "\"prod\"")
+		optionalDevOrProd := pool.PutOptional(pool.PutUnion([]TypeIndex{dev, prod}))
+
+		assert.Equal(t, "Int64?", pool.Get(optionalInt64).Name())
+		assert.Equal(t, "(\"dev\" | \"prod\")?", pool.Get(optionalDevOrProd).Name())
+
+		assert.Equal(t, optionalInt64, pool.PutOptional(BuiltInTypeIndexInt64))
+		assert.Equal(t, optionalInt64, pool.PutOptional(optionalInt64))
+		assert.Equal(t, BuiltInTypeIndexNone, pool.PutOptional(BuiltInTypeIndexNone))
+		assert.Equal(t, optionalInt64, pool.BaseTypeIndex(optionalInt64))
+	})
+
+	t.Run("pooled range types", func(t *testing.T) {
+		pool := NewTypePool()
+
+		int64Range := pool.PutRange(BuiltInTypeIndexInt64)
+		stringRange := pool.PutRange(BuiltInTypeIndexString)
+
+		assert.Equal(t, "Int64..Int64", pool.Get(int64Range).Name())
+		assert.Equal(t, "String..String", pool.Get(stringRange).Name())
+
+		assert.Equal(t, int64Range, pool.PutRange(BuiltInTypeIndexInt64))
+		assert.NotEqual(t, int64Range, stringRange)
+		assert.Equal(t, int64Range, pool.Freeze().Clone().PutRange(BuiltInTypeIndexInt64))
+	})
+
+	t.Run("pooled array types", func(t *testing.T) {
+		pool := NewTypePool()
+
+		int64Array := pool.PutArray(BuiltInTypeIndexInt64)
+		int64ArrayArray := pool.PutArray(int64Array)
+		optionalStringArray := pool.PutArray(pool.PutOptional(BuiltInTypeIndexString))
+		dev := pool.PutLiteral(BuiltInTypeIndexString, 1, "\"dev\"")
+		prod := pool.PutLiteral(BuiltInTypeIndexString, 2, "\"prod\"")
+		devOrProdArray := pool.PutArray(pool.PutUnion([]TypeIndex{dev, prod}))
+
+		assert.Equal(t, "Int64[]", pool.Get(int64Array).Name())
+		assert.Equal(t, "Int64[][]", pool.Get(int64ArrayArray).Name())
+		assert.Equal(t, "String?[]", pool.Get(optionalStringArray).Name())
+		assert.Equal(t, "(\"dev\" | \"prod\")[]", pool.Get(devOrProdArray).Name())
+
+		assert.Equal(t, int64Array, pool.PutArray(BuiltInTypeIndexInt64))
+		assert.Equal(t, int64Array, pool.BaseTypeIndex(int64Array))
+	})
+
+	t.Run("pooled function types", func(t *testing.T) {
+		pool := NewTypePool()
+
+		int64ToInt64 := pool.PutFunction([]TypeIndex{BuiltInTypeIndexInt64}, BuiltInTypeIndexInt64)
+		twoArguments := pool.PutFunction([]TypeIndex{BuiltInTypeIndexInt64, BuiltInTypeIndexString}, BuiltInTypeIndexBool)
+		unionResult := pool.PutFunction([]TypeIndex{BuiltInTypeIndexInt64},
+			pool.PutUnion([]TypeIndex{BuiltInTypeIndexInt64, BuiltInTypeIndexString}))
+
+		assert.Equal(t, "(Int64) -> Int64", pool.Get(int64ToInt64).Name())
+		assert.Equal(t, "(Int64, String) -> Bool", pool.Get(twoArguments).Name())
+		assert.Equal(t, "(Int64) -> (Int64 | String)", pool.Get(unionResult).Name())
+
+		assert.Equal(t, "((Int64) -> Int64)[]", pool.Get(pool.PutArray(int64ToInt64)).Name())
+		assert.Equal(t, "((Int64) -> Int64)?", pool.Get(pool.PutOptional(int64ToInt64)).Name())
+
+		assert.Equal(t, int64ToInt64, pool.PutFunction([]TypeIndex{BuiltInTypeIndexInt64}, BuiltInTypeIndexInt64))
+		assert.NotEqual(t, int64ToInt64, pool.PutFunction([]TypeIndex{BuiltInTypeIndexInt64}, BuiltInTypeIndexString))
+	})
+
+	t.Run("pooled constraint types", func(t *testing.T) {
+		pool := NewTypePool()
+
+		atMost100 := pool.PutConstraint(BuiltInTypeIndexInt64, "val <= 100")
+		positive := pool.PutConstraint(BuiltInTypeIndexInt64, "val > 0")
+		dev := pool.PutLiteral(BuiltInTypeIndexString, 1, "\"dev\"")
+		prod := pool.PutLiteral(BuiltInTypeIndexString, 2, "\"prod\"")
+		notDev := pool.PutConstraint(pool.PutUnion([]TypeIndex{dev, prod}), "val != \"dev\"")
+
+		assert.NotEqual(t, atMost100, positive)
+		assert.Equal(t, atMost100, pool.PutConstraint(BuiltInTypeIndexInt64, "val <= 100"))
+		assert.Equal(t, "Int64 && val <= 100", pool.Get(atMost100).Name())
+		assert.Equal(t, "(\"dev\" | \"prod\") && val != \"dev\"", pool.Get(notDev).Name())
+		assert.Equal(t, BuiltInTypeIndexInt64, pool.BaseTypeIndex(atMost100))
+		assert.Equal(t, BuiltInTypeIndexString, pool.BaseTypeIndex(notDev))
+	})
+
+	t.Run("pooled literal types", func(t *testing.T) {
+		pool := NewTypePool()
+
+		dev := pool.PutLiteral(BuiltInTypeIndexString, 1, "\"dev\"")
+		one := pool.PutLiteral(BuiltInTypeIndexInt64, 1, "1")
+
+		assert.NotEqual(t, dev, one)
+		assert.Equal(t, dev, pool.PutLiteral(BuiltInTypeIndexString, 1, "\"dev\""))
+		assert.Equal(t, "\"dev\"", pool.Get(dev).Name())
+		assert.Equal(t, BuiltInTypeIndexString, pool.BaseTypeIndex(dev))
+	})
+
+	t.Run("equal types pooled separately", func(t *testing.T) {
+		pool := NewTypePool()
+
+		record1 := pool.Put(&RecordType{
+			FieldNameIndexes: []pools.NameIndex{1},
+			FieldTypeIndexes: []TypeIndex{BuiltInTypeIndexInt64},
+		})
+		record2 := pool.Put(&RecordType{
+			FieldNameIndexes: []pools.NameIndex{1},
+			FieldTypeIndexes: []TypeIndex{BuiltInTypeIndexInt64},
+		})
+		otherRecord := pool.Put(&RecordType{
+			FieldNameIndexes: []pools.NameIndex{2},
+			FieldTypeIndexes: []TypeIndex{BuiltInTypeIndexInt64},
+		})
+
+		assert.NotEqual(t, record1, record2)
+		assert.True(t, pool.AreTypesEqual(record1, record2))
+		assert.True(t, pool.AreTypesEqual(pool.PutOptional(record1), pool.PutOptional(record2)))
+		assert.True(t, pool.AreTypesEqual(pool.PutArray(record1), pool.PutArray(record2)))
+		assert.False(t, pool.AreTypesEqual(record1, otherRecord))
+		assert.False(t, pool.AreTypesEqual(pool.PutOptional(record1), record1))
+		assert.False(t, pool.AreTypesEqual(BuiltInTypeIndexInt64, BuiltInTypeIndexString))
+	})
+
+	t.Run("pooled union types", func(t *testing.T) {
+		pool := NewTypePool()
+
+		prod := pool.PutLiteral(BuiltInTypeIndexString, 2, "\"prod\"")
+		dev := pool.PutLiteral(BuiltInTypeIndexString, 1, "\"dev\"")
+		int64OrString := pool.PutUnion([]TypeIndex{BuiltInTypeIndexString, BuiltInTypeIndexInt64})
+		devOrProd := pool.PutUnion([]TypeIndex{prod, dev})
+
+		assert.Equal(t, "Int64 | String", pool.Get(int64OrString).Name())
+		assert.Equal(t, "\"dev\" | \"prod\"", pool.Get(devOrProd).Name())
+
+		assert.Equal(t, int64OrString, pool.PutUnion([]TypeIndex{BuiltInTypeIndexInt64, BuiltInTypeIndexString}))
+		assert.Equal(t, devOrProd, pool.PutUnion([]TypeIndex{dev, prod, dev}))
+		assert.Equal(t, devOrProd, pool.PutUnion([]TypeIndex{prod, devOrProd}))
+		assert.Equal(t, dev, pool.PutUnion([]TypeIndex{dev, dev}))
+		assert.Equal(t, BuiltInTypeIndexString, pool.PutUnion([]TypeIndex{devOrProd, BuiltInTypeIndexString}))
+
+		assert.Equal(t, BuiltInTypeIndexString, pool.BaseTypeIndex(devOrProd))
+		assert.Equal(t, int64OrString, pool.BaseTypeIndex(int64OrString))
+
+		assert.True(t, pool.IsTaggedUnion(int64OrString))
+		assert.False(t, pool.IsTaggedUnion(devOrProd))
+		assert.False(t, pool.IsTaggedUnion(BuiltInTypeIndexInt64))
+	})
+
+	t.Run("cloned union types", func(t *testing.T) {
+		pool := NewTypePool()
+
+		dev := pool.PutLiteral(BuiltInTypeIndexString, 1, "\"dev\"")
+		devOrInt64 := pool.PutUnion([]TypeIndex{dev, BuiltInTypeIndexInt64})
+
+		clone := pool.Freeze().Clone()
+
+		assert.Equal(t, dev, clone.PutLiteral(BuiltInTypeIndexString, 1, "\"dev\""))
+		assert.Equal(t, devOrInt64, clone.PutUnion([]TypeIndex{BuiltInTypeIndexInt64, dev}))
 	})
 
 }
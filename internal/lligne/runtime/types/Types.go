@@ -18,9 +18,17 @@ const (
 	TypeCategoryInt64
 	TypeCategoryString
 	TypeCategoryType
+	TypeCategoryError
+	TypeCategoryNone
 
+	TypeCategoryArray
+	TypeCategoryConstraint
+	TypeCategoryFunction
+	TypeCategoryLiteral
 	TypeCategoryOptional
+	TypeCategoryRange
 	TypeCategoryRecord
+	TypeCategoryUnion
 )
 
 //=====================================================================================================================
@@ -34,6 +42,19 @@ type IType interface {
 
 //=====================================================================================================================
 
+// ArrayType is the type of arrays whose elements all have one type, e.g. Int64[]. Array types are created by
+// TypePool.PutArray.
+type ArrayType struct {
+	ElementTypeIndex TypeIndex
+	name             string
+}
+
+func (t *ArrayType) isType()                {}
+func (t *ArrayType) Category() TypeCategory { return TypeCategoryArray }
+func (t *ArrayType) Name() string           { return t.name }
+
+//=====================================================================================================================
+
 type BoolType struct {
 }
 
@@ -45,6 +66,36 @@ var BoolTypeInstance = &BoolType{}
 
 //=====================================================================================================================
 
+// ConstraintType is a type whose values are the values of another type that satisfy a predicate, e.g. the type
+// "Int64 && val <= 100". The predicate is written in terms of "val", the value being checked, and is compiled into a
+// code block of its own, separate from the type. Values are represented the same way as values of the constrained type.
+// Constraint types are created by TypePool.PutConstraint.
+type ConstraintType struct {
+	ConstrainedTypeIndex TypeIndex
+	BaseTypeIndex        TypeIndex // The base type of the constrained type
+	Predicate            string    // The predicate as written in source code
+	name                 string
+}
+
+func (t *ConstraintType) isType()                {}
+func (t *ConstraintType) Category() TypeCategory { return TypeCategoryConstraint }
+func (t *ConstraintType) Name() string           { return t.name }
+
+//=====================================================================================================================
+
+// ErrorType is the type of an expression that failed type checking. It stands in for the type the expression should
+// have had, so that checking can go on without reporting further errors about the same mistake.
+type ErrorType struct {
+}
+
+func (t *ErrorType) isType()                {}
+func (t *ErrorType) Category() TypeCategory { return TypeCategoryError }
+func (t *ErrorType) Name() string           { return "Error" }
+
+var ErrorTypeInstance = &ErrorType{}
+
+//=====================================================================================================================
+
 type Float64Type struct {
 }
 
@@ -56,6 +107,21 @@ var Float64TypeInstance = &Float64Type{}
 
 //=====================================================================================================================
 
+// FunctionType is the type of functions taking arguments of given types and computing a result of another type, e.g.
+// (Int64, String) -> Bool. The names of the parameters are not part of the type. A value of a function type is the
+// index of the code block of the function. Function types are created by TypePool.PutFunction.
+type FunctionType struct {
+	ParameterTypeIndexes []TypeIndex
+	ResultTypeIndex      TypeIndex
+	name                 string
+}
+
+func (t *FunctionType) isType()                {}
+func (t *FunctionType) Category() TypeCategory { return TypeCategoryFunction }
+func (t *FunctionType) Name() string           { return t.name }
+
+//=====================================================================================================================
+
 type Int64Type struct {
 }
 
@@ -67,15 +133,99 @@ var Int64TypeInstance = &Int64Type{}
 
 //=====================================================================================================================
 
+// LiteralType is a type with a single value, e.g. the type "dev" whose only value is the string "dev". Its value is
+// represented the same way as the values of its base type.
+type LiteralType struct {
+	BaseTypeIndex TypeIndex
+	Value         uint64
+	Text          string // The value as written in source code
+}
+
+func (t *LiteralType) isType()                {}
+func (t *LiteralType) Category() TypeCategory { return TypeCategoryLiteral }
+func (t *LiteralType) Name() string           { return t.Text }
+
+//=====================================================================================================================
+
+// NoneType is the type of "none", the absent value of every optional type.
+type NoneType struct {
+}
+
+func (t *NoneType) isType()                {}
+func (t *NoneType) Category() TypeCategory { return TypeCategoryNone }
+func (t *NoneType) Name() string           { return "None" }
+
+var NoneTypeInstance = &NoneType{}
+
+//=====================================================================================================================
+
+// OptionalType is a type whose values are the values of another type plus the absent value "none", e.g. Int64?.
+// Optional types are created by TypePool.PutOptional, which never makes an optional of an optional.
+type OptionalType struct {
+	ValueTypeIndex TypeIndex
+	name           string
+}
+
+func (t *OptionalType) isType()                {}
+func (t *OptionalType) Category() TypeCategory { return TypeCategoryOptional }
+func (t *OptionalType) Name() string           { return t.name }
+
+//=====================================================================================================================
+
+// RangeType is the type of ranges between two bounds of one ordered type, inclusive of both, e.g. the type of 1..10,
+// named Int64..Int64. A range is represented by its index in a range pool. Range types are created by
+// TypePool.PutRange.
+type RangeType struct {
+	BoundTypeIndex TypeIndex
+	name           string
+}
+
+func (t *RangeType) isType()                {}
+func (t *RangeType) Category() TypeCategory { return TypeCategoryRange }
+func (t *RangeType) Name() string           { return t.name }
+
+//=====================================================================================================================
+
 type RecordType struct {
 	FieldNameIndexes []pools.NameIndex
 	FieldTypeIndexes []TypeIndex
+	FieldPresences   []RecordFieldPresence // nil when every field has a value of its own
 }
 
 func (t *RecordType) isType()                {}
 func (t *RecordType) Category() TypeCategory { return TypeCategoryRecord }
 func (t *RecordType) Name() string           { return "Record-TBD" }
 
+// FieldIndex returns the index of the field with given name or -1 if the record type has no such field.
+func (t *RecordType) FieldIndex(fieldNameIndex pools.NameIndex) int {
+	for i, nameIndex := range t.FieldNameIndexes {
+		if nameIndex == fieldNameIndex {
+			return i
+		}
+	}
+	return -1
+}
+
+// FieldPresence tells how the field with given index gets its value.
+func (t *RecordType) FieldPresence(fieldIndex int) RecordFieldPresence {
+	if t.FieldPresences == nil {
+		return RecordFieldPresenceValue
+	}
+	return t.FieldPresences[fieldIndex]
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// RecordFieldPresence tells how a field of a record gets its value. A record intersected with another one takes each
+// field from the record with the more definite presence, i.e. the lower one.
+type RecordFieldPresence uint16
+
+const (
+	RecordFieldPresenceValue    RecordFieldPresence = iota // The field has a value of its own
+	RecordFieldPresenceDefault                             // The field has a default value until given another one
+	RecordFieldPresenceRequired                            // The field has no value until given one
+)
+
 //=====================================================================================================================
 
 type StringType struct {
@@ -100,6 +250,20 @@ var TypeTypeInstance = &TypeType{}
 
 //=====================================================================================================================
 
+// UnionType is a type whose values are the values of any one of its member types, e.g. Int64 | String. Unions are
+// created by TypePool.PutUnion, which keeps their members flattened, deduplicated, and in canonical order.
+type UnionType struct {
+	MemberTypeIndexes []TypeIndex
+	BaseTypeIndex     TypeIndex // The type shared by the values of all the members, or else the union itself
+	name              string
+}
+
+func (t *UnionType) isType()                {}
+func (t *UnionType) Category() TypeCategory { return TypeCategoryUnion }
+func (t *UnionType) Name() string           { return t.name }
+
+//=====================================================================================================================
+
 type UnitType struct {
 }
 
@@ -0,0 +1,62 @@
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package unions
+
+import "lligne-cli/internal/lligne/runtime/types"
+
+//=====================================================================================================================
+
+// UnionValue is a value of a union whose members have different base types, tagged with the base type of the member
+// it belongs to.
+type UnionValue struct {
+	TypeIndex types.TypeIndex
+	Value     uint64
+}
+
+//=====================================================================================================================
+
+// UnionPool holds the tagged values of unions whose members have different base types, interned so that they can be
+// retrieved by index. A value of such a union is the index of its tagged value in the pool, which keeps every union
+// value within one 64-bit slot.
+type UnionPool struct {
+	values  []UnionValue
+	indexes map[UnionValue]uint64
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// NewUnionPool creates a new empty union value pool.
+func NewUnionPool() *UnionPool {
+	return &UnionPool{
+		values:  nil,
+		indexes: make(map[UnionValue]uint64),
+	}
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Get returns the tagged value at the given index.
+func (p *UnionPool) Get(index uint64) UnionValue {
+	return p.values[index]
+}
+
+//---------------------------------------------------------------------------------------------------------------------
+
+// Put looks for the tagged value already in the pool. It adds it if not there.
+// Returns the index of the new or existing entry.
+func (p *UnionPool) Put(value UnionValue) uint64 {
+	result, found := p.indexes[value]
+
+	if !found {
+		result = uint64(len(p.values))
+		p.values = append(p.values, value)
+		p.indexes[value] = result
+	}
+
+	return result
+}
+
+//=====================================================================================================================
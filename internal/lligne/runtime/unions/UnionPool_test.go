@@ -0,0 +1,35 @@
+//
+// # Tests of UnionPool.
+//
+// (C) Copyright 2023 Martin E. Nordberg III
+// Apache 2.0 License
+//
+
+package unions
+
+import (
+	"github.com/stretchr/testify/assert"
+	"lligne-cli/internal/lligne/runtime/types"
+	"testing"
+)
+
+//---------------------------------------------------------------------------------------------------------------------
+
+func TestUnionPool(t *testing.T) {
+
+	t.Run("pooled tagged values", func(t *testing.T) {
+		pool := NewUnionPool()
+
+		i0 := pool.Put(UnionValue{TypeIndex: types.BuiltInTypeIndexInt64, Value: 1})
+		i1 := pool.Put(UnionValue{TypeIndex: types.BuiltInTypeIndexBool, Value: 1})
+		i2 := pool.Put(UnionValue{TypeIndex: types.BuiltInTypeIndexInt64, Value: 1})
+
+		assert.Equal(t, uint64(0), i0)
+		assert.Equal(t, uint64(1), i1)
+		assert.Equal(t, i0, i2)
+		assert.Equal(t, UnionValue{TypeIndex: types.BuiltInTypeIndexBool, Value: 1}, pool.Get(i1))
+	})
+
+}
+
+//---------------------------------------------------------------------------------------------------------------------